@@ -3,10 +3,13 @@ package main
 import (
 	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/api/facade"
+	"github.com/M0hammadUsman/letschat/internal/api/hub"
 	"github.com/M0hammadUsman/letschat/internal/api/mailer"
+	"github.com/M0hammadUsman/letschat/internal/api/metrics"
 	"github.com/M0hammadUsman/letschat/internal/api/repository"
 	"github.com/M0hammadUsman/letschat/internal/api/server"
 	"github.com/M0hammadUsman/letschat/internal/api/service"
+	"github.com/M0hammadUsman/letschat/internal/api/storage"
 	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/common"
 	"log/slog"
@@ -14,38 +17,76 @@ import (
 )
 
 func main() {
-	utility.ConfigureSlog(os.Stderr)
-	cfg := utility.ParseFlags()
+	cfg, err := utility.LoadConfig()
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	utility.ConfigureSlog(os.Stderr, cfg.ENV)
 	// Base
 	db := repository.OpenDB(cfg)
 	bgTask := common.NewBackgroundTask()
-	mailr := mailer.New(cfg)
+	// Metrics, the transactions are timed by wrapping the DB
+	mtrcs := metrics.New(bgTask)
+	txMan := mtrcs.InstrumentTX(db)
+	mailBackend, err := mailer.New(cfg)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	store, err := storage.New(cfg.Attachments.Storage, cfg.Attachments.Dir)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
 	// Repositories
 	userRepo := repository.NewUserRepository(db)
 	tokenRepo := repository.NewTokenRepository(db)
 	messageRepo := repository.NewMessageRepository(db)
 	conversationRepo := repository.NewConversationRepository(db)
+	groupRepo := repository.NewGroupRepository(db)
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	mailRepo := repository.NewMailRepository(db)
+	// Mail Queue, the mails failed to be sent are retried in the background
+	mailr := mailer.NewQueue(mailBackend, mailRepo, cfg.Mailer.RetryFor)
+	bgTask.Run(mailr.Run)
 	// Services
-	userService := service.NewUserService(userRepo)
-	tokenService := service.NewTokenService(tokenRepo)
-	messageService := service.NewMessageService(messageRepo)
+	userService := service.NewUserService(userRepo, cfg.Auth.MaxFailedAttempts, cfg.Auth.FailedAttemptsWindow, cfg.Auth.Lockout)
+	tokenService := service.NewTokenService(tokenRepo, cfg.Auth.TokenSecret, service.TokenTTLs{
+		Access:       cfg.Auth.AccessTTL,
+		Refresh:      cfg.Auth.RefreshTTL,
+		OTP:          cfg.Auth.OTPTTL,
+		TwoFactor:    cfg.Auth.TwoFactorTTL,
+		SSHChallenge: cfg.Auth.SSHChallengeTTL,
+	})
+	messageService := service.NewMessageService(messageRepo, cfg.MsgHistory)
 	conversationService := service.NewConversationService(conversationRepo)
+	groupService := service.NewGroupService(groupRepo)
+	attachmentService := service.NewAttachmentService(attachmentRepo, store, cfg.Attachments.MaxSize)
 	// Service Group
-	srv := service.New(userService, tokenService, messageService, conversationService)
+	srv := service.New(userService, tokenService, messageService, conversationService, groupService, attachmentService)
 	// Facades
-	userFacade := facade.NewUserFacade(srv, db, mailr, bgTask)
-	tokenFacade := facade.NewTokenFacade(srv, db, mailr, bgTask)
-	messageFacade := facade.NewMessageFacade(srv, db, bgTask)
+	userFacade := facade.NewUserFacade(srv, txMan, mailr, bgTask)
+	tokenFacade := facade.NewTokenFacade(srv, txMan, mailr, bgTask)
+	messageFacade := facade.NewMessageFacade(srv, txMan, bgTask)
 	conversationFacade := facade.NewConversationFacade(srv)
+	groupFacade := facade.NewGroupFacade(srv, txMan)
+	attachmentFacade := facade.NewAttachmentFacade(srv)
 	// Facade Group
-	fac := facade.New(userFacade, tokenFacade, messageFacade, conversationFacade)
+	fac := facade.New(userFacade, tokenFacade, messageFacade, conversationFacade, groupFacade, attachmentFacade)
+	// Hub
+	h, err := hub.New(cfg.Hub, db)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
 	// Server
-	s := server.NewServer(cfg, bgTask, fac)
+	s := server.NewServer(cfg, bgTask, fac, h, mtrcs)
 	// printing banner
 	fmt.Println("    __         __            __          __ \n   / /   ___  / /___________/ /_  ____ _/ /_\n  / /   / _ \\/ __/ ___/ ___/ __ \\/ __ `/ __/\n / /___/  __/ /_(__  ) /__/ / / / /_/ / /_  \n/_____/\\___/\\__/____/\\___/_/ /_/\\__,_/\\__/  \n                                            ")
 	// Starting Server and setting up cleanup processes
 	s.ShutdownCleanup() // will run once the server shutdown initiates
-	if err := s.Serve(); err != nil {
+	if err = s.Serve(); err != nil {
 		slog.Error(err.Error())
 	}
 }
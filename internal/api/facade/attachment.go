@@ -0,0 +1,28 @@
+package facade
+
+import (
+	"context"
+	"github.com/M0hammadUsman/letschat/internal/api/service"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+)
+
+type AttachmentFacade struct {
+	service *service.Service
+}
+
+func NewAttachmentFacade(srv *service.Service) *AttachmentFacade {
+	return &AttachmentFacade{service: srv}
+}
+
+func (f *AttachmentFacade) UploadAttachment(
+	ctx context.Context,
+	name, contentType string,
+	r io.Reader,
+) (*domain.Attachment, error) {
+	return f.service.UploadAttachment(ctx, name, contentType, r)
+}
+
+func (f *AttachmentFacade) OpenAttachment(ctx context.Context, id string) (*domain.Attachment, io.ReadCloser, error) {
+	return f.service.OpenAttachment(ctx, id)
+}
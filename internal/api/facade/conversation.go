@@ -2,6 +2,7 @@ package facade
 
 import (
 	"context"
+	"errors"
 	"github.com/M0hammadUsman/letschat/internal/api/service"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 )
@@ -14,6 +15,38 @@ func NewConversationFacade(srv *service.Service) *ConversationFacade {
 	return &ConversationFacade{srv}
 }
 
+// GetConversations returns the direct & the group conversations of the user, groups along with their members
 func (f *ConversationFacade) GetConversations(ctx context.Context) ([]*domain.Conversation, error) {
-	return f.service.GetConversations(ctx)
+	convos, err := f.service.GetConversations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, convo := range convos {
+		if !convo.IsGroup {
+			continue
+		}
+		if convo.Members, err = f.service.GetGroupMembers(ctx, convo.UserID); err != nil {
+			return nil, err
+		}
+	}
+	return convos, nil
+}
+
+// AcceptConversationRequest accepts the msg request from the user, or the invite to the group with the ID,
+// as both are listed as the conversation requests
+func (f *ConversationFacade) AcceptConversationRequest(ctx context.Context, senderID string) error {
+	err := f.service.AcceptConversationRequest(ctx, senderID)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return f.service.AcceptGroupInvite(ctx, senderID)
+	}
+	return err
+}
+
+// DeclineConversationRequest declines the msg request from the user, or the invite to the group with the ID
+func (f *ConversationFacade) DeclineConversationRequest(ctx context.Context, senderID string) error {
+	err := f.service.DeclineConversationRequest(ctx, senderID)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return f.service.DeclineGroupInvite(ctx, senderID)
+	}
+	return err
 }
@@ -1,23 +1,44 @@
 package facade
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 type Facade struct {
 	*UserFacade
 	*TokenFacade
 	*MessageFacade
 	*ConversationFacade
+	*GroupFacade
+	*AttachmentFacade
 }
 
-func New(uf *UserFacade, tf *TokenFacade, mf *MessageFacade, cf *ConversationFacade) *Facade {
+func New(uf *UserFacade,
+	tf *TokenFacade,
+	mf *MessageFacade,
+	cf *ConversationFacade,
+	gf *GroupFacade,
+	af *AttachmentFacade) *Facade {
 	return &Facade{
 		UserFacade:         uf,
 		TokenFacade:        tf,
 		MessageFacade:      mf,
 		ConversationFacade: cf,
+		GroupFacade:        gf,
+		AttachmentFacade:   af,
 	}
 }
 
 type TXManager interface {
 	RunInTX(ctx context.Context, fn func(ctx context.Context) error) error
 }
+
+// mailTTL formats the TTL of the mailed OTPs for the mails, in whole minutes
+func mailTTL(ttl time.Duration) string {
+	if m := int(ttl.Minutes()); m != 1 {
+		return fmt.Sprintf("%v minutes", m)
+	}
+	return "1 minute"
+}
@@ -0,0 +1,82 @@
+package facade
+
+import (
+	"context"
+	"github.com/M0hammadUsman/letschat/internal/api/service"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+)
+
+type GroupFacade struct {
+	service   *service.Service
+	txManager TXManager
+}
+
+func NewGroupFacade(srv *service.Service, txMan TXManager) *GroupFacade {
+	return &GroupFacade{
+		service:   srv,
+		txManager: txMan,
+	}
+}
+
+// CreateGroup creates the group, none of the members may have blocked the user in the context
+func (f *GroupFacade) CreateGroup(ctx context.Context, g *domain.GroupCreate) (*domain.Group, error) {
+	var group *domain.Group
+	err := f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		var err error
+		if group, err = f.service.CreateGroup(ctx, g); err != nil {
+			return err
+		}
+		return f.rejectBlockers(ctx, "members", "must not contain the users who have blocked you", g.Members...)
+	})
+	return group, err
+}
+
+// AddGroupMember adds the user to the group, unless the user has blocked the one in the context
+func (f *GroupFacade) AddGroupMember(ctx context.Context, groupID string, m *domain.GroupMemberAdd) error {
+	return f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		if err := f.service.AddGroupMember(ctx, groupID, m); err != nil {
+			return err
+		}
+		return f.rejectBlockers(ctx, "userID", "has blocked you", m.UserID)
+	})
+}
+
+func (f *GroupFacade) RemoveGroupMember(ctx context.Context, groupID, userID string) error {
+	return f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		return f.service.RemoveGroupMember(ctx, groupID, userID)
+	})
+}
+
+func (f *GroupFacade) LeaveGroup(ctx context.Context, groupID string) error {
+	return f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		return f.service.LeaveGroup(ctx, groupID)
+	})
+}
+
+func (f *GroupFacade) GetGroupMembers(ctx context.Context, groupID string) ([]*domain.GroupMember, error) {
+	return f.service.GetGroupMembers(ctx, groupID)
+}
+
+// Helpers & Stuff ----------------------------------------------------------------------------------------------------
+
+// rejectBlockers returns the validation error for the key if any of the users has blocked the user in the context,
+// the users are checked once validated, so it's run after the service call, rolling back the TX
+func (f *GroupFacade) rejectBlockers(ctx context.Context, key, msg string, userIDs ...string) error {
+	usr := utility.ContextGetUser(ctx)
+	for _, userID := range userIDs {
+		if userID == usr.ID {
+			continue
+		}
+		blocked, err := f.service.IsUserBlocked(ctx, usr.ID, userID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			ev := domain.NewErrValidation()
+			ev.AddError(key, msg)
+			return ev
+		}
+	}
+	return nil
+}
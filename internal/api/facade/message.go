@@ -2,10 +2,12 @@ package facade
 
 import (
 	"context"
+	"errors"
 	"github.com/M0hammadUsman/letschat/internal/api/service"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/common"
 	"github.com/M0hammadUsman/letschat/internal/domain"
-	"log/slog"
+	"slices"
 )
 
 type MessageFacade struct {
@@ -24,15 +26,70 @@ func NewMessageFacade(service *service.Service,
 	}
 }
 
+// ProcessSentMessage validates & persists the sent msg, returns the msgs to be relayed,
+// a single one for direct msgs & a copy per member for the msgs fanned out to a group
 func (f *MessageFacade) ProcessSentMessage(ctx context.Context,
 	m domain.MessageSent,
 	u *domain.User,
-) (*domain.Message, bool, error) {
+) ([]*domain.Message, bool, error) {
 	if ev := m.ValidateMessageSent(); ev != nil && ev.HasErrors() {
 		return nil, false, ev
 	}
 	msg := f.service.PopulateMessage(m, u)
+	if m.ConversationID == nil && blockable(msg.Operation) {
+		blocked, err := f.service.IsUserBlocked(ctx, msg.SenderID, m.ReceiverID)
+		if err != nil {
+			return nil, false, err
+		}
+		if blocked && msg.Operation == domain.TypingMsg { // nothing to tell the sender, just dropped
+			return nil, false, nil
+		}
+		if blocked {
+			ev := domain.NewErrValidation()
+			ev.AddError("receiverID", "has blocked you")
+			return nil, false, ev
+		}
+	}
+	switch msg.Operation {
+	case domain.CreateMsg:
+		// right away, not along with the msg in the background, so an edit sent straight after finds the sender
+		if err := f.service.RecordMessageSender(ctx, msg); err != nil {
+			return nil, false, err
+		}
+	case domain.EditMsg:
+		if err := f.service.ValidateMessageEdit(ctx, msg); err != nil {
+			return nil, false, err
+		}
+	}
+	if m.ConversationID != nil {
+		msgs, err := f.fanOutToGroup(ctx, msg, m.IsFannedOut())
+		if err != nil {
+			return nil, false, err
+		}
+		if err = f.shareAttachment(ctx, msgs...); err != nil {
+			return nil, false, err
+		}
+		f.processMessage(ctx, msgs...)
+		return msgs, false, nil
+	}
 	convoCreated := false
+	// the msg request sent by the receiver, is yet to be accepted (or declined) by the sender
+	requested, err := f.service.IsConversationRequest(ctx, m.ReceiverID, msg.SenderID)
+	if err != nil {
+		return nil, false, err
+	}
+	if requested {
+		switch msg.Operation {
+		case domain.ReadMsg, domain.TypingMsg: // the sender of the request isn't told of anything, till accepted
+			return nil, false, nil
+		case domain.CreateMsg: // replying accepts the request
+			if err = f.service.AcceptConversationRequest(ctx, m.ReceiverID); err != nil {
+				return nil, false, err
+			}
+			// conversations are synced, same as for the newly created one
+			convoCreated = true
+		}
+	}
 	if msg.Operation == domain.CreateMsg {
 		convoExists, err := f.service.ConversationExists(ctx, msg.SenderID, m.ReceiverID)
 		if err != nil {
@@ -45,22 +102,88 @@ func (f *MessageFacade) ProcessSentMessage(ctx context.Context,
 			}
 		}
 	}
+	if err := f.shareAttachment(ctx, msg); err != nil {
+		return nil, convoCreated, err
+	}
 	f.processMessage(ctx, msg)
-	return msg, convoCreated, nil
+	return []*domain.Message{msg}, convoCreated, nil
 }
 
 func (f *MessageFacade) WriteUnDeliveredMessagesToWSConn(ctx context.Context, c domain.MsgChan) error {
 	return f.service.GetUnDeliveredMessages(ctx, c)
 }
 
+func (f *MessageFacade) GetMessageHistory(
+	ctx context.Context,
+	withUsrID string,
+	cursor *domain.MessageCursor,
+	pageSize int,
+) ([]*domain.Message, *domain.MessageCursor, error) {
+	return f.service.GetMessageHistory(ctx, withUsrID, cursor, pageSize)
+}
+
 // Helpers & Stuff ----------------------------------------------------------------------------------------------------
 
-func (f *MessageFacade) processMessage(ctx context.Context, msg *domain.Message) {
+// fanOutToGroup ensures the sender is a member of the group, the msgs that are not to be fanned out (acks)
+// are exchanged with a single member, so the receiver must also be a member
+func (f *MessageFacade) fanOutToGroup(ctx context.Context, msg *domain.Message, fanOut bool) ([]*domain.Message, error) {
+	members, err := f.service.GetGroupMembers(ctx, *msg.ConversationID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotGroupMember) {
+			ev := domain.NewErrValidation()
+			ev.AddError("conversationID", "must be a group you're a member of")
+			return nil, ev
+		}
+		return nil, err
+	}
+	memberIDs := make([]string, len(members))
+	for i, member := range members {
+		memberIDs[i] = member.UserID
+	}
+	if !fanOut {
+		if !slices.Contains(memberIDs, msg.ReceiverID) {
+			ev := domain.NewErrValidation()
+			ev.AddError("receiverID", "must be a member of the group")
+			return nil, ev
+		}
+		return []*domain.Message{msg}, nil
+	}
+	return f.service.FanOutMessage(msg, memberIDs), nil
+}
+
+// blockable tells whether the msgs with the op are rejected once the sender is blocked by the receiver,
+// the acks still go through, so the receiver's own msgs are not left undelivered
+func blockable(op domain.MsgOperation) bool {
+	return op == domain.CreateMsg ||
+		op == domain.EditMsg ||
+		op == domain.ReactMsg ||
+		op == domain.UnreactMsg ||
+		op == domain.TypingMsg
+}
+
+// shareAttachment lets the receivers of the new msgs download the attachment sent along, if any
+func (f *MessageFacade) shareAttachment(ctx context.Context, msgs ...*domain.Message) error {
+	if len(msgs) == 0 || msgs[0].Operation != domain.CreateMsg || msgs[0].AttachmentID == nil {
+		return nil
+	}
+	rcvrIDs := make([]string, len(msgs))
+	for i, msg := range msgs {
+		rcvrIDs[i] = msg.ReceiverID
+	}
+	return f.service.ShareAttachment(ctx, *msgs[0].AttachmentID, msgs[0].SenderID, rcvrIDs...)
+}
+
+func (f *MessageFacade) processMessage(ctx context.Context, msgs ...*domain.Message) {
 	f.bgTask.Run(func(context.Context) {
 		if err := f.txManager.RunInTX(ctx, func(ctx context.Context) error {
-			return f.service.ProcessSentMessages(ctx, msg)
+			for _, msg := range msgs {
+				if err := f.service.ProcessSentMessages(ctx, msg); err != nil {
+					return err
+				}
+			}
+			return nil
 		}); err != nil {
-			slog.Error(err.Error())
+			utility.ContextGetLogger(ctx).Error(err.Error())
 		}
 
 	})
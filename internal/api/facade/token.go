@@ -5,21 +5,21 @@ import (
 	"errors"
 	"github.com/M0hammadUsman/letschat/internal/api/mailer"
 	"github.com/M0hammadUsman/letschat/internal/api/service"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/common"
 	"github.com/M0hammadUsman/letschat/internal/domain"
-	"log/slog"
 )
 
 type TokenFacade struct {
 	service   *service.Service
 	txManager TXManager
-	mailer    *mailer.Mailer
+	mailer    mailer.Mailer
 	bgTask    *common.BackgroundTask
 }
 
 func NewTokenFacade(service *service.Service,
 	txMan TXManager,
-	mailer *mailer.Mailer,
+	mailer mailer.Mailer,
 	bgTask *common.BackgroundTask) *TokenFacade {
 	return &TokenFacade{
 		service:   service,
@@ -50,40 +50,169 @@ func (t *TokenFacade) GenerateOTP(ctx context.Context, email string) error {
 		data := map[string]string{
 			"name":  usr.Name,
 			"token": otp,
+			"ttl":   mailTTL(t.service.OTPTTL()),
 		}
 		if err = t.mailer.Send(email, "email.tmpl.html", data); err != nil {
-			slog.Error(err.Error())
+			utility.ContextGetLogger(ctx).Error(err.Error())
 		}
 	})
 	return nil
 }
 
-func (t *TokenFacade) GenerateAuthToken(ctx context.Context, u *domain.UserAuth) (string, error) {
-	usrID, err := t.service.AuthenticateUser(ctx, u)
+// GeneratePasswordResetOTP mails the OTP to reset the password with, the previous ones are no longer valid
+func (t *TokenFacade) GeneratePasswordResetOTP(ctx context.Context, email string) error {
+	usr, err := t.service.GetByUniqueField(ctx, email)
+	ev := domain.NewErrValidation()
 	if err != nil {
-		return "", err
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("email", "not registered")
+			return ev
+		}
+		return err
+	}
+	if !usr.Activated {
+		ev.AddError("email", "not activated")
+		return ev
 	}
 	var otp string
 	if err = t.txManager.RunInTX(ctx, func(ctx context.Context) error {
-		// idempotent if domain.ScopeAuthentication tokens do not exist for the user
-		if err = t.service.DeleteAllForUser(ctx, usrID, domain.ScopeAuthentication); err != nil {
+		if err = t.service.DeleteAllForUser(ctx, usr.ID, domain.ScopePasswordReset); err != nil {
 			return err
 		}
-		otp, err = t.service.GenerateToken(ctx, usrID, domain.ScopeAuthentication)
-		if err != nil {
+		otp, err = t.service.GenerateToken(ctx, usr.ID, domain.ScopePasswordReset)
+		return err
+	}); err != nil {
+		return err
+	}
+	t.bgTask.Run(func(context.Context) {
+		data := map[string]string{
+			"name":  usr.Name,
+			"token": otp,
+			"ttl":   mailTTL(t.service.OTPTTL()),
+		}
+		if err := t.mailer.Send(email, "password_reset.tmpl.html", data); err != nil {
+			utility.ContextGetLogger(ctx).Error(err.Error())
+		}
+	})
+	return nil
+}
+
+// GenerateAuthToken starts a new session for the device, the other sessions of the user are left logged in;
+// if the user has the second factor enabled *domain.ErrTwoFactorRequired is returned instead, the login is then
+// completed with CompleteTwoFactorAuth
+func (t *TokenFacade) GenerateAuthToken(ctx context.Context, u *domain.UserAuth) (*domain.AuthTokens, error) {
+	usrID, err := t.service.AuthenticateUser(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return t.startSession(ctx, usrID, u.Device, u.IP)
+}
+
+// GenerateSSHChallenge returns the challenge to sign with the SSH key of the fingerprint, to log in with
+func (t *TokenFacade) GenerateSSHChallenge(ctx context.Context, r *domain.SSHChallengeRequest) (string, error) {
+	usrID, err := t.service.GetSSHLoginUserID(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	return t.service.GenerateToken(ctx, usrID, domain.ScopeSSHChallenge)
+}
+
+// GenerateSSHAuthToken logs in with the signed challenge, the same as GenerateAuthToken does with the password
+func (t *TokenFacade) GenerateSSHAuthToken(ctx context.Context, a *domain.SSHAuth) (*domain.AuthTokens, error) {
+	// consumed before verifying, a challenge is good for a single attempt
+	challenge, err := t.service.ConsumeSSHChallenge(ctx, a.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.service.AuthenticateSSH(ctx, challenge.UserID, a); err != nil {
+		return nil, err
+	}
+	return t.startSession(ctx, challenge.UserID, a.Device, a.IP)
+}
+
+// CompleteTwoFactorAuth starts the session the password was checked for, once the TOTP or a recovery code matches
+func (t *TokenFacade) CompleteTwoFactorAuth(
+	ctx context.Context,
+	tfa *domain.TwoFactorAuth,
+) (*domain.AuthTokens, error) {
+	challenge, err := t.service.GetTwoFactorToken(ctx, tfa.Token)
+	if err != nil {
+		return nil, err
+	}
+	// outside the TX, so the failed attempts are counted
+	if err = t.service.VerifySecondFactor(ctx, challenge.UserID, tfa.Code); err != nil {
+		return nil, err
+	}
+	var session *domain.Token
+	if err = t.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		if err = t.service.DeleteToken(ctx, challenge.ID); err != nil {
+			if errors.Is(err, domain.ErrRecordNotFound) { // completed concurrently
+				ev := domain.NewErrValidation()
+				ev.AddError("twoFactorToken", "invalid or expired")
+				return ev
+			}
 			return err
 		}
-		return nil
+		session, err = t.service.GenerateSessionToken(ctx, challenge.UserID, challenge.Device, challenge.IP)
+		return err
 	}); err != nil {
-		return "", err
+		return nil, err
+	}
+	return t.authTokens(session)
+}
+
+// RefreshAuthToken rotates the refresh token & issues a new access token for its session
+func (t *TokenFacade) RefreshAuthToken(ctx context.Context, refreshToken string) (*domain.AuthTokens, error) {
+	session, err := t.service.RotateRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return t.authTokens(session)
+}
+
+// VerifyAuthToken verifies the access token alone, the access token of a revoked session works till it expires,
+// its websocket connection is closed right away though
+func (t *TokenFacade) VerifyAuthToken(_ context.Context, token string) (*domain.User, error) {
+	return t.service.VerifyAccessToken(token)
+}
+
+func (t *TokenFacade) GetSessions(ctx context.Context) ([]*domain.Session, error) {
+	return t.service.GetSessions(ctx)
+}
+
+func (t *TokenFacade) RevokeSession(ctx context.Context, id string) error {
+	return t.service.DeleteSession(ctx, id)
+}
+
+// startSession starts the session of the authenticated user, or returns *domain.ErrTwoFactorRequired if the user
+// has the second factor enabled
+func (t *TokenFacade) startSession(ctx context.Context, usrID, device, ip string) (*domain.AuthTokens, error) {
+	enabled, err := t.service.IsTOTPEnabled(ctx, usrID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		token, err := t.service.GenerateTwoFactorToken(ctx, usrID, device, ip)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &domain.ErrTwoFactorRequired{Token: token}
+	}
+	session, err := t.service.GenerateSessionToken(ctx, usrID, device, ip)
+	if err != nil {
+		return nil, err
 	}
-	return otp, nil
+	return t.authTokens(session)
 }
 
-func (t *TokenFacade) VerifyAuthToken(ctx context.Context, token string) (*domain.User, error) {
-	usr, err := t.service.GetForToken(ctx, domain.ScopeAuthentication, token)
+func (t *TokenFacade) authTokens(session *domain.Token) (*domain.AuthTokens, error) {
+	access, ttl, err := t.service.GenerateAccessToken(session.UserID, session.ID)
 	if err != nil {
 		return nil, err
 	}
-	return usr, nil
+	return &domain.AuthTokens{
+		AccessToken:  access,
+		ExpiresIn:    int(ttl.Seconds()),
+		RefreshToken: session.PlainText,
+	}, nil
 }
@@ -4,22 +4,25 @@ import (
 	"context"
 	"github.com/M0hammadUsman/letschat/internal/api/mailer"
 	"github.com/M0hammadUsman/letschat/internal/api/service"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/common"
 	"github.com/M0hammadUsman/letschat/internal/domain"
-	"log/slog"
 	"time"
 )
 
+// digestBatchSize is the count of the digests fetched at once
+const digestBatchSize = 100
+
 type UserFacade struct {
 	service   *service.Service
 	txManager TXManager
-	mailer    *mailer.Mailer
+	mailer    mailer.Mailer
 	bgTask    *common.BackgroundTask
 }
 
 func NewUserFacade(service *service.Service,
 	txMan TXManager,
-	mailer *mailer.Mailer,
+	mailer mailer.Mailer,
 	bgTask *common.BackgroundTask) *UserFacade {
 	return &UserFacade{
 		service:   service,
@@ -47,9 +50,10 @@ func (f *UserFacade) RegisterUser(ctx context.Context, u *domain.UserRegister) e
 		data := map[string]any{
 			"name":  u.Name,
 			"token": otp,
+			"ttl":   mailTTL(f.service.OTPTTL()),
 		}
 		if err := f.mailer.Send(u.Email, "email.tmpl.html", data); err != nil {
-			slog.Error(err.Error())
+			utility.ContextGetLogger(ctx).Error(err.Error())
 		}
 	})
 	return nil
@@ -59,25 +63,260 @@ func (f *UserFacade) GetByUniqueField(ctx context.Context, fieldValue string) (*
 	return f.service.GetByUniqueField(ctx, fieldValue)
 }
 
-func (f *UserFacade) UpdateUser(ctx context.Context, u *domain.UserUpdate) error {
-	return f.service.UpdateUser(ctx, u)
+// UpdateUser updates the user in the context, a change of the email is left pending till it's confirmed with the OTP
+// mailed to the new email, returns whether a change is pending; the old email is told of it, so a hijacked session
+// can't take the account over unnoticed
+func (f *UserFacade) UpdateUser(ctx context.Context, u *domain.UserUpdate) (bool, error) {
+	u.ID = utility.ContextGetUser(ctx).ID // whatever the ID in the body, only the user in the context is updated
+	var usr *domain.User
+	var otp string
+	if err := f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		if err := f.service.UpdateUser(ctx, u); err != nil {
+			return err
+		}
+		var err error
+		usr, err = f.service.RequestEmailChange(ctx, u.Email)
+		if err != nil || usr == nil {
+			return err
+		}
+		if err = f.service.DeleteAllForUser(ctx, usr.ID, domain.ScopeEmailChange); err != nil {
+			return err
+		}
+		otp, err = f.service.GenerateToken(ctx, usr.ID, domain.ScopeEmailChange)
+		return err
+	}); err != nil {
+		return false, err
+	}
+	if usr == nil {
+		return false, nil
+	}
+	f.bgTask.Run(func(context.Context) {
+		data := map[string]string{
+			"name":  u.Name,
+			"token": otp,
+			"ttl":   mailTTL(f.service.OTPTTL()),
+		}
+		if err := f.mailer.Send(u.Email, "email_change.tmpl.html", data); err != nil {
+			utility.ContextGetLogger(ctx).Error(err.Error())
+		}
+		data = map[string]string{
+			"name":  u.Name,
+			"email": u.Email,
+		}
+		if err := f.mailer.Send(usr.Email, "email_change_notice.tmpl.html", data); err != nil {
+			utility.ContextGetLogger(ctx).Error(err.Error())
+		}
+	})
+	return true, nil
+}
+
+// ConfirmEmailChange applies the pending email change of the user in the context once the OTP is verified
+func (f *UserFacade) ConfirmEmailChange(ctx context.Context, plainOTP string) error {
+	// verified outside the TX, the failed attempts must be counted
+	email, err := f.service.VerifyEmailChange(ctx, plainOTP)
+	if err != nil {
+		return err
+	}
+	return f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		if err := f.service.ApplyEmailChange(ctx, email); err != nil {
+			return err
+		}
+		return f.service.DeleteAllForUser(ctx, utility.ContextGetUser(ctx).ID, domain.ScopeEmailChange)
+	})
+}
+
+func (f *UserFacade) CancelEmailChange(ctx context.Context) error {
+	return f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		if err := f.service.CancelEmailChange(ctx); err != nil {
+			return err
+		}
+		return f.service.DeleteAllForUser(ctx, utility.ContextGetUser(ctx).ID, domain.ScopeEmailChange)
+	})
 }
 
 func (f *UserFacade) UpdateUserOnlineStatus(ctx context.Context, u *domain.User, online bool) error {
 	return f.service.UpdateUserOnlineStatus(ctx, u, online)
 }
 
-func (f *UserFacade) ActivateUser(ctx context.Context, plainToken string) error {
+func (f *UserFacade) ActivateUser(ctx context.Context, email, plainOTP string) error {
+	// verified outside the TX, the failed attempts must be counted even though the activation fails
+	usr, err := f.service.VerifyOTP(ctx, domain.ScopeActivation, email, plainOTP)
+	if err != nil {
+		return err
+	}
 	return f.txManager.RunInTX(ctx, func(ctx context.Context) error {
-		usr, err := f.service.GetForToken(ctx, domain.ScopeActivation, plainToken)
-		if err != nil {
+		if err := f.service.ActivateUser(ctx, usr); err != nil {
+			return err
+		}
+		return f.service.DeleteAllForUser(ctx, usr.ID, domain.ScopeActivation)
+	})
+}
+
+// ResetPassword sets the new password once the OTP is verified, every device logged in has to log in again
+// ResetPassword resets the password & logs every session out, returns the revoked sessions
+func (f *UserFacade) ResetPassword(ctx context.Context, pr *domain.UserPasswordReset) ([]*domain.Token, error) {
+	// verified outside the TX, same as for the activation
+	usr, err := f.service.VerifyOTP(ctx, domain.ScopePasswordReset, pr.Email, pr.OTP)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*domain.Token
+	err = f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		if err := f.service.ResetPassword(ctx, usr, pr.Password); err != nil {
 			return err
 		}
-		if err = f.service.ActivateUser(ctx, usr); err != nil {
+		if err := f.service.DeleteAllForUser(ctx, usr.ID, domain.ScopePasswordReset); err != nil {
 			return err
 		}
-		return f.service.DeleteAllForUser(ctx, usr.ID, domain.ScopeActivation)
+		var err error
+		sessions, err = f.service.DeleteAllSessions(ctx, usr.ID)
+		return err
 	})
+	return sessions, err
+}
+
+// DeleteUser deletes the user in the context along with its pending msgs, conversations & uploaded attachments,
+// returns the sessions of the user, all revoked
+func (f *UserFacade) DeleteUser(ctx context.Context, password string) ([]*domain.Token, error) {
+	// confirmed outside the TX, the failed attempts must be counted
+	if err := f.service.ConfirmPassword(ctx, password); err != nil {
+		return nil, err
+	}
+	usr := utility.ContextGetUser(ctx)
+	attachmentIDs, err := f.service.GetUploadedAttachmentIDs(ctx, usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*domain.Token
+	if err = f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		if err := f.service.DeleteAllMessagesForUser(ctx, usr.ID); err != nil {
+			return err
+		}
+		if err := f.service.DeleteAllConversationsForUser(ctx, usr.ID); err != nil {
+			return err
+		}
+		// deleted upfront, rather than cascaded, as their websocket connections are to be closed
+		var err error
+		if sessions, err = f.service.DeleteAllSessions(ctx, usr.ID); err != nil {
+			return err
+		}
+		// the other tokens, keys, group memberships & attachments cascade from the user
+		return f.service.DeleteUser(ctx)
+	}); err != nil {
+		return nil, err
+	}
+	// the content is only deleted once the rows are gone for good, a failure just leaves some orphan files behind
+	if err = f.service.DeleteAttachmentContents(ctx, attachmentIDs...); err != nil {
+		utility.ContextGetLogger(ctx).Error(err.Error())
+	}
+	return sessions, nil
+}
+
+// ExportUser returns all the server holds about the user in the context
+func (f *UserFacade) ExportUser(ctx context.Context) (*domain.UserExport, error) {
+	usr, err := f.service.GetByUniqueField(ctx, utility.ContextGetUser(ctx).ID)
+	if err != nil {
+		return nil, err
+	}
+	convos, err := f.service.GetConversations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := f.service.GetPendingMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.UserExport{
+		Profile:             usr,
+		Conversations:       convos,
+		UndeliveredMessages: msgs,
+		ExportedAt:          time.Now(),
+	}, nil
+}
+
+func (f *UserFacade) IsTOTPEnabled(ctx context.Context) (bool, error) {
+	return f.service.IsTOTPEnabled(ctx, utility.ContextGetUser(ctx).ID)
+}
+
+func (f *UserFacade) EnrollTOTP(ctx context.Context) (*domain.TOTPEnrollment, error) {
+	return f.service.EnrollTOTP(ctx)
+}
+
+// ConfirmTOTP enables the second factor, returns the recovery codes
+func (f *UserFacade) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	var codes []string
+	err := f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		var err error
+		codes, err = f.service.ConfirmTOTP(ctx, code)
+		return err
+	})
+	return codes, err
+}
+
+// DisableTOTP turns the second factor off for the user in the context, once the password is confirmed
+func (f *UserFacade) DisableTOTP(ctx context.Context, password string) error {
+	// confirmed outside the TX, the failed attempts must be counted
+	if err := f.service.ConfirmPassword(ctx, password); err != nil {
+		return err
+	}
+	return f.txManager.RunInTX(ctx, func(ctx context.Context) error {
+		return f.service.DisableTOTP(ctx)
+	})
+}
+
+func (f *UserFacade) AddSSHKey(ctx context.Context, k *domain.SSHKeyAdd) (*domain.SSHKey, error) {
+	return f.service.AddSSHKey(ctx, k)
+}
+
+func (f *UserFacade) GetSSHKeys(ctx context.Context) ([]*domain.SSHKey, error) {
+	return f.service.GetSSHKeys(ctx)
+}
+
+func (f *UserFacade) DeleteSSHKey(ctx context.Context, id string) error {
+	return f.service.DeleteSSHKey(ctx, id)
+}
+
+func (f *UserFacade) IsDigestEnabled(ctx context.Context) (bool, error) {
+	return f.service.IsDigestEnabled(ctx)
+}
+
+func (f *UserFacade) SetDigestEnabled(ctx context.Context, enabled bool) error {
+	return f.service.SetDigestEnabled(ctx, enabled)
+}
+
+// SendDigests mails the digests due to the offline users, the ones with a msg yet to be delivered for longer than
+// the olderThan, a digest per user for the msgs sent since their last one, returns the count of the digests mailed
+func (f *UserFacade) SendDigests(ctx context.Context, olderThan time.Duration) (int, error) {
+	var sent int
+	for {
+		digests, err := f.service.GetDueDigests(ctx, olderThan, digestBatchSize)
+		if err != nil {
+			return sent, err
+		}
+		for _, d := range digests {
+			// claimed before it's mailed, the other nodes may be sending the same digest
+			claimed, err := f.service.ClaimDigest(ctx, d.UserID, d.LastSentAt)
+			if err != nil {
+				return sent, err
+			}
+			if !claimed {
+				continue
+			}
+			data := map[string]any{
+				"name":    d.Name,
+				"total":   d.Total(),
+				"senders": d.Senders,
+			}
+			// the mailer queues the ones failed to be sent, it only fails if the mail can't be rendered or queued
+			if err = f.mailer.Send(d.Email, "digest.tmpl.html", data); err != nil {
+				utility.ContextGetLogger(ctx).Error(err.Error(), "userID", d.UserID)
+			}
+			sent++
+		}
+		if len(digests) < digestBatchSize {
+			return sent, nil
+		}
+	}
 }
 
 func (f *UserFacade) SearchUser(
@@ -91,3 +330,32 @@ func (f *UserFacade) SearchUser(
 func (f *UserFacade) SetOnlineUsersLastSeen(ctx context.Context) error {
 	return f.service.SetOnlineUsersLastSeen(ctx, time.Now())
 }
+
+func (f *UserFacade) SetUserKey(ctx context.Context, k *domain.UserKeySet) (*domain.UserKey, error) {
+	return f.service.SetUserKey(ctx, k.PublicKey)
+}
+
+func (f *UserFacade) GetUserKey(ctx context.Context, userID string) (*domain.UserKey, error) {
+	return f.service.GetUserKey(ctx, userID)
+}
+
+func (f *UserFacade) BlockUser(ctx context.Context, userID string) error {
+	return f.service.BlockUser(ctx, userID)
+}
+
+func (f *UserFacade) UnblockUser(ctx context.Context, userID string) error {
+	return f.service.UnblockUser(ctx, userID)
+}
+
+func (f *UserFacade) MuteUser(ctx context.Context, userID string) error {
+	return f.service.MuteUser(ctx, userID)
+}
+
+func (f *UserFacade) UnmuteUser(ctx context.Context, userID string) error {
+	return f.service.UnmuteUser(ctx, userID)
+}
+
+// GetBlockerIDs returns the IDs of the users who have blocked the user, they're not to be told of the user's presence
+func (f *UserFacade) GetBlockerIDs(ctx context.Context, userID string) ([]string, error) {
+	return f.service.GetBlockerIDs(ctx, userID)
+}
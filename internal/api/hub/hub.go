@@ -0,0 +1,110 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/api/repository"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"sync"
+)
+
+// Hub routes the msgs to the websocket connections of the users, the connections may live on this node or,
+// depending on the implementation, on some other node (instance) of the API
+type Hub interface {
+	// Subscribe registers the connection of the user, returns true if it's the first connection of the user
+	// across all the nodes
+	Subscribe(ctx context.Context, u *domain.User) (bool, error)
+	// Unsubscribe removes the connection of the user, returns true if the user has no connection left on any node
+	Unsubscribe(ctx context.Context, u *domain.User) (bool, error)
+	// Publish fans out the msg to every connection of the user, except the given one (may be nil)
+	Publish(ctx context.Context, userID string, msg *domain.Message, except *domain.User) error
+	// Run does the housekeeping of the hub, blocks until the shtdwnCtx is done
+	Run(shtdwnCtx context.Context)
+	// Close removes this node from the hub, returns true if there is no other node left,
+	// meaning every user still marked online is now offline
+	Close(ctx context.Context) (bool, error)
+}
+
+// New returns the Hub for the kind set in the config, (memory|postgres)
+func New(kind string, db *repository.DB) (Hub, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryHub(), nil
+	case "postgres":
+		return NewPostgresHub(db)
+	default:
+		return nil, fmt.Errorf("unknown hub %q, must be one of (memory|postgres)", kind)
+	}
+}
+
+// local holds the connections living on this node, keys are userID,
+// a user may be subscribed from several devices, each connection with its own domain.User.Messages
+type local struct {
+	mu    sync.RWMutex
+	conns map[string]map[*domain.User]struct{}
+}
+
+func newLocal() *local {
+	return &local{conns: make(map[string]map[*domain.User]struct{})}
+}
+
+// add returns true if it's the first connection of the user on this node
+func (l *local) add(u *domain.User) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	conns, ok := l.conns[u.ID]
+	if !ok {
+		conns = make(map[*domain.User]struct{})
+		l.conns[u.ID] = conns
+	}
+	conns[u] = struct{}{}
+	return len(conns) == 1
+}
+
+// remove returns true if it was the last connection of the user on this node
+func (l *local) remove(u *domain.User) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	conns, ok := l.conns[u.ID]
+	if !ok {
+		return true
+	}
+	delete(conns, u)
+	if len(conns) == 0 {
+		delete(l.conns, u.ID)
+		return true
+	}
+	return false
+}
+
+// counts returns the connections of each user on this node
+func (l *local) counts() map[string]int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	counts := make(map[string]int, len(l.conns))
+	for userID, conns := range l.conns {
+		counts[userID] = len(conns)
+	}
+	return counts
+}
+
+// deliver writes the msg to every local connection of the user, connections that cannot keep up are closed
+func (l *local) deliver(userID string, msg *domain.Message, except *domain.User) {
+	var slow []*domain.User
+	l.mu.RLock()
+	for conn := range l.conns[userID] {
+		if conn == except {
+			continue
+		}
+		select {
+		case conn.Messages <- msg:
+		default:
+			slow = append(slow, conn)
+		}
+	}
+	l.mu.RUnlock()
+	// closing outside the lock, as closing handshake may take a while
+	for _, conn := range slow {
+		conn.CloseSlow()
+	}
+}
@@ -0,0 +1,38 @@
+package hub
+
+import (
+	"context"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+)
+
+var _ Hub = (*MemoryHub)(nil)
+
+// MemoryHub only knows about the connections of this process, suitable when running a single instance of the API
+type MemoryHub struct {
+	*local
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{local: newLocal()}
+}
+
+func (h *MemoryHub) Subscribe(_ context.Context, u *domain.User) (bool, error) {
+	return h.add(u), nil
+}
+
+func (h *MemoryHub) Unsubscribe(_ context.Context, u *domain.User) (bool, error) {
+	return h.remove(u), nil
+}
+
+func (h *MemoryHub) Publish(_ context.Context, userID string, msg *domain.Message, except *domain.User) error {
+	h.deliver(userID, msg, except)
+	return nil
+}
+
+func (h *MemoryHub) Run(shtdwnCtx context.Context) {
+	<-shtdwnCtx.Done()
+}
+
+func (h *MemoryHub) Close(_ context.Context) (bool, error) {
+	return true, nil
+}
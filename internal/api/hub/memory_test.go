@@ -0,0 +1,83 @@
+package hub
+
+import (
+	"context"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"testing"
+)
+
+func newConn(userID string, buffer int) *domain.User {
+	u := &domain.User{ID: userID, Messages: make(chan *domain.Message, buffer)}
+	u.CloseSlow = func() { close(u.Messages) }
+	return u
+}
+
+func TestMemoryHubPresence(t *testing.T) {
+	ctx := context.Background()
+	h := NewMemoryHub()
+	phone, laptop := newConn("u1", 1), newConn("u1", 1)
+	if first, _ := h.Subscribe(ctx, phone); !first {
+		t.Error("first connection of the user not reported as first")
+	}
+	if first, _ := h.Subscribe(ctx, laptop); first {
+		t.Error("second connection of the user reported as first")
+	}
+	if last, _ := h.Unsubscribe(ctx, phone); last {
+		t.Error("user reported offline while still connected from the laptop")
+	}
+	if last, _ := h.Unsubscribe(ctx, laptop); !last {
+		t.Error("last connection of the user not reported as last")
+	}
+	// unsubscribing a connection the hub does not know of must not report the user online
+	if last, _ := h.Unsubscribe(ctx, phone); !last {
+		t.Error("unknown connection not reported as last")
+	}
+}
+
+func TestMemoryHubPublish(t *testing.T) {
+	ctx := context.Background()
+	h := NewMemoryHub()
+	phone, laptop, other := newConn("u1", 1), newConn("u1", 1), newConn("u2", 1)
+	for _, c := range []*domain.User{phone, laptop, other} {
+		h.Subscribe(ctx, c)
+	}
+	msg := &domain.Message{ID: "m1"}
+	if err := h.Publish(ctx, "u1", msg, phone); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-laptop.Messages:
+		if got != msg {
+			t.Errorf("got msg %v, want %v", got.ID, msg.ID)
+		}
+	default:
+		t.Error("msg not delivered to the other connection of the user")
+	}
+	if len(phone.Messages) != 0 {
+		t.Error("msg delivered to the excepted connection")
+	}
+	if len(other.Messages) != 0 {
+		t.Error("msg delivered to another user")
+	}
+	// no connection, nothing to deliver to
+	if err := h.Publish(ctx, "u3", msg, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryHubClosesSlowConnections(t *testing.T) {
+	ctx := context.Background()
+	h := NewMemoryHub()
+	slow, fast := newConn("u1", 1), newConn("u1", 2)
+	h.Subscribe(ctx, slow)
+	h.Subscribe(ctx, fast)
+	h.Publish(ctx, "u1", &domain.Message{ID: "m1"}, nil)
+	h.Publish(ctx, "u1", &domain.Message{ID: "m2"}, nil) // slow's buffer is full by now
+	<-slow.Messages
+	if _, open := <-slow.Messages; open {
+		t.Error("slow connection not closed")
+	}
+	if len(fast.Messages) != 2 {
+		t.Errorf("fast connection got %v msgs, want 2", len(fast.Messages))
+	}
+}
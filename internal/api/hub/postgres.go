@@ -0,0 +1,331 @@
+package hub
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/api/repository"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pgChannel = "letschat_hub"
+	// postgres rejects NOTIFY payloads of 8000 bytes or more
+	maxNotifyPayload = 7999
+	// payloads parked in hub_payload are referenced with this prefix followed by the id
+	payloadRefPrefix  = "ref:"
+	heartbeatInterval = 10 * time.Second
+	// nodes that missed this much heartbeats are considered dead
+	staleNodeAfter = 3 * heartbeatInterval
+)
+
+var _ Hub = (*PostgresHub)(nil)
+
+// PostgresHub routes the msgs between the nodes using postgres LISTEN/NOTIFY, the presence of the users across
+// the nodes is kept in the hub_subscription table so Subscribe & Unsubscribe report the global first/last connection
+type PostgresHub struct {
+	*local
+	db     *repository.DB
+	nodeID string
+}
+
+// envelope is what gets notified to the other nodes
+type envelope struct {
+	NodeID string          `json:"nodeID"`
+	UserID string          `json:"userID"`
+	Msg    *domain.Message `json:"msg"`
+}
+
+func NewPostgresHub(db *repository.DB) (*PostgresHub, error) {
+	h := &PostgresHub{
+		local:  newLocal(),
+		db:     db,
+		nodeID: uuid.NewString(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, `INSERT INTO hub_node (id) VALUES ($1)`, h.nodeID); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *PostgresHub) Subscribe(ctx context.Context, u *domain.User) (bool, error) {
+	query := `
+		INSERT INTO hub_subscription (node_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (node_id, user_id) DO UPDATE
+		SET connections = hub_subscription.connections + 1
+		`
+	total, err := h.updatePresence(ctx, u.ID, query)
+	if err != nil {
+		return false, err
+	}
+	h.add(u)
+	return total == 1, nil
+}
+
+func (h *PostgresHub) Unsubscribe(ctx context.Context, u *domain.User) (bool, error) {
+	h.remove(u) // regardless of the DB, this node must not write to the connection anymore
+	decrement := `
+		UPDATE hub_subscription
+		SET connections = connections - 1
+		WHERE node_id = $1 AND user_id = $2
+		`
+	cleanup := `
+		DELETE FROM hub_subscription
+		WHERE node_id = $1 AND user_id = $2 AND connections <= 0
+		`
+	total, err := h.updatePresence(ctx, u.ID, decrement, cleanup)
+	if err != nil {
+		return false, err
+	}
+	return total == 0, nil
+}
+
+// updatePresence runs the queries against the user's subscription on this node & returns the user's total connections
+// across all the nodes, the user is locked for the tx, so concurrent connections on different nodes agree on first/last
+func (h *PostgresHub) updatePresence(ctx context.Context, userID string, queries ...string) (int, error) {
+	tx, err := h.db.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, userID); err != nil {
+		return 0, err
+	}
+	for _, query := range queries {
+		if _, err = tx.ExecContext(ctx, query, h.nodeID, userID); err != nil {
+			return 0, err
+		}
+	}
+	var total int
+	query := `
+		SELECT COALESCE(SUM(connections), 0)
+		FROM hub_subscription
+		WHERE user_id = $1
+		`
+	if err = tx.GetContext(ctx, &total, query, userID); err != nil {
+		return 0, err
+	}
+	return total, tx.Commit()
+}
+
+func (h *PostgresHub) Publish(ctx context.Context, userID string, msg *domain.Message, except *domain.User) error {
+	h.deliver(userID, msg, except)
+	payload, err := json.Marshal(envelope{NodeID: h.nodeID, UserID: userID, Msg: msg})
+	if err != nil {
+		return err
+	}
+	notify := string(payload)
+	if len(payload) > maxNotifyPayload {
+		var id int64
+		query := `
+			INSERT INTO hub_payload (payload)
+			VALUES ($1)
+			RETURNING id
+			`
+		if err = h.db.GetContext(ctx, &id, query, notify); err != nil {
+			return err
+		}
+		notify = payloadRefPrefix + strconv.FormatInt(id, 10)
+	}
+	_, err = h.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, pgChannel, notify)
+	return err
+}
+
+// Run listens for the msgs published by the other nodes & keeps the node alive, until the shtdwnCtx is done.
+// Msgs notified while the listener is reconnecting are lost, the persisted ones reach the users once they reconnect
+func (h *PostgresHub) Run(shtdwnCtx context.Context) {
+	go func() {
+		for {
+			err := h.listen(shtdwnCtx)
+			if shtdwnCtx.Err() != nil {
+				return
+			}
+			slog.Error("hub listener stopped, reconnecting", "err", err)
+			select {
+			case <-time.After(time.Second):
+			case <-shtdwnCtx.Done():
+				return
+			}
+		}
+	}()
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.heartbeat(shtdwnCtx); err != nil {
+				slog.Error(err.Error())
+			}
+		case <-shtdwnCtx.Done():
+			return
+		}
+	}
+}
+
+// listen holds a dedicated connection for LISTEN, the connection is discarded once done, so it never gets back
+// to the pool with the LISTEN still active
+func (h *PostgresHub) listen(ctx context.Context) error {
+	conn, err := h.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+pgChannel); err != nil {
+			return errors.Join(err, driver.ErrBadConn)
+		}
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return errors.Join(err, driver.ErrBadConn)
+			}
+			if err = h.handleNotification(ctx, n.Payload); err != nil {
+				slog.Error(err.Error())
+			}
+		}
+	})
+}
+
+func (h *PostgresHub) handleNotification(ctx context.Context, payload string) error {
+	if ref, ok := strings.CutPrefix(payload, payloadRefPrefix); ok {
+		id, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid hub payload reference %q", ref)
+		}
+		if err = h.db.GetContext(ctx, &payload, `SELECT payload FROM hub_payload WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+	var env envelope
+	if err := json.Unmarshal([]byte(payload), &env); err != nil {
+		return err
+	}
+	if env.NodeID == h.nodeID { // already delivered locally, while publishing
+		return nil
+	}
+	h.deliver(env.UserID, env.Msg, nil)
+	return nil
+}
+
+// heartbeat keeps this node alive, drops the dead ones & the parked payloads every node had time to read. A node
+// dropped by the others, e.g. after stalling past the staleNodeAfter, registers itself again along with its users
+func (h *PostgresHub) heartbeat(ctx context.Context) error {
+	var registered bool
+	query := `
+		INSERT INTO hub_node (id)
+		VALUES ($1)
+		ON CONFLICT (id) DO UPDATE
+		SET heartbeat_at = NOW()
+		RETURNING xmax = 0
+		`
+	if err := h.db.GetContext(ctx, &registered, query, h.nodeID); err != nil {
+		return err
+	}
+	if registered {
+		slog.Warn("hub node was dropped as dead, registered it again", "node", h.nodeID)
+		if err := h.resubscribe(ctx); err != nil {
+			return err
+		}
+	}
+	staleAt := time.Now().Add(-staleNodeAfter)
+	if err := h.dropNodes(ctx, `heartbeat_at < $1`, staleAt); err != nil {
+		return err
+	}
+	_, err := h.db.ExecContext(ctx, `DELETE FROM hub_payload WHERE created_at < $1`, staleAt)
+	return err
+}
+
+// resubscribe restores the subscriptions of the users connected to this node, along with their online status,
+// a Subscribe racing with it has its connection counted already, so the higher of the counts is kept, the users
+// deleted meanwhile are skipped
+func (h *PostgresHub) resubscribe(ctx context.Context) error {
+	counts := h.counts()
+	if len(counts) == 0 {
+		return nil
+	}
+	tx, err := h.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	userIDs := make([]string, 0, len(counts))
+	for userID, connections := range counts {
+		query := `
+			INSERT INTO hub_subscription (node_id, user_id, connections)
+			SELECT $1, id, $3 FROM users WHERE id = $2
+			ON CONFLICT (node_id, user_id) DO UPDATE
+			SET connections = GREATEST(hub_subscription.connections, EXCLUDED.connections)
+			`
+		if _, err = tx.ExecContext(ctx, query, h.nodeID, userID, connections); err != nil {
+			return err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE users SET last_online = NULL WHERE id = ANY($1)`, userIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (h *PostgresHub) Close(ctx context.Context) (bool, error) {
+	if err := h.dropNodes(ctx, `id = $1`, h.nodeID); err != nil {
+		return false, err
+	}
+	var alive int
+	query := `
+		SELECT COUNT(*)
+		FROM hub_node
+		WHERE heartbeat_at >= $1
+		`
+	if err := h.db.GetContext(ctx, &alive, query, time.Now().Add(-staleNodeAfter)); err != nil {
+		return false, err
+	}
+	return alive == 0, nil
+}
+
+// dropNodes removes the nodes matching the cond along with their subscriptions,
+// users left with no connection on any node are marked offline
+func (h *PostgresHub) dropNodes(ctx context.Context, cond string, args ...any) error {
+	tx, err := h.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var userIDs []string
+	query := `
+		DELETE FROM hub_subscription
+		WHERE node_id IN (SELECT id FROM hub_node WHERE ` + cond + `)
+		RETURNING user_id
+		`
+	if err = tx.SelectContext(ctx, &userIDs, query, args...); err != nil {
+		return err
+	}
+	if len(userIDs) != 0 {
+		query = `
+			UPDATE users
+			SET last_online = NOW()
+			WHERE id = ANY($1)
+			  AND last_online IS NULL
+			  AND NOT EXISTS (SELECT 1 FROM hub_subscription hs WHERE hs.user_id = users.id)
+			`
+		if _, err = tx.ExecContext(ctx, query, userIDs); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM hub_node WHERE `+cond, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,209 @@
+package hub
+
+import (
+	"context"
+	"github.com/M0hammadUsman/letschat/internal/api/repository"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/google/uuid"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The tests run two nodes of the hub, as two instances of the API would, against the migrated DB of the
+// LETSCHAT_TEST_DB_DSN, they're skipped if it's not set
+
+func openTestDB(t *testing.T) *repository.DB {
+	t.Helper()
+	dsn := os.Getenv("LETSCHAT_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("LETSCHAT_TEST_DB_DSN not set")
+	}
+	cfg := &utility.Config{}
+	cfg.DB.DSN = dsn
+	cfg.DB.MaxOpenConn = 10
+	cfg.DB.MaxIdleConn = 10
+	db := repository.OpenDB(cfg)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestNode starts a node of the hub, it's run till the test is done
+func newTestNode(t *testing.T, db *repository.DB) *PostgresHub {
+	t.Helper()
+	h, err := NewPostgresHub(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go h.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		h.Close(context.Background())
+	})
+	return h
+}
+
+// insertTestUser inserts an online user, deleted once the test is done
+func insertTestUser(t *testing.T, db *repository.DB) string {
+	t.Helper()
+	var id string
+	query := `
+		INSERT INTO users (name, email, password, last_online, activated)
+		VALUES ('hub test', $1, '\x00', NULL, TRUE)
+		RETURNING id
+		`
+	if err := db.Get(&id, query, uuid.NewString()+"@hub.test"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, id) })
+	return id
+}
+
+func TestPostgresHubPresenceAcrossNodes(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	nodeA, nodeB := newTestNode(t, db), newTestNode(t, db)
+	userID := insertTestUser(t, db)
+	onA, onB := newConn(userID, 1), newConn(userID, 1)
+	if first, err := nodeA.Subscribe(ctx, onA); err != nil || !first {
+		t.Fatalf("first connection across the nodes: got %v, %v", first, err)
+	}
+	if first, err := nodeB.Subscribe(ctx, onB); err != nil || first {
+		t.Fatalf("connection on the other node reported as first: got %v, %v", first, err)
+	}
+	if last, err := nodeA.Unsubscribe(ctx, onA); err != nil || last {
+		t.Fatalf("user reported offline while connected to the other node: got %v, %v", last, err)
+	}
+	if last, err := nodeB.Unsubscribe(ctx, onB); err != nil || !last {
+		t.Fatalf("last connection across the nodes: got %v, %v", last, err)
+	}
+}
+
+func TestPostgresHubDeliveryAcrossNodes(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	nodeA, nodeB := newTestNode(t, db), newTestNode(t, db)
+	userID := insertTestUser(t, db)
+	onB := newConn(userID, 128)
+	if _, err := nodeB.Subscribe(ctx, onB); err != nil {
+		t.Fatal(err)
+	}
+	// the msgs notified before the nodeB is listening are lost, so publishing till one gets through
+	small := &domain.Message{ID: uuid.NewString(), Body: "hello"}
+	if got := publishUntilDelivered(t, nodeA, userID, small, onB); got.Body != small.Body {
+		t.Errorf("got body %q, want %q", got.Body, small.Body)
+	}
+	// parked in the hub_payload, as it's over the NOTIFY limit
+	large := &domain.Message{ID: uuid.NewString(), Body: strings.Repeat("x", maxNotifyPayload)}
+	if got := publishUntilDelivered(t, nodeA, userID, large, onB); got.Body != large.Body {
+		t.Errorf("got body of %v bytes, want %v bytes", len(got.Body), len(large.Body))
+	}
+}
+
+func TestPostgresHubDeadNodeCleanup(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	dead, alive := newTestNode(t, db), newTestNode(t, db)
+	userID := insertTestUser(t, db)
+	if _, err := dead.Subscribe(ctx, newConn(userID, 1)); err != nil {
+		t.Fatal(err)
+	}
+	// the dead node stops heart beating, without closing
+	staleAt := time.Now().Add(-2 * staleNodeAfter)
+	if _, err := db.Exec(`UPDATE hub_node SET heartbeat_at = $1 WHERE id = $2`, staleAt, dead.nodeID); err != nil {
+		t.Fatal(err)
+	}
+	if err := alive.heartbeat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var nodes int
+	if err := db.Get(&nodes, `SELECT COUNT(*) FROM hub_node WHERE id = $1`, dead.nodeID); err != nil {
+		t.Fatal(err)
+	}
+	if nodes != 0 {
+		t.Error("dead node not dropped")
+	}
+	var lastOnline *time.Time
+	if err := db.Get(&lastOnline, `SELECT last_online FROM users WHERE id = $1`, userID); err != nil {
+		t.Fatal(err)
+	}
+	if lastOnline == nil {
+		t.Error("user connected only to the dead node is still marked online")
+	}
+	// the user reconnecting to the alive node is back to the first connection
+	if first, err := alive.Subscribe(ctx, newConn(userID, 1)); err != nil || !first {
+		t.Errorf("connection after the dead node is dropped: got %v, %v", first, err)
+	}
+}
+
+func TestPostgresHubNodeDroppedWhileAlive(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	nodeA, stalled := newTestNode(t, db), newTestNode(t, db)
+	connected, reconnecting := insertTestUser(t, db), insertTestUser(t, db)
+	onStalled := newConn(connected, 128)
+	if _, err := stalled.Subscribe(ctx, onStalled); err != nil {
+		t.Fatal(err)
+	}
+	// the other nodes drop it as dead, e.g. it stalled past the staleNodeAfter
+	if err := nodeA.dropNodes(ctx, `id = $1`, stalled.nodeID); err != nil {
+		t.Fatal(err)
+	}
+	if err := stalled.heartbeat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var connections int
+	query := `SELECT connections FROM hub_subscription WHERE node_id = $1 AND user_id = $2`
+	if err := db.Get(&connections, query, stalled.nodeID, connected); err != nil || connections != 1 {
+		t.Fatalf("subscription of the connected user: got %v, %v, want 1", connections, err)
+	}
+	var lastOnline *time.Time
+	if err := db.Get(&lastOnline, `SELECT last_online FROM users WHERE id = $1`, connected); err != nil {
+		t.Fatal(err)
+	}
+	if lastOnline != nil {
+		t.Error("user still connected to the node is marked offline")
+	}
+	// new connections are subscribed again & the msgs still get delivered to the node
+	onReconnect := newConn(reconnecting, 128)
+	if first, err := stalled.Subscribe(ctx, onReconnect); err != nil || !first {
+		t.Fatalf("connection to the node registered again: got %v, %v", first, err)
+	}
+	for userID, conn := range map[string]*domain.User{connected: onStalled, reconnecting: onReconnect} {
+		msg := &domain.Message{ID: uuid.NewString(), Body: "still there"}
+		if got := publishUntilDelivered(t, nodeA, userID, msg, conn); got.Body != msg.Body {
+			t.Errorf("got body %q, want %q", got.Body, msg.Body)
+		}
+	}
+}
+
+func publishUntilDelivered(t *testing.T, from *PostgresHub, userID string, msg *domain.Message, to *domain.User) *domain.Message {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	publish := func() {
+		if err := from.Publish(context.Background(), userID, msg, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publish()
+	for {
+		select {
+		case got, ok := <-to.Messages:
+			if !ok {
+				t.Fatal("connection closed as slow")
+			}
+			if got.ID == msg.ID { // the others are the retries of the msg published before
+				return got
+			}
+		case <-ticker.C:
+			publish()
+		case <-timeout:
+			t.Fatal("msg not delivered across the nodes")
+		}
+	}
+}
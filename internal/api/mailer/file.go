@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+var _ Mailer = (*FileMailer)(nil)
+
+// FileMailer writes the mails to a maildir, for the local development, any mail client reading a maildir can
+// open them, or they're just the .eml files in the new dir
+type FileMailer struct {
+	dir    string
+	sender string
+	host   string
+	// seq keeps the names of the mails written within the same nanosecond unique
+	seq atomic.Uint64
+}
+
+// NewFileMailer creates the tmp, new & cur dirs of the maildir, if they're not there already
+func NewFileMailer(dir, sender string) (*FileMailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return &FileMailer{dir: dir, sender: sender, host: host}, nil
+}
+
+func (m *FileMailer) Send(recipient, templateFile string, data any) error {
+	mail, err := Render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+	return m.Deliver(mail)
+}
+
+// Deliver writes the mail to the tmp dir first & then moves it to the new one, so it's never read half written
+func (m *FileMailer) Deliver(mail *domain.Mail) error {
+	name := fmt.Sprintf("%v.%v_%v.%v", time.Now().UnixNano(), os.Getpid(), m.seq.Add(1), m.host)
+	tmpPath := filepath.Join(m.dir, "tmp", name)
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = newMessage(m.sender, mail).WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(m.dir, "new", name))
+}
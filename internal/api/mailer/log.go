@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"log/slog"
+)
+
+var _ Mailer = (*LogMailer)(nil)
+
+// LogMailer sends nothing, it only logs the mails, along with the plain text of them, OTPs included, in dev
+type LogMailer struct {
+	dev bool
+}
+
+func NewLogMailer(dev bool) *LogMailer {
+	return &LogMailer{dev: dev}
+}
+
+func (m *LogMailer) Send(recipient, templateFile string, data any) error {
+	mail, err := Render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+	return m.Deliver(mail)
+}
+
+func (m *LogMailer) Deliver(mail *domain.Mail) error {
+	if m.dev {
+		slog.Info("mail", "to", mail.Recipient, "subject", mail.Subject, "text", mail.Text)
+		return nil
+	}
+	slog.Info("mail not sent, the log mailer is in use", "to", mail.Recipient, "subject", mail.Subject)
+	return nil
+}
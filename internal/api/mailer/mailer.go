@@ -3,46 +3,86 @@ package mailer
 import (
 	"bytes"
 	"embed"
+	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
 	"gopkg.in/gomail.v2"
-	"html/template"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
 )
 
 //go:embed templates
 var templateFS embed.FS
 
-type Mailer struct {
-	dialer *gomail.Dialer
-	sender string
+// defaultSender is the sender of the mails written by the file & log backends, if no sender is set
+const defaultSender = "letschat@localhost"
+
+// Mailer sends the mails rendered from the templates, each template defines the subject, the plainBody & the
+// HTML body of the mail
+type Mailer interface {
+	// Send renders the template with the data & sends the mail to the recipient
+	Send(recipient, templateFile string, data any) error
+	// Deliver sends the mail as it's already rendered
+	Deliver(m *domain.Mail) error
 }
 
-func New(cfg *utility.Config) *Mailer {
-	return &Mailer{
-		dialer: gomail.NewDialer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password),
-		sender: cfg.SMTP.Sender,
+// New returns the Mailer for the backend set in the config, (smtp|file|log)
+func New(cfg *utility.Config) (Mailer, error) {
+	sender := cfg.SMTP.Sender
+	switch cfg.Mailer.Backend {
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, sender), nil
+	case "file":
+		if sender == "" {
+			sender = defaultSender
+		}
+		return NewFileMailer(cfg.Mailer.Dir, sender)
+	case "log":
+		return NewLogMailer(cfg.ENV == "dev"), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q, must be one of (smtp|file|log)", cfg.Mailer.Backend)
 	}
 }
 
-func (m Mailer) Send(recipient, templateFile string, data any) error {
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+// Render executes the template with the data, the subject & the plainBody are executed as text, so the data is
+// not HTML escaped in them
+func Render(recipient, templateFile string, data any) (*domain.Mail, error) {
+	textTmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+	htmlTmpl, err := htmltemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	subject := new(bytes.Buffer)
-	if err = tmpl.ExecuteTemplate(subject, "subject", data); err != nil {
-		return err
+	if err = textTmpl.ExecuteTemplate(subject, "subject", data); err != nil {
+		return nil, err
+	}
+	text := new(bytes.Buffer)
+	if err = textTmpl.ExecuteTemplate(text, "plainBody", data); err != nil {
+		return nil, err
 	}
 	html := new(bytes.Buffer)
-	if err = tmpl.ExecuteTemplate(html, "body", data); err != nil {
-		return err
+	if err = htmlTmpl.ExecuteTemplate(html, "body", data); err != nil {
+		return nil, err
 	}
+	return &domain.Mail{
+		Recipient: recipient,
+		Subject:   subject.String(),
+		Text:      strings.TrimSpace(text.String()),
+		HTML:      html.String(),
+	}, nil
+}
+
+// newMessage is the multipart message of the mail, with the plain text as the alternative of the HTML
+func newMessage(sender string, m *domain.Mail) *gomail.Message {
 	msg := gomail.NewMessage()
-	msg.SetHeader("From", m.sender)
-	msg.SetHeader("To", recipient)
-	msg.SetHeader("Subject", subject.String())
-	msg.SetBody("text/html", html.String())
-	if err = m.dialer.DialAndSend(msg); err != nil {
-		return err
-	}
-	return nil
+	msg.SetHeader("From", sender)
+	msg.SetHeader("To", m.Recipient)
+	msg.SetHeader("Subject", m.Subject)
+	msg.SetBody("text/plain", m.Text)
+	msg.AddAlternative("text/html", m.HTML)
+	return msg
 }
@@ -0,0 +1,118 @@
+package mailer
+
+import (
+	"context"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"log/slog"
+	"time"
+)
+
+const (
+	// retryInterval is how often the queue is checked for the mails due to be retried
+	retryInterval = 30 * time.Second
+	// claimLease is how long the claimed mails are left to this node, before the others may retry them as well
+	claimLease = 2 * time.Minute
+	claimLimit = 20
+	maxBackoff = 10 * time.Minute
+)
+
+var _ Mailer = (*Queue)(nil)
+
+// otpTemplates are never queued, so the OTPs never sit in the DB in plain text, the user can ask for another one
+var otpTemplates = map[string]bool{
+	"email.tmpl.html":          true,
+	"password_reset.tmpl.html": true,
+	"email_change.tmpl.html":   true,
+}
+
+// Queue sends the mails with the Mailer, the ones failed to be sent are kept in the DB & retried with a backoff
+// till they're sent or expire, so an outage of the SMTP server doesn't lose them, the ones with the OTPs are
+// only tried once though, see otpTemplates
+type Queue struct {
+	mailer Mailer
+	repo   domain.MailRepository
+	// retryFor is how long a failed mail is retried for, the digests get stale after all
+	retryFor time.Duration
+}
+
+func NewQueue(mailer Mailer, repo domain.MailRepository, retryFor time.Duration) *Queue {
+	return &Queue{mailer: mailer, repo: repo, retryFor: retryFor}
+}
+
+func (q *Queue) Send(recipient, templateFile string, data any) error {
+	mail, err := Render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+	if otpTemplates[templateFile] {
+		return q.mailer.Deliver(mail)
+	}
+	return q.Deliver(mail)
+}
+
+// Deliver queues the mail if it fails to be sent, returns an error only if it could neither be sent nor queued
+func (q *Queue) Deliver(mail *domain.Mail) error {
+	err := q.mailer.Deliver(mail)
+	if err == nil {
+		return nil
+	}
+	slog.Warn("unable to send the mail, queued to be retried", "to", mail.Recipient, "err", err.Error())
+	now := time.Now()
+	mail.Attempts = 1
+	mail.NextAttemptAt = now.Add(retryBackoff(mail.Attempts))
+	mail.Expiry = now.Add(q.retryFor)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return q.repo.InsertMail(ctx, mail)
+}
+
+// Run retries the queued mails every retryInterval, blocks until the shtdwnCtx is done
+func (q *Queue) Run(shtdwnCtx context.Context) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-shtdwnCtx.Done():
+			return
+		case <-ticker.C:
+			q.retry()
+		}
+	}
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+func (q *Queue) retry() {
+	ctx, cancel := context.WithTimeout(context.Background(), claimLease)
+	defer cancel()
+	expired, err := q.repo.DeleteExpiredMails(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	if expired > 0 {
+		slog.Error("mails expired before they could be sent", "count", expired)
+	}
+	mails, err := q.repo.ClaimDueMails(ctx, claimLimit, claimLease)
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	for _, mail := range mails {
+		if err = q.mailer.Deliver(mail); err != nil {
+			slog.Warn("unable to send the queued mail", "to", mail.Recipient, "attempts", mail.Attempts+1,
+				"err", err.Error())
+			err = q.repo.RescheduleMail(ctx, mail.ID, time.Now().Add(retryBackoff(mail.Attempts+1)))
+		} else {
+			err = q.repo.DeleteMail(ctx, mail.ID)
+		}
+		if err != nil {
+			slog.Error(err.Error())
+		}
+	}
+}
+
+// retryBackoff doubles with every failed attempt, starting at the retryInterval, up to the maxBackoff
+func retryBackoff(attempts int) time.Duration {
+	return min(retryInterval<<min(attempts-1, 10), maxBackoff)
+}
@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"gopkg.in/gomail.v2"
+)
+
+var _ Mailer = (*SMTPMailer)(nil)
+
+type SMTPMailer struct {
+	dialer *gomail.Dialer
+	sender string
+}
+
+func NewSMTPMailer(host string, port int, username, password, sender string) *SMTPMailer {
+	return &SMTPMailer{
+		dialer: gomail.NewDialer(host, port, username, password),
+		sender: sender,
+	}
+}
+
+func (m *SMTPMailer) Send(recipient, templateFile string, data any) error {
+	mail, err := Render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+	return m.Deliver(mail)
+}
+
+func (m *SMTPMailer) Deliver(mail *domain.Mail) error {
+	return m.dialer.DialAndSend(newMessage(m.sender, mail))
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/common"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"strconv"
+	"time"
+)
+
+// Metrics of the API, served on the admin port, the counts are of this node only
+type Metrics struct {
+	*Registry
+	Subscribers *Gauge
+	// MsgsProcessed by the operation, the ones sent over the websocket & processed without an error
+	MsgsProcessed *Counter
+	// MsgsDeferred over the per-user send quota, they're deferred rather than dropped
+	MsgsDeferred    *Counter
+	SlowDisconnects *Counter
+	TXDuration      *Histogram
+	TXFailures      *Counter
+	// HTTPRequests by the route pattern & the status
+	HTTPRequests *Histogram
+}
+
+func New(bt *common.BackgroundTask) *Metrics {
+	r := NewRegistry()
+	r.NewGaugeFunc("letschat_background_tasks", "Background tasks in flight.", func() float64 {
+		return float64(bt.Tasks())
+	})
+	return &Metrics{
+		Registry: r,
+		Subscribers: r.NewGauge("letschat_ws_subscribers",
+			"Websocket connections subscribed."),
+		MsgsProcessed: r.NewCounter("letschat_ws_messages_processed_total",
+			"Msgs received over the websocket & processed, by the operation.", "operation"),
+		MsgsDeferred: r.NewCounter("letschat_ws_messages_deferred_total",
+			"Msgs deferred for being over the per-user send quota, none are dropped."),
+		SlowDisconnects: r.NewCounter("letschat_ws_slow_disconnects_total",
+			"Websocket connections closed for being too slow to keep up with the msgs."),
+		TXDuration: r.NewHistogram("letschat_db_tx_duration_seconds",
+			"Duration of the DB transactions, committed or not.", DefBuckets),
+		TXFailures: r.NewCounter("letschat_db_tx_failures_total",
+			"DB transactions rolled back for an error of the DB or of the commit, not for the request being invalid."),
+		HTTPRequests: r.NewHistogram("letschat_http_request_duration_seconds",
+			"Duration of the HTTP requests, by the route & the status.", DefBuckets, "route", "status"),
+	}
+}
+
+func (m *Metrics) MsgProcessed(op domain.MsgOperation) {
+	m.MsgsProcessed.Inc(operationLabel(op))
+}
+
+// HTTPRequest observes the request, the route is the pattern of the ServeMux it matched, if any
+func (m *Metrics) HTTPRequest(route string, status int, took time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	m.HTTPRequests.Observe(took.Seconds(), route, strconv.Itoa(status))
+}
+
+type txRunner interface {
+	RunInTX(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TXManager times the transactions run with the one it wraps, it's a facade.TXManager itself
+type TXManager struct {
+	next    txRunner
+	metrics *Metrics
+}
+
+func (m *Metrics) InstrumentTX(next txRunner) *TXManager {
+	return &TXManager{next: next, metrics: m}
+}
+
+func (t *TXManager) RunInTX(ctx context.Context, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := t.next.RunInTX(ctx, fn)
+	t.metrics.TXDuration.Observe(time.Since(start).Seconds())
+	if txFailed(err) {
+		t.metrics.TXFailures.Inc()
+	}
+	return err
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+var operationLabels = map[domain.MsgOperation]string{
+	domain.CreateMsg:           "create",
+	domain.DeliveredMsg:        "delivered",
+	domain.DeliveredConfirmMsg: "delivered_confirm",
+	domain.ReadMsg:             "read",
+	domain.ReadConfirmMsg:      "read_confirm",
+	domain.DeleteMsg:           "delete",
+	domain.DeleteConfirmMsg:    "delete_confirm",
+	domain.OnlineMsg:           "online",
+	domain.OfflineMsg:          "offline",
+	domain.TypingMsg:           "typing",
+	domain.SyncConvosMsg:       "sync_convos",
+	domain.EditMsg:             "edit",
+	domain.EditConfirmMsg:      "edit_confirm",
+	domain.ReactMsg:            "react",
+	domain.UnreactMsg:          "unreact",
+	domain.ReactConfirmMsg:     "react_confirm",
+	domain.SessionRevokedMsg:   "session_revoked",
+}
+
+// answerErrs are rolled back for, but are the answer to the request rather than a failure of the TX
+var answerErrs = []error{
+	domain.ErrRecordNotFound,
+	domain.ErrDuplicateEmail,
+	domain.ErrEditConflict,
+	domain.ErrNotGroupMember,
+	domain.ErrNotGroupAdmin,
+}
+
+// txFailed tells whether the TX failed for an error of the DB or of the commit, not for the request being invalid
+func txFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ev *domain.ErrValidation
+	if errors.As(err, &ev) {
+		return false
+	}
+	for _, answer := range answerErrs {
+		if errors.Is(err, answer) {
+			return false
+		}
+	}
+	return true
+}
+
+// operationLabel is not a String method of the MsgOperation, pgx would then encode the operation as the text
+func operationLabel(op domain.MsgOperation) string {
+	if l, ok := operationLabels[op]; ok {
+		return l
+	}
+	return strconv.Itoa(int(op))
+}
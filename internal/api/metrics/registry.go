@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// DefBuckets are the upper bounds, in seconds, of the histogram buckets for the latencies
+	DefBuckets   = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// Registry holds the metrics & serves them in the Prometheus text exposition format, in the order registered,
+// without the need of any external client library
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// ServeHTTP writes the current values of all the metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	r.mu.Lock()
+	families := slices.Clone(r.families)
+	r.mu.Unlock()
+	for _, f := range families {
+		f.write(bw)
+	}
+	bw.Flush()
+}
+
+// Counter only ever goes up, per combination of the label values
+type Counter struct{ *family }
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", labels, nil)}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(labelValues).value += v
+}
+
+// Gauge goes up & down, per combination of the label values
+type Gauge struct{ *family }
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, "gauge", labels, nil)}
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labelValues).value += v
+}
+
+// NewGaugeFunc registers a gauge, without labels, whose value is read from the fn every time it's served
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	f := r.register(name, help, "gauge", nil, nil)
+	f.valueFn = fn
+}
+
+// Histogram counts the observations into the buckets, per combination of the label values
+type Histogram struct{ *family }
+
+// NewHistogram with the upper bounds of the buckets in increasing order, the +Inf one is implied
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("buckets of the histogram %v must be in increasing order", name))
+	}
+	return &Histogram{r.register(name, help, "histogram", labels, buckets)}
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labelValues)
+	// the counts are per bucket, they're made cumulative when served
+	i, _ := slices.BinarySearch(h.buckets, v)
+	s.counts[i]++
+	s.value += v
+	s.count++
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+type family struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64
+	valueFn          func() float64
+	mu               sync.Mutex
+	series           map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	// value is the sum of the observations for the histograms
+	value float64
+	// counts per bucket, the last one is the +Inf, histograms only
+	counts []uint64
+	count  uint64
+}
+
+func (r *Registry) register(name, help, kind string, labels []string, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.families {
+		if f.name == name {
+			panic(fmt.Sprintf("metric %v is already registered", name))
+		}
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	if len(labels) == 0 { // served as 0 rather than left out till it's first set
+		f.get(nil)
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// get returns the series of the label values, must be called with the mu locked
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %v takes %v label values, got %v", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %v %v\n", f.name, strings.ReplaceAll(f.help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %v %v\n", f.name, f.kind)
+	if f.valueFn != nil {
+		fmt.Fprintf(w, "%v %v\n", f.name, formatFloat(f.valueFn()))
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != "histogram" {
+			fmt.Fprintf(w, "%v%v %v\n", f.name, f.formatLabels(s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, c := range s.counts {
+			cumulative += c
+			le := math.Inf(1)
+			if i < len(f.buckets) {
+				le = f.buckets[i]
+			}
+			fmt.Fprintf(w, "%v_bucket%v %v\n", f.name, f.formatLabels(s.labelValues, formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%v_sum%v %v\n", f.name, f.formatLabels(s.labelValues, ""), formatFloat(s.value))
+		fmt.Fprintf(w, "%v_count%v %v\n", f.name, f.formatLabels(s.labelValues, ""), s.count)
+	}
+}
+
+// formatLabels formats the label pairs, with the le label of the histogram bucket appended if not empty
+func (f *family) formatLabels(values []string, le string) string {
+	if len(values) == 0 && le == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf(`%v="%v"`, f.labels[i], labelEscaper.Replace(v)))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%v"`, le))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ domain.AttachmentRepository = (*AttachmentRepository)(nil)
+
+type AttachmentRepository struct {
+	db *DB
+}
+
+func NewAttachmentRepository(db *DB) *AttachmentRepository {
+	return &AttachmentRepository{db}
+}
+
+func (r *AttachmentRepository) InsertAttachment(ctx context.Context, a *domain.Attachment) error {
+	query := `
+		INSERT INTO attachment (id, uploader_id, name, content_type, size, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+		`
+	args := []any{a.ID, a.UploaderID, a.Name, a.ContentType, a.Size, a.SHA256}
+	if tx := contextGetTX(ctx); tx != nil {
+		return tx.QueryRowxContext(ctx, query, args...).Scan(&a.CreatedAt)
+	}
+	return r.db.QueryRowxContext(ctx, query, args...).Scan(&a.CreatedAt)
+}
+
+func (r *AttachmentRepository) GetAttachment(ctx context.Context, id string) (*domain.Attachment, error) {
+	query := `
+		SELECT id, uploader_id, name, content_type, size, sha256, created_at
+		FROM attachment
+		WHERE id = $1
+		`
+	var a domain.Attachment
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowxContext(ctx, query, id).StructScan(&a)
+	} else {
+		err = r.db.QueryRowxContext(ctx, query, id).StructScan(&a)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AttachmentRepository) InsertAttachmentGrant(ctx context.Context, id, userID string) error {
+	query := `
+		INSERT INTO attachment_grant (attachment_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, id, userID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, id, userID)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, no such attachment or user
+		return domain.ErrRecordNotFound
+	}
+	return err
+}
+
+// HasAttachmentAccess tells whether the user has uploaded the attachment or it's been shared with the user
+func (r *AttachmentRepository) HasAttachmentAccess(ctx context.Context, id, userID string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM attachment WHERE id = $1 AND uploader_id = $2)
+		    OR EXISTS (SELECT 1 FROM attachment_grant WHERE attachment_id = $1 AND user_id = $2)
+		`
+	var exists bool
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowxContext(ctx, query, id, userID).Scan(&exists)
+	} else {
+		err = r.db.QueryRowxContext(ctx, query, id, userID).Scan(&exists)
+	}
+	return exists, err
+}
+
+func (r *AttachmentRepository) GetUploadedAttachmentIDs(ctx context.Context, userID string) ([]string, error) {
+	query := `
+		SELECT id
+		FROM attachment
+		WHERE uploader_id = $1
+		`
+	ids := make([]string, 0)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &ids, query, userID)
+	} else {
+		err = r.db.SelectContext(ctx, &ids, query, userID)
+	}
+	return ids, err
+}
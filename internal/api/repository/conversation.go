@@ -19,8 +19,8 @@ func NewConversationRepository(db *DB) *ConversationRepository {
 
 func (r *ConversationRepository) CreateConversation(ctx context.Context, senderID, receiverID string) (bool, error) {
 	query := `
-		INSERT INTO conversation 
-		VALUES ($1, $2)
+		INSERT INTO conversation (sender_id, receiver_id, accepted)
+		VALUES ($1, $2, FALSE)
 		`
 	var err error
 	var res sql.Result
@@ -54,11 +54,28 @@ func (r *ConversationRepository) GetConversations(ctx context.Context, usrID str
 	        CASE 
 	            WHEN sender_id = $1 THEN receiver.last_online
 	            ELSE sender.last_online
-	        END AS last_online
+	        END AS last_online,
+	        FALSE AS is_group,
+	        EXISTS (
+	            SELECT 1 FROM user_block 
+	            WHERE user_id = $1 AND blocked_user_id IN (sender_id, receiver_id)
+	        ) AS blocked,
+	        EXISTS (
+	            SELECT 1 FROM user_mute 
+	            WHERE user_id = $1 AND muted_user_id IN (sender_id, receiver_id)
+	        ) AS muted,
+	        NOT accepted AS pending,
+	        NOT accepted AND receiver_id = $1 AS request
 		FROM conversation
 		    INNER JOIN users sender ON sender_id = sender.id
 		    INNER JOIN users receiver ON receiver_id = receiver.id
 		WHERE sender_id = $1 OR receiver_id = $1
+		UNION ALL
+		SELECT g.id AS user_id, g.name AS username, '' AS user_email, NULL AS last_online, TRUE AS is_group,
+		       FALSE AS blocked, FALSE AS muted, NOT gm.accepted AS pending, NOT gm.accepted AS request
+		FROM group_chat g
+		    INNER JOIN group_member gm ON g.id = gm.group_id
+		WHERE gm.user_id = $1
 		`
 	var rows *sqlx.Rows
 	if tx := contextGetTX(ctx); tx != nil {
@@ -98,3 +115,70 @@ func (r *ConversationRepository) ConversationExists(ctx context.Context, senderI
 	}
 	return exists, nil
 }
+
+func (r *ConversationRepository) AcceptConversation(ctx context.Context, senderID, receiverID string) error {
+	query := `
+		UPDATE conversation
+		SET accepted = TRUE
+		WHERE sender_id = $1 AND receiver_id = $2 AND accepted = FALSE
+		`
+	return r.execOnPendingConversation(ctx, query, senderID, receiverID)
+}
+
+func (r *ConversationRepository) DeletePendingConversation(ctx context.Context, senderID, receiverID string) error {
+	query := `
+		DELETE FROM conversation
+		WHERE sender_id = $1 AND receiver_id = $2 AND accepted = FALSE
+		`
+	return r.execOnPendingConversation(ctx, query, senderID, receiverID)
+}
+
+func (r *ConversationRepository) PendingConversationExists(ctx context.Context, senderID, receiverID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+		    SELECT 1 FROM conversation 
+		    WHERE sender_id = $1 AND receiver_id = $2 AND accepted = FALSE
+		)
+		`
+	var exists bool
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowContext(ctx, query, senderID, receiverID).Scan(&exists)
+	} else {
+		err = r.DB.QueryRowContext(ctx, query, senderID, receiverID).Scan(&exists)
+	}
+	return exists, err
+}
+
+func (r *ConversationRepository) execOnPendingConversation(
+	ctx context.Context,
+	query, senderID, receiverID string,
+) error {
+	var err error
+	var res sql.Result
+	if tx := contextGetTX(ctx); tx != nil {
+		res, err = tx.ExecContext(ctx, query, senderID, receiverID)
+	} else {
+		res, err = r.DB.ExecContext(ctx, query, senderID, receiverID)
+	}
+	if err != nil {
+		return err
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *ConversationRepository) DeleteAllConversationsForUser(ctx context.Context, usrID string) error {
+	query := `
+		DELETE FROM conversation
+		WHERE sender_id = $1 OR receiver_id = $1
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.ExecContext(ctx, query, usrID)
+		return err
+	}
+	_, err := r.DB.ExecContext(ctx, query, usrID)
+	return err
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ domain.GroupRepository = (*GroupRepository)(nil)
+
+type GroupRepository struct {
+	db *DB
+}
+
+func NewGroupRepository(db *DB) *GroupRepository {
+	return &GroupRepository{db}
+}
+
+func (r *GroupRepository) InsertGroup(ctx context.Context, g *domain.Group) (string, error) {
+	query := `
+		INSERT INTO group_chat (name, created_by)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version
+		`
+	args := []any{g.Name, g.CreatedBy}
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowxContext(ctx, query, args...).Scan(&g.ID, &g.CreatedAt, &g.Version)
+	} else {
+		err = r.db.QueryRowxContext(ctx, query, args...).Scan(&g.ID, &g.CreatedAt, &g.Version)
+	}
+	return g.ID, err
+}
+
+func (r *GroupRepository) DeleteGroup(ctx context.Context, groupID string) error {
+	query := `
+		DELETE FROM group_chat
+		WHERE id = $1
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.ExecContext(ctx, query, groupID)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, query, groupID)
+	return err
+}
+
+func (r *GroupRepository) InsertGroupMember(ctx context.Context, groupID, userID, role, addedByID string) error {
+	query := `
+		INSERT INTO group_member (group_id, user_id, role, accepted)
+		VALUES ($1, $2, $3, $2 = $4 OR EXISTS (
+		    SELECT 1 FROM conversation
+		    WHERE accepted AND ((sender_id = $2 AND receiver_id = $4) OR (sender_id = $4 AND receiver_id = $2))
+		))
+		ON CONFLICT (group_id, user_id)
+		DO UPDATE SET role = EXCLUDED.role
+		`
+	args := []any{groupID, userID, role, addedByID}
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, args...)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, args...)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, no such user
+		return domain.ErrRecordNotFound
+	}
+	return err
+}
+
+func (r *GroupRepository) DeleteGroupMember(ctx context.Context, groupID, userID string) error {
+	query := `
+		DELETE FROM group_member
+		WHERE group_id = $1 AND user_id = $2
+		`
+	var err error
+	var res sql.Result
+	if tx := contextGetTX(ctx); tx != nil {
+		res, err = tx.ExecContext(ctx, query, groupID, userID)
+	} else {
+		res, err = r.db.ExecContext(ctx, query, groupID, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *GroupRepository) AcceptGroupMember(ctx context.Context, groupID, userID string) error {
+	query := `
+		UPDATE group_member
+		SET accepted = TRUE, joined_at = NOW()
+		WHERE group_id = $1 AND user_id = $2 AND accepted = FALSE
+		`
+	return r.execOnPendingGroupMember(ctx, query, groupID, userID)
+}
+
+func (r *GroupRepository) DeletePendingGroupMember(ctx context.Context, groupID, userID string) error {
+	query := `
+		DELETE FROM group_member
+		WHERE group_id = $1 AND user_id = $2 AND accepted = FALSE
+		`
+	return r.execOnPendingGroupMember(ctx, query, groupID, userID)
+}
+
+func (r *GroupRepository) PendingGroupMemberExists(ctx context.Context, groupID, userID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+		    SELECT 1 FROM group_member
+		    WHERE group_id = $1 AND user_id = $2 AND accepted = FALSE
+		)
+		`
+	var exists bool
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowContext(ctx, query, groupID, userID).Scan(&exists)
+	} else {
+		err = r.db.QueryRowContext(ctx, query, groupID, userID).Scan(&exists)
+	}
+	return exists, err
+}
+
+func (r *GroupRepository) execOnPendingGroupMember(ctx context.Context, query, groupID, userID string) error {
+	var err error
+	var res sql.Result
+	if tx := contextGetTX(ctx); tx != nil {
+		res, err = tx.ExecContext(ctx, query, groupID, userID)
+	} else {
+		res, err = r.db.ExecContext(ctx, query, groupID, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *GroupRepository) GetGroupMember(ctx context.Context, groupID, userID string) (*domain.GroupMember, error) {
+	query := `
+		SELECT gm.group_id, gm.user_id, u.name AS username, u.email AS user_email, gm.role, u.last_online
+		FROM group_member gm
+		    INNER JOIN users u ON gm.user_id = u.id
+		WHERE gm.group_id = $1 AND gm.user_id = $2 AND gm.accepted
+		`
+	var member domain.GroupMember
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowxContext(ctx, query, groupID, userID).StructScan(&member)
+	} else {
+		err = r.db.QueryRowxContext(ctx, query, groupID, userID).StructScan(&member)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *GroupRepository) GetGroupMembers(ctx context.Context, groupID string) ([]*domain.GroupMember, error) {
+	query := `
+		SELECT gm.group_id, gm.user_id, u.name AS username, u.email AS user_email, gm.role, u.last_online
+		FROM group_member gm
+		    INNER JOIN users u ON gm.user_id = u.id
+		WHERE gm.group_id = $1 AND gm.accepted
+		ORDER BY gm.joined_at
+		`
+	members := make([]*domain.GroupMember, 0)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &members, query, groupID)
+	} else {
+		err = r.db.SelectContext(ctx, &members, query, groupID)
+	}
+	return members, err
+}
+
+// PromoteOldestGroupMember makes the longest standing member an admin, if the group has no admin left
+func (r *GroupRepository) PromoteOldestGroupMember(ctx context.Context, groupID string) error {
+	query := `
+		UPDATE group_member
+		SET role = 'admin'
+		WHERE group_id = $1
+		  AND NOT EXISTS (SELECT 1 FROM group_member WHERE group_id = $1 AND role = 'admin' AND accepted)
+		  AND user_id = (SELECT user_id FROM group_member WHERE group_id = $1 AND accepted ORDER BY joined_at LIMIT 1)
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.ExecContext(ctx, query, groupID)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, query, groupID)
+	return err
+}
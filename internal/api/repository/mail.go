@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"time"
+)
+
+var _ domain.MailRepository = (*MailRepository)(nil)
+
+type MailRepository struct {
+	db *DB
+}
+
+func NewMailRepository(db *DB) *MailRepository {
+	return &MailRepository{db}
+}
+
+func (r *MailRepository) InsertMail(ctx context.Context, m *domain.Mail) error {
+	query := `
+		INSERT INTO mail_queue (recipient, subject, text_body, html_body, attempts, next_attempt_at, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	args := []any{m.Recipient, m.Subject, m.Text, m.HTML, m.Attempts, m.NextAttemptAt, m.Expiry}
+	if tx := contextGetTX(ctx); tx != nil {
+		return tx.QueryRowxContext(ctx, query, args...).Scan(&m.ID, &m.CreatedAt)
+	}
+	return r.db.QueryRowxContext(ctx, query, args...).Scan(&m.ID, &m.CreatedAt)
+}
+
+func (r *MailRepository) ClaimDueMails(ctx context.Context, limit int, lease time.Duration) ([]*domain.Mail, error) {
+	query := `
+		UPDATE mail_queue
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id
+			FROM mail_queue
+			WHERE next_attempt_at <= NOW() AND expiry > NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, subject, text_body, html_body, attempts, next_attempt_at, expiry, created_at
+	`
+	mails := make([]*domain.Mail, 0)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &mails, query, limit, lease.Seconds())
+	} else {
+		err = r.db.SelectContext(ctx, &mails, query, limit, lease.Seconds())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mails, nil
+}
+
+func (r *MailRepository) RescheduleMail(ctx context.Context, id string, next time.Time) error {
+	query := `
+		UPDATE mail_queue
+		SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id = $1
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, id, next)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, id, next)
+	}
+	return err
+}
+
+func (r *MailRepository) DeleteMail(ctx context.Context, id string) error {
+	query := `
+		DELETE FROM mail_queue
+		WHERE id = $1
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, id)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, id)
+	}
+	return err
+}
+
+func (r *MailRepository) DeleteExpiredMails(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM mail_queue
+		WHERE expiry <= NOW()
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query)
+	} else {
+		result, err = r.db.ExecContext(ctx, query)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -2,8 +2,11 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 var _ domain.MessageRepository = (*MessageRepository)(nil)
@@ -16,18 +19,25 @@ func NewMessageRepository(db *DB) *MessageRepository {
 	return &MessageRepository{db}
 }
 
-func (r *MessageRepository) GetByID(ctx context.Context, id string, op domain.MsgOperation) (*domain.Message, error) {
+// GetByID returns the msg with the op, exchanged between the pair of users, in either direction
+func (r *MessageRepository) GetByID(
+	ctx context.Context,
+	id, senderID, receiverID string,
+	op domain.MsgOperation,
+) (*domain.Message, error) {
 	query := `
 		SELECT * FROM message 
         WHERE id = $1
-		AND operation = $2
+		AND ((sender_id = $2 AND receiver_id = $3) OR (sender_id = $3 AND receiver_id = $2))
+		AND operation = $4
         `
+	args := []any{id, senderID, receiverID, op}
 	var message domain.Message
 	var err error
 	if tx := contextGetTX(ctx); tx != nil {
-		err = tx.QueryRowxContext(ctx, query, id, op).StructScan(&message)
+		err = tx.QueryRowxContext(ctx, query, args...).StructScan(&message)
 	} else {
-		err = r.db.QueryRowxContext(ctx, query, id, op).StructScan(&message)
+		err = r.db.QueryRowxContext(ctx, query, args...).StructScan(&message)
 	}
 	return &message, err
 }
@@ -61,13 +71,15 @@ func (r *MessageRepository) GetUnDeliveredMessages(ctx context.Context, rcvrID s
 
 func (r *MessageRepository) InsertMessage(ctx context.Context, m *domain.Message) error {
 	query := `
-		INSERT INTO message (id, sender_id, receiver_id, body, sent_at, delivered_at, read_at, operation) 
-		VALUES (:id, :sender_id, :receiver_id, :body, :sent_at, :delivered_at, :read_at, :operation)
-		ON CONFLICT (id)
+		INSERT INTO message (id, sender_id, receiver_id, conversation_id, reply_to_id, attachment_id, body, encrypted, sent_at, delivered_at, read_at, operation) 
+		VALUES (:id, :sender_id, :receiver_id, :conversation_id, :reply_to_id, :attachment_id, :body, :encrypted, :sent_at, :delivered_at, :read_at, :operation)
+		ON CONFLICT (id, sender_id, receiver_id)
 		DO UPDATE SET
-		              sender_id = EXCLUDED.sender_id,
-		              receiver_id = EXCLUDED.receiver_id,
+		              conversation_id = EXCLUDED.conversation_id,
+		              reply_to_id = EXCLUDED.reply_to_id,
+		              attachment_id = EXCLUDED.attachment_id,
 		              body = EXCLUDED.body,
+		              encrypted = EXCLUDED.encrypted,
 		              sent_at = EXCLUDED.sent_at,
 		              delivered_at = EXCLUDED.delivered_at,
 		              read_at = EXCLUDED.read_at,
@@ -81,15 +93,303 @@ func (r *MessageRepository) InsertMessage(ctx context.Context, m *domain.Message
 	return err
 }
 
-func (r *MessageRepository) DeleteMessage(ctx context.Context, mID string) error {
+// DeleteMessage deletes the msg exchanged between the pair of users, in either direction,
+// the copies of a group msg fanned out to the other members are left as is
+func (r *MessageRepository) DeleteMessage(ctx context.Context, mID, senderID, receiverID string) error {
 	query := `
 		DELETE FROM message 
         WHERE id = $1
+        AND ((sender_id = $2 AND receiver_id = $3) OR (sender_id = $3 AND receiver_id = $2))
         `
 	if tx := contextGetTX(ctx); tx != nil {
-		_, err := tx.ExecContext(ctx, query, mID)
+		_, err := tx.ExecContext(ctx, query, mID, senderID, receiverID)
 		return err
 	}
-	_, err := r.db.ExecContext(ctx, query, mID)
+	_, err := r.db.ExecContext(ctx, query, mID, senderID, receiverID)
 	return err
 }
+
+func (r *MessageRepository) InsertMessageHistory(ctx context.Context, m *domain.Message) error {
+	query := `
+		INSERT INTO message_history (id, sender_id, receiver_id, conversation_id, reply_to_id, attachment_id, body, encrypted, sent_at)
+		VALUES (:id, :sender_id, :receiver_id, :conversation_id, :reply_to_id, :attachment_id, :body, :encrypted, :sent_at)
+		ON CONFLICT (id) DO NOTHING
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.NamedExecContext(ctx, query, m)
+		return err
+	}
+	_, err := r.db.NamedExecContext(ctx, query, m)
+	return err
+}
+
+// UpdateMessageHistory sets the delivered_at & read_at of the msg, m is the ack from the receiver of the msg,
+// group msgs are left as is, as they're delivered & read per member
+func (r *MessageRepository) UpdateMessageHistory(ctx context.Context, m *domain.Message) error {
+	query := `
+		UPDATE message_history
+		SET delivered_at = COALESCE(:delivered_at, delivered_at),
+		    read_at = COALESCE(:read_at, read_at)
+		WHERE id = :id AND receiver_id = :sender_id AND conversation_id IS NULL
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.NamedExecContext(ctx, query, m)
+		return err
+	}
+	_, err := r.db.NamedExecContext(ctx, query, m)
+	return err
+}
+
+// DeleteMessageHistory deletes the msg, only if m is sent by the sender of the msg
+func (r *MessageRepository) DeleteMessageHistory(ctx context.Context, m *domain.Message) error {
+	query := `
+		DELETE FROM message_history
+		WHERE id = $1 AND sender_id = $2
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.ExecContext(ctx, query, m.ID, m.SenderID)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, query, m.ID, m.SenderID)
+	return err
+}
+
+// EditMessageHistory sets the new revision of the msg, only if m is sent by the sender of the msg
+func (r *MessageRepository) EditMessageHistory(ctx context.Context, m *domain.Message) error {
+	query := `
+		UPDATE message_history
+		SET body = :body,
+		    encrypted = :encrypted,
+		    edited_at = :sent_at
+		WHERE id = :id AND sender_id = :sender_id
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.NamedExecContext(ctx, query, m)
+		return err
+	}
+	_, err := r.db.NamedExecContext(ctx, query, m)
+	return err
+}
+
+func (r *MessageRepository) InsertMessageSender(ctx context.Context, id, senderID string) error {
+	query := `
+		INSERT INTO message_sender (id, sender_id)
+		VALUES ($1, $2)
+		ON CONFLICT (id) DO NOTHING
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.ExecContext(ctx, query, id, senderID)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, query, id, senderID)
+	return err
+}
+
+// GetMessageSenderID returns the sender of the msg, kept after the msg is delivered, domain.ErrRecordNotFound if
+// the msg was never sent
+func (r *MessageRepository) GetMessageSenderID(ctx context.Context, id string) (string, error) {
+	query := `SELECT sender_id FROM message_sender WHERE id = $1`
+	var senderID string
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowxContext(ctx, query, id).Scan(&senderID)
+	} else {
+		err = r.db.QueryRowxContext(ctx, query, id).Scan(&senderID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrRecordNotFound
+		}
+		return "", err
+	}
+	return senderID, nil
+}
+
+// GetMessageHistory returns the msgs between the users, or of the group if withUsrID is a group the user is member of,
+// newest first, sent before the cursor if any
+func (r *MessageRepository) GetMessageHistory(
+	ctx context.Context,
+	usrID, withUsrID string,
+	cursor *domain.MessageCursor,
+	limit int,
+) ([]*domain.Message, error) {
+	query := `
+		SELECT id, sender_id,
+		       -- the group msgs are addressed to the group if sent by the user, to the user otherwise, as delivered
+		       CASE WHEN conversation_id IS NULL THEN receiver_id WHEN sender_id = $1 THEN conversation_id ELSE $1 END AS receiver_id,
+		       conversation_id, reply_to_id, attachment_id, body, encrypted, sent_at, delivered_at, read_at, edited_at
+		FROM message_history
+		WHERE ((conversation_id IS NULL AND ((sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1)))
+		    OR (conversation_id = $2 AND EXISTS (SELECT 1 FROM group_member WHERE group_id = $2 AND user_id = $1 AND accepted)))
+		  AND ($3::TIMESTAMPTZ IS NULL OR (sent_at, id) < ($3, $4::UUID))
+		ORDER BY sent_at DESC, id DESC
+		LIMIT $5
+		`
+	var sentAt *time.Time
+	var id *string
+	if cursor != nil {
+		sentAt, id = &cursor.SentAt, &cursor.ID
+	}
+	args := []any{usrID, withUsrID, sentAt, id, limit}
+	msgs := make([]*domain.Message, 0, limit)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &msgs, query, args...)
+	} else {
+		err = r.db.SelectContext(ctx, &msgs, query, args...)
+	}
+	return msgs, err
+}
+
+// UpsertReaction holds the (un)reaction till it's delivered, a later op on the same emoji replaces the earlier one
+func (r *MessageRepository) UpsertReaction(ctx context.Context, m *domain.Message) error {
+	query := `
+		INSERT INTO message_reaction (msg_id, sender_id, receiver_id, conversation_id, emoji, sent_at, operation)
+		VALUES (:id, :sender_id, :receiver_id, :conversation_id, :body, :sent_at, :operation)
+		ON CONFLICT (msg_id, sender_id, receiver_id, emoji)
+		DO UPDATE SET
+		              sent_at = EXCLUDED.sent_at,
+		              operation = EXCLUDED.operation
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.NamedExecContext(ctx, query, m)
+		return err
+	}
+	_, err := r.db.NamedExecContext(ctx, query, m)
+	return err
+}
+
+// DeleteReaction deletes the (un)reaction exchanged between the pair of users, in either direction
+func (r *MessageRepository) DeleteReaction(ctx context.Context, mID, senderID, receiverID, emoji string) error {
+	query := `
+		DELETE FROM message_reaction
+		WHERE msg_id = $1 AND emoji = $4
+		AND ((sender_id = $2 AND receiver_id = $3) OR (sender_id = $3 AND receiver_id = $2))
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.ExecContext(ctx, query, mID, senderID, receiverID, emoji)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, query, mID, senderID, receiverID, emoji)
+	return err
+}
+
+func (r *MessageRepository) GetUnDeliveredReactions(ctx context.Context, rcvrID string, c domain.MsgChan) error {
+	query := `
+		SELECT msg_id AS id, sender_id, receiver_id, conversation_id, emoji AS body, sent_at, operation
+		FROM message_reaction
+		WHERE receiver_id = $1
+		ORDER BY sent_at
+		`
+	var rows *sqlx.Rows
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		rows, err = tx.QueryxContext(ctx, query, rcvrID)
+	} else {
+		rows, err = r.db.QueryxContext(ctx, query, rcvrID)
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var msg domain.Message
+		if err = rows.StructScan(&msg); err != nil {
+			return err
+		}
+		c <- &msg
+	}
+	return rows.Err()
+}
+
+func (r *MessageRepository) GetPendingMessages(ctx context.Context, usrID string) ([]*domain.Message, error) {
+	query := `
+		SELECT *
+		FROM message
+		WHERE sender_id = $1 OR receiver_id = $1
+		ORDER BY sent_at
+		`
+	msgs := make([]*domain.Message, 0)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &msgs, query, usrID)
+	} else {
+		err = r.db.SelectContext(ctx, &msgs, query, usrID)
+	}
+	return msgs, err
+}
+
+func (r *MessageRepository) DeleteAllMessagesForUser(ctx context.Context, usrID string) error {
+	query := `
+		DELETE FROM message
+		WHERE sender_id = $1 OR receiver_id = $1
+		`
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err := tx.ExecContext(ctx, query, usrID)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, query, usrID)
+	return err
+}
+
+func (r *MessageRepository) GetDueDigests(
+	ctx context.Context,
+	olderThan time.Duration,
+	limit int,
+) ([]*domain.Digest, error) {
+	query := `
+		WITH due AS (
+			SELECT DISTINCT m.receiver_id AS user_id, d.last_sent_at
+			FROM message m
+			JOIN users u ON u.id = m.receiver_id
+			LEFT JOIN email_digest d ON d.user_id = m.receiver_id
+			WHERE m.operation = $1
+			  AND m.sent_at <= NOW() - $2 * INTERVAL '1 second'
+			  AND (d.last_sent_at IS NULL OR m.sent_at > d.last_sent_at)
+			  AND NOT COALESCE(d.opted_out, FALSE)
+			  AND u.activated
+			  AND u.last_online IS NOT NULL
+			LIMIT $3
+		)
+		SELECT u.id, u.name, u.email, due.last_sent_at, s.name AS sender_name, COUNT(*) AS msg_count
+		FROM due
+		JOIN users u ON u.id = due.user_id
+		JOIN message m ON m.receiver_id = due.user_id AND m.operation = $1
+		JOIN users s ON s.id = m.sender_id
+		GROUP BY u.id, u.name, u.email, due.last_sent_at, s.id, s.name
+		ORDER BY u.id, msg_count DESC
+		`
+	args := []any{domain.CreateMsg, olderThan.Seconds(), limit}
+	var rows *sqlx.Rows
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		rows, err = tx.QueryxContext(ctx, query, args...)
+	} else {
+		rows, err = r.db.QueryxContext(ctx, query, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	digests := make([]*domain.Digest, 0)
+	var d *domain.Digest
+	for rows.Next() {
+		var row struct {
+			ID         string
+			Name       string
+			Email      string
+			LastSentAt *time.Time `db:"last_sent_at"`
+			SenderName string     `db:"sender_name"`
+			Count      int        `db:"msg_count"`
+		}
+		if err = rows.StructScan(&row); err != nil {
+			return nil, err
+		}
+		if d == nil || d.UserID != row.ID { // the rows of a user are in a row
+			d = &domain.Digest{UserID: row.ID, Name: row.Name, Email: row.Email, LastSentAt: row.LastSentAt}
+			digests = append(digests, d)
+		}
+		d.Senders = append(d.Senders, &domain.DigestSender{Name: row.SenderName, Count: row.Count})
+	}
+	return digests, rows.Err()
+}
@@ -31,13 +31,9 @@ func OpenDB(cfg *utility.Config) *DB {
 	if err != nil {
 		panic("failed to connect database")
 	}
-	idleDuration, err := time.ParseDuration(cfg.DB.MaxIdleConnTime)
-	if err != nil {
-		panic("failed to parse max idle connection time, valid defaults must be set")
-	}
 	db.SetMaxOpenConns(cfg.DB.MaxOpenConn)
 	db.SetMaxIdleConns(cfg.DB.MaxIdleConn)
-	db.SetConnMaxIdleTime(idleDuration)
+	db.SetConnMaxIdleTime(cfg.DB.MaxIdleConnTime)
 	return &DB{db}
 }
 
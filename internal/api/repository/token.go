@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 )
 
@@ -17,8 +19,8 @@ func NewTokenRepository(db *DB) *TokenRepository {
 
 func (r *TokenRepository) Insert(ctx context.Context, token *domain.Token) error {
 	query := `
-		INSERT INTO token (hash, user_id, expiry, scope) 
-		VALUES (:hash, :user_id, :expiry, :scope)
+		INSERT INTO token (id, hash, user_id, expiry, scope, device, ip) 
+		VALUES (:id, :hash, :user_id, :expiry, :scope, :device, :ip)
 		`
 	tx := contextGetTX(ctx)
 	var err error
@@ -44,3 +46,179 @@ func (r *TokenRepository) DeleteAllForUser(ctx context.Context, userID, scope st
 	}
 	return err
 }
+
+func (r *TokenRepository) DeleteAllSessions(ctx context.Context, userID string) ([]*domain.Token, error) {
+	query := `
+		DELETE FROM token
+		WHERE user_id = $1 AND scope = $2
+		RETURNING id, user_id
+		`
+	sessions := make([]*domain.Token, 0)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &sessions, query, userID, domain.ScopeAuthentication)
+	} else {
+		err = r.db.SelectContext(ctx, &sessions, query, userID, domain.ScopeAuthentication)
+	}
+	return sessions, err
+}
+
+func (r *TokenRepository) GetSessions(ctx context.Context, userID string) ([]*domain.Session, error) {
+	query := `
+		SELECT id, device, ip, created_at, last_used_at, expiry
+		FROM token
+		WHERE user_id = $1 AND scope = $2 AND expiry > NOW()
+		ORDER BY created_at DESC
+		`
+	sessions := make([]*domain.Session, 0)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &sessions, query, userID, domain.ScopeAuthentication)
+	} else {
+		err = r.db.SelectContext(ctx, &sessions, query, userID, domain.ScopeAuthentication)
+	}
+	return sessions, err
+}
+
+func (r *TokenRepository) DeleteSession(ctx context.Context, id, userID string) error {
+	query := `
+		DELETE FROM token
+		WHERE id = $1 AND user_id = $2 AND scope = $3
+		`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, id, userID, domain.ScopeAuthentication)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, id, userID, domain.ScopeAuthentication)
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, oldHash []byte, token *domain.Token) error {
+	// a single statement, so of the concurrent rotations with the same oldHash only the first one succeeds
+	query := `
+		WITH session AS (
+			UPDATE token
+			SET hash = $2, expiry = $3, last_used_at = NOW()
+			WHERE hash = $1 AND scope = $4 AND expiry > NOW()
+			RETURNING id, user_id
+		), rotated AS (
+			INSERT INTO rotated_token (hash, token_id)
+			SELECT $1, id FROM session
+		)
+		SELECT id, user_id FROM session
+		`
+	args := []any{oldHash, token.Hash, token.Expiry, domain.ScopeAuthentication}
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&token.ID, &token.UserID)
+	} else {
+		err = r.db.QueryRowContext(ctx, query, args...).Scan(&token.ID, &token.UserID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrRecordNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *TokenRepository) DeleteRotatedSession(ctx context.Context, hash []byte) (*domain.Token, error) {
+	query := `
+		DELETE FROM token
+		WHERE id = (SELECT token_id FROM rotated_token WHERE hash = $1)
+		RETURNING id, user_id
+		`
+	var token domain.Token
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowContext(ctx, query, hash).Scan(&token.ID, &token.UserID)
+	} else {
+		err = r.db.QueryRowContext(ctx, query, hash).Scan(&token.ID, &token.UserID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *TokenRepository) GetByHash(ctx context.Context, scope string, hash []byte) (*domain.Token, error) {
+	query := `
+		SELECT id, hash, user_id, expiry, scope, device, ip
+		FROM token
+		WHERE hash = $1 AND scope = $2 AND expiry > NOW()
+		`
+	var token domain.Token
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.GetContext(ctx, &token, query, hash, scope)
+	} else {
+		err = r.db.GetContext(ctx, &token, query, hash, scope)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *TokenRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM token WHERE id = $1`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, id)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, id)
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *TokenRepository) DeleteByHash(ctx context.Context, scope string, hash []byte) (*domain.Token, error) {
+	query := `
+		DELETE FROM token
+		WHERE hash = $1 AND scope = $2 AND expiry > NOW()
+		RETURNING id, hash, user_id, expiry, scope, device, ip
+		`
+	var token domain.Token
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.GetContext(ctx, &token, query, hash, scope)
+	} else {
+		err = r.db.GetContext(ctx, &token, query, hash, scope)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
@@ -118,12 +118,12 @@ func (r *UserRepository) UpdateUser(ctx context.Context, u *domain.User) error {
 
 func (r *UserRepository) GetForToken(ctx context.Context, scope string, hash []byte) (*domain.User, error) {
 	query := `
-		SELECT * FROM users u
-	    WHERE id IN (
-	    SELECT user_id
-	    FROM token WHERE scope = $1 
-         AND hash = $2 
-         AND expiry > NOW())
+		SELECT u.*, t.id AS session_id
+		FROM users u
+		JOIN token t ON t.user_id = u.id
+		WHERE t.scope = $1
+		  AND t.hash = $2
+		  AND t.expiry > NOW()
 		`
 	var usr domain.User
 	var err error
@@ -169,18 +169,19 @@ func (r *UserRepository) ActivateUser(ctx context.Context, user *domain.User) er
 
 func (r *UserRepository) GetByQuery(
 	ctx context.Context,
-	paramName, paramValue string,
+	usrID, paramName, paramValue string,
 	filter domain.Filter,
 ) ([]*domain.User, *domain.Metadata, error) {
 	query := fmt.Sprintf(`
 	SELECT COUNT(*) OVER() total, *
 	FROM users
 	WHERE STRICT_WORD_SIMILARITY($1, %v) > 0.5 AND activated = TRUE
+	  AND NOT EXISTS (SELECT 1 FROM user_block WHERE user_id = $4 AND blocked_user_id = users.id)
 	ORDER BY STRICT_WORD_SIMILARITY($1, %v)
 	LIMIT $2
 	OFFSET $3
 	`, paramName, paramName)
-	args := []any{paramValue, filter.Limit(), filter.Offset()}
+	args := []any{paramValue, filter.Limit(), filter.Offset(), usrID}
 	var rows *sqlx.Rows
 	if tx := contextGetTX(ctx); tx != nil {
 		rows, _ = tx.QueryxContext(ctx, query, args...)
@@ -222,3 +223,646 @@ func (r *UserRepository) SetOnlineUsersLastSeen(ctx context.Context, t time.Time
 	}
 	return err
 }
+
+// UpsertUserKey sets the key of the user, the UpdatedAt only changes if the key does
+func (r *UserRepository) UpsertUserKey(ctx context.Context, k *domain.UserKey) error {
+	query := `
+		INSERT INTO user_key (user_id, public_key)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+		              public_key = EXCLUDED.public_key,
+		              updated_at = CASE WHEN user_key.public_key = EXCLUDED.public_key THEN user_key.updated_at ELSE NOW() END
+		RETURNING updated_at
+	`
+	if tx := contextGetTX(ctx); tx != nil {
+		return tx.QueryRowxContext(ctx, query, k.UserID, k.PublicKey).Scan(&k.UpdatedAt)
+	}
+	return r.db.QueryRowxContext(ctx, query, k.UserID, k.PublicKey).Scan(&k.UpdatedAt)
+}
+
+func (r *UserRepository) GetUserKey(ctx context.Context, userID string) (*domain.UserKey, error) {
+	query := `
+		SELECT user_id, public_key, updated_at
+		FROM user_key
+		WHERE user_id = $1
+	`
+	var k domain.UserKey
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowxContext(ctx, query, userID).StructScan(&k)
+	} else {
+		err = r.db.QueryRowxContext(ctx, query, userID).StructScan(&k)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *UserRepository) InsertUserBlock(ctx context.Context, userID, blockedUserID string) error {
+	query := `
+		INSERT INTO user_block (user_id, blocked_user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, blocked_user_id) DO NOTHING
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, userID, blockedUserID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, userID, blockedUserID)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, no such user
+		return domain.ErrRecordNotFound
+	}
+	return err
+}
+
+func (r *UserRepository) DeleteUserBlock(ctx context.Context, userID, blockedUserID string) error {
+	query := `
+		DELETE FROM user_block
+		WHERE user_id = $1 AND blocked_user_id = $2
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, userID, blockedUserID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, userID, blockedUserID)
+	}
+	return err
+}
+
+func (r *UserRepository) UserBlockExists(ctx context.Context, userID, blockedUserID string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM user_block WHERE user_id = $1 AND blocked_user_id = $2)
+	`
+	var exists bool
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowContext(ctx, query, userID, blockedUserID).Scan(&exists)
+	} else {
+		err = r.db.QueryRowContext(ctx, query, userID, blockedUserID).Scan(&exists)
+	}
+	return exists, err
+}
+
+func (r *UserRepository) GetBlockerIDs(ctx context.Context, userID string) ([]string, error) {
+	query := `
+		SELECT user_id
+		FROM user_block
+		WHERE blocked_user_id = $1
+	`
+	ids := make([]string, 0)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &ids, query, userID)
+	} else {
+		err = r.db.SelectContext(ctx, &ids, query, userID)
+	}
+	return ids, err
+}
+
+func (r *UserRepository) InsertUserMute(ctx context.Context, userID, mutedUserID string) error {
+	query := `
+		INSERT INTO user_mute (user_id, muted_user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, muted_user_id) DO NOTHING
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, userID, mutedUserID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, userID, mutedUserID)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, no such user
+		return domain.ErrRecordNotFound
+	}
+	return err
+}
+
+func (r *UserRepository) DeleteUserMute(ctx context.Context, userID, mutedUserID string) error {
+	query := `
+		DELETE FROM user_mute
+		WHERE user_id = $1 AND muted_user_id = $2
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, userID, mutedUserID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, userID, mutedUserID)
+	}
+	return err
+}
+
+func (r *UserRepository) GetLockedUntil(ctx context.Context, userID string) (*time.Time, error) {
+	query := `
+		SELECT locked_until
+		FROM failed_attempt
+		WHERE user_id = $1 AND locked_until > NOW()
+	`
+	var lockedUntil *time.Time
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowContext(ctx, query, userID).Scan(&lockedUntil)
+	} else {
+		err = r.db.QueryRowContext(ctx, query, userID).Scan(&lockedUntil)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return lockedUntil, err
+}
+
+func (r *UserRepository) InsertFailedAttempt(
+	ctx context.Context,
+	userID string,
+	maxAttempts int,
+	window, lockout time.Duration,
+) (*time.Time, error) {
+	// the attempts older than the window are dropped, the count starts over from this one
+	query := `
+		INSERT INTO failed_attempt AS fa (user_id, attempts, first_failed_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET attempts = CASE WHEN fa.first_failed_at <= NOW() - $3 * INTERVAL '1 second' THEN 1
+			WHEN fa.attempts + 1 >= $2 THEN 0 ELSE fa.attempts + 1 END,
+			first_failed_at = CASE WHEN fa.first_failed_at <= NOW() - $3 * INTERVAL '1 second' OR fa.attempts + 1 >= $2
+			THEN NOW() ELSE fa.first_failed_at END,
+			locked_until = CASE WHEN fa.first_failed_at > NOW() - $3 * INTERVAL '1 second' AND fa.attempts + 1 >= $2
+			THEN NOW() + $4 * INTERVAL '1 second' ELSE fa.locked_until END
+		RETURNING locked_until
+	`
+	args := []any{userID, maxAttempts, window.Seconds(), lockout.Seconds()}
+	var lockedUntil *time.Time
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&lockedUntil)
+	} else {
+		err = r.db.QueryRowContext(ctx, query, args...).Scan(&lockedUntil)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, no such user
+		return nil, domain.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil != nil && lockedUntil.Before(time.Now()) { // an expired lock from the past
+		return nil, nil
+	}
+	return lockedUntil, nil
+}
+
+func (r *UserRepository) DeleteFailedAttempts(ctx context.Context, userID string) error {
+	query := `
+		DELETE FROM failed_attempt
+		WHERE user_id = $1
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, userID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, userID)
+	}
+	return err
+}
+
+func (r *UserRepository) DeleteUser(ctx context.Context, userID string) error {
+	query := `
+		DELETE FROM users
+		WHERE id = $1
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, userID)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, userID)
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) UpsertPendingTOTP(ctx context.Context, userID string, secret []byte) error {
+	query := `
+		INSERT INTO user_totp AS ut (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE NOT ut.enabled
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, userID, secret)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, userID, secret)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, no such user
+		return domain.ErrRecordNotFound
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 { // the conflicting row is the enabled one
+		return domain.ErrTOTPEnabled
+	}
+	return nil
+}
+
+func (r *UserRepository) GetTOTP(ctx context.Context, userID string) (*domain.TOTP, error) {
+	query := `
+		SELECT user_id, secret, enabled, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+	var totp domain.TOTP
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.GetContext(ctx, &totp, query, userID)
+	} else {
+		err = r.db.GetContext(ctx, &totp, query, userID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &totp, nil
+}
+
+func (r *UserRepository) EnableTOTP(ctx context.Context, userID string, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET enabled = TRUE, last_used_step = $2
+		WHERE user_id = $1 AND NOT enabled
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, userID, step)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, userID, step)
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) SetTOTPLastUsedStep(ctx context.Context, userID string, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, userID, step)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, userID, step)
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrEditConflict
+	}
+	return nil
+}
+
+func (r *UserRepository) DeleteTOTP(ctx context.Context, userID string) error {
+	query := `
+		DELETE FROM user_totp
+		WHERE user_id = $1
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, userID)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, userID)
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes [][]byte) error {
+	deleteQuery := `
+		DELETE FROM recovery_code
+		WHERE user_id = $1
+	`
+	insertQuery := `
+		INSERT INTO recovery_code (hash, user_id)
+		VALUES ($1, $2)
+	`
+	tx := contextGetTX(ctx)
+	var err error
+	if tx != nil {
+		_, err = tx.ExecContext(ctx, deleteQuery, userID)
+	} else {
+		_, err = r.db.ExecContext(ctx, deleteQuery, userID)
+	}
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if tx != nil {
+			_, err = tx.ExecContext(ctx, insertQuery, hash, userID)
+		} else {
+			_, err = r.db.ExecContext(ctx, insertQuery, hash, userID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *UserRepository) DeleteRecoveryCode(ctx context.Context, userID string, hash []byte) error {
+	query := `
+		DELETE FROM recovery_code
+		WHERE hash = $1 AND user_id = $2
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, hash, userID)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, hash, userID)
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) InsertSSHKey(ctx context.Context, k *domain.SSHKey) error {
+	query := `
+		INSERT INTO user_ssh_key (user_id, name, fingerprint, public_key)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	args := []any{k.UserID, k.Name, k.Fingerprint, k.PublicKey}
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&k.ID, &k.CreatedAt)
+	} else {
+		err = r.db.QueryRowContext(ctx, query, args...).Scan(&k.ID, &k.CreatedAt)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505": // unique violation, the user already has the key
+			return domain.ErrSSHKeyExists
+		case "23503": // foreign key violation, no such user
+			return domain.ErrRecordNotFound
+		}
+	}
+	return err
+}
+
+func (r *UserRepository) GetSSHKeys(ctx context.Context, userID string) ([]*domain.SSHKey, error) {
+	query := `
+		SELECT id, user_id, name, fingerprint, public_key, created_at, last_used_at
+		FROM user_ssh_key
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+	keys := make([]*domain.SSHKey, 0)
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.SelectContext(ctx, &keys, query, userID)
+	} else {
+		err = r.db.SelectContext(ctx, &keys, query, userID)
+	}
+	return keys, err
+}
+
+func (r *UserRepository) GetSSHKey(ctx context.Context, userID, fingerprint string) (*domain.SSHKey, error) {
+	query := `
+		SELECT id, user_id, name, fingerprint, public_key, created_at, last_used_at
+		FROM user_ssh_key
+		WHERE user_id = $1 AND fingerprint = $2
+	`
+	var k domain.SSHKey
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.GetContext(ctx, &k, query, userID, fingerprint)
+	} else {
+		err = r.db.GetContext(ctx, &k, query, userID, fingerprint)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *UserRepository) DeleteSSHKey(ctx context.Context, id, userID string) error {
+	query := `
+		DELETE FROM user_ssh_key
+		WHERE id = $1 AND user_id = $2
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, id, userID)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, id, userID)
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) SetSSHKeyLastUsed(ctx context.Context, id string) error {
+	query := `
+		UPDATE user_ssh_key
+		SET last_used_at = NOW()
+		WHERE id = $1
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, id)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, id)
+	}
+	return err
+}
+
+// UpsertPendingEmail replaces the pending email change of the user, if any
+func (r *UserRepository) UpsertPendingEmail(ctx context.Context, userID, email string) error {
+	query := `
+		INSERT INTO email_change (user_id, email)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email = EXCLUDED.email, created_at = NOW()
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, userID, email)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, userID, email)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, no such user
+		return domain.ErrRecordNotFound
+	}
+	return err
+}
+
+func (r *UserRepository) GetPendingEmail(ctx context.Context, userID string) (string, error) {
+	query := `
+		SELECT email
+		FROM email_change
+		WHERE user_id = $1
+	`
+	var email string
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.GetContext(ctx, &email, query, userID)
+	} else {
+		err = r.db.GetContext(ctx, &email, query, userID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrRecordNotFound
+		}
+		return "", err
+	}
+	return email, nil
+}
+
+// DeletePendingEmail is a no-op if the user has no pending email change
+func (r *UserRepository) DeletePendingEmail(ctx context.Context, userID string) error {
+	query := `
+		DELETE FROM email_change
+		WHERE user_id = $1
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, userID)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, userID)
+	}
+	return err
+}
+
+func (r *UserRepository) GetDigestOptOut(ctx context.Context, userID string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM email_digest WHERE user_id = $1 AND opted_out)
+	`
+	var optOut bool
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		err = tx.GetContext(ctx, &optOut, query, userID)
+	} else {
+		err = r.db.GetContext(ctx, &optOut, query, userID)
+	}
+	return optOut, err
+}
+
+func (r *UserRepository) SetDigestOptOut(ctx context.Context, userID string, optOut bool) error {
+	query := `
+		INSERT INTO email_digest (user_id, opted_out)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET opted_out = EXCLUDED.opted_out
+	`
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		_, err = tx.ExecContext(ctx, query, userID, optOut)
+	} else {
+		_, err = r.db.ExecContext(ctx, query, userID, optOut)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign key violation, no such user
+		return domain.ErrRecordNotFound
+	}
+	return err
+}
+
+func (r *UserRepository) SetDigestSent(ctx context.Context, userID string, lastSentAt *time.Time) (bool, error) {
+	// a concurrent insert conflicts & then the row is no longer the one read, so only one of them wins
+	query := `
+		INSERT INTO email_digest AS ed (user_id, last_sent_at)
+		VALUES ($1, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET last_sent_at = NOW()
+		WHERE ed.last_sent_at IS NOT DISTINCT FROM $2
+	`
+	var result sql.Result
+	var err error
+	if tx := contextGetTX(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, userID, lastSentAt)
+	} else {
+		result, err = r.db.ExecContext(ctx, query, userID, lastSentAt)
+	}
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
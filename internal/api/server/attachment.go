@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// UploadAttachmentHandler reads the raw content of the file from the body, the file name is given as the name query param
+func (s *Server) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > s.Config.Attachments.MaxSize {
+		s.attachmentTooLargeResponse(w, r)
+		return
+	}
+	// uploads outlast the server wide timeouts
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Now().Add(5 * time.Minute)); err != nil {
+		utility.ContextGetLogger(r.Context()).Error(err.Error())
+	}
+	if err := rc.SetWriteDeadline(time.Now().Add(5*time.Minute + 10*time.Second)); err != nil {
+		utility.ContextGetLogger(r.Context()).Error(err.Error())
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.Config.Attachments.MaxSize+1)
+	name := s.readString(r.URL.Query(), "name", "")
+	attachment, err := s.Facade.UploadAttachment(r.Context(), name, r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			s.attachmentTooLargeResponse(w, r)
+			return
+		}
+		s.attachmentErrorResponse(w, r, err)
+		return
+	}
+	if err = s.writeJSON(w, envelop{"attachment": attachment}, http.StatusCreated, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+// DownloadAttachmentHandler streams the content of the file, the ETag holds the SHA-256 of the content,
+// so the clients can verify what they've saved
+func (s *Server) DownloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, content, err := s.Facade.OpenAttachment(r.Context(), r.PathValue("attachmentID"))
+	if err != nil {
+		s.attachmentErrorResponse(w, r, err)
+		return
+	}
+	defer content.Close()
+	rc := http.NewResponseController(w)
+	if err = rc.SetWriteDeadline(time.Now().Add(5 * time.Minute)); err != nil {
+		utility.ContextGetLogger(r.Context()).Error(err.Error())
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("ETag", fmt.Sprintf("%q", attachment.SHA256))
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, content); err != nil {
+		utility.ContextGetLogger(r.Context()).Error(err.Error())
+	}
+}
+
+// Helpers & Stuff ----------------------------------------------------------------------------------------------------
+
+func (s *Server) attachmentErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var ev *domain.ErrValidation
+	switch {
+	case errors.As(err, &ev):
+		s.failedValidationResponse(w, r, ev.Errors)
+	case errors.Is(err, domain.ErrAttachmentTooLarge):
+		s.attachmentTooLargeResponse(w, r)
+	case errors.Is(err, domain.ErrRecordNotFound):
+		s.notFoundResponse(w, r)
+	default:
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) attachmentTooLargeResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the attachment must not be larger than %d bytes", s.Config.Attachments.MaxSize)
+	s.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"net/http"
@@ -13,23 +14,99 @@ func (s *Server) GetConversationsHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
+	for _, convo := range c {
+		if convo.Pending && !convo.Request { // the receiver's presence is not disclosed till the request is accepted
+			convo.LastOnline = nil
+		}
+	}
 	if err = s.writeJSON(w, envelop{"conversations": c}, http.StatusOK, nil); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
-// Once the receivers gets this broadcast, they will re-fetch the conversations, for synchronization
+// AcceptConversationRequestHandler accepts the msg request sent by the user in the path, the conversations are synced
+// so the sender gets to know of the presence & the read receipts from now on, for a group invite the members are
+func (s *Server) AcceptConversationRequestHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("userID")
+	if err := s.Facade.AcceptConversationRequest(r.Context(), id); err != nil {
+		s.conversationRequestErrorResponse(w, r, err)
+		return
+	}
+	if err := s.syncConvos(r.Context()); err != nil {
+		utility.ContextGetLogger(r.Context()).Error(err.Error())
+	}
+	if members, err := s.Facade.GetGroupMembers(r.Context(), id); err == nil {
+		s.syncGroupMembers(r.Context(), members)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeclineConversationRequestHandler removes the msg request sent by the user in the path, the sender is no longer
+// in the conversations so it's told to sync separately
+func (s *Server) DeclineConversationRequestHandler(w http.ResponseWriter, r *http.Request) {
+	senderID := r.PathValue("userID")
+	if err := s.Facade.DeclineConversationRequest(r.Context(), senderID); err != nil {
+		s.conversationRequestErrorResponse(w, r, err)
+		return
+	}
+	u := utility.ContextGetUser(r.Context())
+	for _, usrID := range []string{senderID, u.ID} {
+		t := time.Now()
+		s.publish(r.Context(), usrID, &domain.Message{SenderID: u.ID, SentAt: &t, Operation: domain.SyncConvosMsg}, nil)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMessageHistoryHandler returns a page of msgs exchanged with the user, newest first,
+// the returned cursor fetches the next (older) page, empty cursor means there is nothing more
+func (s *Server) GetMessageHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	withUsrID := r.PathValue("userID")
+	v := r.URL.Query()
+	ev := domain.NewErrValidation()
+	pageSize := s.readInt(v, "size", 50, ev)
+	cursor, err := domain.DecodeMessageCursor(s.readString(v, "cursor", ""))
+	if err != nil {
+		ev.AddError("cursor", err.Error())
+	}
+	if domain.ValidateMessageHistoryParams(ev, withUsrID, pageSize); ev.HasErrors() {
+		s.failedValidationResponse(w, r, ev.Errors)
+		return
+	}
+	msgs, next, err := s.Facade.GetMessageHistory(r.Context(), withUsrID, cursor, pageSize)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNoMsgHistory):
+			s.notFoundResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"messages": msgs, "cursor": next.Encode()}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+// Once the receivers gets this broadcast, they will re-fetch the conversations, for synchronization,
+// the other devices of the user in the context are also told to sync
 func (s *Server) syncConvos(ctx context.Context) error {
 	convos, err := s.Facade.GetConversations(ctx)
 	if err != nil {
 		return err
 	}
+	s.publishSyncConvos(ctx, convos)
+	return nil
+}
+
+// publishSyncConvos tells the online users of the conversations & the other devices of the user to re-fetch
+// their conversations, split from syncConvos for the conversations fetched beforehand, e.g. before they're deleted
+func (s *Server) publishSyncConvos(ctx context.Context, convos []*domain.Conversation) {
 	u := utility.ContextGetUser(ctx)
 	if u == nil {
 		panic("no user was found in the context, Hint: missing Authentication middleware")
 	}
 	for _, convo := range convos {
-		if convo.LastOnline != nil { // meaning the user is not online
+		if convo.IsGroup || convo.LastOnline != nil { // meaning the user is not online
 			continue
 		}
 		t := time.Now()
@@ -38,9 +115,38 @@ func (s *Server) syncConvos(ctx context.Context) error {
 			SentAt:    &t,
 			Operation: domain.SyncConvosMsg,
 		}
-		if v, ok := s.Subscribers[convo.UserID]; ok {
-			v.Messages <- &msg
+		s.publish(ctx, convo.UserID, &msg, nil)
+	}
+	t := time.Now()
+	s.publish(ctx, u.ID, &domain.Message{SenderID: u.ID, SentAt: &t, Operation: domain.SyncConvosMsg}, u)
+}
+
+// syncGroupMembers tells every member of the group to re-fetch their conversations,
+// along with the given users, e.g. the ones removed from the group
+func (s *Server) syncGroupMembers(ctx context.Context, members []*domain.GroupMember, userIDs ...string) {
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	u := utility.ContextGetUser(ctx)
+	t := time.Now()
+	for _, userID := range userIDs {
+		msg := domain.Message{
+			SenderID:  u.ID,
+			SentAt:    &t,
+			Operation: domain.SyncConvosMsg,
 		}
+		s.publish(ctx, userID, &msg, nil)
+	}
+}
+
+func (s *Server) conversationRequestErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var ev *domain.ErrValidation
+	switch {
+	case errors.As(err, &ev):
+		s.failedValidationResponse(w, r, ev.Errors)
+	case errors.Is(err, domain.ErrRecordNotFound):
+		s.notFoundResponse(w, r)
+	default:
+		s.serverErrorResponse(w, r, err)
 	}
-	return nil
 }
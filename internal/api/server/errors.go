@@ -1,16 +1,19 @@
 package server
 
 import (
-	"log/slog"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"runtime/debug"
 )
 
-func (s *Server) errorResponse(w http.ResponseWriter, _ *http.Request, status int, message any) {
+func (s *Server) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
 	data := envelop{"errors": message}
 	if err := s.writeJSON(w, data, status, nil); err != nil {
-		slog.Error(err.Error())
+		utility.ContextGetLogger(r.Context()).Error(err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
@@ -20,7 +23,7 @@ func (s *Server) badRequestResponse(w http.ResponseWriter, r *http.Request, err
 }
 
 func (s *Server) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	slog.Error(err.Error())
+	utility.ContextGetLogger(r.Context()).Error(err.Error())
 	debug.PrintStack()
 	message := "the Server encountered a problem and could not process your request"
 	s.errorResponse(w, r, http.StatusInternalServerError, message)
@@ -45,6 +48,11 @@ func (s *Server) alreadyActivatedResponse(w http.ResponseWriter, r *http.Request
 	s.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (s *Server) totpEnabledResponse(w http.ResponseWriter, r *http.Request) {
+	message := "two-factor authentication is already enabled, disable it first to enroll again"
+	s.errorResponse(w, r, http.StatusConflict, message)
+}
+
 func (s *Server) invalidCredentialResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
 	s.errorResponse(w, r, http.StatusUnauthorized, message)
@@ -66,7 +74,19 @@ func (s *Server) inactiveAccountResponse(w http.ResponseWriter, r *http.Request)
 	s.errorResponse(w, r, http.StatusForbidden, message)
 }
 
-func (s *Server) redundantSubscription(w http.ResponseWriter, r *http.Request) {
-	message := "single instance of subscription is allowed for this account"
-	s.errorResponse(w, r, http.StatusConflict, message)
+func (s *Server) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	s.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (s *Server) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	message := "rate limit exceeded, please try again later"
+	s.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (s *Server) lockedOutResponse(w http.ResponseWriter, r *http.Request, until time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+	message := "too many failed attempts, the account is temporarily locked"
+	s.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"net/http"
+)
+
+func (s *Server) CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var groupCreate domain.GroupCreate
+	if err := s.readJSON(w, r, &groupCreate); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	group, err := s.Facade.CreateGroup(r.Context(), &groupCreate)
+	if err != nil {
+		s.groupErrorResponse(w, r, err)
+		return
+	}
+	if members, err := s.Facade.GetGroupMembers(r.Context(), group.ID); err == nil {
+		s.syncGroupMembers(r.Context(), members, groupCreate.Members...) // the invited ones are told too
+	}
+	if err = s.writeJSON(w, envelop{"group": group}, http.StatusCreated, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) GetGroupMembersHandler(w http.ResponseWriter, r *http.Request) {
+	members, err := s.Facade.GetGroupMembers(r.Context(), r.PathValue("groupID"))
+	if err != nil {
+		s.groupErrorResponse(w, r, err)
+		return
+	}
+	if err = s.writeJSON(w, envelop{"members": members}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) AddGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var memberAdd domain.GroupMemberAdd
+	if err := s.readJSON(w, r, &memberAdd); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	groupID := r.PathValue("groupID")
+	if err := s.Facade.AddGroupMember(r.Context(), groupID, &memberAdd); err != nil {
+		s.groupErrorResponse(w, r, err)
+		return
+	}
+	if members, err := s.Facade.GetGroupMembers(r.Context(), groupID); err == nil {
+		s.syncGroupMembers(r.Context(), members, memberAdd.UserID) // the user may only be invited
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) RemoveGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	s.removeGroupMember(w, r, r.PathValue("userID"))
+}
+
+func (s *Server) LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
+	s.removeGroupMember(w, r, utility.ContextGetUser(r.Context()).ID)
+}
+
+// Helpers & Stuff ----------------------------------------------------------------------------------------------------
+
+func (s *Server) removeGroupMember(w http.ResponseWriter, r *http.Request, userID string) {
+	groupID := r.PathValue("groupID")
+	// fetched before the removal, so the removed member is also told to sync
+	members, err := s.Facade.GetGroupMembers(r.Context(), groupID)
+	if err != nil {
+		s.groupErrorResponse(w, r, err)
+		return
+	}
+	if err = s.Facade.RemoveGroupMember(r.Context(), groupID, userID); err != nil {
+		s.groupErrorResponse(w, r, err)
+		return
+	}
+	s.syncGroupMembers(r.Context(), members)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) groupErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var ev *domain.ErrValidation
+	switch {
+	case errors.As(err, &ev):
+		s.failedValidationResponse(w, r, ev.Errors)
+	case errors.Is(err, domain.ErrNotGroupMember):
+		s.notFoundResponse(w, r)
+	case errors.Is(err, domain.ErrNotGroupAdmin):
+		s.notPermittedResponse(w, r)
+	default:
+		s.serverErrorResponse(w, r, err)
+	}
+}
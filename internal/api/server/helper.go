@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -89,3 +90,12 @@ func (s *Server) readString(v url.Values, key, defaultValue string) string {
 	}
 	return str
 }
+
+// clientIP returns the IP of the client the request came from, without the port
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
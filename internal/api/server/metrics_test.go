@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrapeMetrics GETs the /metrics of the admin port
+func scrapeMetrics(t *testing.T, s *Server) string {
+	t.Helper()
+	ts := httptest.NewServer(s.adminRoutes())
+	defer ts.Close()
+	res, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v scraping the metrics", res.StatusCode)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMetricsAreScrapedFromTheAdminPort(t *testing.T) {
+	s, db := newTestServer(t, newTestConfig())
+	subscribed := make(chan string, 2)
+	s.Hub = &notifyingHub{Hub: s.Hub, subscribed: subscribed}
+	senderID, receiverID := insertTestUser(t, db), insertTestUser(t, db)
+	insertTestContacts(t, db, senderID, receiverID)
+	api := httptest.NewServer(s.routes())
+	t.Cleanup(api.Close)
+
+	// the registration is run in a TX, the OTP is then mailed in the background
+	email := uuid.NewString() + "@server.test"
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE email = $1`, email) })
+	body := fmt.Sprintf(`{"name": "metrics test", "email": %q, "password": "pa55word"}`, email)
+	res, err := http.Post(api.URL+"/v1/users", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("got status %v registering, want %v", res.StatusCode, http.StatusAccepted)
+	}
+
+	receiver := dialTestWebsocket(t, s, receiverID)
+	sender := dialTestWebsocket(t, s, senderID)
+	for range 2 {
+		select {
+		case <-subscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the users to subscribe")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = wsjson.Write(ctx, sender, newTestMsg(receiverID, "metrics")); err != nil {
+		t.Fatal(err)
+	}
+	// the msg is counted as processed before it's published to the receiver
+	for {
+		var msg domain.Message
+		if err = wsjson.Read(ctx, receiver, &msg); err != nil {
+			t.Fatalf("reading the msg: %v", err)
+		}
+		if msg.Operation == domain.CreateMsg {
+			break
+		}
+	}
+
+	scrape := scrapeMetrics(t, s)
+	for _, want := range []string{
+		// websocket, both users are still connected
+		"# TYPE letschat_ws_subscribers gauge\n",
+		"\nletschat_ws_subscribers 2\n",
+		"# TYPE letschat_ws_messages_processed_total counter\n",
+		"\nletschat_ws_messages_processed_total{operation=\"create\"} 1\n",
+		"# TYPE letschat_ws_messages_deferred_total counter\n",
+		"# TYPE letschat_ws_slow_disconnects_total counter\n",
+		// TX
+		"# TYPE letschat_db_tx_duration_seconds histogram\n",
+		"\nletschat_db_tx_duration_seconds_bucket{le=\"+Inf\"} ",
+		"\nletschat_db_tx_duration_seconds_count ",
+		"# TYPE letschat_db_tx_failures_total counter\n",
+		// HTTP, by the route pattern & the status
+		"# TYPE letschat_http_request_duration_seconds histogram\n",
+		"\nletschat_http_request_duration_seconds_bucket{route=\"POST /v1/users\",status=\"202\",le=\"+Inf\"} 1\n",
+		"\nletschat_http_request_duration_seconds_count{route=\"POST /v1/users\",status=\"202\"} 1\n",
+		// background tasks, the two of each websocket connection at least
+		"# TYPE letschat_background_tasks gauge\n",
+		"\nletschat_background_tasks ",
+	} {
+		if !strings.Contains(scrape, want) {
+			t.Errorf("scrape is missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{
+		"\nletschat_db_tx_duration_seconds_count 0\n",
+		"\nletschat_background_tasks 0\n",
+		"\nletschat_background_tasks 1\n",
+	} {
+		if strings.Contains(scrape, unwanted) {
+			t.Errorf("scrape has %q", strings.TrimSpace(unwanted))
+		}
+	}
+	if t.Failed() {
+		t.Log(scrape)
+	}
+}
@@ -1,13 +1,26 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/google/uuid"
+	"github.com/justinas/alice"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"regexp"
 	"strings"
+	"time"
 )
 
+// rgxRequestID the X-Request-ID set by a proxy in front is kept if it's a sane one, it ends up in the logs
+var rgxRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
 func (s *Server) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Vary", "Authorization")
@@ -29,6 +42,10 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 			return
 		}
 		r = utility.ContextSetUser(r, usr)
+		if info := utility.ContextGetRequestInfo(r.Context()); info != nil {
+			info.UserID = usr.ID
+			info.Logger = info.Logger.With("userID", usr.ID, "sessionID", usr.SessionID)
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -55,6 +72,83 @@ func (s *Server) requireActivatedUser(next http.Handler) http.Handler {
 	})
 }
 
+// throttleAuth rate limits the requests per client IP & per the email in the body, if there is any,
+// so neither a single client nor many clients together can hammer the same account
+func (s *Server) throttleAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := s.authIPLimiters.allow(clientIP(r)); !ok {
+			s.rateLimitExceededResponse(w, r, retryAfter)
+			return
+		}
+		// peeking the email, the body is put back as is for the handler to read
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+		if err != nil {
+			s.badRequestResponse(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var input struct {
+			Email string `json:"email"`
+		}
+		if err = json.Unmarshal(body, &input); err == nil && input.Email != "" {
+			if ok, retryAfter := s.authEmailLimiters.allow(strings.ToLower(input.Email)); !ok {
+				s.rateLimitExceededResponse(w, r, retryAfter)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRequest assigns the request its ID, the X-Request-ID of the request if it's a valid one, sets the RequestInfo
+// in the context & access logs the request once served, it's the outermost middleware, so the requests rejected by
+// the others are logged too
+func (s *Server) logRequest(mux *http.ServeMux) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get("X-Request-ID")
+			if !rgxRequestID.MatchString(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set("X-Request-ID", id)
+			logger := slog.Default().With("requestID", id)
+			info := &utility.RequestInfo{ID: id, Logger: logger}
+			r = utility.ContextSetRequestInfo(r, info)
+			sr := newStatusRecorder(w)
+			next.ServeHTTP(sr, r)
+			_, route := mux.Handler(r)
+			// not the info.Logger, the userID would be there twice once authenticated
+			logger.Info("request",
+				"method", r.Method,
+				"route", route,
+				"status", sr.status,
+				"latency", time.Since(start),
+				"userID", info.UserID)
+		})
+	}
+}
+
+// instrument observes the latency & status of the requests, per route pattern of the mux, it's right after the
+// logRequest, so the requests rejected by the others are observed too
+func (s *Server) instrument(mux *http.ServeMux) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the pattern the mux sets is on the request as passed down by the other middlewares, not on this one
+			_, route := mux.Handler(r)
+			// the websocket lasts as long as the user is online, that's no request duration
+			if route == "/sub" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			sr := newStatusRecorder(w)
+			next.ServeHTTP(sr, r)
+			s.Metrics.HTTPRequest(route, sr.status, time.Since(start))
+		})
+	}
+}
+
 func (s *Server) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -66,3 +160,41 @@ func (s *Server) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// statusRecorder records the status of the response, the ResponseController & the websocket reach the
+// ResponseWriter it wraps through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// newStatusRecorder returns the w itself if it's already a statusRecorder
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	if sr, ok := w.(*statusRecorder); ok {
+		return sr
+	}
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if !sr.wroteHeader {
+		sr.status = code
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// Hijack is asserted by the websocket, it does not unwrap
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(sr.ResponseWriter).Hijack()
+}
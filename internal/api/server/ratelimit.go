@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+// keyedLimiter hands out a token bucket per key (e.g. the userID), so one busy key can't use up the quota of others
+type keyedLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*limiterEntry
+	limit    rate.Limit
+	burst    int
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newKeyedLimiter(limit rate.Limit, burst int) *keyedLimiter {
+	return &keyedLimiter{
+		limiters: make(map[string]*limiterEntry),
+		limit:    limit,
+		burst:    burst,
+	}
+}
+
+// get returns the bucket of the key, creating a full one if the key is new or was cleaned up
+func (kl *keyedLimiter) get(key string) *rate.Limiter {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	e, ok := kl.limiters[key]
+	if !ok {
+		e = &limiterEntry{limiter: rate.NewLimiter(kl.limit, kl.burst)}
+		kl.limiters[key] = e
+	}
+	e.lastSeen = time.Now()
+	return e.limiter
+}
+
+// allow takes a token from the bucket of the key, if there's none it returns the time to retry after
+func (kl *keyedLimiter) allow(key string) (bool, time.Duration) {
+	r := kl.get(key).Reserve()
+	if d := r.Delay(); d > 0 {
+		r.Cancel() // the request is rejected, so it must not use up the token
+		return false, d
+	}
+	return true, 0
+}
+
+// cleanup periodically drops the buckets not used for the idle duration, must be run as a background task
+func (kl *keyedLimiter) cleanup(shtdwnCtx context.Context, idle time.Duration) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			kl.mu.Lock()
+			for key, e := range kl.limiters {
+				if time.Since(e.lastSeen) > idle {
+					delete(kl.limiters, key)
+				}
+			}
+			kl.mu.Unlock()
+		case <-shtdwnCtx.Done():
+			return
+		}
+	}
+}
@@ -8,23 +8,71 @@ import (
 func (s *Server) routes() http.Handler {
 	mux := http.NewServeMux()
 	// Middlewares
-	base := alice.New(s.recoverPanic, s.authenticate)
+	base := alice.New(s.logRequest(mux), s.instrument(mux), s.recoverPanic, s.authenticate)
 	authenticated := alice.New(s.requireAuthenticatedUser)
 	protected := authenticated.Append(s.requireActivatedUser)
+	throttled := alice.New(s.throttleAuth)
 	// User Routes
 	mux.HandleFunc("POST /v1/users", s.RegisterUserHandler)
 	mux.Handle("GET /v1/users/{field}", authenticated.ThenFunc(s.GetByUniqueFieldHandler))
 	mux.Handle("GET /v1/users", authenticated.ThenFunc(s.SearchUserHandler))
 	mux.Handle("GET /v1/users/current", protected.ThenFunc(s.GetCurrentActiveUserHandler))
 	mux.Handle("PUT /v1/users", protected.ThenFunc(s.UpdateUserHandler))
-	mux.HandleFunc("POST /v1/users/activate", s.ActivateUserHandler)
+	mux.Handle("DELETE /v1/users", protected.ThenFunc(s.DeleteUserHandler))
+	mux.Handle("GET /v1/users/export", protected.ThenFunc(s.ExportUserHandler))
+	mux.Handle("POST /v1/users/activate", throttled.ThenFunc(s.ActivateUserHandler))
+	mux.Handle("PUT /v1/users/password", throttled.ThenFunc(s.ResetPasswordHandler))
+	mux.Handle("PUT /v1/users/current/key", protected.ThenFunc(s.SetUserKeyHandler))
+	mux.Handle("PUT /v1/users/current/email", protected.ThenFunc(s.ConfirmEmailChangeHandler))
+	mux.Handle("DELETE /v1/users/current/email", protected.ThenFunc(s.CancelEmailChangeHandler))
+	mux.Handle("GET /v1/users/current/totp", protected.ThenFunc(s.GetTOTPHandler))
+	mux.Handle("POST /v1/users/current/totp", protected.ThenFunc(s.EnrollTOTPHandler))
+	mux.Handle("PUT /v1/users/current/totp", protected.ThenFunc(s.ConfirmTOTPHandler))
+	mux.Handle("DELETE /v1/users/current/totp", protected.ThenFunc(s.DisableTOTPHandler))
+	mux.Handle("GET /v1/users/current/ssh-keys", protected.ThenFunc(s.GetSSHKeysHandler))
+	mux.Handle("POST /v1/users/current/ssh-keys", protected.ThenFunc(s.AddSSHKeyHandler))
+	mux.Handle("DELETE /v1/users/current/ssh-keys/{id}", protected.ThenFunc(s.DeleteSSHKeyHandler))
+	mux.Handle("GET /v1/users/current/digest", protected.ThenFunc(s.GetDigestHandler))
+	mux.Handle("PUT /v1/users/current/digest", protected.ThenFunc(s.SetDigestHandler))
+	mux.Handle("GET /v1/users/{userID}/key", protected.ThenFunc(s.GetUserKeyHandler))
+	mux.Handle("PUT /v1/users/{userID}/block", protected.ThenFunc(s.BlockUserHandler))
+	mux.Handle("DELETE /v1/users/{userID}/block", protected.ThenFunc(s.UnblockUserHandler))
+	mux.Handle("PUT /v1/users/{userID}/mute", protected.ThenFunc(s.MuteUserHandler))
+	mux.Handle("DELETE /v1/users/{userID}/mute", protected.ThenFunc(s.UnmuteUserHandler))
 	// Token Routes
-	mux.HandleFunc("POST /v1/tokens/otp", s.GenerateOTPHandler)
-	mux.HandleFunc("POST /v1/tokens/auth", s.GenerateAuthTokenHandler)
+	mux.Handle("POST /v1/tokens/otp", throttled.ThenFunc(s.GenerateOTPHandler))
+	mux.Handle("POST /v1/tokens/auth", throttled.ThenFunc(s.GenerateAuthTokenHandler))
+	mux.Handle("POST /v1/tokens/auth/2fa", throttled.ThenFunc(s.CompleteTwoFactorAuthHandler))
+	mux.Handle("POST /v1/tokens/ssh", throttled.ThenFunc(s.GenerateSSHChallengeHandler))
+	mux.Handle("POST /v1/tokens/ssh/verify", throttled.ThenFunc(s.GenerateSSHAuthTokenHandler))
+	// not throttled, the refresh tokens can't be guessed & every client refreshes every few minutes
+	mux.HandleFunc("POST /v1/tokens/refresh", s.RefreshAuthTokenHandler)
+	mux.Handle("POST /v1/tokens/password-reset", throttled.ThenFunc(s.GeneratePasswordResetOTPHandler))
+	mux.Handle("GET /v1/tokens", protected.ThenFunc(s.GetSessionsHandler))
+	mux.Handle("DELETE /v1/tokens/{id}", protected.ThenFunc(s.RevokeSessionHandler))
 	// Conversation Routes
 	mux.Handle("GET /v1/conversations", protected.ThenFunc(s.GetConversationsHandler))
+	mux.Handle("GET /v1/conversations/{userID}/messages", protected.ThenFunc(s.GetMessageHistoryHandler))
+	mux.Handle("POST /v1/conversations/{userID}/accept", protected.ThenFunc(s.AcceptConversationRequestHandler))
+	mux.Handle("DELETE /v1/conversations/{userID}/request", protected.ThenFunc(s.DeclineConversationRequestHandler))
+	// Group Routes
+	mux.Handle("POST /v1/groups", protected.ThenFunc(s.CreateGroupHandler))
+	mux.Handle("GET /v1/groups/{groupID}/members", protected.ThenFunc(s.GetGroupMembersHandler))
+	mux.Handle("POST /v1/groups/{groupID}/members", protected.ThenFunc(s.AddGroupMemberHandler))
+	mux.Handle("DELETE /v1/groups/{groupID}/members/{userID}", protected.ThenFunc(s.RemoveGroupMemberHandler))
+	mux.Handle("DELETE /v1/groups/{groupID}/members/current", protected.ThenFunc(s.LeaveGroupHandler))
+	// Attachment Routes
+	mux.Handle("POST /v1/attachments", protected.ThenFunc(s.UploadAttachmentHandler))
+	mux.Handle("GET /v1/attachments/{attachmentID}", protected.ThenFunc(s.DownloadAttachmentHandler))
 	// Websocket Routes
 	mux.Handle("/sub", protected.ThenFunc(s.WebsocketSubscribeHandler))
 
 	return base.Then(mux)
 }
+
+// adminRoutes are served on the admin port, not to be exposed publicly
+func (s *Server) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", s.Metrics)
+	return mux
+}
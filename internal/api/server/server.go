@@ -5,16 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/api/facade"
+	"github.com/M0hammadUsman/letschat/internal/api/hub"
+	"github.com/M0hammadUsman/letschat/internal/api/metrics"
 	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/common"
-	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/coder/websocket"
 	"golang.org/x/time/rate"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 )
@@ -23,26 +23,38 @@ type Server struct {
 	Config                  *utility.Config
 	BackgroundTask          *common.BackgroundTask
 	Facade                  *facade.Facade
+	Hub                     hub.Hub
+	Metrics                 *metrics.Metrics
 	wsAcceptOpts            *websocket.AcceptOptions
 	subscriberMessageBuffer int
-	publishLimiter          *rate.Limiter
-
-	SubsMu      sync.Mutex
-	Subscribers map[string]*domain.User
+	// each user (all of its devices together) gets its own bucket for the msgs it sends over the websocket
+	sendLimiters *keyedLimiter
+	// the auth endpoints are throttled per client IP & per target email
+	authIPLimiters    *keyedLimiter
+	authEmailLimiters *keyedLimiter
 }
 
-func NewServer(cfg *utility.Config, bt *common.BackgroundTask, facade *facade.Facade) *Server {
+func NewServer(
+	cfg *utility.Config,
+	bt *common.BackgroundTask,
+	facade *facade.Facade,
+	hub hub.Hub,
+	metrics *metrics.Metrics,
+) *Server {
 	return &Server{
 		Config:         cfg,
 		BackgroundTask: bt,
 		Facade:         facade,
+		Hub:            hub,
+		Metrics:        metrics,
 		wsAcceptOpts: &websocket.AcceptOptions{
 			CompressionMode:    websocket.CompressionContextTakeover,
 			InsecureSkipVerify: true,
 		},
-		subscriberMessageBuffer: 16,
-		publishLimiter:          rate.NewLimiter(rate.Limit(100*time.Millisecond), 10),
-		Subscribers:             make(map[string]*domain.User), // keys are userID
+		subscriberMessageBuffer: cfg.WsBuffer,
+		sendLimiters:            newKeyedLimiter(rate.Limit(cfg.WsLimiter.RPS), cfg.WsLimiter.Burst),
+		authIPLimiters:          newKeyedLimiter(rate.Limit(cfg.Auth.RPS), cfg.Auth.Burst),
+		authEmailLimiters:       newKeyedLimiter(rate.Limit(cfg.Auth.RPS), cfg.Auth.Burst),
 	}
 }
 
@@ -50,9 +62,9 @@ func (s *Server) Serve() error {
 	srv := &http.Server{
 		Addr:         fmt.Sprint(":", s.Config.Port),
 		Handler:      s.routes(),
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 6 * time.Second,
-		IdleTimeout:  time.Minute,
+		ReadTimeout:  s.Config.HTTP.ReadTimeout,
+		WriteTimeout: s.Config.HTTP.WriteTimeout,
+		IdleTimeout:  s.Config.HTTP.IdleTimeout,
 	}
 	shutdownErr := make(chan error)
 	go func() {
@@ -60,7 +72,7 @@ func (s *Server) Serve() error {
 		signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 		sig := <-quit
 		slog.Info("shutting down server", "signal", sig.String())
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), s.Config.HTTP.ShutdownTimeout)
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil {
 			slog.Error(err.Error())
@@ -69,18 +81,34 @@ func (s *Server) Serve() error {
 			shutdownErr <- nil
 		}
 	}()
+	s.BackgroundTask.Run(s.Hub.Run)
+	s.BackgroundTask.Run(func(shtdwnCtx context.Context) {
+		s.sendLimiters.cleanup(shtdwnCtx, 3*time.Minute)
+	})
+	s.BackgroundTask.Run(func(shtdwnCtx context.Context) {
+		s.authIPLimiters.cleanup(shtdwnCtx, 3*time.Minute)
+	})
+	s.BackgroundTask.Run(func(shtdwnCtx context.Context) {
+		s.authEmailLimiters.cleanup(shtdwnCtx, 3*time.Minute)
+	})
+	if s.Config.AdminPort > 0 {
+		s.BackgroundTask.Run(s.serveAdmin)
+	}
+	if s.Config.Digest.After > 0 && s.Config.Digest.Interval > 0 {
+		s.BackgroundTask.Run(s.sendDigests)
+	}
 	slog.Info("starting server", "addr", srv.Addr)
 	err := srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	} else {
-		slog.Info("waiting for ongoing http requests", "max wait", "5 sec...")
+		slog.Info("waiting for ongoing http requests", "max wait", s.Config.HTTP.ShutdownTimeout.String())
 	}
 	if err = <-shutdownErr; err != nil {
 		return err
 	}
 	slog.Info("server down, waiting for background tasks to gracefully shutdown",
-		"tasks", s.BackgroundTask.Tasks,
+		"tasks", s.BackgroundTask.Tasks(),
 		"max wait", "5 sec...")
 	if err = s.BackgroundTask.Shutdown(6 * time.Second); err != nil {
 		slog.Warn(err.Error())
@@ -89,11 +117,57 @@ func (s *Server) Serve() error {
 	return nil
 }
 
+// serveAdmin serves the metrics on the admin port, till the shutdown
+func (s *Server) serveAdmin(shtdwnCtx context.Context) {
+	srv := &http.Server{
+		Addr:         fmt.Sprint(":", s.Config.AdminPort),
+		Handler:      s.adminRoutes(),
+		ReadTimeout:  s.Config.HTTP.ReadTimeout,
+		WriteTimeout: s.Config.HTTP.WriteTimeout,
+		IdleTimeout:  s.Config.HTTP.IdleTimeout,
+	}
+	go func() {
+		<-shtdwnCtx.Done()
+		srv.Close() // nothing worth waiting for, a scrape missed is of no harm
+	}()
+	slog.Info("starting admin server", "addr", srv.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error(err.Error())
+	}
+}
+
+// sendDigests mails the digests due every Digest.Interval, till the shutdown
+func (s *Server) sendDigests(shtdwnCtx context.Context) {
+	ticker := time.NewTicker(s.Config.Digest.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-shtdwnCtx.Done():
+			return
+		case <-ticker.C:
+			sent, err := s.Facade.SendDigests(shtdwnCtx, s.Config.Digest.After)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error(err.Error())
+			}
+			if sent > 0 {
+				slog.Info("mailed digests", "count", sent)
+			}
+		}
+	}
+}
+
 func (s *Server) ShutdownCleanup() {
 	s.BackgroundTask.Run(func(shtdwnCtx context.Context) {
 		<-shtdwnCtx.Done()
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
+		lastNode, err := s.Hub.Close(ctx)
+		if err != nil {
+			slog.Error(err.Error())
+		}
+		if !lastNode { // users online on the other nodes must stay online
+			return
+		}
 		for range 5 { // 5 reties if something gets wrong
 			if err := s.Facade.SetOnlineUsersLastSeen(ctx); err == nil {
 				break
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"github.com/M0hammadUsman/letschat/internal/api/facade"
+	"github.com/M0hammadUsman/letschat/internal/api/hub"
+	"github.com/M0hammadUsman/letschat/internal/api/mailer"
+	"github.com/M0hammadUsman/letschat/internal/api/metrics"
+	"github.com/M0hammadUsman/letschat/internal/api/repository"
+	"github.com/M0hammadUsman/letschat/internal/api/service"
+	"github.com/M0hammadUsman/letschat/internal/api/storage"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/common"
+	"github.com/google/uuid"
+	"os"
+	"testing"
+	"time"
+)
+
+// The tests needing the DB run against the migrated DB of the LETSCHAT_TEST_DB_DSN, they're skipped if it's not set
+
+// newTestConfig returns the config the test servers are started with, to be adjusted by the tests
+func newTestConfig() *utility.Config {
+	cfg := &utility.Config{ENV: "test", Hub: "memory", WsBuffer: 64}
+	cfg.DB.DSN = os.Getenv("LETSCHAT_TEST_DB_DSN")
+	cfg.DB.MaxOpenConn = 10
+	cfg.DB.MaxIdleConn = 10
+	cfg.WsLimiter.RPS = 10
+	cfg.WsLimiter.Burst = 10
+	cfg.Auth.RPS = 10
+	cfg.Auth.Burst = 10
+	cfg.Auth.MaxFailedAttempts = 5
+	cfg.Auth.FailedAttemptsWindow = time.Minute
+	cfg.Auth.Lockout = time.Minute
+	cfg.Auth.TokenSecret = "test-secret"
+	cfg.Auth.AccessTTL = time.Minute
+	cfg.Auth.RefreshTTL = time.Hour
+	cfg.Auth.OTPTTL = 15 * time.Minute
+	cfg.Auth.TwoFactorTTL = 5 * time.Minute
+	cfg.Auth.SSHChallengeTTL = 2 * time.Minute
+	cfg.Attachments.Storage = "disk"
+	cfg.Attachments.MaxSize = 1 << 20
+	return cfg
+}
+
+// newTestServer wires the server the same way the API does, along with the DB it's using, the hub of the cfg is
+// run till the test is done
+func newTestServer(t *testing.T, cfg *utility.Config) (*Server, *repository.DB) {
+	t.Helper()
+	if cfg.DB.DSN == "" {
+		t.Skip("LETSCHAT_TEST_DB_DSN not set")
+	}
+	cfg.Attachments.Dir = t.TempDir()
+	db := repository.OpenDB(cfg)
+	t.Cleanup(func() { db.Close() })
+	bgTask := common.NewBackgroundTask()
+	mtrcs := metrics.New(bgTask)
+	txMan := mtrcs.InstrumentTX(db)
+	mailr := mailer.NewLogMailer(false)
+	store, err := storage.New(cfg.Attachments.Storage, cfg.Attachments.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := service.New(
+		service.NewUserService(
+			repository.NewUserRepository(db),
+			cfg.Auth.MaxFailedAttempts,
+			cfg.Auth.FailedAttemptsWindow,
+			cfg.Auth.Lockout,
+		),
+		service.NewTokenService(repository.NewTokenRepository(db), cfg.Auth.TokenSecret, service.TokenTTLs{
+			Access:       cfg.Auth.AccessTTL,
+			Refresh:      cfg.Auth.RefreshTTL,
+			OTP:          cfg.Auth.OTPTTL,
+			TwoFactor:    cfg.Auth.TwoFactorTTL,
+			SSHChallenge: cfg.Auth.SSHChallengeTTL,
+		}),
+		service.NewMessageService(repository.NewMessageRepository(db), cfg.MsgHistory),
+		service.NewConversationService(repository.NewConversationRepository(db)),
+		service.NewGroupService(repository.NewGroupRepository(db)),
+		service.NewAttachmentService(repository.NewAttachmentRepository(db), store, cfg.Attachments.MaxSize),
+	)
+	fac := facade.New(
+		facade.NewUserFacade(srv, txMan, mailr, bgTask),
+		facade.NewTokenFacade(srv, txMan, mailr, bgTask),
+		facade.NewMessageFacade(srv, txMan, bgTask),
+		facade.NewConversationFacade(srv),
+		facade.NewGroupFacade(srv, txMan),
+		facade.NewAttachmentFacade(srv),
+	)
+	h, err := hub.New(cfg.Hub, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go h.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		h.Close(context.Background())
+	})
+	return NewServer(cfg, bgTask, fac, h, mtrcs), db
+}
+
+// insertTestUser inserts an activated user, deleted along with its msgs & conversations once the test is done
+func insertTestUser(t *testing.T, db *repository.DB) string {
+	t.Helper()
+	var id string
+	query := `
+		INSERT INTO users (name, email, password, activated)
+		VALUES ('server test', $1, '\x00', TRUE)
+		RETURNING id
+		`
+	if err := db.Get(&id, query, uuid.NewString()+"@server.test"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM message WHERE sender_id = $1 OR receiver_id = $1`, id)
+		db.Exec(`DELETE FROM conversation WHERE sender_id = $1 OR receiver_id = $1`, id)
+		db.Exec(`DELETE FROM users WHERE id = $1`, id)
+	})
+	return id
+}
+
+// insertTestContacts makes the users contacts, i.e. an accepted conversation between them
+func insertTestContacts(t *testing.T, db *repository.DB, senderID, receiverID string) {
+	t.Helper()
+	query := `
+		INSERT INTO conversation (sender_id, receiver_id, accepted)
+		VALUES ($1, $2, TRUE)
+		`
+	if _, err := db.Exec(query, senderID, receiverID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"net/http"
+	"time"
 )
 
 func (s *Server) GenerateOTPHandler(w http.ResponseWriter, r *http.Request) {
@@ -29,24 +32,205 @@ func (s *Server) GenerateOTPHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (s *Server) GeneratePasswordResetOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	if err := s.Facade.GeneratePasswordResetOTP(r.Context(), input.Email); err != nil {
+		var ev *domain.ErrValidation
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
 func (s *Server) GenerateAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
 	var usr domain.UserAuth
 	if err := s.readJSON(w, r, &usr); err != nil {
 		s.badRequestResponse(w, r, err)
 		return
 	}
-	token, err := s.Facade.GenerateAuthToken(r.Context(), &usr)
+	usr.IP = clientIP(r)
+	tokens, err := s.Facade.GenerateAuthToken(r.Context(), &usr)
 	if err != nil {
 		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
+		var tfr *domain.ErrTwoFactorRequired
 		switch {
 		case errors.As(err, &ev):
 			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
+		case errors.As(err, &tfr):
+			// the password is right, the login is completed with the token & the second factor
+			if err = s.writeJSON(w, envelop{"twoFactorToken": tfr.Token}, http.StatusAccepted, nil); err != nil {
+				s.serverErrorResponse(w, r, err)
+			}
 		default:
 			s.serverErrorResponse(w, r, err)
 		}
 		return
 	}
-	if err = s.writeJSON(w, envelop{"token": token}, http.StatusOK, nil); err != nil {
+	if err = s.writeJSON(w, envelop{"tokens": tokens}, http.StatusOK, nil); err != nil {
 		s.serverErrorResponse(w, r, err)
 	}
 }
+
+// GenerateSSHChallengeHandler hands out the challenge to sign with the SSH key, to log in without the password
+func (s *Server) GenerateSSHChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.SSHChallengeRequest
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	challenge, err := s.Facade.GenerateSSHChallenge(r.Context(), &input)
+	if err != nil {
+		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	res := envelop{"challenge": challenge, "namespace": domain.SSHSignatureNamespace}
+	if err = s.writeJSON(w, res, http.StatusCreated, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) GenerateSSHAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.SSHAuth
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	input.IP = clientIP(r)
+	tokens, err := s.Facade.GenerateSSHAuthToken(r.Context(), &input)
+	if err != nil {
+		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
+		var tfr *domain.ErrTwoFactorRequired
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
+		case errors.As(err, &tfr):
+			if err = s.writeJSON(w, envelop{"twoFactorToken": tfr.Token}, http.StatusAccepted, nil); err != nil {
+				s.serverErrorResponse(w, r, err)
+			}
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"tokens": tokens}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) CompleteTwoFactorAuthHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.TwoFactorAuth
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	tokens, err := s.Facade.CompleteTwoFactorAuth(r.Context(), &input)
+	if err != nil {
+		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"tokens": tokens}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+// RefreshAuthTokenHandler swaps the refresh token for a new pair of tokens, a reused refresh token revokes its
+// session, closing its websocket connection, as either the client or someone who stole the token is an impostor
+func (s *Server) RefreshAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	tokens, err := s.Facade.RefreshAuthToken(r.Context(), input.RefreshToken)
+	if err != nil {
+		var ev *domain.ErrValidation
+		var reused *domain.ErrRefreshTokenReused
+		switch {
+		case errors.As(err, &ev):
+			s.invalidAuthenticationTokenResponse(w, r)
+		case errors.As(err, &reused):
+			s.publishSessionRevoked(r.Context(), reused.UserID, reused.SessionID)
+			s.invalidAuthenticationTokenResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"tokens": tokens}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) GetSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.Facade.GetSessions(r.Context())
+	if err != nil {
+		s.serverErrorResponse(w, r, err)
+		return
+	}
+	if err = s.writeJSON(w, envelop{"sessions": sessions}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+// RevokeSessionHandler logs the session out, its websocket connection (if any) is closed on whichever node it's on
+func (s *Server) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.Facade.RevokeSession(r.Context(), id); err != nil {
+		var ev *domain.ErrValidation
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			s.notFoundResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	s.publishSessionRevoked(r.Context(), utility.ContextGetUser(r.Context()).ID, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishSessionRevoked closes the websocket connection of the revoked session, on whichever node it's on
+func (s *Server) publishSessionRevoked(ctx context.Context, userID, sessionID string) {
+	t := time.Now()
+	msg := &domain.Message{SenderID: userID, Body: sessionID, SentAt: &t, Operation: domain.SessionRevokedMsg}
+	s.publish(ctx, userID, msg, nil)
+}
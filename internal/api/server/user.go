@@ -1,10 +1,12 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"net/http"
+	"time"
 )
 
 func (s *Server) RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -50,7 +52,8 @@ func (s *Server) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		s.badRequestResponse(w, r, err)
 		return
 	}
-	if err := s.Facade.UpdateUser(r.Context(), &userUpdate); err != nil {
+	emailPending, err := s.Facade.UpdateUser(r.Context(), &userUpdate)
+	if err != nil {
 		var ev *domain.ErrValidation
 		switch {
 		case errors.As(err, &ev):
@@ -63,24 +66,71 @@ func (s *Server) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// tell every user related to this updated user to sync their conversations
+	if err = s.syncConvos(r.Context()); err != nil {
+		s.serverErrorResponse(w, r, err)
+		return
+	}
+	if emailPending {
+		if err = s.writeJSON(w, envelop{"pendingEmail": userUpdate.Email}, http.StatusAccepted, nil); err != nil {
+			s.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+func (s *Server) ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.UserEmailChange
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	if err := s.Facade.ConfirmEmailChange(r.Context(), input.OTP); err != nil {
+		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
+		case errors.Is(err, domain.ErrEditConflict):
+			s.editConflictResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// the contacts see the email, same as for any other update
 	if err := s.syncConvos(r.Context()); err != nil {
 		s.serverErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) CancelEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.Facade.CancelEmailChange(r.Context()); err != nil {
+		s.serverErrorResponse(w, r, err)
+		return
 	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) ActivateUserHandler(w http.ResponseWriter, r *http.Request) {
 	var token struct {
-		OTP string `json:"otp"`
+		Email string `json:"email"`
+		OTP   string `json:"otp"`
 	}
 	if err := s.readJSON(w, r, &token); err != nil {
 		s.badRequestResponse(w, r, err)
 		return
 	}
-	if err := s.Facade.ActivateUser(r.Context(), token.OTP); err != nil {
+	if err := s.Facade.ActivateUser(r.Context(), token.Email, token.OTP); err != nil {
 		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
 		switch {
 		case errors.As(err, &ev):
 			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
 		case errors.Is(err, domain.ErrAlreadyActive):
 			s.alreadyActivatedResponse(w, r)
 		case errors.Is(err, domain.ErrEditConflict):
@@ -92,6 +142,242 @@ func (s *Server) ActivateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.UserPasswordReset
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	sessions, err := s.Facade.ResetPassword(r.Context(), &input)
+	if err != nil {
+		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
+		case errors.Is(err, domain.ErrEditConflict):
+			s.editConflictResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// whoever is logged in with the old password is logged out of the websocket as well
+	for _, session := range sessions {
+		s.publishSessionRevoked(r.Context(), session.UserID, session.ID)
+	}
+}
+
+func (s *Server) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.UserDelete
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	// fetched beforehand, the contacts & the group members are told to sync once the conversations are gone
+	convos, err := s.Facade.GetConversations(r.Context())
+	if err != nil {
+		s.serverErrorResponse(w, r, err)
+		return
+	}
+	sessions, err := s.Facade.DeleteUser(r.Context(), input.Password)
+	if err != nil {
+		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	s.publishSyncConvos(r.Context(), convos)
+	for _, convo := range convos {
+		if convo.IsGroup {
+			s.syncGroupMembers(r.Context(), convo.Members)
+		}
+	}
+	// the devices still connected are logged out, nothing is to be sent or received by the deleted user
+	for _, session := range sessions {
+		s.publishSessionRevoked(r.Context(), session.UserID, session.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) GetTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	enabled, err := s.Facade.IsTOTPEnabled(r.Context())
+	if err != nil {
+		s.serverErrorResponse(w, r, err)
+		return
+	}
+	if err = s.writeJSON(w, envelop{"totp": envelop{"enabled": enabled}}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+// EnrollTOTPHandler hands out a new secret, the second factor is only enabled once it's confirmed with a code
+func (s *Server) EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := s.Facade.EnrollTOTP(r.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTOTPEnabled):
+			s.totpEnabledResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"totp": enrollment}, http.StatusCreated, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	codes, err := s.Facade.ConfirmTOTP(r.Context(), input.Code)
+	if err != nil {
+		var ev *domain.ErrValidation
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.Is(err, domain.ErrTOTPEnabled):
+			s.totpEnabledResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"recoveryCodes": codes}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.UserDelete
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	if err := s.Facade.DisableTOTP(r.Context(), input.Password); err != nil {
+		var ev *domain.ErrValidation
+		var lo *domain.ErrLockedOut
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.As(err, &lo):
+			s.lockedOutResponse(w, r, lo.Until)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			s.notFoundResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) GetSSHKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.Facade.GetSSHKeys(r.Context())
+	if err != nil {
+		s.serverErrorResponse(w, r, err)
+		return
+	}
+	if err = s.writeJSON(w, envelop{"sshKeys": keys}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) AddSSHKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.SSHKeyAdd
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	key, err := s.Facade.AddSSHKey(r.Context(), &input)
+	if err != nil {
+		var ev *domain.ErrValidation
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"sshKey": key}, http.StatusCreated, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) DeleteSSHKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.Facade.DeleteSSHKey(r.Context(), r.PathValue("id")); err != nil {
+		var ev *domain.ErrValidation
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			s.notFoundResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) GetDigestHandler(w http.ResponseWriter, r *http.Request) {
+	enabled, err := s.Facade.IsDigestEnabled(r.Context())
+	if err != nil {
+		s.serverErrorResponse(w, r, err)
+		return
+	}
+	if err = s.writeJSON(w, envelop{"digest": domain.DigestSetting{Enabled: enabled}}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) SetDigestHandler(w http.ResponseWriter, r *http.Request) {
+	var input domain.DigestSetting
+	if err := s.readJSON(w, r, &input); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	if err := s.Facade.SetDigestEnabled(r.Context(), input.Enabled); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			s.invalidAuthenticationTokenResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) ExportUserHandler(w http.ResponseWriter, r *http.Request) {
+	export, err := s.Facade.ExportUser(r.Context())
+	if err != nil {
+		s.serverErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Content-Disposition", `attachment; filename="letschat-export.json"`)
+	if err = s.writeJSON(w, envelop{"export": export}, http.StatusOK, headers); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
 func (s *Server) SearchUserHandler(w http.ResponseWriter, r *http.Request) {
 	var filter domain.Filter
 	v := r.URL.Query()
@@ -117,8 +403,102 @@ func (s *Server) SearchUserHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) GetCurrentActiveUserHandler(w http.ResponseWriter, r *http.Request) {
-	u := utility.ContextGetUser(r.Context())
-	if err := s.writeJSON(w, envelop{"user": u}, http.StatusOK, nil); err != nil {
+	// the user in the context is only what the access token carries
+	u, err := s.Facade.GetByUniqueField(r.Context(), utility.ContextGetUser(r.Context()).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			s.invalidAuthenticationTokenResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"user": u}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) SetUserKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var keySet domain.UserKeySet
+	if err := s.readJSON(w, r, &keySet); err != nil {
+		s.badRequestResponse(w, r, err)
+		return
+	}
+	key, err := s.Facade.SetUserKey(r.Context(), &keySet)
+	if err != nil {
+		var ev *domain.ErrValidation
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"key": key}, http.StatusOK, nil); err != nil {
 		s.serverErrorResponse(w, r, err)
 	}
 }
+
+func (s *Server) GetUserKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key, err := s.Facade.GetUserKey(r.Context(), r.PathValue("userID"))
+	if err != nil {
+		var ev *domain.ErrValidation
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			s.notFoundResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if err = s.writeJSON(w, envelop{"key": key}, http.StatusOK, nil); err != nil {
+		s.serverErrorResponse(w, r, err)
+	}
+}
+
+func (s *Server) BlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.updateUserRelation(w, r, s.Facade.BlockUser)
+}
+
+func (s *Server) UnblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.updateUserRelation(w, r, s.Facade.UnblockUser)
+}
+
+func (s *Server) MuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.updateUserRelation(w, r, s.Facade.MuteUser)
+}
+
+func (s *Server) UnmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.updateUserRelation(w, r, s.Facade.UnmuteUser)
+}
+
+// Helpers & Stuff ----------------------------------------------------------------------------------------------------
+
+// updateUserRelation (un)blocks or (un)mutes the user in the path for the current user, whose devices are then told
+// to sync their conversations, as the conversation carries the blocked & muted flags
+func (s *Server) updateUserRelation(
+	w http.ResponseWriter,
+	r *http.Request,
+	update func(ctx context.Context, userID string) error,
+) {
+	if err := update(r.Context(), r.PathValue("userID")); err != nil {
+		var ev *domain.ErrValidation
+		switch {
+		case errors.As(err, &ev):
+			s.failedValidationResponse(w, r, ev.Errors)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			s.notFoundResponse(w, r)
+		default:
+			s.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	u := utility.ContextGetUser(r.Context())
+	t := time.Now()
+	s.publish(r.Context(), u.ID, &domain.Message{SenderID: u.ID, SentAt: &t, Operation: domain.SyncConvosMsg}, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
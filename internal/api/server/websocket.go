@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/coder/websocket"
@@ -10,36 +11,40 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 )
 
-var (
-	ErrAlreadySubscribed = errors.New("already subscribed")
-)
-
 func (s *Server) WebsocketSubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	// carries the requestID, userID & sessionID of the connection
+	logger := utility.ContextGetLogger(r.Context())
 	conn, err := s.subscribe(w, r)
 	if err != nil {
-		switch {
-		case errors.Is(err, ErrAlreadySubscribed):
-			s.redundantSubscription(w, r)
-		default:
-			slog.Error(err.Error())
-		}
+		logger.Error(err.Error())
 		return
 	}
 	u := utility.ContextGetUser(r.Context())
 
-	s.addSubscriber(u)
-	if err = s.Facade.UpdateUserOnlineStatus(r.Context(), u, true); err != nil {
-		s.editConflictResponse(w, r)
+	// only the first connection of the user changes its online status, other devices just join in
+	first, err := s.Hub.Subscribe(r.Context(), u)
+	if err != nil {
+		logger.Error(err.Error())
+		conn.Close(websocket.StatusTryAgainLater, "unable to subscribe")
 		return
 	}
-	if err = s.broadcastUserOnlineStatus(r.Context(), u, true); err != nil {
-		s.serverErrorResponse(w, r, err)
-		return
+	if first {
+		if err = s.Facade.UpdateUserOnlineStatus(r.Context(), u, true); err != nil {
+			s.unsubscribe(r.Context(), u)
+			conn.Close(websocket.StatusTryAgainLater, "unable to update online status")
+			return
+		}
+		if err = s.broadcastUserOnlineStatus(r.Context(), u, true); err != nil {
+			logger.Error(err.Error())
+		}
 	}
+	s.Metrics.Subscribers.Inc()
+	defer s.WebsocketSubscribeHandlerDeferFunc(r.Context(), conn)
 
 	// buffered because if there's any error, just return, don't want the other writes to block
 	errChan := make(chan error, 1) // if there is a single err we log and return
@@ -53,10 +58,9 @@ func (s *Server) WebsocketSubscribeHandler(w http.ResponseWriter, r *http.Reques
 	})
 
 	if err = s.Facade.WriteUnDeliveredMessagesToWSConn(r.Context(), u.Messages); err != nil {
-		slog.Error(err.Error())
+		logger.Error(err.Error())
 		return
 	}
-	defer s.WebsocketSubscribeHandlerDeferFunc(r.Context(), conn)
 
 	if err = <-errChan; err != nil {
 		// Once there is an error from one of the background tasks,
@@ -70,16 +74,21 @@ func (s *Server) WebsocketSubscribeHandler(w http.ResponseWriter, r *http.Reques
 			errors.Is(err, context.Canceled) {
 			return
 		}
-		slog.Error(err.Error())
+		logger.Error(err.Error())
 	}
 }
 
-// WebsocketSubscribeHandlerDeferFunc sets the user's LastOnline to time.Now
+// WebsocketSubscribeHandlerDeferFunc removes the connection from the subscribers, once the last connection
+// of the user is closed, it broadcasts the user as offline & sets the user's LastOnline to time.Now
 func (s *Server) WebsocketSubscribeHandlerDeferFunc(reqCtx context.Context, conn *websocket.Conn) {
 	u := utility.ContextGetUser(reqCtx)
-	s.broadcastUserOnlineStatus(reqCtx, u, false)
-	s.removeSubscriber(u)
+	s.Metrics.Subscribers.Dec()
+	last := s.unsubscribe(reqCtx, u)
 	conn.CloseNow()
+	if !last { // user is still online from some other device
+		return
+	}
+	s.broadcastUserOnlineStatus(reqCtx, u, false)
 	for range 5 { // Very unlikely to fail
 		if err := s.Facade.UpdateUserOnlineStatus(reqCtx, u, false); err == nil { // successful case
 			break
@@ -91,12 +100,12 @@ func (s *Server) subscribe(w http.ResponseWriter, r *http.Request) (*websocket.C
 	var mu sync.Mutex
 	var conn *websocket.Conn
 
-	u := utility.ContextGetUser(r.Context()) // User will be authenticated and setup in the context using middleware
-	if _, ok := s.Subscribers[u.ID]; ok {    // multiple online instances of the account are not allowed by design
-		return nil, ErrAlreadySubscribed
-	}
+	// User will be authenticated and setup in the context using middleware, every request gets its own *domain.User
+	// so each connection (device) of the same account has its own Messages chan
+	u := utility.ContextGetUser(r.Context())
 	u.Messages = make(chan *domain.Message, s.subscriberMessageBuffer)
 	u.CloseSlow = func() {
+		s.Metrics.SlowDisconnects.Inc()
 		mu.Lock()
 		defer mu.Unlock()
 		if conn != nil {
@@ -120,11 +129,17 @@ func (s *Server) handleReceivedMessages(shutdownCtx, reqCtx context.Context, con
 	for {
 		select {
 		case msg := <-u.Messages:
-			if s.publishLimiter.Allow() {
-				if err := writeWithTimeout(conn, 2*time.Second, msg); err != nil {
-					slog.Error(err.Error())
-					return err
+			if msg.Operation == domain.SessionRevokedMsg {
+				if msg.Body == u.SessionID {
+					conn.Close(websocket.StatusPolicyViolation, "session revoked")
+					return nil
 				}
+				continue // the other sessions of the user carry on
+			}
+			// no pacing here, a connection that can't keep up fills its buffer & is closed by the hub as slow
+			if err := writeWithTimeout(conn, 2*time.Second, msg); err != nil {
+				utility.ContextGetLogger(reqCtx).Error(err.Error())
+				return err
 			}
 		case <-reqCtx.Done():
 			return nil
@@ -136,59 +151,97 @@ func (s *Server) handleReceivedMessages(shutdownCtx, reqCtx context.Context, con
 
 func (s *Server) handleSentMessages(shutdownCtx, reqCtx context.Context, conn *websocket.Conn) error {
 	u := utility.ContextGetUser(reqCtx)
+	throttled := false
 	for {
 		var ms domain.MessageSent
 		// read will immediately errors out once the client shuts the Ws connection
 		if err := wsjson.Read(shutdownCtx, conn, &ms); err != nil {
 			return err
 		}
-		// ProcessSentMessage populate the domain.Message and also concurrently persist it to DB with 5 retries
-		msg, convoCreated, err := s.Facade.ProcessSentMessage(reqCtx, ms, u)
+		// over the quota the msg is deferred, never dropped, the reads are paused meanwhile so the client slows down too,
+		// the bucket is looked up per msg, as that keeps it from being cleaned up while the connection is in use
+		if r := s.sendLimiters.get(u.ID).Reserve(); r.Delay() > 0 {
+			s.Metrics.MsgsDeferred.Inc()
+			if !throttled {
+				throttled = true
+				handleRateLimitExceeded(conn, r.Delay())
+			}
+			t := time.NewTimer(r.Delay())
+			select {
+			case <-t.C:
+			case <-reqCtx.Done():
+				t.Stop()
+				return reqCtx.Err()
+			case <-shutdownCtx.Done():
+				t.Stop()
+				return shutdownCtx.Err()
+			}
+		} else {
+			throttled = false
+		}
+		// ProcessSentMessage populate the domain.Message and also concurrently persist it to DB with 5 retries,
+		// there is a msg per member for the msgs fanned out to a group
+		msgs, convoCreated, err := s.Facade.ProcessSentMessage(reqCtx, ms, u)
 		if err != nil {
-			if errors.Is(err, domain.ErrValidation{}) {
+			var ev *domain.ErrValidation
+			if errors.As(err, &ev) {
 				handleValidationError(conn, err)
 			} else {
 				return err
 			}
 			continue
 		}
-		if relayTo, ok := s.Subscribers[ms.ReceiverID]; ok {
-			// we do not want to send msg, these Ops are only for ack to server
-			if msg.Operation == domain.DeliveredConfirmMsg ||
-				msg.Operation == domain.ReadConfirmMsg ||
-				msg.Operation == domain.DeleteConfirmMsg {
-				continue
+		s.Metrics.MsgProcessed(ms.Operation)
+		// we do not want to send msg, these Ops are only for ack to server
+		if len(msgs) == 0 ||
+			ms.Operation == domain.DeliveredConfirmMsg ||
+			ms.Operation == domain.ReadConfirmMsg ||
+			ms.Operation == domain.DeleteConfirmMsg ||
+			ms.Operation == domain.EditConfirmMsg ||
+			ms.Operation == domain.ReactConfirmMsg {
+			continue
+		}
+		for _, msg := range msgs {
+			s.publish(reqCtx, msg.ReceiverID, msg, nil)
+		}
+		// keeping the sender's other devices in sync with what has been done from this one
+		if ms.Operation == domain.CreateMsg ||
+			ms.Operation == domain.ReadMsg ||
+			ms.Operation == domain.DeleteMsg ||
+			ms.Operation == domain.EditMsg ||
+			ms.Operation == domain.ReactMsg ||
+			ms.Operation == domain.UnreactMsg {
+			mirror := *msgs[0]
+			if ms.IsFannedOut() { // addressed to the group, as it was sent
+				mirror.ReceiverID = *mirror.ConversationID
 			}
-			select {
-			case relayTo.Messages <- msg:
-				if convoCreated {
-					if err = s.syncConvos(reqCtx); err != nil {
-						slog.Error(err.Error())
-						return err
-					}
-				}
-			case <-shutdownCtx.Done():
-				return nil
-			case <-reqCtx.Done():
-				return nil
-			default:
-				u.CloseSlow()
-				return nil
+			s.publish(reqCtx, u.ID, &mirror, u)
+		}
+		if convoCreated {
+			if err = s.syncConvos(reqCtx); err != nil {
+				utility.ContextGetLogger(reqCtx).Error(err.Error())
+				return err
 			}
 		}
 	}
 }
 
-func (s *Server) addSubscriber(u *domain.User) {
-	s.SubsMu.Lock()
-	s.Subscribers[u.ID] = u
-	s.SubsMu.Unlock()
+// unsubscribe removes the connection of the user from the hub, returns true if it was the last connection of the user,
+// if the hub fails to tell, the user is considered still online somewhere
+func (s *Server) unsubscribe(ctx context.Context, u *domain.User) bool {
+	last, err := s.Hub.Unsubscribe(ctx, u)
+	if err != nil {
+		utility.ContextGetLogger(ctx).Error(err.Error())
+		return false
+	}
+	return last
 }
 
-func (s *Server) removeSubscriber(u *domain.User) {
-	s.SubsMu.Lock()
-	delete(s.Subscribers, u.ID)
-	s.SubsMu.Unlock()
+// publish fans out the msg to every connection of the user (on any node), except the given one (may be nil)
+func (s *Server) publish(ctx context.Context, userID string, msg *domain.Message, except *domain.User) {
+	if err := s.Hub.Publish(ctx, userID, msg, except); err != nil {
+		utility.ContextGetLogger(ctx).Error(err.Error())
+	}
 }
 
 func (s *Server) broadcastUserOnlineStatus(ctx context.Context, u *domain.User, online bool) error {
@@ -196,8 +249,17 @@ func (s *Server) broadcastUserOnlineStatus(ctx context.Context, u *domain.User,
 	if err != nil {
 		return err
 	}
+	// the users who have blocked this one are not told of its presence
+	blockerIDs, err := s.Facade.GetBlockerIDs(ctx, u.ID)
+	if err != nil {
+		return err
+	}
 	for _, convo := range convos {
-		if convo.LastOnline != nil { // meaning the user is not online
+		if convo.IsGroup || convo.LastOnline != nil { // meaning the user is not online
+			continue
+		}
+		// neither are the senders of the msg requests yet to be accepted
+		if slices.Contains(blockerIDs, convo.UserID) || convo.Request {
 			continue
 		}
 		t := time.Now()
@@ -210,9 +272,7 @@ func (s *Server) broadcastUserOnlineStatus(ctx context.Context, u *domain.User,
 			SentAt:    &t,
 			Operation: op,
 		}
-		if v, ok := s.Subscribers[convo.UserID]; ok {
-			v.Messages <- &msg
-		}
+		s.publish(ctx, convo.UserID, &msg, nil)
 	}
 	return nil
 }
@@ -232,3 +292,13 @@ func handleValidationError(conn *websocket.Conn, err error) {
 		}
 	}
 }
+
+// handleRateLimitExceeded tells the client, in the same shape as the validation errors, that its msgs are being deferred
+func handleRateLimitExceeded(conn *websocket.Conn, delay time.Duration) {
+	errs := map[string]string{
+		"rate": fmt.Sprintf("limit exceeded, messages are being deferred by %v", delay.Round(time.Millisecond)),
+	}
+	if err := writeWithTimeout(conn, 5*time.Second, errs); err != nil {
+		slog.Error(err.Error())
+	}
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/api/hub"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// notifyingHub tells of the users once they're subscribed, so the msgs aren't published before
+type notifyingHub struct {
+	hub.Hub
+	subscribed chan string
+}
+
+func (h *notifyingHub) Subscribe(ctx context.Context, u *domain.User) (bool, error) {
+	first, err := h.Hub.Subscribe(ctx, u)
+	h.subscribed <- u.ID
+	return first, err
+}
+
+// dialTestWebsocket connects the user to the websocket of the server, the authentication is skipped
+func dialTestWebsocket(t *testing.T, s *Server, userID string) *websocket.Conn {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := &domain.User{ID: userID, SessionID: uuid.NewString(), Activated: true}
+		s.WebsocketSubscribeHandler(w, utility.ContextSetUser(r, u))
+	}))
+	t.Cleanup(ts.Close)
+	conn, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn
+}
+
+// newTestMsg returns a new 1:1 msg, the server relays the body as the ciphertext it's flagged as
+func newTestMsg(receiverID, body string) domain.MessageSent {
+	now := time.Now()
+	id := uuid.NewString()
+	return domain.MessageSent{
+		ID:         &id,
+		ReceiverID: receiverID,
+		Body:       &body,
+		Encrypted:  true,
+		SentAt:     &now,
+		Operation:  domain.CreateMsg,
+	}
+}
+
+func TestWebsocketDefersMsgsOverTheQuota(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.WsLimiter.RPS = 5
+	cfg.WsLimiter.Burst = 3
+	s, db := newTestServer(t, cfg)
+	subscribed := make(chan string, 2)
+	s.Hub = &notifyingHub{Hub: s.Hub, subscribed: subscribed}
+	senderID, receiverID := insertTestUser(t, db), insertTestUser(t, db)
+	insertTestContacts(t, db, senderID, receiverID)
+
+	receiver := dialTestWebsocket(t, s, receiverID)
+	sender := dialTestWebsocket(t, s, senderID)
+	for range 2 {
+		select {
+		case <-subscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the users to subscribe")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	const count = 8 // well over the burst, so the last ones are deferred
+	for i := range count {
+		if err := wsjson.Write(ctx, sender, newTestMsg(receiverID, fmt.Sprint("msg ", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the sender is told once that its msgs are being deferred
+	for {
+		var frame map[string]any
+		if err := wsjson.Read(ctx, sender, &frame); err != nil {
+			t.Fatalf("reading the rate limit error: %v", err)
+		}
+		if rate, ok := frame["rate"].(string); ok {
+			if !strings.Contains(rate, "deferred") {
+				t.Fatalf("unexpected rate limit error: %q", rate)
+			}
+			break
+		}
+	}
+	// every msg is still delivered, in the order sent
+	for i := 0; i < count; {
+		var msg domain.Message
+		if err := wsjson.Read(ctx, receiver, &msg); err != nil {
+			t.Fatalf("got %v msgs, reading the next: %v", i, err)
+		}
+		if msg.Operation != domain.CreateMsg {
+			continue // presence & the like
+		}
+		if want := fmt.Sprint("msg ", i); msg.Body != want {
+			t.Fatalf("msg %v: got body %q, want %q", i, msg.Body, want)
+		}
+		i++
+	}
+	rec := httptest.NewRecorder()
+	s.Metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(rec.Body.String(), "\nletschat_ws_messages_deferred_total 0\n") {
+		t.Fatal("no msgs counted as deferred")
+	}
+}
+
+func TestWebsocketMsgsAcrossTwoServers(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Hub = "postgres"
+	serverA, db := newTestServer(t, cfg)
+	cfgB := newTestConfig()
+	cfgB.Hub = "postgres"
+	serverB, _ := newTestServer(t, cfgB)
+	subscribed := make(chan string, 2)
+	serverA.Hub = &notifyingHub{Hub: serverA.Hub, subscribed: subscribed}
+	serverB.Hub = &notifyingHub{Hub: serverB.Hub, subscribed: subscribed}
+	senderID, receiverID := insertTestUser(t, db), insertTestUser(t, db)
+	insertTestContacts(t, db, senderID, receiverID)
+
+	receiver := dialTestWebsocket(t, serverB, receiverID)
+	sender := dialTestWebsocket(t, serverA, senderID)
+	for range 2 {
+		select {
+		case <-subscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the users to subscribe")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// the msgs notified before the serverB is listening are lost, so typing till one gets through
+	typed := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			wsjson.Write(ctx, sender, domain.MessageSent{ReceiverID: receiverID, Operation: domain.TypingMsg})
+			select {
+			case <-typed:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	readUntil := func(op domain.MsgOperation) *domain.Message {
+		for {
+			var msg domain.Message
+			if err := wsjson.Read(ctx, receiver, &msg); err != nil {
+				t.Fatalf("reading the msg of the operation %v: %v", op, err)
+			}
+			if msg.Operation == op {
+				return &msg
+			}
+		}
+	}
+	readUntil(domain.TypingMsg)
+	close(typed)
+
+	ms := newTestMsg(receiverID, "across the servers")
+	if err := wsjson.Write(ctx, sender, ms); err != nil {
+		t.Fatal(err)
+	}
+	if got := readUntil(domain.CreateMsg); got.ID != *ms.ID || got.Body != *ms.Body || got.SenderID != senderID {
+		t.Fatalf("got msg %v with body %q from %v, want %v with body %q from %v",
+			got.ID, got.Body, got.SenderID, *ms.ID, *ms.Body, senderID)
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+)
+
+var _ domain.AttachmentService = (*AttachmentService)(nil)
+
+type AttachmentService struct {
+	attachmentRepository domain.AttachmentRepository
+	storage              domain.AttachmentStorage
+	maxSize              int64
+}
+
+func NewAttachmentService(ar domain.AttachmentRepository, st domain.AttachmentStorage, maxSize int64) *AttachmentService {
+	return &AttachmentService{
+		attachmentRepository: ar,
+		storage:              st,
+		maxSize:              maxSize,
+	}
+}
+
+// UploadAttachment stores the content & then its metadata, the content is hashed while it's being stored,
+// returns domain.ErrAttachmentTooLarge if the content is larger than the max size
+func (s *AttachmentService) UploadAttachment(
+	ctx context.Context,
+	name, contentType string,
+	r io.Reader,
+) (*domain.Attachment, error) {
+	ev := domain.NewErrValidation()
+	if domain.ValidateAttachmentName(name, ev); ev.HasErrors() {
+		return nil, ev
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	a := &domain.Attachment{
+		ID:          uuid.New().String(),
+		UploaderID:  utility.ContextGetUser(ctx).ID,
+		Name:        name,
+		ContentType: contentType,
+	}
+	h := sha256.New()
+	// reading a byte more than allowed, so we can tell if the content is larger
+	size, err := s.storage.Put(ctx, a.ID, io.TeeReader(io.LimitReader(r, s.maxSize+1), h))
+	if err == nil && size > s.maxSize {
+		err = domain.ErrAttachmentTooLarge
+	}
+	if err == nil {
+		a.Size = size
+		a.SHA256 = hex.EncodeToString(h.Sum(nil))
+		err = s.attachmentRepository.InsertAttachment(ctx, a)
+	}
+	if err != nil {
+		if delErr := s.storage.Delete(context.WithoutCancel(ctx), a.ID); delErr != nil {
+			slog.Error(delErr.Error())
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// OpenAttachment returns domain.ErrRecordNotFound to the users it's not shared with, so they can't tell it exists
+func (s *AttachmentService) OpenAttachment(ctx context.Context, id string) (*domain.Attachment, io.ReadCloser, error) {
+	ev := domain.NewErrValidation()
+	if domain.ValidateUUID(id, ev, "attachmentID"); ev.HasErrors() {
+		return nil, nil, ev
+	}
+	ok, err := s.attachmentRepository.HasAttachmentAccess(ctx, id, utility.ContextGetUser(ctx).ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, domain.ErrRecordNotFound
+	}
+	a, err := s.attachmentRepository.GetAttachment(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.storage.Open(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, content, nil
+}
+
+func (s *AttachmentService) ShareAttachment(ctx context.Context, id, senderID string, receiverIDs ...string) error {
+	ok, err := s.attachmentRepository.HasAttachmentAccess(ctx, id, senderID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		ev := domain.NewErrValidation()
+		ev.AddError("attachmentID", "must be an attachment you have access to")
+		return ev
+	}
+	for _, rcvrID := range receiverIDs {
+		if err = s.attachmentRepository.InsertAttachmentGrant(ctx, id, rcvrID); err != nil {
+			if errors.Is(err, domain.ErrRecordNotFound) {
+				continue // the receiver is gone, the msg won't be delivered anyway
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *AttachmentService) GetUploadedAttachmentIDs(ctx context.Context, userID string) ([]string, error) {
+	return s.attachmentRepository.GetUploadedAttachmentIDs(ctx, userID)
+}
+
+func (s *AttachmentService) DeleteAttachmentContents(ctx context.Context, ids ...string) error {
+	var errs []error
+	for _, id := range ids {
+		errs = append(errs, s.storage.Delete(ctx, id))
+	}
+	return errors.Join(errs...)
+}
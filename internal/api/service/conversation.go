@@ -31,3 +31,30 @@ func (s *ConversationService) GetConversations(ctx context.Context) ([]*domain.C
 func (s *ConversationService) ConversationExists(ctx context.Context, senderID, receiverID string) (bool, error) {
 	return s.conversationRepository.ConversationExists(ctx, senderID, receiverID)
 }
+
+// AcceptConversationRequest accepts the msg request sent by the user with the senderID to the user in the context
+func (s *ConversationService) AcceptConversationRequest(ctx context.Context, senderID string) error {
+	ev := domain.NewErrValidation()
+	if domain.ValidateUUID(senderID, ev, "userID"); ev.HasErrors() {
+		return ev
+	}
+	return s.conversationRepository.AcceptConversation(ctx, senderID, utility.ContextGetUser(ctx).ID)
+}
+
+// DeclineConversationRequest removes the msg request, the sender may send a new one, unless blocked
+func (s *ConversationService) DeclineConversationRequest(ctx context.Context, senderID string) error {
+	ev := domain.NewErrValidation()
+	if domain.ValidateUUID(senderID, ev, "userID"); ev.HasErrors() {
+		return ev
+	}
+	return s.conversationRepository.DeletePendingConversation(ctx, senderID, utility.ContextGetUser(ctx).ID)
+}
+
+// IsConversationRequest tells whether there is a msg request from the sender, yet to be accepted by the receiver
+func (s *ConversationService) IsConversationRequest(ctx context.Context, senderID, receiverID string) (bool, error) {
+	return s.conversationRepository.PendingConversationExists(ctx, senderID, receiverID)
+}
+
+func (s *ConversationService) DeleteAllConversationsForUser(ctx context.Context, usrID string) error {
+	return s.conversationRepository.DeleteAllConversationsForUser(ctx, usrID)
+}
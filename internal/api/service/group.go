@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"slices"
+)
+
+var _ domain.GroupService = (*GroupService)(nil)
+
+type GroupService struct {
+	groupRepository domain.GroupRepository
+}
+
+func NewGroupService(gr domain.GroupRepository) *GroupService {
+	return &GroupService{groupRepository: gr}
+}
+
+// CreateGroup creates the group with the user in the context as its admin, the members who aren't contacts of the
+// user are only invited, expected to be run in a TX
+func (s *GroupService) CreateGroup(ctx context.Context, g *domain.GroupCreate) (*domain.Group, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateGroupName(g.Name, ev)
+	domain.ValidateGroupMembers(g.Members, ev)
+	if ev.HasErrors() {
+		return nil, ev
+	}
+	usr := utility.ContextGetUser(ctx)
+	group := &domain.Group{Name: g.Name, CreatedBy: usr.ID}
+	groupID, err := s.groupRepository.InsertGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.groupRepository.InsertGroupMember(ctx, groupID, usr.ID, domain.GroupRoleAdmin, usr.ID); err != nil {
+		return nil, err
+	}
+	slices.Sort(g.Members)
+	for _, memberID := range slices.Compact(g.Members) {
+		if memberID == usr.ID {
+			continue
+		}
+		if err = s.groupRepository.InsertGroupMember(ctx, groupID, memberID, domain.GroupRoleMember, usr.ID); err != nil {
+			if errors.Is(err, domain.ErrRecordNotFound) {
+				ev.AddError("members", "must only contain existing users")
+				return nil, ev
+			}
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// AddGroupMember adds the user to the group or updates the role if already a member, only admins are allowed to,
+// the user is only invited if not a contact of the admin
+func (s *GroupService) AddGroupMember(ctx context.Context, groupID string, m *domain.GroupMemberAdd) error {
+	if m.Role == "" {
+		m.Role = domain.GroupRoleMember
+	}
+	ev := domain.NewErrValidation()
+	domain.ValidateUUID(groupID, ev, "groupID")
+	domain.ValidateUUID(m.UserID, ev, "userID")
+	domain.ValidateGroupRole(m.Role, ev)
+	if ev.HasErrors() {
+		return ev
+	}
+	if err := s.requireAdmin(ctx, groupID); err != nil {
+		return err
+	}
+	usr := utility.ContextGetUser(ctx)
+	if err := s.groupRepository.InsertGroupMember(ctx, groupID, m.UserID, m.Role, usr.ID); err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("userID", "not exists")
+			return ev
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveGroupMember removes the user from the group, admins may remove anyone, others can only remove themselves.
+// Once the last admin is gone the oldest member is promoted & once the last member is gone the group is deleted,
+// expected to be run in a TX
+func (s *GroupService) RemoveGroupMember(ctx context.Context, groupID, userID string) error {
+	ev := domain.NewErrValidation()
+	domain.ValidateUUID(groupID, ev, "groupID")
+	domain.ValidateUUID(userID, ev, "userID")
+	if ev.HasErrors() {
+		return ev
+	}
+	if usr := utility.ContextGetUser(ctx); usr.ID != userID {
+		if err := s.requireAdmin(ctx, groupID); err != nil {
+			return err
+		}
+	}
+	if err := s.groupRepository.DeleteGroupMember(ctx, groupID, userID); err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return domain.ErrNotGroupMember
+		}
+		return err
+	}
+	members, err := s.groupRepository.GetGroupMembers(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return s.groupRepository.DeleteGroup(ctx, groupID)
+	}
+	return s.groupRepository.PromoteOldestGroupMember(ctx, groupID)
+}
+
+func (s *GroupService) LeaveGroup(ctx context.Context, groupID string) error {
+	return s.RemoveGroupMember(ctx, groupID, utility.ContextGetUser(ctx).ID)
+}
+
+// GetGroupMembers returns the members of the group, the user in the context must be one of them or invited to it
+func (s *GroupService) GetGroupMembers(ctx context.Context, groupID string) ([]*domain.GroupMember, error) {
+	ev := domain.NewErrValidation()
+	if domain.ValidateUUID(groupID, ev, "groupID"); ev.HasErrors() {
+		return nil, ev
+	}
+	members, err := s.groupRepository.GetGroupMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	usr := utility.ContextGetUser(ctx)
+	if slices.ContainsFunc(members, func(m *domain.GroupMember) bool { return m.UserID == usr.ID }) {
+		return members, nil
+	}
+	invited, err := s.groupRepository.PendingGroupMemberExists(ctx, groupID, usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !invited {
+		return nil, domain.ErrNotGroupMember
+	}
+	return members, nil
+}
+
+// AcceptGroupInvite makes the user in the context a member of the group it's been invited to
+func (s *GroupService) AcceptGroupInvite(ctx context.Context, groupID string) error {
+	ev := domain.NewErrValidation()
+	if domain.ValidateUUID(groupID, ev, "groupID"); ev.HasErrors() {
+		return ev
+	}
+	return s.groupRepository.AcceptGroupMember(ctx, groupID, utility.ContextGetUser(ctx).ID)
+}
+
+// DeclineGroupInvite removes the invite of the user in the context, the admins may invite again
+func (s *GroupService) DeclineGroupInvite(ctx context.Context, groupID string) error {
+	ev := domain.NewErrValidation()
+	if domain.ValidateUUID(groupID, ev, "groupID"); ev.HasErrors() {
+		return ev
+	}
+	return s.groupRepository.DeletePendingGroupMember(ctx, groupID, utility.ContextGetUser(ctx).ID)
+}
+
+func (s *GroupService) IsGroupMember(ctx context.Context, groupID, userID string) (bool, error) {
+	if _, err := s.groupRepository.GetGroupMember(ctx, groupID, userID); err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *GroupService) requireAdmin(ctx context.Context, groupID string) error {
+	member, err := s.groupRepository.GetGroupMember(ctx, groupID, utility.ContextGetUser(ctx).ID)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return domain.ErrNotGroupMember
+		}
+		return err
+	}
+	if member.Role != domain.GroupRoleAdmin {
+		return domain.ErrNotGroupAdmin
+	}
+	return nil
+}
@@ -2,28 +2,36 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/google/uuid"
+	"time"
 )
 
 type MessageService struct {
 	messageRepo domain.MessageRepository
+	// if set, msgs are also kept in the history after being delivered, so the clients can fetch them later
+	history bool
 }
 
-func NewMessageService(messageRepo domain.MessageRepository) *MessageService {
-	return &MessageService{messageRepo}
+func NewMessageService(messageRepo domain.MessageRepository, history bool) *MessageService {
+	return &MessageService{messageRepo, history}
 }
 
 func (*MessageService) PopulateMessage(m domain.MessageSent, sndr *domain.User) *domain.Message {
 	msg := &domain.Message{
-		SenderID:    sndr.ID,
-		ReceiverID:  m.ReceiverID,
-		SentAt:      m.SentAt,
-		DeliveredAt: m.DeliveredAt,
-		ReadAt:      m.ReadAt,
-		Operation:   m.Operation,
+		SenderID:       sndr.ID,
+		ReceiverID:     m.ReceiverID,
+		ConversationID: m.ConversationID,
+		ReplyToID:      m.ReplyToID,
+		AttachmentID:   m.AttachmentID,
+		Encrypted:      m.Encrypted,
+		SentAt:         m.SentAt,
+		DeliveredAt:    m.DeliveredAt,
+		ReadAt:         m.ReadAt,
+		Operation:      m.Operation,
 	}
 	if m.ID != nil {
 		msg.ID = *m.ID
@@ -42,26 +50,54 @@ func (s *MessageService) ProcessSentMessages(ctx context.Context, m *domain.Mess
 	switch m.Operation {
 
 	case domain.CreateMsg:
+		if err := s.saveToHistory(ctx, m); err != nil {
+			return err
+		}
 		return s.messageRepo.InsertMessage(ctx, m)
 
 	// these OPs cases will delete msgs with specified Ops, CreateMsg, DeliveredMsg, Any Op
 	case domain.DeliveredMsg, domain.ReadMsg, domain.DeleteMsg:
 		if m.Operation == domain.ReadMsg {
-			msg, _ := s.messageRepo.GetByID(ctx, m.ID, domain.DeliveredMsg)
+			msg, _ := s.messageRepo.GetByID(ctx, m.ID, m.SenderID, m.ReceiverID, domain.DeliveredMsg)
 			if msg != nil {
 				// if the sender is offline and the msg is delivered & read in that case, also persist deliveredAt field
 				m.DeliveredAt = msg.DeliveredAt
 			}
 		}
-		if err := s.messageRepo.DeleteMessage(ctx, m.ID); err != nil {
+		if err := s.saveToHistory(ctx, m); err != nil {
+			return err
+		}
+		if err := s.messageRepo.DeleteMessage(ctx, m.ID, m.SenderID, m.ReceiverID); err != nil {
+			return err
+		}
+		return s.messageRepo.InsertMessage(ctx, m)
+
+	case domain.EditMsg:
+		if err := s.saveToHistory(ctx, m); err != nil {
+			return err
+		}
+		// the receiver is yet to get the msg, so it'll just get the latest revision
+		pending, err := s.messageRepo.GetByID(ctx, m.ID, m.SenderID, m.ReceiverID, domain.CreateMsg)
+		if err == nil && pending.SenderID == m.SenderID {
+			pending.Body = m.Body
+			pending.Encrypted = m.Encrypted
+			return s.messageRepo.InsertMessage(ctx, pending)
+		}
+		if err = s.messageRepo.DeleteMessage(ctx, m.ID, m.SenderID, m.ReceiverID); err != nil {
 			return err
 		}
 		return s.messageRepo.InsertMessage(ctx, m)
 
+	case domain.ReactMsg, domain.UnreactMsg:
+		return s.messageRepo.UpsertReaction(ctx, m)
+
+	case domain.ReactConfirmMsg:
+		return s.messageRepo.DeleteReaction(ctx, m.ID, m.SenderID, m.ReceiverID, m.Body)
+
 	// these OPs are not for persistence, but merely a confirmation to ensure robustness
-	// these OPs cases will delete msgs with specified Ops, DeliveredMsg, ReadMsg, DeleteMsg
-	case domain.DeliveredConfirmMsg, domain.ReadConfirmMsg, domain.DeleteConfirmMsg:
-		return s.messageRepo.DeleteMessage(ctx, m.ID)
+	// these OPs cases will delete msgs with specified Ops, DeliveredMsg, ReadMsg, DeleteMsg, EditMsg
+	case domain.DeliveredConfirmMsg, domain.ReadConfirmMsg, domain.DeleteConfirmMsg, domain.EditConfirmMsg:
+		return s.messageRepo.DeleteMessage(ctx, m.ID, m.SenderID, m.ReceiverID)
 
 	// these Ops will be processed directly if the appropriate party(sender/receiver) is online
 	case domain.OnlineMsg, domain.OfflineMsg, domain.TypingMsg:
@@ -75,16 +111,111 @@ func (s *MessageService) ProcessSentMessages(ctx context.Context, m *domain.Mess
 func (s *MessageService) GetUnDeliveredMessages(ctx context.Context, c domain.MsgChan) error {
 	u := utility.ContextGetUser(ctx)
 	// the order matters here
-	ops := []domain.MsgOperation{domain.DeleteMsg, domain.DeliveredMsg, domain.ReadMsg, domain.CreateMsg}
+	ops := []domain.MsgOperation{domain.DeleteMsg, domain.DeliveredMsg, domain.ReadMsg, domain.CreateMsg, domain.EditMsg}
 	for _, op := range ops {
 		// this directly writes to the msg chan
 		if err := s.messageRepo.GetUnDeliveredMessages(ctx, u.ID, op, c); err != nil {
 			return err
 		}
 	}
+	// reactions last, as they're made on the msgs above
+	return s.messageRepo.GetUnDeliveredReactions(ctx, u.ID, c)
+}
+
+func (s *MessageService) GetPendingMessages(ctx context.Context) ([]*domain.Message, error) {
+	return s.messageRepo.GetPendingMessages(ctx, utility.ContextGetUser(ctx).ID)
+}
+
+func (s *MessageService) DeleteAllMessagesForUser(ctx context.Context, usrID string) error {
+	return s.messageRepo.DeleteAllMessagesForUser(ctx, usrID)
+}
+
+func (s *MessageService) GetDueDigests(
+	ctx context.Context,
+	olderThan time.Duration,
+	limit int,
+) ([]*domain.Digest, error) {
+	return s.messageRepo.GetDueDigests(ctx, olderThan, limit)
+}
+
+// FanOutMessage copies the group msg for every member other than the sender, each addressed to the member
+func (*MessageService) FanOutMessage(m *domain.Message, memberIDs []string) []*domain.Message {
+	msgs := make([]*domain.Message, 0, len(memberIDs))
+	for _, memberID := range memberIDs {
+		if memberID == m.SenderID {
+			continue
+		}
+		msg := *m
+		msg.ReceiverID = memberID
+		msgs = append(msgs, &msg)
+	}
+	return msgs
+}
+
+// RecordMessageSender keeps the sender of the new msg, for its edits to be validated against, even once delivered
+func (s *MessageService) RecordMessageSender(ctx context.Context, m *domain.Message) error {
+	return s.messageRepo.InsertMessageSender(ctx, m.ID, m.SenderID)
+}
+
+// ValidateMessageEdit ensures only the original sender edits the msg, the sender is kept after the msg is delivered,
+// so the msgs the server doesn't know the sender of were never sent & are rejected
+func (s *MessageService) ValidateMessageEdit(ctx context.Context, m *domain.Message) error {
+	sndrID, err := s.messageRepo.GetMessageSenderID(ctx, m.ID)
+	ev := domain.NewErrValidation()
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("id", "must be a msg sent by you to be edited")
+			return ev
+		}
+		return err
+	}
+	domain.ValidateMessageEditor(ev, sndrID, m.SenderID)
+	if ev.HasErrors() {
+		return ev
+	}
 	return nil
 }
 
+func (s *MessageService) GetMessageHistory(
+	ctx context.Context,
+	withUsrID string,
+	cursor *domain.MessageCursor,
+	pageSize int,
+) ([]*domain.Message, *domain.MessageCursor, error) {
+	if !s.history {
+		return nil, nil, domain.ErrNoMsgHistory
+	}
+	u := utility.ContextGetUser(ctx)
+	msgs, err := s.messageRepo.GetMessageHistory(ctx, u.ID, withUsrID, cursor, pageSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a short page means there is nothing more to fetch
+	if len(msgs) < pageSize {
+		return msgs, nil, nil
+	}
+	return msgs, domain.CursorFromMessage(msgs[len(msgs)-1]), nil
+}
+
+// saveToHistory mirrors the msg ops onto the history, NOOP if the history is not enabled
+func (s *MessageService) saveToHistory(ctx context.Context, m *domain.Message) error {
+	if !s.history {
+		return nil
+	}
+	switch m.Operation {
+	case domain.CreateMsg:
+		return s.messageRepo.InsertMessageHistory(ctx, m)
+	case domain.DeliveredMsg, domain.ReadMsg:
+		return s.messageRepo.UpdateMessageHistory(ctx, m)
+	case domain.DeleteMsg:
+		return s.messageRepo.DeleteMessageHistory(ctx, m)
+	case domain.EditMsg:
+		return s.messageRepo.EditMessageHistory(ctx, m)
+	default:
+		return nil
+	}
+}
+
 func (s *MessageService) SaveMessage(ctx context.Context, m *domain.Message) error {
 	return s.messageRepo.InsertMessage(ctx, m)
 }
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/google/uuid"
+	"testing"
+)
+
+// senderRepository keeps the senders of the msgs in memory, the rest of the domain.MessageRepository is left nil
+type senderRepository struct {
+	domain.MessageRepository
+	senders map[string]string
+}
+
+func (r *senderRepository) InsertMessageSender(_ context.Context, id, senderID string) error {
+	if _, ok := r.senders[id]; !ok {
+		r.senders[id] = senderID
+	}
+	return nil
+}
+
+func (r *senderRepository) GetMessageSenderID(_ context.Context, id string) (string, error) {
+	senderID, ok := r.senders[id]
+	if !ok {
+		return "", domain.ErrRecordNotFound
+	}
+	return senderID, nil
+}
+
+func TestOnlyTheSenderEditsTheMsg(t *testing.T) {
+	ctx := context.Background()
+	// without the history, so the msg is no longer held once delivered
+	s := NewMessageService(&senderRepository{senders: make(map[string]string)}, false)
+	sender, other := uuid.NewString(), uuid.NewString()
+	msg := &domain.Message{ID: uuid.NewString(), SenderID: sender, Operation: domain.CreateMsg}
+	if err := s.RecordMessageSender(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	// a msg sent again with the ID doesn't take it over
+	if err := s.RecordMessageSender(ctx, &domain.Message{ID: msg.ID, SenderID: other}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateMessageEdit(ctx, &domain.Message{ID: msg.ID, SenderID: sender}); err != nil {
+		t.Fatalf("edit by the sender: %v", err)
+	}
+	for name, edit := range map[string]*domain.Message{
+		"by someone else":   {ID: msg.ID, SenderID: other},
+		"of an unknown msg": {ID: uuid.NewString(), SenderID: sender},
+	} {
+		var ev *domain.ErrValidation
+		if err := s.ValidateMessageEdit(ctx, edit); !errors.As(err, &ev) || ev.Errors["id"] == "" {
+			t.Errorf("edit %v: got %v, want it rejected", name, err)
+		}
+	}
+}
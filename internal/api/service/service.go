@@ -9,16 +9,22 @@ type Service struct {
 	domain.TokenService
 	domain.MessageService
 	domain.ConversationService
+	domain.GroupService
+	domain.AttachmentService
 }
 
 func New(us domain.UserService,
 	ts domain.TokenService,
 	ms domain.MessageService,
-	cs domain.ConversationService) *Service {
+	cs domain.ConversationService,
+	gs domain.GroupService,
+	as domain.AttachmentService) *Service {
 	return &Service{
 		UserService:         us,
 		TokenService:        ts,
 		MessageService:      ms,
 		ConversationService: cs,
+		GroupService:        gs,
+		AttachmentService:   as,
 	}
 }
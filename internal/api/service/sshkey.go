@@ -0,0 +1,48 @@
+package service
+
+import (
+	"crypto/rsa"
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"golang.org/x/crypto/ssh"
+	"strings"
+)
+
+// minRSABits the RSA keys shorter than this are refused, as is ssh-keygen by default
+const minRSABits = 2048
+
+var errWeakSSHKey = errors.New("ssh key not accepted")
+
+// parseSSHPublicKey parses the key in the authorized_keys format, the DSA keys, the short RSA keys & the
+// certificates are not accepted
+func parseSSHPublicKey(authorizedKey string) (ssh.PublicKey, error) {
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(authorizedKey)))
+	if err != nil {
+		return nil, err
+	}
+	switch pubKey.Type() {
+	case ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		return pubKey, nil
+	case ssh.KeyAlgoRSA:
+		cryptoKey, ok := pubKey.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, errWeakSSHKey
+		}
+		rsaKey, ok := cryptoKey.CryptoPublicKey().(*rsa.PublicKey)
+		if !ok || rsaKey.N.BitLen() < minRSABits {
+			return nil, errWeakSSHKey
+		}
+		return pubKey, nil
+	default:
+		return nil, errWeakSSHKey
+	}
+}
+
+// verifySSHSignature checks the signature of the namespaced challenge, the SHA1 RSA signatures are refused
+func verifySSHSignature(pubKey ssh.PublicKey, challenge string, sig domain.SSHSignature) bool {
+	if sig.Format == ssh.KeyAlgoRSA {
+		return false
+	}
+	data := []byte(domain.SSHSignatureNamespace + challenge)
+	return pubKey.Verify(data, &ssh.Signature{Format: sig.Format, Blob: sig.Blob}) == nil
+}
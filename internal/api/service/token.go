@@ -2,12 +2,20 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
 	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/google/uuid"
+	"log/slog"
 	"math/big"
+	"strings"
 	"time"
 )
 
@@ -15,21 +23,57 @@ var _ domain.TokenService = (*TokenService)(nil)
 
 type TokenService struct {
 	tokenRepo domain.TokenRepository
+	// signs the access tokens, has to be the same on every node
+	secret []byte
+	ttls   TokenTTLs
 }
 
-func NewTokenService(tokenRepo domain.TokenRepository) *TokenService {
-	return &TokenService{tokenRepo: tokenRepo}
+// TokenTTLs are how long the tokens of each kind are valid for
+type TokenTTLs struct {
+	Access time.Duration
+	// Refresh is the TTL of the refresh tokens, a session expires unless refreshed within it
+	Refresh time.Duration
+	// OTP is the TTL of the mailed activation, password reset & email change OTPs
+	OTP          time.Duration
+	TwoFactor    time.Duration
+	SSHChallenge time.Duration
+}
+
+// accessClaims is the payload of the access token, signed with HMAC-SHA256
+type accessClaims struct {
+	UserID    string `json:"sub"`
+	SessionID string `json:"sid"`
+	Expiry    int64  `json:"exp"` // unix seconds
+}
+
+// NewTokenService with an empty secret signs the access tokens with a random one, they are then neither valid
+// across the restarts nor on the other nodes
+func NewTokenService(
+	tokenRepo domain.TokenRepository,
+	secret string,
+	ttls TokenTTLs,
+) *TokenService {
+	key := []byte(secret)
+	if len(key) == 0 {
+		slog.Warn("no auth token secret is set, signing the access tokens with a random one")
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &TokenService{tokenRepo: tokenRepo, secret: key, ttls: ttls}
 }
 
 // GenerateToken generates OTP if scope is ScopeActivation & AuthenticationToken if scope is ScopeAuthentication
+// or ScopeSSHChallenge
 func (s *TokenService) GenerateToken(ctx context.Context, userID string, scope string) (string, error) {
 	token := new(domain.Token)
 	var err error
 	switch scope {
-	case domain.ScopeActivation:
-		token, err = generateOTP(userID, scope, domain.ScopeActivationTTL)
+	case domain.ScopeActivation, domain.ScopePasswordReset, domain.ScopeEmailChange:
+		token, err = generateOTP(userID, scope, s.ttls.OTP)
 	case domain.ScopeAuthentication:
-		token, err = generateAuthToken(userID, scope, domain.ScopeAuthenticationTTL)
+		token, err = generateAuthToken(userID, scope, s.ttls.Refresh)
+	case domain.ScopeSSHChallenge:
+		token, err = generateAuthToken(userID, scope, s.ttls.SSHChallenge)
 	default:
 		panic("invalid token scope")
 	}
@@ -42,12 +86,182 @@ func (s *TokenService) GenerateToken(ctx context.Context, userID string, scope s
 	return token.PlainText, nil
 }
 
+func (s *TokenService) OTPTTL() time.Duration {
+	return s.ttls.OTP
+}
+
 func (s *TokenService) DeleteAllForUser(ctx context.Context, userID string, scope string) error {
 	return s.tokenRepo.DeleteAllForUser(ctx, userID, scope)
 }
 
+func (s *TokenService) GenerateSessionToken(ctx context.Context, userID, device, ip string) (*domain.Token, error) {
+	token, err := generateAuthToken(userID, domain.ScopeAuthentication, s.ttls.Refresh)
+	if err != nil {
+		return nil, fmt.Errorf("error generating token: %w", err)
+	}
+	token.Device = device
+	token.IP = ip
+	if err = s.tokenRepo.Insert(ctx, token); err != nil {
+		return nil, fmt.Errorf("error inserting token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *TokenService) GenerateTwoFactorToken(ctx context.Context, userID, device, ip string) (string, error) {
+	token, err := generateAuthToken(userID, domain.ScopeTwoFactor, s.ttls.TwoFactor)
+	if err != nil {
+		return "", fmt.Errorf("error generating token: %w", err)
+	}
+	token.Device = device
+	token.IP = ip
+	if err = s.tokenRepo.Insert(ctx, token); err != nil {
+		return "", fmt.Errorf("error inserting token: %w", err)
+	}
+	return token.PlainText, nil
+}
+
+func (s *TokenService) GetTwoFactorToken(ctx context.Context, plainToken string) (*domain.Token, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateTwoFactorToken(plainToken, ev)
+	if ev.HasErrors() {
+		return nil, ev
+	}
+	hash := sha256.Sum256([]byte(plainToken))
+	token, err := s.tokenRepo.GetByHash(ctx, domain.ScopeTwoFactor, hash[:])
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("twoFactorToken", "invalid or expired")
+			return nil, ev
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *TokenService) ConsumeSSHChallenge(ctx context.Context, plainToken string) (*domain.Token, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateSSHChallenge(plainToken, ev)
+	if ev.HasErrors() {
+		return nil, ev
+	}
+	hash := sha256.Sum256([]byte(plainToken))
+	token, err := s.tokenRepo.DeleteByHash(ctx, domain.ScopeSSHChallenge, hash[:])
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("challenge", "invalid or expired")
+			return nil, ev
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *TokenService) DeleteToken(ctx context.Context, id string) error {
+	return s.tokenRepo.Delete(ctx, id)
+}
+
+func (s *TokenService) RotateRefreshToken(ctx context.Context, plainToken string) (*domain.Token, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateRefreshToken(plainToken, ev)
+	if ev.HasErrors() {
+		return nil, ev
+	}
+	oldHash := sha256.Sum256([]byte(plainToken))
+	token, err := generateAuthToken("", domain.ScopeAuthentication, s.ttls.Refresh)
+	if err != nil {
+		return nil, fmt.Errorf("error generating token: %w", err)
+	}
+	err = s.tokenRepo.RotateRefreshToken(ctx, oldHash[:], token)
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, err
+	}
+	// either it's expired, revoked or never existed, or it was rotated out & someone is using it again
+	revoked, err := s.tokenRepo.DeleteRotatedSession(ctx, oldHash[:])
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("refreshToken", "invalid or expired")
+			return nil, ev
+		}
+		return nil, err
+	}
+	return nil, &domain.ErrRefreshTokenReused{UserID: revoked.UserID, SessionID: revoked.ID}
+}
+
+func (s *TokenService) GenerateAccessToken(userID, sessionID string) (string, time.Duration, error) {
+	claims := accessClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Expiry:    time.Now().Add(s.ttls.Access).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", 0, err
+	}
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(payload)
+	return signed + "." + enc.EncodeToString(s.sign(signed)), s.ttls.Access, nil
+}
+
+func (s *TokenService) VerifyAccessToken(token string) (*domain.User, error) {
+	ev := domain.NewErrValidation()
+	signed, sig, ok := strings.Cut(token, ".")
+	enc := base64.RawURLEncoding
+	decodedSig, err := enc.DecodeString(sig)
+	if !ok || err != nil || !hmac.Equal(decodedSig, s.sign(signed)) {
+		ev.AddError("token", "invalid")
+		return nil, ev
+	}
+	payload, err := enc.DecodeString(signed)
+	if err != nil {
+		ev.AddError("token", "invalid")
+		return nil, ev
+	}
+	var claims accessClaims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		ev.AddError("token", "invalid")
+		return nil, ev
+	}
+	if time.Now().Unix() >= claims.Expiry {
+		ev.AddError("token", "expired")
+		return nil, ev
+	}
+	// only the activated users can log in, so the access tokens are only ever issued to them
+	return &domain.User{ID: claims.UserID, SessionID: claims.SessionID, Activated: true}, nil
+}
+
+func (s *TokenService) GetSessions(ctx context.Context) ([]*domain.Session, error) {
+	usr := utility.ContextGetUser(ctx)
+	sessions, err := s.tokenRepo.GetSessions(ctx, usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == usr.SessionID
+	}
+	return sessions, nil
+}
+
+func (s *TokenService) DeleteSession(ctx context.Context, id string) error {
+	ev := domain.NewErrValidation()
+	domain.ValidateUUID(id, ev, "id")
+	if ev.HasErrors() {
+		return ev
+	}
+	return s.tokenRepo.DeleteSession(ctx, id, utility.ContextGetUser(ctx).ID)
+}
+
+func (s *TokenService) sign(signed string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
 func generateOTP(userID, scope string, ttl time.Duration) (*domain.Token, error) {
 	token := &domain.Token{
+		ID:     uuid.NewString(),
 		UserID: userID,
 		Expiry: time.Now().Add(ttl),
 		Scope:  scope,
@@ -64,6 +278,7 @@ func generateOTP(userID, scope string, ttl time.Duration) (*domain.Token, error)
 
 func generateAuthToken(userID, scope string, ttl time.Duration) (*domain.Token, error) {
 	token := &domain.Token{
+		ID:     uuid.NewString(),
 		UserID: userID,
 		Expiry: time.Now().Add(ttl),
 		Scope:  scope,
@@ -77,3 +292,7 @@ func generateAuthToken(userID, scope string, ttl time.Duration) (*domain.Token,
 	token.Hash = hashArray[:]
 	return token, nil
 }
+
+func (s *TokenService) DeleteAllSessions(ctx context.Context, userID string) ([]*domain.Token, error) {
+	return s.tokenRepo.DeleteAllSessions(ctx, userID)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the TOTP of RFC 6238 with the defaults every authenticator app goes by, SHA1, 6 digits & 30 seconds steps
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func generateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, 20) // 160 bits, as recommended by RFC 4226
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// totpURI is the Key URI the authenticator apps scan from the QR code
+func totpURI(secret []byte, email string) string {
+	label := url.PathEscape(domain.TOTPIssuer + ":" + email)
+	params := url.Values{}
+	params.Set("secret", totpEncoding.EncodeToString(secret))
+	params.Set("issuer", domain.TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(domain.TOTPDigits))
+	params.Set("period", fmt.Sprint(int(domain.TOTPPeriod.Seconds())))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(domain.TOTPPeriod.Seconds())
+}
+
+// hotp is the HMAC-based OTP of RFC 4226 for the counter
+func hotp(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1_000_000)
+}
+
+// matchTOTP returns the step the code was generated for, it's only matched against the steps after the
+// lastUsedStep, within the skew of the step of t, so an accepted code can't be used again
+func matchTOTP(secret []byte, code string, t time.Time, lastUsedStep int64) (int64, bool) {
+	current := totpStep(t)
+	for step := current - domain.TOTPSkew; step <= current+domain.TOTPSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes returns the codes formatted as shown to the user along with the hashes to store
+func generateRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, domain.RecoveryCodeCount)
+	hashes := make([][]byte, domain.RecoveryCodeCount)
+	for i := range codes {
+		randBytes := make([]byte, 7)
+		if _, err := rand.Read(randBytes); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(randBytes))[:10] // 50 bits
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) []byte {
+	hash := sha256.Sum256([]byte(domain.NormalizeRecoveryCode(code)))
+	return hash[:]
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/api/utility"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// totpRepository keeps the TOTP & the recovery codes of a single user in memory, the rest of the
+// domain.UserRepository is left nil, as it's not used by the TOTP
+type totpRepository struct {
+	domain.UserRepository
+	user     *domain.User
+	totp     *domain.TOTP
+	recovery [][]byte
+	failed   int
+}
+
+func (r *totpRepository) GetByUniqueField(_ context.Context, _, _ string) (*domain.User, error) {
+	return r.user, nil
+}
+
+func (r *totpRepository) UpsertPendingTOTP(_ context.Context, userID string, secret []byte) error {
+	if r.totp != nil && r.totp.Enabled {
+		return domain.ErrTOTPEnabled
+	}
+	r.totp = &domain.TOTP{UserID: userID, Secret: secret}
+	return nil
+}
+
+func (r *totpRepository) GetTOTP(_ context.Context, _ string) (*domain.TOTP, error) {
+	if r.totp == nil {
+		return nil, domain.ErrRecordNotFound
+	}
+	totp := *r.totp
+	return &totp, nil
+}
+
+func (r *totpRepository) EnableTOTP(_ context.Context, _ string, step int64) error {
+	r.totp.Enabled = true
+	r.totp.LastUsedStep = step
+	return nil
+}
+
+func (r *totpRepository) SetTOTPLastUsedStep(_ context.Context, _ string, step int64) error {
+	if step <= r.totp.LastUsedStep {
+		return domain.ErrEditConflict
+	}
+	r.totp.LastUsedStep = step
+	return nil
+}
+
+func (r *totpRepository) DeleteTOTP(_ context.Context, _ string) error {
+	r.totp = nil
+	return nil
+}
+
+func (r *totpRepository) ReplaceRecoveryCodes(_ context.Context, _ string, hashes [][]byte) error {
+	r.recovery = hashes
+	return nil
+}
+
+func (r *totpRepository) DeleteRecoveryCode(_ context.Context, _ string, hash []byte) error {
+	i := slices.IndexFunc(r.recovery, func(h []byte) bool { return slices.Equal(h, hash) })
+	if i == -1 {
+		return domain.ErrRecordNotFound
+	}
+	r.recovery = slices.Delete(r.recovery, i, i+1)
+	return nil
+}
+
+func (r *totpRepository) GetLockedUntil(_ context.Context, _ string) (*time.Time, error) {
+	return nil, nil
+}
+
+func (r *totpRepository) InsertFailedAttempt(_ context.Context, _ string, _ int, _, _ time.Duration) (*time.Time, error) {
+	r.failed++
+	return nil, nil
+}
+
+func (r *totpRepository) DeleteFailedAttempts(_ context.Context, _ string) error {
+	r.failed = 0
+	return nil
+}
+
+// newTOTPTestService returns the service with the clock fixed at the now, along with the ctx of its user
+func newTOTPTestService(now *time.Time) (*UserService, *totpRepository, context.Context) {
+	usr := &domain.User{ID: "3b8e2c3e-8c4a-4d7e-9a55-0f6c1d2b7e11", Email: "totp@letschat.test", Activated: true}
+	repo := &totpRepository{user: usr}
+	s := NewUserService(repo, 5, time.Minute, time.Minute)
+	s.clock = func() time.Time { return *now }
+	return s, repo, context.WithValue(context.Background(), utility.UserCtxKey, usr)
+}
+
+// enrollTOTP enrolls & confirms the TOTP of the user at the now, returns the secret & the recovery codes
+func enrollTOTP(t *testing.T, s *UserService, ctx context.Context, now time.Time) ([]byte, []string) {
+	t.Helper()
+	enrollment, err := s.EnrollTOTP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := totpEncoding.DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := s.ConfirmTOTP(ctx, hotp(secret, totpStep(now)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret, codes
+}
+
+func assertInvalidCode(t *testing.T, err error) {
+	t.Helper()
+	var ev *domain.ErrValidation
+	if !errors.As(err, &ev) || ev.Errors["code"] != "invalid" {
+		t.Fatalf("got %v, want the code to be invalid", err)
+	}
+}
+
+func TestHOTPMatchesTheRFCVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA1, truncated to the 6 digits
+	secret := []byte("12345678901234567890")
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924"} {
+		if got := hotp(secret, totpStep(time.Unix(unix, 0))); got != want {
+			t.Errorf("at %v: got %v, want %v", unix, got, want)
+		}
+	}
+}
+
+func TestTOTPEnrollAndConfirm(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s, repo, ctx := newTOTPTestService(&now)
+	if _, err := s.ConfirmTOTP(ctx, "123456"); err == nil {
+		t.Fatal("confirmed without enrolling")
+	}
+	enrollment, err := s.EnrollTOTP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || !strings.Contains(enrollment.URI, enrollment.Secret) {
+		t.Fatalf("unexpected URI %v", enrollment.URI)
+	}
+	if enabled, _ := s.IsTOTPEnabled(ctx, repo.user.ID); enabled {
+		t.Fatal("enabled before it's confirmed")
+	}
+	secret, _ := totpEncoding.DecodeString(enrollment.Secret)
+	wrong := hotp(secret, totpStep(now)+5)
+	_, err = s.ConfirmTOTP(ctx, wrong)
+	assertInvalidCode(t, err)
+
+	codes, err := s.ConfirmTOTP(ctx, hotp(secret, totpStep(now)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != domain.RecoveryCodeCount || len(repo.recovery) != domain.RecoveryCodeCount {
+		t.Fatalf("got %v recovery codes, %v stored, want %v", len(codes), len(repo.recovery), domain.RecoveryCodeCount)
+	}
+	if enabled, _ := s.IsTOTPEnabled(ctx, repo.user.ID); !enabled {
+		t.Fatal("not enabled once confirmed")
+	}
+	if _, err = s.ConfirmTOTP(ctx, hotp(secret, totpStep(now))); !errors.Is(err, domain.ErrTOTPEnabled) {
+		t.Fatalf("got %v, want %v", err, domain.ErrTOTPEnabled)
+	}
+}
+
+func TestTOTPAcceptsOneStepOfSkew(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s, repo, ctx := newTOTPTestService(&now)
+	secret, _ := enrollTOTP(t, s, ctx, now)
+	step := totpStep(now)
+	// a step ahead of the clock, then a step behind it, each newer than the last one used
+	now = now.Add(domain.TOTPPeriod) // step+1
+	if err := s.VerifySecondFactor(ctx, repo.user.ID, hotp(secret, step+2)); err != nil {
+		t.Fatalf("a step ahead: %v", err)
+	}
+	now = now.Add(3 * domain.TOTPPeriod) // step+4
+	if err := s.VerifySecondFactor(ctx, repo.user.ID, hotp(secret, step+3)); err != nil {
+		t.Fatalf("a step behind: %v", err)
+	}
+	// two steps either way are out of the skew
+	assertInvalidCode(t, s.VerifySecondFactor(ctx, repo.user.ID, hotp(secret, step+6)))
+	now = now.Add(4 * domain.TOTPPeriod) // step+8
+	assertInvalidCode(t, s.VerifySecondFactor(ctx, repo.user.ID, hotp(secret, step+6)))
+	if repo.failed != 2 {
+		t.Fatalf("got %v failed attempts counted, want 2", repo.failed)
+	}
+}
+
+func TestTOTPRejectsReplays(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s, repo, ctx := newTOTPTestService(&now)
+	secret, _ := enrollTOTP(t, s, ctx, now)
+	// the code confirming the enrollment is already used
+	assertInvalidCode(t, s.VerifySecondFactor(ctx, repo.user.ID, hotp(secret, totpStep(now))))
+	now = now.Add(domain.TOTPPeriod)
+	code := hotp(secret, totpStep(now))
+	if err := s.VerifySecondFactor(ctx, repo.user.ID, code); err != nil {
+		t.Fatal(err)
+	}
+	assertInvalidCode(t, s.VerifySecondFactor(ctx, repo.user.ID, code))
+	// nor is an older code within the skew accepted once a newer one is used
+	now = now.Add(domain.TOTPPeriod)
+	if err := s.VerifySecondFactor(ctx, repo.user.ID, hotp(secret, totpStep(now)+1)); err != nil {
+		t.Fatal(err)
+	}
+	assertInvalidCode(t, s.VerifySecondFactor(ctx, repo.user.ID, hotp(secret, totpStep(now))))
+}
+
+func TestRecoveryCodesAreUsedOnce(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s, repo, ctx := newTOTPTestService(&now)
+	_, codes := enrollTOTP(t, s, ctx, now)
+	if err := s.VerifySecondFactor(ctx, repo.user.ID, codes[0]); err != nil {
+		t.Fatal(err)
+	}
+	assertInvalidCode(t, s.VerifySecondFactor(ctx, repo.user.ID, codes[0]))
+	// entered in any case, without the separator
+	if err := s.VerifySecondFactor(ctx, repo.user.ID, strings.ToUpper(strings.ReplaceAll(codes[1], "-", ""))); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.recovery) != domain.RecoveryCodeCount-2 {
+		t.Fatalf("got %v recovery codes left, want %v", len(repo.recovery), domain.RecoveryCodeCount-2)
+	}
+	if err := s.DisableTOTP(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertInvalidCode(t, s.VerifySecondFactor(ctx, repo.user.ID, codes[2]))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
 	"strings"
 	"time"
 )
@@ -18,11 +19,26 @@ var _ domain.UserService = (*UserService)(nil)
 
 type UserService struct {
 	userRepository domain.UserRepository
+	// the account is locked for the lockout duration after the maxFailedAttempts password or OTP attempts,
+	// made within the failedAttemptsWindow
+	maxFailedAttempts    int
+	failedAttemptsWindow time.Duration
+	lockout              time.Duration
+	// clock is what the TOTP codes are checked against
+	clock func() time.Time
 }
 
-func NewUserService(userRepo domain.UserRepository) *UserService {
+func NewUserService(
+	userRepo domain.UserRepository,
+	maxFailedAttempts int,
+	failedAttemptsWindow, lockout time.Duration,
+) *UserService {
 	return &UserService{
-		userRepository: userRepo,
+		userRepository:       userRepo,
+		maxFailedAttempts:    maxFailedAttempts,
+		failedAttemptsWindow: failedAttemptsWindow,
+		lockout:              lockout,
+		clock:                time.Now,
 	}
 }
 
@@ -99,15 +115,6 @@ func (s *UserService) UpdateUser(ctx context.Context, u *domain.UserUpdate) erro
 		return err
 	}
 	usr.Name = u.Name
-	exists, err := s.userRepository.ExistsUser(ctx, u.Email)
-	if err != nil {
-		return err
-	}
-	if exists && utility.ContextGetUser(ctx).Email != u.Email {
-		ev.AddError("email", "already exists")
-		return ev
-	}
-	usr.Email = u.Email
 	if u.CurrentPassword != nil {
 		if !comparePasswordHash(usr.Password, *u.CurrentPassword) {
 			ev.AddError("currentPassword", "does not match")
@@ -126,6 +133,108 @@ func (s *UserService) UpdateUser(ctx context.Context, u *domain.UserUpdate) erro
 	return nil
 }
 
+func (s *UserService) RequestEmailChange(ctx context.Context, email string) (*domain.User, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateEmail(email, ev)
+	if ev.HasErrors() {
+		return nil, ev
+	}
+	usr, err := s.userRepository.GetByUniqueField(ctx, "id", utility.ContextGetUser(ctx).ID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(usr.Email, email) { // the emails are case-insensitive
+		return nil, nil
+	}
+	exists, err := s.userRepository.ExistsUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		ev.AddError("email", "already exists")
+		return nil, ev
+	}
+	if err = s.userRepository.UpsertPendingEmail(ctx, usr.ID, email); err != nil {
+		return nil, err
+	}
+	return usr, nil
+}
+
+func (s *UserService) VerifyEmailChange(ctx context.Context, plainOTP string) (string, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateOTP(plainOTP, ev)
+	if ev.HasErrors() {
+		return "", ev
+	}
+	usrID := utility.ContextGetUser(ctx).ID
+	email, err := s.userRepository.GetPendingEmail(ctx, usrID)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("otp", "no email change is pending")
+			return "", ev
+		}
+		return "", err
+	}
+	if err = s.checkLockedOut(ctx, usrID); err != nil {
+		return "", err
+	}
+	tokenHash := sha256.Sum256([]byte(plainOTP))
+	otpUsr, err := s.userRepository.GetForToken(ctx, domain.ScopeEmailChange, tokenHash[:])
+	if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+		return "", err
+	}
+	if otpUsr == nil || otpUsr.ID != usrID {
+		if err = s.countFailedAttempt(ctx, usrID); err != nil {
+			return "", err
+		}
+		ev.AddError("otp", "invalid")
+		return "", ev
+	}
+	if err = s.userRepository.DeleteFailedAttempts(ctx, usrID); err != nil {
+		return "", err
+	}
+	return email, nil
+}
+
+func (s *UserService) ApplyEmailChange(ctx context.Context, email string) error {
+	usr, err := s.userRepository.GetByUniqueField(ctx, "id", utility.ContextGetUser(ctx).ID)
+	if err != nil {
+		return err
+	}
+	// some other account may have taken the email while the change was pending
+	exists, err := s.userRepository.ExistsUser(ctx, email)
+	if err != nil {
+		return err
+	}
+	if exists && !strings.EqualFold(usr.Email, email) {
+		ev := domain.NewErrValidation()
+		ev.AddError("email", "already exists")
+		return ev
+	}
+	usr.Email = email
+	if err = s.userRepository.UpdateUser(ctx, usr); err != nil {
+		return err
+	}
+	return s.userRepository.DeletePendingEmail(ctx, usr.ID)
+}
+
+func (s *UserService) CancelEmailChange(ctx context.Context) error {
+	return s.userRepository.DeletePendingEmail(ctx, utility.ContextGetUser(ctx).ID)
+}
+
+func (s *UserService) IsDigestEnabled(ctx context.Context) (bool, error) {
+	optOut, err := s.userRepository.GetDigestOptOut(ctx, utility.ContextGetUser(ctx).ID)
+	return !optOut, err
+}
+
+func (s *UserService) SetDigestEnabled(ctx context.Context, enabled bool) error {
+	return s.userRepository.SetDigestOptOut(ctx, utility.ContextGetUser(ctx).ID, !enabled)
+}
+
+func (s *UserService) ClaimDigest(ctx context.Context, userID string, lastSentAt *time.Time) (bool, error) {
+	return s.userRepository.SetDigestSent(ctx, userID, lastSentAt)
+}
+
 func (s *UserService) UpdateUserOnlineStatus(ctx context.Context, usr *domain.User, online bool) error {
 	u, err := s.userRepository.GetByUniqueField(ctx, "id", usr.ID)
 	if err != nil {
@@ -179,6 +288,7 @@ func (s *UserService) AuthenticateUser(ctx context.Context, u *domain.UserAuth)
 	ev := domain.NewErrValidation()
 	domain.ValidateEmail(u.Email, ev)
 	domain.ValidPlainPassword(u.Password, ev)
+	domain.ValidateDevice(u.Device, ev)
 	if ev.HasErrors() {
 		return "", ev
 	}
@@ -188,18 +298,336 @@ func (s *UserService) AuthenticateUser(ctx context.Context, u *domain.UserAuth)
 			ev.AddError("email", "not registered")
 			return "", ev
 		}
+		return "", err
 	}
 	if !usr.Activated {
 		ev.AddError("email", "not activated")
 		return "", ev
 	}
+	if err = s.checkLockedOut(ctx, usr.ID); err != nil {
+		return "", err
+	}
 	if !comparePasswordHash(usr.Password, u.Password) {
+		if err = s.countFailedAttempt(ctx, usr.ID); err != nil {
+			return "", err
+		}
 		ev.AddError("password", "does not match")
 		return "", ev
 	}
+	if err = s.userRepository.DeleteFailedAttempts(ctx, usr.ID); err != nil {
+		return "", err
+	}
 	return usr.ID, nil
 }
 
+func (s *UserService) VerifyOTP(ctx context.Context, scope, email, plainOTP string) (*domain.User, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateEmail(email, ev)
+	domain.ValidateOTP(plainOTP, ev)
+	if ev.HasErrors() {
+		return nil, ev
+	}
+	usr, err := s.userRepository.GetByUniqueField(ctx, "email", email)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("email", "not registered")
+			return nil, ev
+		}
+		return nil, err
+	}
+	switch {
+	case scope == domain.ScopeActivation && usr.Activated:
+		return nil, domain.ErrAlreadyActive
+	case scope == domain.ScopePasswordReset && !usr.Activated:
+		ev.AddError("email", "not activated")
+		return nil, ev
+	}
+	if err = s.checkLockedOut(ctx, usr.ID); err != nil {
+		return nil, err
+	}
+	tokenHash := sha256.Sum256([]byte(plainOTP))
+	otpUsr, err := s.userRepository.GetForToken(ctx, scope, tokenHash[:])
+	if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, err
+	}
+	// the OTP of some other account is just as much a failed attempt
+	if otpUsr == nil || otpUsr.ID != usr.ID {
+		if err = s.countFailedAttempt(ctx, usr.ID); err != nil {
+			return nil, err
+		}
+		ev.AddError("otp", "invalid")
+		return nil, ev
+	}
+	if err = s.userRepository.DeleteFailedAttempts(ctx, usr.ID); err != nil {
+		return nil, err
+	}
+	return usr, nil
+}
+
+func (s *UserService) ResetPassword(ctx context.Context, user *domain.User, newPassword string) error {
+	ev := domain.NewErrValidation()
+	domain.ValidPlainPassword(newPassword, ev)
+	if ev.HasErrors() {
+		return ev
+	}
+	passHash, err := generatePasswordHash(newPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = passHash
+	return s.userRepository.UpdateUser(ctx, user)
+}
+
+func (s *UserService) ConfirmPassword(ctx context.Context, password string) error {
+	ev := domain.NewErrValidation()
+	ev.Evaluate(password != "", "password", "must be provided")
+	if ev.HasErrors() {
+		return ev
+	}
+	usr, err := s.userRepository.GetByUniqueField(ctx, "id", utility.ContextGetUser(ctx).ID)
+	if err != nil {
+		return err
+	}
+	if err = s.checkLockedOut(ctx, usr.ID); err != nil {
+		return err
+	}
+	if !comparePasswordHash(usr.Password, password) {
+		if err = s.countFailedAttempt(ctx, usr.ID); err != nil {
+			return err
+		}
+		ev.AddError("password", "does not match")
+		return ev
+	}
+	return s.userRepository.DeleteFailedAttempts(ctx, usr.ID)
+}
+
+func (s *UserService) DeleteUser(ctx context.Context) error {
+	return s.userRepository.DeleteUser(ctx, utility.ContextGetUser(ctx).ID)
+}
+
+func (s *UserService) EnrollTOTP(ctx context.Context) (*domain.TOTPEnrollment, error) {
+	usr, err := s.userRepository.GetByUniqueField(ctx, "id", utility.ContextGetUser(ctx).ID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("error generating TOTP secret: %w", err)
+	}
+	if err = s.userRepository.UpsertPendingTOTP(ctx, usr.ID, secret); err != nil {
+		return nil, err
+	}
+	return &domain.TOTPEnrollment{
+		Secret: totpEncoding.EncodeToString(secret),
+		URI:    totpURI(secret, usr.Email),
+	}, nil
+}
+
+func (s *UserService) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateTOTPCode(code, ev)
+	if ev.HasErrors() {
+		return nil, ev
+	}
+	usrID := utility.ContextGetUser(ctx).ID
+	totp, err := s.userRepository.GetTOTP(ctx, usrID)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("code", "enroll first")
+			return nil, ev
+		}
+		return nil, err
+	}
+	if totp.Enabled {
+		return nil, domain.ErrTOTPEnabled
+	}
+	step, ok := matchTOTP(totp.Secret, code, s.clock(), totp.LastUsedStep)
+	if !ok {
+		ev.AddError("code", "invalid")
+		return nil, ev
+	}
+	if err = s.userRepository.EnableTOTP(ctx, usrID, step); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("error generating recovery codes: %w", err)
+	}
+	if err = s.userRepository.ReplaceRecoveryCodes(ctx, usrID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP removes the TOTP of the user in the context, enabled or pending, along with the recovery codes
+func (s *UserService) DisableTOTP(ctx context.Context) error {
+	usrID := utility.ContextGetUser(ctx).ID
+	if err := s.userRepository.DeleteTOTP(ctx, usrID); err != nil {
+		return err
+	}
+	return s.userRepository.ReplaceRecoveryCodes(ctx, usrID, nil)
+}
+
+func (s *UserService) IsTOTPEnabled(ctx context.Context, userID string) (bool, error) {
+	totp, err := s.userRepository.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totp.Enabled, nil
+}
+
+func (s *UserService) VerifySecondFactor(ctx context.Context, userID, code string) error {
+	ev := domain.NewErrValidation()
+	domain.ValidateSecondFactorCode(code, ev)
+	if ev.HasErrors() {
+		return ev
+	}
+	if err := s.checkLockedOut(ctx, userID); err != nil {
+		return err
+	}
+	var ok bool
+	var err error
+	if domain.RgxTOTPCode.MatchString(code) {
+		ok, err = s.useTOTPCode(ctx, userID, code)
+	} else {
+		err = s.userRepository.DeleteRecoveryCode(ctx, userID, hashRecoveryCode(code))
+		ok = err == nil
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err = s.countFailedAttempt(ctx, userID); err != nil {
+			return err
+		}
+		ev.AddError("code", "invalid")
+		return ev
+	}
+	return s.userRepository.DeleteFailedAttempts(ctx, userID)
+}
+
+func (s *UserService) AddSSHKey(ctx context.Context, k *domain.SSHKeyAdd) (*domain.SSHKey, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateSSHKeyName(k.Name, ev)
+	pubKey, err := parseSSHPublicKey(k.PublicKey)
+	if err != nil {
+		ev.AddError("publicKey", "must be an ed25519, ecdsa or 2048+ bits rsa key in the authorized_keys format")
+	}
+	if ev.HasErrors() {
+		return nil, ev
+	}
+	usrID := utility.ContextGetUser(ctx).ID
+	keys, err := s.userRepository.GetSSHKeys(ctx, usrID)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) >= domain.MaxSSHKeysPerUser {
+		ev.AddError("publicKey", fmt.Sprintf("no more than %v keys can be added", domain.MaxSSHKeysPerUser))
+		return nil, ev
+	}
+	key := &domain.SSHKey{
+		UserID:      usrID,
+		Name:        strings.TrimSpace(k.Name),
+		Fingerprint: ssh.FingerprintSHA256(pubKey),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pubKey))),
+	}
+	if err = s.userRepository.InsertSSHKey(ctx, key); err != nil {
+		if errors.Is(err, domain.ErrSSHKeyExists) {
+			ev.AddError("publicKey", "already added")
+			return nil, ev
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *UserService) GetSSHKeys(ctx context.Context) ([]*domain.SSHKey, error) {
+	return s.userRepository.GetSSHKeys(ctx, utility.ContextGetUser(ctx).ID)
+}
+
+func (s *UserService) DeleteSSHKey(ctx context.Context, id string) error {
+	ev := domain.NewErrValidation()
+	domain.ValidateUUID(id, ev, "id")
+	if ev.HasErrors() {
+		return ev
+	}
+	return s.userRepository.DeleteSSHKey(ctx, id, utility.ContextGetUser(ctx).ID)
+}
+
+func (s *UserService) GetSSHLoginUserID(ctx context.Context, r *domain.SSHChallengeRequest) (string, error) {
+	ev := domain.NewErrValidation()
+	domain.ValidateEmail(r.Email, ev)
+	domain.ValidateSSHFingerprint(r.Fingerprint, ev)
+	if ev.HasErrors() {
+		return "", ev
+	}
+	usr, err := s.userRepository.GetByUniqueField(ctx, "email", r.Email)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("email", "not registered")
+			return "", ev
+		}
+		return "", err
+	}
+	if !usr.Activated {
+		ev.AddError("email", "not activated")
+		return "", ev
+	}
+	if err = s.checkLockedOut(ctx, usr.ID); err != nil {
+		return "", err
+	}
+	if _, err = s.userRepository.GetSSHKey(ctx, usr.ID, r.Fingerprint); err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			ev.AddError("fingerprint", "not registered")
+			return "", ev
+		}
+		return "", err
+	}
+	return usr.ID, nil
+}
+
+func (s *UserService) AuthenticateSSH(ctx context.Context, userID string, a *domain.SSHAuth) error {
+	ev := domain.NewErrValidation()
+	domain.ValidateSSHFingerprint(a.Fingerprint, ev)
+	domain.ValidateSSHSignature(a.Signature, ev)
+	domain.ValidateDevice(a.Device, ev)
+	if ev.HasErrors() {
+		return ev
+	}
+	if err := s.checkLockedOut(ctx, userID); err != nil {
+		return err
+	}
+	key, err := s.userRepository.GetSSHKey(ctx, userID, a.Fingerprint)
+	if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+		return err
+	}
+	var verified bool
+	if key != nil { // nil if the key was removed since the challenge
+		pubKey, err := parseSSHPublicKey(key.PublicKey)
+		if err != nil {
+			return fmt.Errorf("error parsing stored ssh key: %w", err)
+		}
+		verified = verifySSHSignature(pubKey, a.Challenge, a.Signature)
+	}
+	if !verified {
+		if err = s.countFailedAttempt(ctx, userID); err != nil {
+			return err
+		}
+		ev.AddError("signature", "does not match")
+		return ev
+	}
+	if err = s.userRepository.DeleteFailedAttempts(ctx, userID); err != nil {
+		return err
+	}
+	return s.userRepository.SetSSHKeyLastUsed(ctx, key.ID)
+}
+
 func (s *UserService) GetByQuery(
 	ctx context.Context,
 	queryParam string,
@@ -211,13 +639,84 @@ func (s *UserService) GetByQuery(
 	} else {
 		paramName = "name"
 	}
-	return s.userRepository.GetByQuery(ctx, paramName, queryParam, filter)
+	return s.userRepository.GetByQuery(ctx, utility.ContextGetUser(ctx).ID, paramName, queryParam, filter)
 }
 
 func (s *UserService) SetOnlineUsersLastSeen(ctx context.Context, t time.Time) error {
 	return s.userRepository.SetOnlineUsersLastSeen(ctx, t)
 }
 
+// SetUserKey publishes the identity key of the user in the context
+func (s *UserService) SetUserKey(ctx context.Context, publicKey []byte) (*domain.UserKey, error) {
+	ev := domain.NewErrValidation()
+	if domain.ValidatePublicKey(publicKey, ev); ev.HasErrors() {
+		return nil, ev
+	}
+	k := &domain.UserKey{UserID: utility.ContextGetUser(ctx).ID, PublicKey: publicKey}
+	if err := s.userRepository.UpsertUserKey(ctx, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (s *UserService) GetUserKey(ctx context.Context, userID string) (*domain.UserKey, error) {
+	ev := domain.NewErrValidation()
+	if domain.ValidateUUID(userID, ev, "userID"); ev.HasErrors() {
+		return nil, ev
+	}
+	return s.userRepository.GetUserKey(ctx, userID)
+}
+
+// BlockUser blocks the user for the user in the context, the msgs from the blocked user are rejected
+func (s *UserService) BlockUser(ctx context.Context, userID string) error {
+	usr := utility.ContextGetUser(ctx)
+	if ev := validateOtherUserID(userID, usr.ID); ev.HasErrors() {
+		return ev
+	}
+	return s.userRepository.InsertUserBlock(ctx, usr.ID, userID)
+}
+
+func (s *UserService) UnblockUser(ctx context.Context, userID string) error {
+	usr := utility.ContextGetUser(ctx)
+	if ev := validateOtherUserID(userID, usr.ID); ev.HasErrors() {
+		return ev
+	}
+	return s.userRepository.DeleteUserBlock(ctx, usr.ID, userID)
+}
+
+// IsUserBlocked tells whether the user with the userID is blocked by the one with the byUserID
+func (s *UserService) IsUserBlocked(ctx context.Context, userID, byUserID string) (bool, error) {
+	return s.userRepository.UserBlockExists(ctx, byUserID, userID)
+}
+
+func (s *UserService) GetBlockerIDs(ctx context.Context, userID string) ([]string, error) {
+	return s.userRepository.GetBlockerIDs(ctx, userID)
+}
+
+// MuteUser mutes the user for the user in the context, only the TUI acts upon it
+func (s *UserService) MuteUser(ctx context.Context, userID string) error {
+	usr := utility.ContextGetUser(ctx)
+	if ev := validateOtherUserID(userID, usr.ID); ev.HasErrors() {
+		return ev
+	}
+	return s.userRepository.InsertUserMute(ctx, usr.ID, userID)
+}
+
+func (s *UserService) UnmuteUser(ctx context.Context, userID string) error {
+	usr := utility.ContextGetUser(ctx)
+	if ev := validateOtherUserID(userID, usr.ID); ev.HasErrors() {
+		return ev
+	}
+	return s.userRepository.DeleteUserMute(ctx, usr.ID, userID)
+}
+
+func validateOtherUserID(userID, currUsrID string) *domain.ErrValidation {
+	ev := domain.NewErrValidation()
+	domain.ValidateUUID(userID, ev, "userID")
+	ev.Evaluate(userID != currUsrID, "userID", "must not be your own")
+	return ev
+}
+
 func generatePasswordHash(plainPassword string) ([]byte, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(plainPassword), 12)
 	if err != nil {
@@ -230,3 +729,50 @@ func generatePasswordHash(plainPassword string) ([]byte, error) {
 func comparePasswordHash(hash []byte, plain string) bool {
 	return bcrypt.CompareHashAndPassword(hash, []byte(plain)) == nil
 }
+
+// useTOTPCode matches the code & marks its step used, a code already used is no match
+func (s *UserService) useTOTPCode(ctx context.Context, userID, code string) (bool, error) {
+	totp, err := s.userRepository.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !totp.Enabled {
+		return false, nil
+	}
+	step, ok := matchTOTP(totp.Secret, code, s.clock(), totp.LastUsedStep)
+	if !ok {
+		return false, nil
+	}
+	err = s.userRepository.SetTOTPLastUsedStep(ctx, userID, step)
+	if errors.Is(err, domain.ErrEditConflict) { // used concurrently
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// checkLockedOut returns *domain.ErrLockedOut if the account is locked after too many failed attempts
+func (s *UserService) checkLockedOut(ctx context.Context, userID string) error {
+	lockedUntil, err := s.userRepository.GetLockedUntil(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil {
+		return &domain.ErrLockedOut{Until: *lockedUntil}
+	}
+	return nil
+}
+
+// countFailedAttempt returns *domain.ErrLockedOut if this attempt was the one to lock the account
+func (s *UserService) countFailedAttempt(ctx context.Context, userID string) error {
+	lockedUntil, err := s.userRepository.InsertFailedAttempt(ctx, userID, s.maxFailedAttempts, s.failedAttemptsWindow, s.lockout)
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil {
+		return &domain.ErrLockedOut{Until: *lockedUntil}
+	}
+	return nil
+}
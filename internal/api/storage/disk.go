@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var _ domain.AttachmentStorage = (*DiskStorage)(nil)
+
+// DiskStorage keeps the attachments as files in a directory on the local disk, a file per key
+type DiskStorage struct {
+	dir string
+}
+
+func NewDiskStorage(dir string) (*DiskStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating attachments dir: %w", err)
+	}
+	return &DiskStorage{dir}, nil
+}
+
+// Put writes the content to a temp file first & moves it in place once complete,
+// so a partially written file is never served
+func (s *DiskStorage) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	n, err := io.Copy(f, &ctxReader{ctx, r})
+	if err != nil {
+		f.Close()
+		return n, err
+	}
+	if err = f.Close(); err != nil {
+		return n, err
+	}
+	return n, os.Rename(f.Name(), path)
+}
+
+func (s *DiskStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrRecordNotFound
+	}
+	return f, err
+}
+
+func (s *DiskStorage) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *DiskStorage) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || key == "." || key == ".." {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+// ctxReader stops the read once the ctx is done, so an abandoned upload doesn't keep on writing
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
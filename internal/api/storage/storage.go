@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+)
+
+// New returns the domain.AttachmentStorage for the kind set in the config, (disk)
+func New(kind, dir string) (domain.AttachmentStorage, error) {
+	switch kind {
+	case "disk":
+		return NewDiskStorage(dir)
+	default:
+		return nil, fmt.Errorf("unknown attachment storage %q, must be one of (disk)", kind)
+	}
+}
@@ -1,55 +1,208 @@
 package utility
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/lmittmann/tint"
+	"gopkg.in/yaml.v3"
 	"io"
 	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// envPrefix of the env vars, the env var of a flag is its name in upper case with the dashes as underscores,
+// prefixed, e.g. LETSCHAT_DB_DSN for the -db-dsn
+const envPrefix = "LETSCHAT_"
+
+// secretFlags are redacted when the config is printed
+var secretFlags = []string{"auth-token-secret", "smtp-password"}
+
+// Config is merged from the defaults, the YAML config file, the LETSCHAT_* env vars & the flags, each overriding the
+// ones before it. The keys of the config file are the ones of the flags, nested at the dashes as per the yaml tags,
+// e.g. the -db-dsn is db.dsn
 type Config struct {
-	Port int
-	ENV  string
-	DB   struct {
-		DSN             string
-		MaxOpenConn     int
-		MaxIdleConn     int
-		MaxIdleConnTime string
-	}
+	Port int `yaml:"port"`
+	// AdminPort serves the /metrics, 0 disables it, not to be exposed publicly
+	AdminPort int    `yaml:"admin-port"`
+	ENV       string `yaml:"env"`
+	Hub       string `yaml:"hub"`
+	// MsgHistory keeps the msgs on the server after delivery, so they can be fetched by new devices
+	MsgHistory bool `yaml:"msg-history"`
+	// WsBuffer is the count of the msgs queued per websocket connection, the connection is closed as too slow once
+	// it's full
+	WsBuffer int `yaml:"ws-buffer"`
+	HTTP     struct {
+		ReadTimeout  time.Duration `yaml:"read-timeout"`
+		WriteTimeout time.Duration `yaml:"write-timeout"`
+		IdleTimeout  time.Duration `yaml:"idle-timeout"`
+		// ShutdownTimeout is how long the ongoing requests are waited for on shutdown
+		ShutdownTimeout time.Duration `yaml:"shutdown-timeout"`
+	} `yaml:"http"`
+	DB struct {
+		DSN             string        `yaml:"dsn"`
+		MaxOpenConn     int           `yaml:"max-open-conn"`
+		MaxIdleConn     int           `yaml:"max-idle-conn"`
+		MaxIdleConnTime time.Duration `yaml:"max-idle-time"`
+	} `yaml:"db"`
+	// WsLimiter is the per-user quota of the msgs sent over the websocket, the msgs over it are deferred
+	WsLimiter struct {
+		RPS   float64 `yaml:"rps"`
+		Burst int     `yaml:"burst"`
+	} `yaml:"ws-limiter"`
+	// Auth throttles the auth, OTP & activation endpoints per client IP & per target email,
+	// and locks the account out for a while after too many failed password or OTP attempts
+	Auth struct {
+		RPS               float64 `yaml:"limiter-rps"`
+		Burst             int     `yaml:"limiter-burst"`
+		MaxFailedAttempts int     `yaml:"max-failed-attempts"`
+		// FailedAttemptsWindow is how long the failed attempts count for, from the first of them
+		FailedAttemptsWindow time.Duration `yaml:"failed-attempts-window"`
+		Lockout              time.Duration `yaml:"lockout"`
+		// TokenSecret signs the access tokens, valid for the AccessTTL, even once their session is revoked, so keep it short
+		TokenSecret string        `yaml:"token-secret"`
+		AccessTTL   time.Duration `yaml:"access-ttl"`
+		// RefreshTTL is how long a session lasts without being refreshed
+		RefreshTTL time.Duration `yaml:"refresh-ttl"`
+		// OTPTTL is how long the mailed activation, password reset & email change OTPs are valid for
+		OTPTTL time.Duration `yaml:"otp-ttl"`
+		// TwoFactorTTL is how long the second factor can be entered for, once the password is checked
+		TwoFactorTTL    time.Duration `yaml:"two-factor-ttl"`
+		SSHChallengeTTL time.Duration `yaml:"ssh-challenge-ttl"`
+	} `yaml:"auth"`
+	Attachments struct {
+		Storage string `yaml:"storage"`
+		Dir     string `yaml:"dir"`
+		MaxSize int64  `yaml:"max-size"` // bytes
+	} `yaml:"attachments"`
+	// Mailer is the backend the mails are sent with, the ones failed to be sent are retried for the RetryFor, except
+	// the ones with the OTPs
+	Mailer struct {
+		Backend  string        `yaml:"backend"`
+		Dir      string        `yaml:"dir"`
+		RetryFor time.Duration `yaml:"retry-for"`
+	} `yaml:"mailer"`
+	// Digest mails the offline users the senders & counts of the msgs yet to be delivered to them for the After,
+	// checked every Interval, either of them 0 disables the digests
+	Digest struct {
+		After    time.Duration `yaml:"after"`
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"digest"`
 	SMTP struct {
-		Host     string
-		Port     int
-		Username string
-		Password string
-		Sender   string
-	}
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		Sender   string `yaml:"sender"`
+	} `yaml:"smtp"`
 }
 
-func ParseFlags() *Config {
+// LoadConfig merges the config, see the Config, & validates it. With the -print-config it prints the config with the
+// secrets redacted, along with the validation errors if any, & exits
+func LoadConfig() (*Config, error) {
 	var cfg Config
 	flag.IntVar(&cfg.Port, "port", 8080, "API server Port")
+	flag.IntVar(&cfg.AdminPort, "admin-port", 9090, "Admin server port, serves the Prometheus /metrics, 0 disables it")
 	flag.StringVar(&cfg.ENV, "env", "dev", "Environment (dev|stag|prod)")
+	flag.StringVar(&cfg.Hub, "hub", "memory", "Websocket hub (memory|postgres), postgres is required to run multiple instances")
+	flag.BoolVar(&cfg.MsgHistory, "msg-history", false, "Keep the message history on the server, opt-in")
+	flag.IntVar(&cfg.WsBuffer, "ws-buffer", 16, "Msgs queued per websocket connection before it's closed as too slow")
+	// HTTP Flags
+	flag.DurationVar(&cfg.HTTP.ReadTimeout, "http-read-timeout", 3*time.Second, "Max duration for reading a request")
+	flag.DurationVar(&cfg.HTTP.WriteTimeout, "http-write-timeout", 6*time.Second, "Max duration for writing a response")
+	flag.DurationVar(&cfg.HTTP.IdleTimeout, "http-idle-timeout", time.Minute, "Max duration a keep-alive connection is kept idle")
+	flag.DurationVar(&cfg.HTTP.ShutdownTimeout, "http-shutdown-timeout", 5*time.Second, "Max duration the ongoing requests are waited for on shutdown")
 	// DB Flags
 	flag.StringVar(&cfg.DB.DSN, "db-dsn", "", "PostgreSQL DSN")
 	flag.IntVar(&cfg.DB.MaxOpenConn, "db-max-open-conn", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.DB.MaxIdleConn, "db-max-idle-conn", 25, "PostgreSQL max idle connections")
-	flag.StringVar(&cfg.DB.MaxIdleConnTime, "db-max-idle-time", "15m", "PostgreSQL max idle connection time")
+	flag.DurationVar(&cfg.DB.MaxIdleConnTime, "db-max-idle-time", 15*time.Minute, "PostgreSQL max idle connection time")
+	// Websocket Limiter Flags
+	flag.Float64Var(&cfg.WsLimiter.RPS, "ws-limiter-rps", 10, "Max msgs a user can send per second over the websocket")
+	flag.IntVar(&cfg.WsLimiter.Burst, "ws-limiter-burst", 30, "Max msgs a user can send in a burst over the websocket")
+	// Auth Flags
+	flag.Float64Var(&cfg.Auth.RPS, "auth-limiter-rps", 0.2, "Max auth requests per second, per client IP & per email")
+	flag.IntVar(&cfg.Auth.Burst, "auth-limiter-burst", 5, "Max auth requests in a burst, per client IP & per email")
+	flag.IntVar(&cfg.Auth.MaxFailedAttempts, "auth-max-failed-attempts", 5, "Failed password or OTP attempts before lockout")
+	flag.DurationVar(&cfg.Auth.FailedAttemptsWindow, "auth-failed-attempts-window", 15*time.Minute, "Duration the failed attempts count towards lockout for, from the first of them")
+	flag.DurationVar(&cfg.Auth.Lockout, "auth-lockout", 15*time.Minute, "Duration the account is locked out for")
+	flag.StringVar(&cfg.Auth.TokenSecret, "auth-token-secret", "", "Secret to sign the access tokens with, the same on every instance, required outside dev")
+	flag.DurationVar(&cfg.Auth.AccessTTL, "auth-access-ttl", 15*time.Minute, "Duration the access tokens are valid for")
+	flag.DurationVar(&cfg.Auth.RefreshTTL, "auth-refresh-ttl", domain.ScopeAuthenticationTTL, "Duration a session lasts without being refreshed")
+	flag.DurationVar(&cfg.Auth.OTPTTL, "auth-otp-ttl", domain.OTPTTL, "Duration the mailed activation, password reset & email change OTPs are valid for")
+	flag.DurationVar(&cfg.Auth.TwoFactorTTL, "auth-two-factor-ttl", domain.ScopeTwoFactorTTL, "Duration the second factor can be entered for, once the password is checked")
+	flag.DurationVar(&cfg.Auth.SSHChallengeTTL, "auth-ssh-challenge-ttl", domain.ScopeSSHChallengeTTL, "Duration the SSH login challenges are valid for")
+	// Attachment Flags
+	flag.StringVar(&cfg.Attachments.Storage, "attachments-storage", "disk", "Attachments storage (disk)")
+	flag.StringVar(&cfg.Attachments.Dir, "attachments-dir", "./attachments", "Directory the attachments are stored in")
+	flag.Int64Var(&cfg.Attachments.MaxSize, "attachments-max-size", 10<<20, "Max size of an attachment in bytes")
+	// Mailer Flags
+	flag.StringVar(&cfg.Mailer.Backend, "mailer-backend", "smtp", "Mailer backend (smtp|file|log), file writes the mails to a maildir")
+	flag.StringVar(&cfg.Mailer.Dir, "mailer-dir", "./mail", "Maildir the file mailer writes the mails to")
+	flag.DurationVar(&cfg.Mailer.RetryFor, "mailer-retry-for", 15*time.Minute, "Duration the mails failed to be sent are retried for")
+	// Digest Flags
+	flag.DurationVar(&cfg.Digest.After, "digest-after", 24*time.Hour, "Mail a digest of the msgs undelivered for this long, 0 disables the digests")
+	flag.DurationVar(&cfg.Digest.Interval, "digest-interval", time.Hour, "Interval the digests due are checked & mailed at")
 	// SMTP Flags
 	flag.StringVar(&cfg.SMTP.Host, "smtp-host", "", "SMTP server host")
 	flag.IntVar(&cfg.SMTP.Port, "smtp-port", 587, "SMTP server port")
 	flag.StringVar(&cfg.SMTP.Username, "smtp-username", "", "SMTP username")
 	flag.StringVar(&cfg.SMTP.Password, "smtp-password", "", "SMTP password")
 	flag.StringVar(&cfg.SMTP.Sender, "smtp-sender", "", "SMTP sender")
+	// Not a part of the Config
+	configFile := flag.String("config", os.Getenv(envPrefix+"CONFIG"), "YAML config file, overridden by the "+envPrefix+"* env vars & the flags")
+	printConfig := flag.Bool("print-config", false, "Print the config, as the env vars, with the secrets redacted & exit")
 	flag.Parse()
-	return &cfg
+	// the flags are parsed first to find the config file, the ones set are applied again once the file & the env
+	// vars are, so they take precedence
+	setFlags := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = f.Value.String()
+	})
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(); err != nil {
+		return nil, err
+	}
+	for name, value := range setFlags {
+		if err := flag.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	ev := cfg.validate()
+	// printed even if it's invalid, along with the validation errors, as that's when it's needed the most
+	if *printConfig {
+		fmt.Print(redactedConfig())
+		if ev.HasErrors() {
+			fmt.Fprintln(os.Stderr, errInvalidConfig(ev))
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if ev.HasErrors() {
+		return nil, errInvalidConfig(ev)
+	}
+	return &cfg, nil
 }
 
-// ConfigureSlog so that it easy to locate the source file & line as the Goland IDE picks up the relative file path.
-func ConfigureSlog(writeTo io.Writer) {
+// ConfigureSlog so that it easy to locate the source file & line as the Goland IDE picks up the relative file path,
+// outside the dev env the logs are written as JSON instead, for the log collectors to parse
+func ConfigureSlog(writeTo io.Writer, env string) {
+	if env != "dev" {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(writeTo, &slog.HandlerOptions{AddSource: true})))
+		return
+	}
 	wd, err := os.Getwd()
 	var tintHandler slog.Handler
 	if err != nil {
@@ -81,3 +234,138 @@ func ConfigureSlog(writeTo io.Writer) {
 	}
 	slog.SetDefault(slog.New(tintHandler))
 }
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+func (cfg *Config) loadFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("unable to read the config file: %w", err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true) // a typo is an error rather than a setting silently ignored
+	if err = dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %v: %w", name, err)
+	}
+	return nil
+}
+
+// loadEnv sets the flags from the env vars of them, the config & print-config flags are not settable with them
+func loadEnv() error {
+	var errs []error
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		name := envName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for the %v: %w", value, name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func (cfg *Config) validate() *domain.ErrValidation {
+	ev := domain.NewErrValidation()
+	ev.Evaluate(cfg.Port > 0 && cfg.Port <= 65535, "port", "must be between 1 & 65535")
+	ev.Evaluate(cfg.AdminPort >= 0 && cfg.AdminPort <= 65535, "admin-port", "must be between 0 & 65535")
+	ev.Evaluate(cfg.AdminPort != cfg.Port, "admin-port", "must not be the same as the port")
+	ev.Evaluate(slices.Contains([]string{"dev", "stag", "prod"}, cfg.ENV), "env", "must be one of (dev|stag|prod)")
+	ev.Evaluate(slices.Contains([]string{"memory", "postgres"}, cfg.Hub), "hub", "must be one of (memory|postgres)")
+	ev.Evaluate(cfg.WsBuffer > 0, "ws-buffer", "must be greater than 0")
+	ev.Evaluate(cfg.HTTP.ReadTimeout > 0, "http-read-timeout", "must be greater than 0")
+	ev.Evaluate(cfg.HTTP.WriteTimeout > 0, "http-write-timeout", "must be greater than 0")
+	ev.Evaluate(cfg.HTTP.IdleTimeout > 0, "http-idle-timeout", "must be greater than 0")
+	ev.Evaluate(cfg.HTTP.ShutdownTimeout > 0, "http-shutdown-timeout", "must be greater than 0")
+	ev.Evaluate(cfg.DB.DSN != "", "db-dsn", "must be provided")
+	ev.Evaluate(cfg.DB.MaxOpenConn >= 0, "db-max-open-conn", "must not be negative, 0 is unlimited")
+	ev.Evaluate(cfg.DB.MaxIdleConn >= 0, "db-max-idle-conn", "must not be negative")
+	ev.Evaluate(cfg.DB.MaxIdleConnTime >= 0, "db-max-idle-time", "must not be negative, 0 is unlimited")
+	ev.Evaluate(cfg.WsLimiter.RPS > 0, "ws-limiter-rps", "must be greater than 0")
+	ev.Evaluate(cfg.WsLimiter.Burst > 0, "ws-limiter-burst", "must be greater than 0")
+	ev.Evaluate(cfg.Auth.RPS > 0, "auth-limiter-rps", "must be greater than 0")
+	ev.Evaluate(cfg.Auth.Burst > 0, "auth-limiter-burst", "must be greater than 0")
+	ev.Evaluate(cfg.Auth.MaxFailedAttempts > 0, "auth-max-failed-attempts", "must be greater than 0")
+	ev.Evaluate(cfg.Auth.FailedAttemptsWindow > 0, "auth-failed-attempts-window", "must be greater than 0")
+	ev.Evaluate(cfg.Auth.Lockout > 0, "auth-lockout", "must be greater than 0")
+	// a random secret is used in dev, so every restart logs everyone out
+	ev.Evaluate(cfg.Auth.TokenSecret != "" || cfg.ENV == "dev", "auth-token-secret", "must be provided outside dev")
+	ev.Evaluate(cfg.Auth.TokenSecret == "" || len(cfg.Auth.TokenSecret) >= 32, "auth-token-secret", "must be at least 32 bytes long")
+	ev.Evaluate(cfg.Auth.AccessTTL > 0, "auth-access-ttl", "must be greater than 0")
+	ev.Evaluate(cfg.Auth.RefreshTTL > cfg.Auth.AccessTTL, "auth-refresh-ttl", "must be longer than the auth-access-ttl")
+	// the mails tell the TTL in minutes
+	ev.Evaluate(cfg.Auth.OTPTTL >= time.Minute, "auth-otp-ttl", "must be at least 1m")
+	ev.Evaluate(cfg.Auth.TwoFactorTTL > 0, "auth-two-factor-ttl", "must be greater than 0")
+	ev.Evaluate(cfg.Auth.SSHChallengeTTL > 0, "auth-ssh-challenge-ttl", "must be greater than 0")
+	ev.Evaluate(cfg.Attachments.Storage == "disk", "attachments-storage", "must be one of (disk)")
+	ev.Evaluate(cfg.Attachments.Dir != "", "attachments-dir", "must be provided")
+	ev.Evaluate(cfg.Attachments.MaxSize > 0, "attachments-max-size", "must be greater than 0")
+	ev.Evaluate(slices.Contains([]string{"smtp", "file", "log"}, cfg.Mailer.Backend), "mailer-backend", "must be one of (smtp|file|log)")
+	ev.Evaluate(cfg.Mailer.Backend != "file" || cfg.Mailer.Dir != "", "mailer-dir", "must be provided for the file mailer")
+	ev.Evaluate(cfg.Mailer.RetryFor >= 0, "mailer-retry-for", "must not be negative")
+	ev.Evaluate(cfg.Digest.After >= 0, "digest-after", "must not be negative")
+	ev.Evaluate(cfg.Digest.Interval >= 0, "digest-interval", "must not be negative")
+	if cfg.Mailer.Backend == "smtp" {
+		ev.Evaluate(cfg.SMTP.Host != "", "smtp-host", "must be provided for the smtp mailer")
+		ev.Evaluate(cfg.SMTP.Port > 0 && cfg.SMTP.Port <= 65535, "smtp-port", "must be between 1 & 65535")
+		ev.Evaluate(cfg.SMTP.Sender != "", "smtp-sender", "must be provided for the smtp mailer")
+	}
+	return ev
+}
+
+// errInvalidConfig lists the validation errors, one per line, along with where each of them can be set
+func errInvalidConfig(ev *domain.ErrValidation) error {
+	names := make([]string, 0, len(ev.Errors))
+	for name := range ev.Errors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var sb strings.Builder
+	sb.WriteString("invalid config:")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n  -%v (%v): %v", name, envName(name), ev.Errors[name])
+	}
+	return errors.New(sb.String())
+}
+
+// redactedConfig is the config as the env vars, one per line, the secrets & the password of the DB DSN redacted
+func redactedConfig() string {
+	var sb strings.Builder
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		value := f.Value.String()
+		switch {
+		case value == "":
+		case slices.Contains(secretFlags, f.Name):
+			value = "REDACTED"
+		case f.Name == "db-dsn":
+			value = redactDSN(value)
+		}
+		fmt.Fprintf(&sb, "%v=%v\n", envName(f.Name), value)
+	})
+	return sb.String()
+}
+
+var rgxDSNPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// redactDSN redacts the password of the DSN, either a URL or in the keyword/value format
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+		}
+		return u.String()
+	}
+	return rgxDSNPassword.ReplaceAllString(dsn, "${1}REDACTED")
+}
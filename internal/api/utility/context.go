@@ -3,12 +3,25 @@ package utility
 import (
 	"context"
 	"github.com/M0hammadUsman/letschat/internal/domain"
+	"log/slog"
 	"net/http"
 )
 
 type ctxKey string
 
-const UserCtxKey = ctxKey("USER")
+const (
+	UserCtxKey    = ctxKey("USER")
+	RequestCtxKey = ctxKey("REQUEST")
+)
+
+// RequestInfo of the request being served, set by the outermost middleware, the user is filled in once it's
+// authenticated down the chain, so the access log has it too
+type RequestInfo struct {
+	ID     string
+	UserID string
+	// Logger logs with the ID of the request & of the user & session, once authenticated
+	Logger *slog.Logger
+}
 
 func ContextSetUser(r *http.Request, user *domain.User) *http.Request {
 	ctx := context.WithValue(r.Context(), UserCtxKey, user)
@@ -22,3 +35,23 @@ func ContextGetUser(ctx context.Context) *domain.User {
 	}
 	return user
 }
+
+func ContextSetRequestInfo(r *http.Request, info *RequestInfo) *http.Request {
+	ctx := context.WithValue(r.Context(), RequestCtxKey, info)
+	return r.WithContext(ctx)
+}
+
+// ContextGetRequestInfo returns nil if the ctx is not of a request
+func ContextGetRequestInfo(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(RequestCtxKey).(*RequestInfo)
+	return info
+}
+
+// ContextGetLogger returns the Logger of the request, the default one if the ctx is not of a request, e.g. of a
+// background task
+func ContextGetLogger(ctx context.Context) *slog.Logger {
+	if info := ContextGetRequestInfo(ctx); info != nil {
+		return info.Logger
+	}
+	return slog.Default()
+}
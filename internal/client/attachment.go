@@ -0,0 +1,165 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrAttachmentTooLarge  = errors.New("the file is too large to be sent")
+	ErrAttachmentNotFound  = errors.New("the file is no longer available")
+	ErrAttachmentCorrupted = errors.New("the downloaded file doesn't match the sent one")
+)
+
+// attachmentsDir is where the received files are saved, within the FilesDir
+const attachmentsDir = "attachments"
+
+// UploadAttachment uploads the file at the path, the returned attachment's ID is to be sent along with the msg
+func (c *Client) UploadAttachment(path string) (*domain.Attachment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%v is a directory", path)
+	}
+	v := url.Values{}
+	v.Set("name", info.Name())
+	r, err := http.NewRequest(http.MethodPost, uploadAttachment+"?"+v.Encode(), f)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	r.ContentLength = info.Size()
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("Authorization", c.bearer())
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, getMostNestedError(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusRequestEntityTooLarge:
+		return nil, ErrAttachmentTooLarge
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusUnprocessableEntity:
+		return nil, ErrServerValidation
+	default:
+		return nil, ErrApplication
+	}
+	readBody, _ := io.ReadAll(resp.Body)
+	var res struct {
+		Attachment *domain.Attachment `json:"attachment"`
+	}
+	if err = json.Unmarshal(readBody, &res); err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	return res.Attachment, nil
+}
+
+// SaveAttachment downloads the attachment into the attachments dir within the FilesDir, the content is verified
+// against the hash sent by the server, returns the path of the saved file
+func (c *Client) SaveAttachment(id string) (string, error) {
+	r, err := http.NewRequest(http.MethodGet, fmt.Sprintf(downloadAttachment, id), nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return "", getMostNestedError(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrAttachmentNotFound
+	case http.StatusUnauthorized:
+		return "", ErrUnauthorized
+	default:
+		return "", ErrApplication
+	}
+	name := id
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = filepath.Base(params["filename"])
+	}
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		name = id
+	}
+	dir := filepath.Join(c.FilesDir, attachmentsDir)
+	if err = os.MkdirAll(dir, 0o750); err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		return "", getMostNestedError(err)
+	}
+	if etag := strings.Trim(resp.Header.Get("ETag"), `"`); etag != "" && etag != hex.EncodeToString(h.Sum(nil)) {
+		return "", ErrAttachmentCorrupted
+	}
+	path, err := freePath(dir, name)
+	if err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	return path, nil
+}
+
+// freePath returns the path for the file name in the dir, suffixed with a number if a file with the name is there
+func freePath(dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	path := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return path, nil
+		} else if err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%v (%d)%v", base, i, ext))
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"time"
 )
 
 var (
@@ -20,8 +21,8 @@ var (
 )
 
 type Client struct {
-	// If zero valued -> requires login
-	// then we set this AuthToken in the OS credential manager of respected Operating systems
+	// AuthToken is the short-lived access token, kept in memory only & refreshed as it's about to expire,
+	// use bearer to get it for a request
 	AuthToken string
 	// it's where all the application related files will live on the client side from db, logging anything
 	FilesDir string
@@ -42,6 +43,16 @@ type Client struct {
 	// initialized in Init func
 	RunStartupProcesses func()
 	wsConn              *websocket.Conn
+	// If zero valued -> requires login
+	// then we set this refreshToken in the OS credential manager of respected Operating systems
+	refreshToken string
+	// when the AuthToken is to be refreshed by, as per the local clock
+	accessDeadline time.Time
+	// serializes the refreshes, a refresh token presented twice revokes the session
+	authMu sync.Mutex
+	// the login awaiting the second factor, set on ErrTwoFactorRequired
+	twoFactorToken string
+	twoFactorEmail string
 	// talks to the api for managing native os based credential manager
 	krm      *keyringManager
 	sentMsgs sentMsgs
@@ -50,6 +61,10 @@ type Client struct {
 	// directory to store application related files on client side, determined on startup for respected OS
 	// supported OS -> windows, mac, linux
 	repo *repository.LocalRepository
+	// backfill state of the conversations from the server's msg history
+	history *msgHistory
+	// keys for the end-to-end encryption of the 1:1 msgs
+	e2ee *e2ee
 }
 
 // Init initializes Storage Dirs, keyringManager to support access token storage at OS level,
@@ -65,7 +80,7 @@ func Init(key int) error {
 		if err != nil {
 			return
 		}
-		c.AuthToken = c.krm.getAuthTokenFromKeyring()
+		c.refreshToken = c.krm.getAuthTokenFromKeyring()
 		c.BT = common.NewBackgroundTask()
 		c.WsConnState = newWsConnBroadcaster()
 		c.LoginState = newLoginBroadcaster()
@@ -77,6 +92,8 @@ func Init(key int) error {
 			return
 		}
 		c.repo = repository.NewLocalRepository(c.db)
+		c.history = newMsgHistory()
+		c.e2ee = newE2EE()
 		// Running idempotent migrations
 		err = c.db.RunMigrations()
 	})
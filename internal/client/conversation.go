@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/M0hammadUsman/letschat/internal/sync"
 	"io"
@@ -65,7 +66,7 @@ func (c *Client) getConversations() ([]*domain.Conversation, int, error) {
 		slog.Error(err.Error())
 		return nil, 0, ErrApplication
 	}
-	r.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	r.Header.Set("Authorization", c.bearer())
 	resp, err := http.DefaultClient.Do(r)
 	if err != nil {
 		slog.Error(err.Error())
@@ -132,3 +133,42 @@ func (c *Client) saveConvosAndWriteToChan(convos []*domain.Conversation) {
 	_ = c.repo.DeleteAllConversations()
 	_ = c.repo.SaveConversations(convos...)
 }
+
+// AcceptConversationRequest accepts the msg request from the user, the server then asks to sync the conversations
+func (c *Client) AcceptConversationRequest(usrID string) error {
+	return c.updateConvoRequest(http.MethodPost, acceptConvoRequest, usrID)
+}
+
+// DeclineConversationRequest declines the msg request from the user & deletes the msgs received along with it
+func (c *Client) DeclineConversationRequest(usrID string) error {
+	if err := c.updateConvoRequest(http.MethodDelete, declineConvoRequest, usrID); err != nil {
+		return err
+	}
+	return c.DeleteForMeAllMsgsForConversation(c.CurrentUsr.ID, usrID)
+}
+
+func (c *Client) updateConvoRequest(method, endpoint, usrID string) error {
+	r, err := http.NewRequest(method, fmt.Sprintf(endpoint, usrID), nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusUnprocessableEntity, http.StatusNotFound:
+		return ErrServerValidation
+	default:
+		slog.Error(resp.Status)
+		return ErrApplication
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// GetDigestEnabled tells whether the current user gets mailed the digests of the msgs received while offline
+func (c *Client) GetDigestEnabled() (bool, error) {
+	r, err := http.NewRequest(http.MethodGet, currentUserDigest, nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return false, ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return false, getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return false, ErrUnauthorized
+	default:
+		slog.Error(res.Status)
+		return false, ErrApplication
+	}
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return false, ErrApplication
+	}
+	var body struct {
+		Digest domain.DigestSetting `json:"digest"`
+	}
+	if err = json.Unmarshal(readBody, &body); err != nil {
+		slog.Error(err.Error())
+		return false, ErrApplication
+	}
+	return body.Digest.Enabled, nil
+}
+
+func (c *Client) SetDigestEnabled(enabled bool) error {
+	jsonBytes, err := json.Marshal(domain.DigestSetting{Enabled: enabled})
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r, err := http.NewRequest(http.MethodPut, currentUserDigest, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
@@ -0,0 +1,399 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/99designs/keyring"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+var (
+	// ErrNoContactKey is returned when the contact is yet to publish an identity key, nothing can be sent to the
+	// contact till then, as the server only relays the ciphertext of the 1:1 msgs
+	ErrNoContactKey = errors.New("contact has no key yet")
+	// ErrNoIdentityKey is returned when the identity key of the account is not on this device, the private key never
+	// leaves the device it was generated on
+	ErrNoIdentityKey = errors.New("the security key of your account is on another device")
+)
+
+// shown in place of the body of the msgs that can't be decrypted
+const undecryptableBody = "🔒 this message can't be decrypted"
+
+// e2ee holds the keys for the end-to-end encryption of the 1:1 msgs, each conversation has its own key
+// derived (X25519 + HKDF) from the identity key of the current user & the one of the contact
+type e2ee struct {
+	mu sync.Mutex
+	// the identity of the user with the usrID, reset once some other user logs in
+	identity *ecdh.PrivateKey
+	usrID    string
+	// keys are the userID of the contacts
+	aeads map[string]cipher.AEAD
+	// contacts whose key has changed since it was first trusted & is yet to be accepted
+	changed map[string]bool
+	// contacts yet to publish a key, nothing can be sent to them
+	noKey map[string]bool
+	// the identity key of the account is not on this device, nothing can be sent to the contacts with a key
+	noIdentity bool
+}
+
+func newE2EE() *e2ee {
+	return &e2ee{
+		aeads:   make(map[string]cipher.AEAD),
+		changed: make(map[string]bool),
+		noKey:   make(map[string]bool),
+	}
+}
+
+// ContactKeyChanged tells whether the key of the contact has changed since it was first trusted,
+// the conversation may no longer be with the same device or, worse, with someone else
+func (c *Client) ContactKeyChanged(usrID string) bool {
+	c.e2ee.mu.Lock()
+	defer c.e2ee.mu.Unlock()
+	return c.e2ee.changed[usrID]
+}
+
+// ContactKeyMissing tells whether the contact was yet to publish a key when last looked up, see ErrNoContactKey
+func (c *Client) ContactKeyMissing(usrID string) bool {
+	c.e2ee.mu.Lock()
+	defer c.e2ee.mu.Unlock()
+	return c.e2ee.noKey[usrID]
+}
+
+// EncryptionReady tells whether the 1:1 msgs can be encrypted for the contact, ErrNoIdentityKey or ErrNoContactKey
+// if not, the key of the contact is fetched from the server if not known yet
+func (c *Client) EncryptionReady(usrID string) error {
+	_, err := c.convoAEAD(usrID, false)
+	return err
+}
+
+// IdentityKeyMissing tells whether the identity key of the account is not on this device, see ErrNoIdentityKey
+func (c *Client) IdentityKeyMissing() bool {
+	c.e2ee.mu.Lock()
+	defer c.e2ee.mu.Unlock()
+	return c.e2ee.noIdentity
+}
+
+// AcceptContactKey trusts the current key of the contact, no more warnings till it changes again
+func (c *Client) AcceptContactKey(usrID string) error {
+	if err := c.repo.AcceptContactKey(usrID); err != nil {
+		return err
+	}
+	c.e2ee.mu.Lock()
+	delete(c.e2ee.changed, usrID)
+	c.e2ee.mu.Unlock()
+	return nil
+}
+
+// setupIdentityKey sets up the identity key of the account on this device, once logged in. The private key only
+// ever lives in the keyring of the device it was generated on, if the account has no key yet, the key of this device
+// or a new one is published. A device without the published key can neither encrypt nor decrypt, till the key is
+// reset on it with the ResetIdentityKey
+func (c *Client) setupIdentityKey() error {
+	c.e2ee.mu.Lock()
+	defer c.e2ee.mu.Unlock()
+	if c.CurrentUsr == nil {
+		return errors.New("no current user to set up the identity key for")
+	}
+	usrID := c.CurrentUsr.ID
+	published, err := c.getUserKey(usrID)
+	if err != nil && !errors.Is(err, ErrNoContactKey) {
+		return err
+	}
+	local, err := c.identityKey()
+	if err != nil && !errors.Is(err, ErrNoIdentityKey) {
+		return err
+	}
+	switch {
+	case published == nil:
+		if local == nil {
+			if local, err = ecdh.X25519().GenerateKey(rand.Reader); err != nil {
+				return err
+			}
+			if err = c.saveIdentityKey(usrID, local); err != nil {
+				return err
+			}
+		}
+		return c.publishIdentityKey(local)
+	case local != nil && bytes.Equal(published.PublicKey, local.PublicKey().Bytes()):
+		return nil
+	}
+	if local != nil { // some older key of the account, the contacts no longer encrypt for it
+		if err = c.krm.removeIdentityKey(usrID); err != nil {
+			slog.Error("unable to remove the identity key from keyring", "err", err.Error())
+		}
+		c.e2ee.identity = nil
+	}
+	c.e2ee.noIdentity = true
+	return ErrNoIdentityKey
+}
+
+// ResetIdentityKey replaces the identity key of the account with a new one generated on this device, the contacts
+// are warned of the change & the other devices of the account lose the end-to-end encryption till reset on them
+func (c *Client) ResetIdentityKey() error {
+	c.e2ee.mu.Lock()
+	defer c.e2ee.mu.Unlock()
+	if c.CurrentUsr == nil {
+		return errors.New("no current user to reset the identity key of")
+	}
+	identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	if err = c.saveIdentityKey(c.CurrentUsr.ID, identity); err != nil {
+		return err
+	}
+	return c.publishIdentityKey(identity)
+}
+
+// saveIdentityKey saves the identity key to the keyring & uses it from now on, must be called with the e2ee.mu held
+func (c *Client) saveIdentityKey(usrID string, identity *ecdh.PrivateKey) error {
+	// without the keyring the key only lasts till the app exits, it has to be reset on the next login
+	if err := c.krm.setIdentityKey(usrID, identity.Bytes()); err != nil {
+		slog.Error("unable to save the identity key to keyring", "err", err.Error())
+	}
+	return c.useIdentityKey(usrID, identity)
+}
+
+// useIdentityKey resets the state derived from the previous identity key, if any, must be called with the e2ee.mu held
+func (c *Client) useIdentityKey(usrID string, identity *ecdh.PrivateKey) error {
+	c.e2ee.noIdentity = false
+	if c.e2ee.identity != nil && c.e2ee.usrID == usrID && c.e2ee.identity.Equal(identity) {
+		return nil
+	}
+	c.e2ee.identity = identity
+	c.e2ee.usrID = usrID
+	clear(c.e2ee.aeads)
+	clear(c.e2ee.changed)
+	changed, err := c.repo.GetChangedContactKeys()
+	if err != nil {
+		return err
+	}
+	for _, id := range changed {
+		c.e2ee.changed[id] = true
+	}
+	return nil
+}
+
+// publishIdentityKey sets the public part of the identity key on the server, so the contacts can encrypt for us,
+// the private part never leaves the device, must be called with the e2ee.mu held
+func (c *Client) publishIdentityKey(identity *ecdh.PrivateKey) error {
+	body, err := json.Marshal(domain.UserKeySet{PublicKey: identity.PublicKey().Bytes()})
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest(http.MethodPut, setUserKey, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", c.bearer())
+	r.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return getMostNestedError(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	default:
+		return fmt.Errorf("publishing identity key, unexpected status %v", resp.StatusCode)
+	}
+}
+
+// encryptMsg returns a copy of the msg, with the body encrypted for the contact, to be sent on the wire,
+// only the new & edited 1:1 msgs are encrypted, others are returned as is
+func (c *Client) encryptMsg(msg *domain.Message) (*domain.Message, error) {
+	if msg.ConversationID != nil || (msg.Operation != domain.CreateMsg && msg.Operation != domain.EditMsg) {
+		return msg, nil
+	}
+	aead, err := c.convoAEAD(msg.ReceiverID, false)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// sealed along with the msg ID, so the ciphertext can't be passed off as some other msg
+	sealed := aead.Seal(nonce, nonce, []byte(msg.Body), []byte(msg.ID))
+	encrypted := *msg
+	encrypted.Body = base64.StdEncoding.EncodeToString(sealed)
+	encrypted.Encrypted = true
+	return &encrypted, nil
+}
+
+// decryptMsg decrypts the body of the msg in place, if the body can't be decrypted with the known key
+// the key of the contact is re-fetched, if still unable the body is replaced with undecryptableBody
+func (c *Client) decryptMsg(msg *domain.Message) {
+	if !msg.Encrypted {
+		return
+	}
+	peerID := msg.SenderID
+	if c.CurrentUsr != nil && msg.SenderID == c.CurrentUsr.ID { // sent from another device of the current user
+		peerID = msg.ReceiverID
+	}
+	body, err := c.openMsgBody(msg, peerID, false)
+	if err != nil {
+		body, err = c.openMsgBody(msg, peerID, true)
+	}
+	if err != nil {
+		slog.Error("unable to decrypt msg", "id", msg.ID, "err", err.Error())
+		body = undecryptableBody
+	}
+	msg.Body = body
+	msg.Encrypted = false
+}
+
+func (c *Client) openMsgBody(msg *domain.Message, peerID string, refresh bool) (string, error) {
+	aead, err := c.convoAEAD(peerID, refresh)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(msg.Body)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	body, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(msg.ID))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// convoAEAD returns the cipher for the conversation with the contact, the key of the contact is fetched from the
+// server if not known yet or to be refreshed, trusted on first use & flagged as changed if it's not the trusted one
+func (c *Client) convoAEAD(peerID string, refresh bool) (cipher.AEAD, error) {
+	c.e2ee.mu.Lock()
+	defer c.e2ee.mu.Unlock()
+	identity, err := c.identityKey()
+	if err != nil {
+		return nil, err
+	}
+	if aead, ok := c.e2ee.aeads[peerID]; ok && !refresh {
+		return aead, nil
+	}
+	key, err := c.getUserKey(peerID)
+	if errors.Is(err, ErrNoContactKey) {
+		c.e2ee.noKey[peerID] = true
+	}
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := c.repo.GetContactKey(peerID)
+	if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, err
+	}
+	if trusted == nil || !bytes.Equal(trusted.PublicKey, key.PublicKey) {
+		key.Changed = trusted != nil || c.e2ee.changed[peerID]
+		if err = c.repo.SaveContactKey(key); err != nil {
+			return nil, err
+		}
+		if key.Changed {
+			c.e2ee.changed[peerID] = true
+		}
+	}
+	pub, err := ecdh.X25519().NewPublicKey(key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := identity.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	// both the sides must derive the same key, so the IDs are ordered
+	a, b := c.e2ee.usrID, peerID
+	if a > b {
+		a, b = b, a
+	}
+	convoKey, err := hkdf.Key(sha256.New, shared, nil, "letschat e2ee v1|"+a+"|"+b, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(convoKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.e2ee.aeads[peerID] = aead
+	delete(c.e2ee.noKey, peerID)
+	return aead, nil
+}
+
+// identityKey loads the identity key of the current user from the keyring, ErrNoIdentityKey if it's not there,
+// must be called with the e2ee.mu held
+func (c *Client) identityKey() (*ecdh.PrivateKey, error) {
+	if c.CurrentUsr == nil {
+		return nil, errors.New("no current user to get the identity key for")
+	}
+	if c.e2ee.identity != nil && c.e2ee.usrID == c.CurrentUsr.ID {
+		return c.e2ee.identity, nil
+	}
+	usrID := c.CurrentUsr.ID
+	raw, err := c.krm.getIdentityKey(usrID)
+	if err != nil {
+		if errors.Is(err, keyring.ErrKeyNotFound) {
+			c.e2ee.noIdentity = true
+			return nil, ErrNoIdentityKey
+		}
+		return nil, err
+	}
+	identity, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.useIdentityKey(usrID, identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (c *Client) getUserKey(usrID string) (*domain.UserKey, error) {
+	r, err := http.NewRequest(http.MethodGet, fmt.Sprintf(getUserKey, usrID), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", c.bearer())
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, getMostNestedError(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNoContactKey
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	default:
+		return nil, fmt.Errorf("fetching the key of the contact, unexpected status %v", resp.StatusCode)
+	}
+	readBody, _ := io.ReadAll(resp.Body)
+	var res struct {
+		Key *domain.UserKey `json:"key"`
+	}
+	if err = json.Unmarshal(readBody, &res); err != nil {
+		return nil, err
+	}
+	return res.Key, nil
+}
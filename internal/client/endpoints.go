@@ -6,6 +6,8 @@ const (
 	usersEndpoint         = "/users"
 	tokensEndpoint        = "/tokens"
 	conversationsEndpoint = "/conversations"
+	messagesEndpoint      = "/messages"
+	attachmentsEndpoint   = "/attachments"
 	wsBaseUrl             = "ws://localhost:8080"
 	websocketsEndpoint    = "/sub"
 
@@ -14,12 +16,50 @@ const (
 	getCurrentActiveUser = getByUniqueField + "/current"
 	searchUser           = getByUniqueField
 	updateUser           = baseUrl + usersEndpoint               // PUT
+	deleteUser           = baseUrl + usersEndpoint               // DELETE
+	exportUser           = baseUrl + usersEndpoint + "/export"   // GET
 	activateUser         = baseUrl + usersEndpoint + "/activate" // POST
+	resetPassword        = baseUrl + usersEndpoint + "/password" // PUT
+	setUserKey           = getCurrentActiveUser + "/key"         // PUT
+	// PUT to confirm the pending email change with the OTP & DELETE to cancel it
+	currentUserEmail = getCurrentActiveUser + "/email"
+	// GET the status, POST to enroll, PUT to confirm & DELETE to disable
+	currentUserTOTP = getCurrentActiveUser + "/totp"
+	// GET the keys & POST to add one
+	currentUserSSHKeys = getCurrentActiveUser + "/ssh-keys"
+	// DELETE, format with the ID of the key
+	deleteSSHKey = currentUserSSHKeys + "/%v"
+	// GET whether the email digests are enabled & PUT to turn them on or off
+	currentUserDigest = getCurrentActiveUser + "/digest"
+	// GET, format with the userID
+	getUserKey = baseUrl + usersEndpoint + "/%v/key"
+	// PUT to block & DELETE to unblock, format with the userID
+	blockUser = baseUrl + usersEndpoint + "/%v/block"
+	// PUT to mute & DELETE to unmute, format with the userID
+	muteUser = baseUrl + usersEndpoint + "/%v/mute"
 
-	generateOTP  = baseUrl + tokensEndpoint + "/otp"  // POST
-	authenticate = baseUrl + tokensEndpoint + "/auth" // POST
+	generateOTP           = baseUrl + tokensEndpoint + "/otp"            // POST
+	authenticate          = baseUrl + tokensEndpoint + "/auth"           // POST
+	authenticateTwoFactor = authenticate + "/2fa"                        // POST
+	sshChallenge          = baseUrl + tokensEndpoint + "/ssh"            // POST
+	authenticateSSH       = sshChallenge + "/verify"                     // POST
+	refreshAuthToken      = baseUrl + tokensEndpoint + "/refresh"        // POST
+	requestPasswordReset  = baseUrl + tokensEndpoint + "/password-reset" // POST
+	getSessions           = baseUrl + tokensEndpoint                     // GET
+	// DELETE, format with the ID of the session
+	revokeSession = baseUrl + tokensEndpoint + "/%v"
 
 	getConversations = baseUrl + conversationsEndpoint
+	// GET, format with the userID of the conversation
+	getMsgHistory = baseUrl + conversationsEndpoint + "/%v" + messagesEndpoint
+	// POST, format with the userID of the sender of the msg request
+	acceptConvoRequest = baseUrl + conversationsEndpoint + "/%v/accept"
+	// DELETE, format with the userID of the sender of the msg request
+	declineConvoRequest = baseUrl + conversationsEndpoint + "/%v/request"
+
+	uploadAttachment = baseUrl + attachmentsEndpoint // POST, the file name as the name query param
+	// GET, format with the attachmentID
+	downloadAttachment = baseUrl + attachmentsEndpoint + "/%v"
 
 	subscribeTo = wsBaseUrl + websocketsEndpoint
 )
@@ -9,6 +9,9 @@ var (
 	ErrExpiredOTP       = errors.New("expired otp")
 	ErrNonActiveUser    = errors.New("not activated")
 	ErrUnauthorized     = errors.New("invalid credentials")
+	ErrTooManyAttempts  = errors.New("too many attempts, try again later")
+	// ErrTwoFactorRequired the password was right, the login is to be completed with CompleteTwoFactorLogin
+	ErrTwoFactorRequired = errors.New("two-factor authentication required")
 	// ErrApplication code is 0
 	ErrApplication = errors.New("your side of application have encountered an error, if the error persists you may report this issue to the developer at https://github.com/M0hammadUsman/letschat")
 )
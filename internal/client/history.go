@@ -0,0 +1,127 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrNoMsgHistory is returned when the server does not keep the msg history
+	ErrNoMsgHistory = errors.New("message history is not available on the server")
+)
+
+// msgHistory tracks, per conversation, where the backfill from the server's msg history has reached
+type msgHistory struct {
+	mu sync.Mutex
+	// keys are the userID of the conversations, value is the cursor for the next page to be fetched,
+	// an empty cursor means the history of the conversation is exhausted
+	cursors map[string]string
+}
+
+func newMsgHistory() *msgHistory {
+	return &msgHistory{cursors: make(map[string]string)}
+}
+
+// exhaust stops any further backfill for the conversation with the user
+func (h *msgHistory) exhaust(usrID string) {
+	h.mu.Lock()
+	h.cursors[usrID] = ""
+	h.mu.Unlock()
+}
+
+// backfillMsgs fetches a page of older msgs exchanged with the user from the server & saves them locally,
+// returns false if there was nothing more to backfill
+func (c *Client) backfillMsgs(usrID string, pageSize int) (bool, error) {
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+	cursor, ok := c.history.cursors[usrID]
+	if ok && cursor == "" {
+		return false, nil
+	}
+	if !ok { // first backfill of the session, continue from the oldest msg we have
+		oldest, err := c.repo.GetOldestMsgForConvo(usrID)
+		if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+			return false, err
+		}
+		cursor = domain.CursorFromMessage(oldest).Encode()
+	}
+	msgs, next, err := c.getMsgHistory(usrID, cursor, pageSize)
+	if err != nil {
+		if errors.Is(err, ErrNoMsgHistory) {
+			c.history.cursors[usrID] = ""
+		}
+		return false, err
+	}
+	clearedAt, err := c.repo.GetConvoClearedAt(usrID)
+	if err != nil {
+		return false, err
+	}
+	if clearedAt != nil {
+		// the pages go back in time, so once a msg is from before the clearing, all the older ones are too
+		kept := msgs[:0]
+		for _, m := range msgs {
+			if m.SentAt == nil || m.SentAt.After(*clearedAt) {
+				kept = append(kept, m)
+			}
+		}
+		if len(kept) != len(msgs) {
+			next = ""
+		}
+		msgs = kept
+	}
+	for _, m := range msgs {
+		c.decryptMsg(m)
+	}
+	if err = c.repo.SaveMsgsIfNotExists(msgs); err != nil {
+		return false, err
+	}
+	c.history.cursors[usrID] = next
+	return len(msgs) != 0, nil
+}
+
+func (c *Client) getMsgHistory(usrID, cursor string, pageSize int) ([]*domain.Message, string, error) {
+	v := url.Values{}
+	v.Set("size", strconv.Itoa(pageSize))
+	if cursor != "" {
+		v.Set("cursor", cursor)
+	}
+	r, err := http.NewRequest(http.MethodGet, fmt.Sprintf(getMsgHistory, usrID)+"?"+v.Encode(), nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, "", ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, "", getMostNestedError(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", ErrNoMsgHistory
+	case http.StatusUnauthorized:
+		return nil, "", ErrUnauthorized
+	default:
+		return nil, "", ErrApplication
+	}
+	readBody, _ := io.ReadAll(resp.Body)
+	var res struct {
+		Messages []*domain.Message `json:"messages"`
+		Cursor   string            `json:"cursor"`
+	}
+	if err = json.Unmarshal(readBody, &res); err != nil {
+		slog.Error(err.Error())
+		return nil, "", ErrApplication
+	}
+	return res.Messages, res.Cursor, nil
+}
@@ -8,6 +8,8 @@ var (
 	appName     = "Letschat"
 	serviceName = " Auth"
 	tokenKey    = " Access Token"
+	// suffixed with the ID of the user, so accounts on the same machine keep their own identity
+	identityKeyPrefix = " Identity Key "
 )
 
 type keyringManager struct {
@@ -56,3 +58,26 @@ func (k *keyringManager) getAuthTokenFromKeyring() string {
 	}
 	return ""
 }
+
+// getIdentityKey returns the private X25519 identity key of the user, keyring.ErrKeyNotFound if there is none yet
+func (k *keyringManager) getIdentityKey(usrID string) ([]byte, error) {
+	item, err := k.kr.Get(identityKeyPrefix + usrID)
+	if err != nil {
+		return nil, err
+	}
+	return item.Data, nil
+}
+
+func (k *keyringManager) setIdentityKey(usrID string, key []byte) error {
+	item := keyring.Item{
+		Key:         identityKeyPrefix + usrID,
+		Data:        key,
+		Label:       "user=" + usrID,
+		Description: "private key to end-to-end encrypt the messages",
+	}
+	return k.kr.Set(item)
+}
+
+func (k *keyringManager) removeIdentityKey(usrID string) error {
+	return k.kr.Remove(identityKeyPrefix + usrID)
+}
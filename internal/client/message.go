@@ -93,6 +93,16 @@ func (c *Client) GetMessagesAsPageAndMarkAsRead(senderID string, page int) ([]*d
 	if err != nil {
 		return nil, nil, err
 	}
+	// local store ran out of pages, backfilling from the server's msg history, so there is a next page
+	if page >= metadata.LastPage {
+		if backfilled, err := c.backfillMsgs(senderID, f.PageSize); err != nil && !errors.Is(err, ErrNoMsgHistory) {
+			slog.Error(err.Error())
+		} else if backfilled {
+			if msgs, metadata, err = c.repo.GetMsgsAsPage(senderID, f); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
 	var msgsToSetAsRead []*domain.Message
 	for _, msg := range msgs {
 		if c.isValidReadUpdate(msg) {
@@ -122,40 +132,66 @@ func (c *Client) handleReceivedMsgs(shtdwnCtx context.Context) {
 				if err != nil {
 					slog.Error(err.Error())
 				}
-				if err = c.setMsgAsDelivered(msg.ID, msg.SenderID); err != nil {
+				// sent from another device of the current user, nothing to acknowledge
+				if msg.SenderID == c.CurrentUsr.ID {
+					c.getPopulateSaveConvosAndWriteToChan()
+					continue
+				}
+				if err = c.setMsgAsDelivered(msg); err != nil {
 					slog.Error(err.Error())
 				}
 				c.getPopulateSaveConvosAndWriteToChan()
 
 			case domain.DeliveredMsg:
-				if err := c.repo.UpdateMsg(msg); err != nil {
+				// settled locally, the group msg is delivered to every member, see settleGroupMsgReceipt
+				if msg.SenderID == c.CurrentUsr.ID {
+					if err := c.repo.UpdateMsg(msg); err != nil {
+						slog.Error(err.Error())
+					}
+					continue
+				}
+				if msg.ConversationID != nil {
+					c.settleGroupMsgReceipt(msg)
+				} else if err := c.repo.UpdateMsg(msg); err != nil {
 					slog.Error(err.Error())
 				}
 				// echo back delivery confirmation
 				c.sentMsgs.msgs <- &domain.Message{
-					ID:         msg.ID,
-					SenderID:   client.CurrentUsr.ID,
-					ReceiverID: msg.SenderID,
-					Body:       "",
-					SentAt:     ptr(time.Now()),
-					Operation:  domain.DeliveredConfirmMsg,
+					ID:             msg.ID,
+					SenderID:       client.CurrentUsr.ID,
+					ReceiverID:     msg.SenderID,
+					ConversationID: msg.ConversationID,
+					Body:           "",
+					SentAt:         ptr(time.Now()),
+					Operation:      domain.DeliveredConfirmMsg,
 				}
 				if !<-c.sentMsgs.done {
 					slog.Error("unable to echo back delivery confirmation")
 				}
 
 			case domain.ReadMsg:
-				if err := c.repo.UpdateMsg(msg); err != nil {
+				// read from another device of the current user or settled locally for a group msg, nothing to acknowledge
+				if msg.SenderID == c.CurrentUsr.ID {
+					if err := c.repo.UpdateMsg(msg); err != nil {
+						slog.Error(err.Error())
+					}
+					c.getPopulateSaveConvosAndWriteToChan()
+					continue
+				}
+				if msg.ConversationID != nil {
+					c.settleGroupMsgReceipt(msg)
+				} else if err := c.repo.UpdateMsg(msg); err != nil {
 					slog.Error(err.Error())
 				}
 				// echo back read confirmation
 				c.sentMsgs.msgs <- &domain.Message{
-					ID:         msg.ID,
-					SenderID:   client.CurrentUsr.ID,
-					ReceiverID: msg.SenderID,
-					Body:       "",
-					SentAt:     ptr(time.Now()),
-					Operation:  domain.ReadConfirmMsg,
+					ID:             msg.ID,
+					SenderID:       client.CurrentUsr.ID,
+					ReceiverID:     msg.SenderID,
+					ConversationID: msg.ConversationID,
+					Body:           "",
+					SentAt:         ptr(time.Now()),
+					Operation:      domain.ReadConfirmMsg,
 				}
 				if !<-c.sentMsgs.done {
 					slog.Error("unable to echo back read confirmation")
@@ -164,19 +200,66 @@ func (c *Client) handleReceivedMsgs(shtdwnCtx context.Context) {
 			case domain.DeleteMsg:
 				_ = c.repo.DeleteMsg(msg.ID)
 				c.getPopulateSaveConvosAndWriteToChan()
+				// deleted from another device of the current user, nothing to acknowledge
+				if msg.SenderID == c.CurrentUsr.ID {
+					continue
+				}
 				// echo back with delete confirmation
 				c.sentMsgs.msgs <- &domain.Message{
-					ID:         msg.ID,
-					SenderID:   c.CurrentUsr.ID,
-					ReceiverID: msg.SenderID,
-					Body:       "",
-					SentAt:     ptr(time.Now()),
-					Operation:  domain.DeleteConfirmMsg,
+					ID:             msg.ID,
+					SenderID:       c.CurrentUsr.ID,
+					ReceiverID:     msg.SenderID,
+					ConversationID: msg.ConversationID,
+					Body:           "",
+					SentAt:         ptr(time.Now()),
+					Operation:      domain.DeleteConfirmMsg,
 				}
 				if !<-c.sentMsgs.done {
 					slog.Error("unable to echo back deletion confirmation")
 				}
 
+			case domain.EditMsg:
+				c.applyMsgEdit(msg)
+				// edited from another device of the current user, nothing to acknowledge
+				if msg.SenderID == c.CurrentUsr.ID {
+					continue
+				}
+				// echo back with edit confirmation
+				c.sentMsgs.msgs <- &domain.Message{
+					ID:             msg.ID,
+					SenderID:       c.CurrentUsr.ID,
+					ReceiverID:     msg.SenderID,
+					ConversationID: msg.ConversationID,
+					Body:           "",
+					SentAt:         ptr(time.Now()),
+					Operation:      domain.EditConfirmMsg,
+				}
+				if !<-c.sentMsgs.done {
+					slog.Error("unable to echo back edit confirmation")
+				}
+
+			case domain.ReactMsg, domain.UnreactMsg:
+				if err := c.saveReaction(msg); err != nil {
+					slog.Error(err.Error())
+				}
+				// reacted from another device of the current user, nothing to acknowledge
+				if msg.SenderID == c.CurrentUsr.ID {
+					continue
+				}
+				// echo back with reaction confirmation
+				c.sentMsgs.msgs <- &domain.Message{
+					ID:             msg.ID,
+					SenderID:       c.CurrentUsr.ID,
+					ReceiverID:     msg.SenderID,
+					ConversationID: msg.ConversationID,
+					Body:           msg.Body,
+					SentAt:         ptr(time.Now()),
+					Operation:      domain.ReactConfirmMsg,
+				}
+				if !<-c.sentMsgs.done {
+					slog.Error("unable to echo back reaction confirmation")
+				}
+
 			case domain.OnlineMsg:
 				c.setUsrOnlineStatus(msg, true)
 
@@ -203,13 +286,14 @@ func (c *Client) handleReceivedMsgs(shtdwnCtx context.Context) {
 	}
 }
 
-func (c *Client) setMsgAsDelivered(msgID, receiverID string) error {
+func (c *Client) setMsgAsDelivered(recvMsg *domain.Message) error {
 	msg := &domain.Message{
-		ID:          msgID,
-		SenderID:    c.CurrentUsr.ID,
-		ReceiverID:  receiverID,
-		DeliveredAt: ptr(time.Now()),
-		Operation:   domain.DeliveredMsg,
+		ID:             recvMsg.ID,
+		SenderID:       c.CurrentUsr.ID,
+		ReceiverID:     recvMsg.SenderID,
+		ConversationID: recvMsg.ConversationID,
+		DeliveredAt:    ptr(time.Now()),
+		Operation:      domain.DeliveredMsg,
 	}
 	c.sentMsgs.msgs <- msg
 	// if msg is not sent
@@ -223,12 +307,16 @@ func (c *Client) setMsgAsDelivered(msgID, receiverID string) error {
 }
 
 func (c *Client) SetMsgAsRead(msg *domain.Message) error {
+	if c.isConvoRequest(msg) {
+		return nil
+	}
 	msgToSend := &domain.Message{
-		ID:         msg.ID,
-		SenderID:   c.CurrentUsr.ID,
-		ReceiverID: msg.SenderID, // confirm that message is read
-		ReadAt:     msg.ReadAt,
-		Operation:  domain.ReadMsg,
+		ID:             msg.ID,
+		SenderID:       c.CurrentUsr.ID,
+		ReceiverID:     msg.SenderID, // confirm that message is read
+		ConversationID: msg.ConversationID,
+		ReadAt:         msg.ReadAt,
+		Operation:      domain.ReadMsg,
 	}
 	// this may block, in theory, depends on the connection
 	c.sentMsgs.msgs <- msgToSend
@@ -267,17 +355,131 @@ func (c *Client) DeleteMsgForEveryone(msg *domain.Message) error {
 	}
 }
 
+// EditMsg sends the new revision of the msg & keeps the prior one locally, msg.SentAt is when it is edited
+func (c *Client) EditMsg(msg *domain.Message) error {
+	// this may block, in theory, depends on the connection
+	c.sentMsgs.msgs <- msg
+	if !<-c.sentMsgs.done {
+		return fmt.Errorf("ws conn closed due to error while editing the message")
+	}
+	if err := c.repo.EditMsg(msg); err != nil {
+		return err
+	}
+	// the edited msg may be the recent one
+	c.getPopulateSaveConvosAndWriteToChan()
+	return nil
+}
+
+// ReactToMsg sends the (un)reaction, msg.Operation being either domain.ReactMsg or domain.UnreactMsg
+// with the emoji as its body
+func (c *Client) ReactToMsg(msg *domain.Message) error {
+	// this may block, in theory, depends on the connection
+	c.sentMsgs.msgs <- msg
+	if !<-c.sentMsgs.done {
+		return fmt.Errorf("ws conn closed due to error while reacting to the message")
+	}
+	return c.saveReaction(msg)
+}
+
+func (c *Client) GetMsgRevisions(msgID string) ([]*domain.MessageRevision, error) {
+	return c.repo.GetMsgRevisions(msgID)
+}
+
 func (c *Client) DeleteForMeAllMsgsForConversation(senderId, receiverId string) error {
 	err := c.repo.DeleteAllForSenderAndReceiver(senderId, receiverId)
 	if err != nil {
 		return err
 	}
+	// the cleared msgs must not come back from the server's history, the receiver is the other end of the convo
+	if err = c.repo.SetConvoClearedAt(receiverId, time.Now()); err != nil {
+		return err
+	}
+	c.history.exhaust(senderId)
+	c.history.exhaust(receiverId)
 	c.getPopulateSaveConvosAndWriteToChan()
 	return nil
 }
 
+func (c *Client) GetMsgReceipts(msgID string) ([]*domain.MessageReceipt, error) {
+	return c.repo.GetMsgReceipts(msgID)
+}
+
+func (c *Client) GetMsgByID(id string) (*domain.Message, error) {
+	return c.repo.GetMsgByID(id)
+}
+
 // Helpers & Stuff -----------------------------------------------------------------------------------------------------
 
+// applyMsgEdit saves the received revision of the msg, the edits by anyone other than the original sender are ignored
+func (c *Client) applyMsgEdit(edit *domain.Message) {
+	msg, err := c.repo.GetMsgByID(edit.ID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrRecordNotFound) { // may have been deleted for me
+			slog.Error(err.Error())
+		}
+		return
+	}
+	if msg.SenderID != edit.SenderID {
+		slog.Error(fmt.Sprintf("ignoring edit of msg %v, not edited by its sender", edit.ID))
+		return
+	}
+	if err = c.repo.EditMsg(edit); err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	c.getPopulateSaveConvosAndWriteToChan()
+}
+
+// saveReaction adds or removes the reaction of the msg sender, as per the op
+func (c *Client) saveReaction(msg *domain.Message) error {
+	if msg.Operation == domain.UnreactMsg {
+		return c.repo.DeleteReaction(msg.ID, msg.SenderID, msg.Body)
+	}
+	return c.repo.SaveReaction(&domain.MessageReaction{
+		MsgID:     msg.ID,
+		UserID:    msg.SenderID,
+		Emoji:     msg.Body,
+		ReactedAt: msg.SentAt,
+	})
+}
+
+// settleGroupMsgReceipt records the member's delivery/read state of the group msg we've sent, once it is delivered
+// to (or read by) every member, the msg itself is marked so & the update is written to RecvMsgs for the TUI to pick
+func (c *Client) settleGroupMsgReceipt(msg *domain.Message) {
+	receipt := &domain.MessageReceipt{
+		MsgID:       msg.ID,
+		UserID:      msg.SenderID,
+		DeliveredAt: msg.DeliveredAt,
+		ReadAt:      msg.ReadAt,
+	}
+	if receipt.DeliveredAt == nil { // read implies delivered
+		receipt.DeliveredAt = receipt.ReadAt
+	}
+	if err := c.repo.SaveMsgReceipt(receipt); err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	undelivered, unread, err := c.repo.GetPendingMsgReceiptsCount(msg.ID, *msg.ConversationID, c.CurrentUsr.ID)
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	if (msg.Operation == domain.DeliveredMsg && undelivered != 0) || (msg.Operation == domain.ReadMsg && unread != 0) {
+		return
+	}
+	settled := &domain.Message{
+		ID:             msg.ID,
+		SenderID:       c.CurrentUsr.ID,
+		ReceiverID:     *msg.ConversationID,
+		ConversationID: msg.ConversationID,
+		DeliveredAt:    receipt.DeliveredAt,
+		ReadAt:         receipt.ReadAt,
+		Operation:      msg.Operation,
+	}
+	// we're one of the RecvMsgs subscribers, writing from here would block forever
+	c.BT.Run(func(context.Context) { c.RecvMsgs.Write(settled) })
+}
+
 func (c *Client) setUsrOnlineStatus(msg *domain.Message, online bool) {
 	convos := c.Conversations.Get()
 	lastOnline := msg.SentAt
@@ -285,10 +487,14 @@ func (c *Client) setUsrOnlineStatus(msg *domain.Message, online bool) {
 		lastOnline = nil
 	}
 	for i := range convos {
-		// offline/online user is in the convos
+		// offline/online user is in the convos, either directly or as a group member
 		if convos[i].UserID == msg.SenderID {
 			convos[i].LastOnline = lastOnline
-			break
+		}
+		for _, member := range convos[i].Members {
+			if member.UserID == msg.SenderID {
+				member.LastOnline = lastOnline
+			}
 		}
 	}
 	c.Conversations.Write(convos)
@@ -299,7 +505,21 @@ func ptr[T any](v T) *T {
 }
 
 func (c *Client) isValidReadUpdate(msg *domain.Message) bool {
-	return msg.SenderID != c.CurrentUsr.ID && msg.DeliveredAt != nil && msg.ReadAt == nil
+	return msg.SenderID != c.CurrentUsr.ID && msg.DeliveredAt != nil && msg.ReadAt == nil && !c.isConvoRequest(msg)
+}
+
+// isConvoRequest tells whether the msg belongs to a msg request yet to be accepted, the sender of the request
+// isn't told of the msgs being read, they're marked as read once the request is accepted & the msgs are viewed again
+func (c *Client) isConvoRequest(msg *domain.Message) bool {
+	if msg.ConversationID != nil {
+		return false
+	}
+	for _, convo := range c.Conversations.Get() {
+		if convo.UserID == msg.SenderID {
+			return convo.Request
+		}
+	}
+	return false
 }
 
 // once there is a message, we also update the conversations as the latest msg will also need update and save to db
@@ -23,8 +23,39 @@ func (c *Client) ResendOtp(email string) error {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return ErrTooManyAttempts
+	}
 	if resp.StatusCode != http.StatusAccepted {
 		return errors.New(http.StatusText(resp.StatusCode))
 	}
 	return nil
 }
+
+// RequestPasswordReset has the server mail the OTP to reset the password of the account with
+func (c *Client) RequestPasswordReset(email string) error {
+	body := struct {
+		Email string `json:"email"`
+	}{Email: email}
+	jsonBytes, err := json.Marshal(body)
+	if err != nil {
+		slog.Error(err.Error())
+		return err
+	}
+	resp, err := http.DefaultClient.Post(requestPasswordReset, "application/json", bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return nil
+	case http.StatusTooManyRequests:
+		return ErrTooManyAttempts
+	case http.StatusUnprocessableEntity:
+		return ErrUnauthorized
+	default:
+		return errors.New(http.StatusText(resp.StatusCode))
+	}
+}
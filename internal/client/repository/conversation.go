@@ -17,21 +17,40 @@ func NewLocalConversationRepository(db *DB) LocalConversationRepository {
 
 func (r LocalConversationRepository) SaveConversations(convos ...*domain.Conversation) error {
 	query := `
-		INSERT INTO conversation(user_id, username, user_email, last_online) 
-		VALUES (:user_id, :username, :user_email, :last_online)
+		INSERT INTO conversation(user_id, username, user_email, last_online, is_group, blocked, muted, pending, request) 
+		VALUES (:user_id, :username, :user_email, :last_online, :is_group, :blocked, :muted, :pending, :request)
 	`
 	for _, convo := range convos {
 		_, err := r.db.NamedExec(query, convo)
 		if err != nil {
 			return err
 		}
+		if err = r.saveGroupMembers(convo.UserID, convo.Members); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+func (r LocalConversationRepository) saveGroupMembers(groupID string, members []*domain.GroupMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	for _, m := range members { // the group id is not sent by the server
+		m.GroupID = groupID
+	}
+	query := `
+		INSERT OR REPLACE INTO group_member(group_id, user_id, username, user_email, role, last_online)
+		VALUES (:group_id, :user_id, :username, :user_email, :role, :last_online)
+	`
+	_, err := r.db.NamedExec(query, members)
+	return err
+}
+
 func (r LocalConversationRepository) DeleteAllConversations() error {
 	query := `
-		DELETE FROM conversation
+		DELETE FROM conversation;
+		DELETE FROM group_member;
 	`
 	_, err := r.db.Exec(query)
 	return err
@@ -39,13 +58,13 @@ func (r LocalConversationRepository) DeleteAllConversations() error {
 
 func (r LocalConversationRepository) GetConversationByUserID(id string) (*domain.Conversation, error) {
 	query := `
-		SELECT user_id, username, user_email, last_online
+		SELECT user_id, username, user_email, last_online, is_group, blocked, muted, pending, request
 		FROM conversation
 		WHERE user_id = :user_id  
 	`
 	var c domain.Conversation
 	var LastOnline any
-	args := []any{&c.UserID, &c.Username, &c.UserEmail, &LastOnline}
+	args := []any{&c.UserID, &c.Username, &c.UserEmail, &LastOnline, &c.IsGroup, &c.Blocked, &c.Muted, &c.Pending, &c.Request}
 	if err := r.db.QueryRow(query, id).Scan(args...); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, domain.ErrRecordNotFound
@@ -57,19 +76,25 @@ func (r LocalConversationRepository) GetConversationByUserID(id string) (*domain
 			c.LastOnline, _ = parseTime(&timeStr)
 		}
 	}
+	if c.IsGroup {
+		var err error
+		if c.Members, err = r.GetGroupMembers(c.UserID); err != nil {
+			return nil, err
+		}
+	}
 	return &c, nil
 }
 
 func (r LocalConversationRepository) GetConversations() ([]*domain.Conversation, error) {
 	query := `
-		SELECT user_id, username, user_email, last_online FROM conversation
+		SELECT user_id, username, user_email, last_online, is_group, blocked, muted, pending, request FROM conversation
 	`
 	rows, _ := r.db.Queryx(query)
 	convos := make([]*domain.Conversation, 0)
 	for rows.Next() {
 		var c domain.Conversation
 		var LastOnline any
-		args := []any{&c.UserID, &c.Username, &c.UserEmail, &LastOnline}
+		args := []any{&c.UserID, &c.Username, &c.UserEmail, &LastOnline, &c.IsGroup, &c.Blocked, &c.Muted, &c.Pending, &c.Request}
 		if err := rows.Scan(args...); err != nil {
 			return nil, err
 		}
@@ -84,5 +109,40 @@ func (r LocalConversationRepository) GetConversations() ([]*domain.Conversation,
 	if rows.Err() != nil {
 		return nil, rows.Err()
 	}
+	for _, c := range convos {
+		if !c.IsGroup {
+			continue
+		}
+		var err error
+		if c.Members, err = r.GetGroupMembers(c.UserID); err != nil {
+			return nil, err
+		}
+	}
 	return convos, nil
 }
+
+func (r LocalConversationRepository) GetGroupMembers(groupID string) ([]*domain.GroupMember, error) {
+	query := `
+		SELECT group_id, user_id, username, user_email, role, last_online
+		FROM group_member
+		WHERE group_id = $1
+	`
+	rows, err := r.db.Query(query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := make([]*domain.GroupMember, 0)
+	for rows.Next() {
+		var m domain.GroupMember
+		var LastOnline any
+		if err = rows.Scan(&m.GroupID, &m.UserID, &m.Username, &m.UserEmail, &m.Role, &LastOnline); err != nil {
+			return nil, err
+		}
+		if timeStr, ok := LastOnline.(time.Time); ok {
+			m.LastOnline = &timeStr
+		}
+		members = append(members, &m)
+	}
+	return members, rows.Err()
+}
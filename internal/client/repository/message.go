@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 type LocalMessageRepository struct {
@@ -20,7 +22,7 @@ func (r LocalMessageRepository) GetLatestMsgBodyForConvos(cui ...string) (Latest
 	query := `
 		SELECT body, sent_at
 		FROM message
-		WHERE sender_id = $1 OR receiver_id = $1
+		WHERE (conversation_id IS NULL AND (sender_id = $1 OR receiver_id = $1)) OR conversation_id = $1
 		ORDER BY sent_at DESC
 	`
 	msgs := make(LatestMsgs, len(cui))
@@ -50,7 +52,8 @@ func (r LocalMessageRepository) getUnreadMsgsCountForConvo(convoId string) (int6
 	query := `
 		SELECT COUNT(*)
 		FROM message
-		WHERE sender_id = $1 AND message.read_at IS NULL
+		WHERE message.read_at IS NULL
+		  AND ((conversation_id IS NULL AND sender_id = $1) OR (conversation_id = $1 AND receiver_id != $1))
 	`
 	var msgCount int64
 	if err := r.db.QueryRow(query, convoId).Scan(&msgCount); err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -61,13 +64,17 @@ func (r LocalMessageRepository) getUnreadMsgsCountForConvo(convoId string) (int6
 
 func (r LocalMessageRepository) GetMsgByID(id string) (*domain.Message, error) {
 	query := `
-		SELECT id, sender_id, receiver_id, body, sent_at, delivered_at, read_at, version
+		SELECT id, sender_id, receiver_id, conversation_id, reply_to_id, attachment_id, body, sent_at, delivered_at, read_at,
+		       edited_at, version
 		FROM message
 		WHERE id = $1
 	`
 	var msg domain.Message
-	var SentAt, DeliveredAt, ReadAt *string
-	args := []any{&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Body, &SentAt, &DeliveredAt, &ReadAt, &msg.Version}
+	var SentAt, DeliveredAt, ReadAt, EditedAt *string
+	args := []any{
+		&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.ConversationID, &msg.ReplyToID, &msg.AttachmentID, &msg.Body, &SentAt,
+		&DeliveredAt, &ReadAt, &EditedAt, &msg.Version,
+	}
 	if err := r.db.QueryRow(query, id).Scan(args...); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, domain.ErrRecordNotFound
@@ -77,18 +84,53 @@ func (r LocalMessageRepository) GetMsgByID(id string) (*domain.Message, error) {
 	msg.SentAt, _ = parseTime(SentAt)
 	msg.DeliveredAt, _ = parseTime(DeliveredAt)
 	msg.ReadAt, _ = parseTime(ReadAt)
+	msg.EditedAt, _ = parseTime(EditedAt)
 	return &msg, nil
 }
 
 func (r LocalMessageRepository) SaveMsg(msg *domain.Message) error {
 	query := `
-		INSERT INTO message (id, sender_id, receiver_id, body, sent_at, delivered_at, read_at)
-		VALUES (:id, :sender_id, :receiver_id, :body, :sent_at, :delivered_at, :read_at)
+		INSERT INTO message (id, sender_id, receiver_id, conversation_id, reply_to_id, attachment_id, body, sent_at, delivered_at, read_at, edited_at)
+		VALUES (:id, :sender_id, :receiver_id, :conversation_id, :reply_to_id, :attachment_id, :body, :sent_at, :delivered_at, :read_at, :edited_at)
 	`
 	_, err := r.db.NamedExec(query, msg)
 	return err
 }
 
+// SaveMsgsIfNotExists saves the msgs, skipping the ones already in the db, used for the msgs backfilled from the server
+func (r LocalMessageRepository) SaveMsgsIfNotExists(msgs []*domain.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	query := `
+		INSERT OR IGNORE INTO message (id, sender_id, receiver_id, conversation_id, reply_to_id, attachment_id, body, sent_at, delivered_at, read_at, edited_at)
+		VALUES (:id, :sender_id, :receiver_id, :conversation_id, :reply_to_id, :attachment_id, :body, :sent_at, :delivered_at, :read_at, :edited_at)
+	`
+	_, err := r.db.NamedExec(query, msgs)
+	return err
+}
+
+// GetOldestMsgForConvo returns the first msg exchanged with the user, domain.ErrRecordNotFound if there is none
+func (r LocalMessageRepository) GetOldestMsgForConvo(usrID string) (*domain.Message, error) {
+	query := `
+		SELECT id, sent_at
+		FROM message
+		WHERE (conversation_id IS NULL AND (sender_id = $1 OR receiver_id = $1)) OR conversation_id = $1
+		ORDER BY sent_at
+		LIMIT 1
+	`
+	var msg domain.Message
+	var SentAt *string
+	if err := r.db.QueryRow(query, usrID).Scan(&msg.ID, &SentAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	msg.SentAt, _ = parseTime(SentAt)
+	return &msg, nil
+}
+
 func (r LocalMessageRepository) UpdateMsg(msg *domain.Message) error {
 	query := `
 		UPDATE message 
@@ -112,27 +154,130 @@ func (r LocalMessageRepository) DeleteMsg(id string) error {
 	query := `
 		DELETE FROM message WHERE id = $1
 	`
+	if _, err := r.db.Exec(query, id); err != nil {
+		return err
+	}
+	query = `
+		DELETE FROM message_revision WHERE msg_id = $1
+	`
+	if _, err := r.db.Exec(query, id); err != nil {
+		return err
+	}
+	query = `
+		DELETE FROM message_reaction WHERE msg_id = $1
+	`
 	_, err := r.db.Exec(query, id)
 	return err
 }
 
+// EditMsg keeps the current body of the msg as a revision & sets the body of the edit, sent_at of the edit is
+// when it was edited
+func (r LocalMessageRepository) EditMsg(edit *domain.Message) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `
+		INSERT OR IGNORE INTO message_revision (msg_id, body, written_at)
+		SELECT id, body, COALESCE(edited_at, sent_at)
+		FROM message
+		WHERE id = $1 AND sent_at IS NOT NULL
+	`
+	if _, err = tx.Exec(query, edit.ID); err != nil {
+		return err
+	}
+	query = `
+		UPDATE message
+		SET body = $1,
+		    edited_at = $2,
+		    version = version + 1
+		WHERE id = $3
+	`
+	res, err := tx.Exec(query, edit.Body, edit.SentAt, edit.ID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return tx.Commit()
+}
+
+// GetMsgRevisions returns the prior bodies of the msg, oldest first
+func (r LocalMessageRepository) GetMsgRevisions(msgID string) ([]*domain.MessageRevision, error) {
+	query := `
+		SELECT body, written_at
+		FROM message_revision
+		WHERE msg_id = $1
+		ORDER BY written_at
+	`
+	rows, err := r.db.Query(query, msgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := make([]*domain.MessageRevision, 0)
+	for rows.Next() {
+		var rev domain.MessageRevision
+		var WrittenAt *string
+		if err = rows.Scan(&rev.Body, &WrittenAt); err != nil {
+			return nil, err
+		}
+		rev.WrittenAt, _ = parseTime(WrittenAt)
+		revisions = append(revisions, &rev)
+	}
+	return revisions, rows.Err()
+}
+
 func (r LocalMessageRepository) DeleteAllForSenderAndReceiver(senderId, receiverId string) error {
 	query := `
 		DELETE FROM message 
-        WHERE (sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1)
+        WHERE (conversation_id IS NULL AND ((sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1)))
+           OR conversation_id = $2
 	`
 	_, err := r.db.Exec(query, senderId, receiverId)
 	return err
 }
 
+// SetConvoClearedAt records when the conversation with the user was cleared, replacing the previous one
+func (r LocalMessageRepository) SetConvoClearedAt(usrID string, clearedAt time.Time) error {
+	query := `
+		INSERT INTO conversation_cleared (user_id, cleared_at)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id)
+		DO UPDATE SET cleared_at = EXCLUDED.cleared_at
+	`
+	_, err := r.db.Exec(query, usrID, clearedAt)
+	return err
+}
+
+// GetConvoClearedAt returns when the conversation with the user was last cleared, nil if it never was
+func (r LocalMessageRepository) GetConvoClearedAt(usrID string) (*time.Time, error) {
+	query := `
+		SELECT cleared_at
+		FROM conversation_cleared
+		WHERE user_id = $1
+	`
+	var clearedAt time.Time
+	if err := r.db.QueryRow(query, usrID).Scan(&clearedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &clearedAt, nil
+}
+
 func (r LocalMessageRepository) GetMsgsAsPage(
 	sen string,
 	fil domain.Filter,
 ) ([]*domain.Message, *domain.Metadata, error) {
 	query := `
-		SELECT COUNT(*) OVER(), id, sender_id, receiver_id, body, sent_at, delivered_at, read_at, version
+		SELECT COUNT(*) OVER(), id, sender_id, receiver_id, conversation_id, reply_to_id, attachment_id, body, sent_at,
+		       delivered_at, read_at, edited_at, version
 		FROM message
-		WHERE sender_id = $1 OR receiver_id = $1
+		WHERE (conversation_id IS NULL AND (sender_id = $1 OR receiver_id = $1)) OR conversation_id = $1
 		ORDER BY sent_at DESC
 		LIMIT $2
 	    OFFSET $3
@@ -143,16 +288,180 @@ func (r LocalMessageRepository) GetMsgsAsPage(
 	msgs := make([]*domain.Message, 0)
 	for rows.Next() {
 		var m domain.Message
-		var SentAt, DeliveredAt, ReadAt *string
-		args = []any{&TotalRows, &m.ID, &m.SenderID, &m.ReceiverID, &m.Body, &SentAt, &DeliveredAt, &ReadAt, &m.Version}
+		var SentAt, DeliveredAt, ReadAt, EditedAt *string
+		args = []any{
+			&TotalRows, &m.ID, &m.SenderID, &m.ReceiverID, &m.ConversationID, &m.ReplyToID, &m.AttachmentID, &m.Body, &SentAt,
+			&DeliveredAt, &ReadAt, &EditedAt, &m.Version,
+		}
 		if err := rows.Scan(args...); err != nil {
 			return nil, &domain.Metadata{}, err
 		}
 		m.SentAt, _ = parseTime(SentAt)
 		m.DeliveredAt, _ = parseTime(DeliveredAt)
 		m.ReadAt, _ = parseTime(ReadAt)
+		m.EditedAt, _ = parseTime(EditedAt)
 		msgs = append(msgs, &m)
 	}
+	if err := r.populateReactions(msgs); err != nil {
+		return nil, &domain.Metadata{}, err
+	}
+	if err := r.populateReplies(msgs); err != nil {
+		return nil, &domain.Metadata{}, err
+	}
 	metadata := domain.CalculateMetadata(TotalRows, fil.PageSize, fil.Page)
 	return msgs, &metadata, nil
 }
+
+// SaveMsgReceipt records the delivery/read state of the group msg for the member, keeping whatever is already recorded
+func (r LocalMessageRepository) SaveMsgReceipt(receipt *domain.MessageReceipt) error {
+	query := `
+		INSERT INTO message_receipt (msg_id, user_id, delivered_at, read_at)
+		VALUES (:msg_id, :user_id, :delivered_at, :read_at)
+		ON CONFLICT (msg_id, user_id)
+		DO UPDATE SET delivered_at = COALESCE(message_receipt.delivered_at, excluded.delivered_at),
+		              read_at = COALESCE(message_receipt.read_at, excluded.read_at)
+	`
+	_, err := r.db.NamedExec(query, receipt)
+	return err
+}
+
+func (r LocalMessageRepository) GetMsgReceipts(msgID string) ([]*domain.MessageReceipt, error) {
+	query := `
+		SELECT msg_id, user_id, delivered_at, read_at
+		FROM message_receipt
+		WHERE msg_id = $1
+	`
+	rows, err := r.db.Query(query, msgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	receipts := make([]*domain.MessageReceipt, 0)
+	for rows.Next() {
+		var receipt domain.MessageReceipt
+		var DeliveredAt, ReadAt *string
+		if err = rows.Scan(&receipt.MsgID, &receipt.UserID, &DeliveredAt, &ReadAt); err != nil {
+			return nil, err
+		}
+		receipt.DeliveredAt, _ = parseTime(DeliveredAt)
+		receipt.ReadAt, _ = parseTime(ReadAt)
+		receipts = append(receipts, &receipt)
+	}
+	return receipts, rows.Err()
+}
+
+// GetPendingMsgReceiptsCount returns the number of group members, other than the sender,
+// the msg is yet to be delivered to & read by
+func (r LocalMessageRepository) GetPendingMsgReceiptsCount(msgID, groupID, senderID string) (int64, int64, error) {
+	query := `
+		SELECT COALESCE(SUM(CASE WHEN mr.delivered_at IS NULL THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN mr.read_at IS NULL THEN 1 ELSE 0 END), 0)
+		FROM group_member gm
+		    LEFT JOIN message_receipt mr ON mr.msg_id = $1 AND mr.user_id = gm.user_id
+		WHERE gm.group_id = $2 AND gm.user_id != $3
+	`
+	var undelivered, unread int64
+	if err := r.db.QueryRow(query, msgID, groupID, senderID).Scan(&undelivered, &unread); err != nil {
+		return 0, 0, err
+	}
+	return undelivered, unread, nil
+}
+
+// SaveReaction adds the reaction of the user to the msg, a user reacts once per emoji
+func (r LocalMessageRepository) SaveReaction(reaction *domain.MessageReaction) error {
+	query := `
+		INSERT OR IGNORE INTO message_reaction (msg_id, user_id, emoji, reacted_at)
+		VALUES (:msg_id, :user_id, :emoji, :reacted_at)
+	`
+	_, err := r.db.NamedExec(query, reaction)
+	return err
+}
+
+func (r LocalMessageRepository) DeleteReaction(msgID, userID, emoji string) error {
+	query := `
+		DELETE FROM message_reaction
+		WHERE msg_id = $1 AND user_id = $2 AND emoji = $3
+	`
+	_, err := r.db.Exec(query, msgID, userID, emoji)
+	return err
+}
+
+// populateReactions sets the reactions of each msg, oldest first
+func (r LocalMessageRepository) populateReactions(msgs []*domain.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	ids := make([]string, len(msgs))
+	byID := make(map[string]*domain.Message, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ID
+		byID[m.ID] = m
+	}
+	query, args, err := sqlx.In(`
+		SELECT msg_id, user_id, emoji, reacted_at
+		FROM message_reaction
+		WHERE msg_id IN (?)
+		ORDER BY reacted_at
+	`, ids)
+	if err != nil {
+		return err
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var reaction domain.MessageReaction
+		var ReactedAt *string
+		if err = rows.Scan(&reaction.MsgID, &reaction.UserID, &reaction.Emoji, &ReactedAt); err != nil {
+			return err
+		}
+		reaction.ReactedAt, _ = parseTime(ReactedAt)
+		byID[reaction.MsgID].Reactions = append(byID[reaction.MsgID].Reactions, &reaction)
+	}
+	return rows.Err()
+}
+
+// populateReplies sets the msgs being replied to, as long as those are still there
+func (r LocalMessageRepository) populateReplies(msgs []*domain.Message) error {
+	var ids []string
+	for _, m := range msgs {
+		if m.ReplyToID != nil {
+			ids = append(ids, *m.ReplyToID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT id, sender_id, body
+		FROM message
+		WHERE id IN (?)
+	`, ids)
+	if err != nil {
+		return err
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	parents := make(map[string]*domain.Message)
+	for rows.Next() {
+		var parent domain.Message
+		if err = rows.Scan(&parent.ID, &parent.SenderID, &parent.Body); err != nil {
+			return err
+		}
+		parents[parent.ID] = &parent
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		if m.ReplyToID != nil {
+			m.ReplyTo = parents[*m.ReplyToID]
+		}
+	}
+	return nil
+}
@@ -26,10 +26,14 @@ const ( // Local Database tables for client side application
             id TEXT PRIMARY KEY,
             sender_id TEXT,
             receiver_id TEXT,
+            conversation_id TEXT, -- group of the msg, NULL if direct
+            reply_to_id TEXT, -- msg being replied to, if any
+            attachment_id TEXT, -- file sent along, if any
             body TEXT NOT NULL,
             sent_at TEXT,
             delivered_at DATETIME,
             read_at DATETIME,
+            edited_at DATETIME,
             version INTEGER NOT NULL DEFAULT 1
 		);
 		CREATE INDEX IF NOT EXISTS idx_message_sender_receiver_sent_at ON message(sender_id, receiver_id, sent_at DESC);
@@ -39,11 +43,84 @@ const ( // Local Database tables for client side application
             user_id TEXT NOT NULL,
             username TEXT NOT NULL,
             user_email TEXT NOT NULL,
-            last_online DATETIME
+            last_online DATETIME,
+            is_group BOOLEAN NOT NULL DEFAULT FALSE,
+            blocked BOOLEAN NOT NULL DEFAULT FALSE,
+            muted BOOLEAN NOT NULL DEFAULT FALSE,
+            pending BOOLEAN NOT NULL DEFAULT FALSE,
+            request BOOLEAN NOT NULL DEFAULT FALSE
+		);
+	`
+	createGroupMemberTable = `
+		CREATE TABLE IF NOT EXISTS group_member (
+            group_id TEXT NOT NULL,
+            user_id TEXT NOT NULL,
+            username TEXT NOT NULL,
+            user_email TEXT NOT NULL,
+            role TEXT NOT NULL,
+            last_online DATETIME,
+            PRIMARY KEY (group_id, user_id)
+		);
+	`
+	createMessageRevisionTable = `
+		-- Prior bodies of the edited msgs, written_at is when the revision was sent (or edited)
+		CREATE TABLE IF NOT EXISTS message_revision (
+            msg_id TEXT NOT NULL,
+            body TEXT NOT NULL,
+            written_at DATETIME NOT NULL,
+            PRIMARY KEY (msg_id, written_at)
+		);
+	`
+	createMessageReactionTable = `
+		CREATE TABLE IF NOT EXISTS message_reaction (
+            msg_id TEXT NOT NULL,
+            user_id TEXT NOT NULL,
+            emoji TEXT NOT NULL,
+            reacted_at DATETIME,
+            PRIMARY KEY (msg_id, user_id, emoji)
+		);
+	`
+	createContactKeyTable = `
+		-- Identity keys of the contacts, trusted on first use, changed is set once a contact's key changes till accepted
+		CREATE TABLE IF NOT EXISTS contact_key (
+            user_id TEXT PRIMARY KEY,
+            public_key BLOB NOT NULL,
+            updated_at DATETIME NOT NULL,
+            changed BOOLEAN NOT NULL DEFAULT FALSE
+		);
+	`
+	createMessageReceiptTable = `
+		-- Delivery & read state of the group msgs sent by the current user, per member
+		CREATE TABLE IF NOT EXISTS message_receipt (
+            msg_id TEXT NOT NULL,
+            user_id TEXT NOT NULL,
+            delivered_at DATETIME,
+            read_at DATETIME,
+            PRIMARY KEY (msg_id, user_id)
+		);
+	`
+	createConversationClearedTable = `
+		-- When the msgs of the conversation were cleared, the ones sent before are not backfilled from the server again
+		CREATE TABLE IF NOT EXISTS conversation_cleared (
+            user_id TEXT PRIMARY KEY,
+            cleared_at DATETIME NOT NULL
 		);
 	`
 )
 
+// columns added after the tables were first created, CREATE TABLE IF NOT EXISTS won't add them to existing databases
+var addedColumns = []struct{ table, column, definition string }{
+	{"message", "conversation_id", "TEXT"},
+	{"conversation", "is_group", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"message", "edited_at", "DATETIME"},
+	{"message", "reply_to_id", "TEXT"},
+	{"message", "attachment_id", "TEXT"},
+	{"conversation", "blocked", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"conversation", "muted", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"conversation", "pending", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"conversation", "request", "BOOLEAN NOT NULL DEFAULT FALSE"},
+}
+
 type DB struct {
 	*sqlx.DB
 }
@@ -80,5 +157,41 @@ func (db *DB) RunMigrations() error {
 	if _, err := db.ExecContext(ctx, createConversationTable); err != nil {
 		return err
 	}
+	if _, err := db.ExecContext(ctx, createGroupMemberTable); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, createMessageReceiptTable); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, createMessageRevisionTable); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, createMessageReactionTable); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, createContactKeyTable); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, createConversationClearedTable); err != nil {
+		return err
+	}
+	for _, c := range addedColumns {
+		if err := db.addColumnIfNotExists(ctx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func (db *DB) addColumnIfNotExists(ctx context.Context, table, column, definition string) error {
+	var count int
+	query := `SELECT COUNT(*) FROM pragma_table_info($1) WHERE name = $2`
+	if err := db.QueryRowContext(ctx, query, table, column).Scan(&count); err != nil {
+		return err
+	}
+	if count != 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v %v", table, column, definition))
+	return err
+}
@@ -57,3 +57,53 @@ func (r LocalUserRepository) DeletePreviousUser() error {
 	_, err := r.db.Exec(query)
 	return err
 }
+
+func (r LocalUserRepository) GetContactKey(usrID string) (*domain.UserKey, error) {
+	query := `
+		SELECT user_id, public_key, updated_at, changed
+		FROM contact_key
+		WHERE user_id = $1
+	`
+	var k domain.UserKey
+	if err := r.db.QueryRowx(query, usrID).StructScan(&k); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r LocalUserRepository) SaveContactKey(k *domain.UserKey) error {
+	query := `
+		INSERT INTO contact_key (user_id, public_key, updated_at, changed)
+		VALUES (:user_id, :public_key, :updated_at, :changed)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+		              public_key = EXCLUDED.public_key,
+		              updated_at = EXCLUDED.updated_at,
+		              changed = EXCLUDED.changed
+	`
+	_, err := r.db.NamedExec(query, k)
+	return err
+}
+
+// GetChangedContactKeys returns the IDs of the contacts whose key has changed & is yet to be accepted
+func (r LocalUserRepository) GetChangedContactKeys() ([]string, error) {
+	query := `
+		SELECT user_id FROM contact_key WHERE changed = TRUE
+	`
+	var ids []string
+	err := r.db.Select(&ids, query)
+	return ids, err
+}
+
+func (r LocalUserRepository) AcceptContactKey(usrID string) error {
+	query := `
+		UPDATE contact_key
+		SET changed = FALSE
+		WHERE user_id = $1
+	`
+	_, err := r.db.Exec(query, usrID)
+	return err
+}
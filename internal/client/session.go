@@ -0,0 +1,87 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// GetSessions returns the devices the current user is logged in on, the one of this client is marked as Current
+func (c *Client) GetSessions() ([]*domain.Session, error) {
+	r, err := http.NewRequest(http.MethodGet, getSessions, nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	default:
+		slog.Error(res.Status)
+		return nil, ErrApplication
+	}
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	var body struct {
+		Sessions []*domain.Session `json:"sessions"`
+	}
+	if err = json.Unmarshal(readBody, &body); err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	return body.Sessions, nil
+}
+
+// RevokeSession logs the device of the session out, returns ErrServerValidation if there's no such session
+func (c *Client) RevokeSession(id string) error {
+	r, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(revokeSession, id), nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusUnprocessableEntity, http.StatusNotFound:
+		return ErrServerValidation
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
+
+// deviceName names the session of this client after the host, cut to the length the server accepts
+func deviceName() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,331 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNoSSHKey = errors.New("none of the SSH keys in the ssh-agent or ~/.ssh are added to the account")
+
+// sshKeyFiles are the default private keys of OpenSSH, tried after the ones of the ssh-agent, the ones with a
+// passphrase are left to the agent
+var sshKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// LoginWithSSH logs in without the password, by signing a challenge with whichever key of the ssh-agent or
+// ~/.ssh is added to the account, returns ErrNoSSHKey if none is
+func (c *Client) LoginWithSSH(email string) error {
+	signers, closeAgent := sshSigners()
+	defer closeAgent()
+	for _, signer := range signers {
+		err := c.loginWithSigner(email, signer)
+		if errors.Is(err, ErrNoSSHKey) { // not this key, try the next one
+			continue
+		}
+		return err
+	}
+	return ErrNoSSHKey
+}
+
+// LocalSSHPublicKey returns the first key of the ssh-agent or ~/.ssh in the authorized_keys format
+func LocalSSHPublicKey() (string, error) {
+	signers, closeAgent := sshSigners()
+	defer closeAgent()
+	if len(signers) == 0 {
+		return "", errors.New("no SSH key found in the ssh-agent or ~/.ssh")
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signers[0].PublicKey()))), nil
+}
+
+func (c *Client) GetSSHKeys() ([]*domain.SSHKey, error) {
+	r, err := http.NewRequest(http.MethodGet, currentUserSSHKeys, nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	default:
+		slog.Error(res.Status)
+		return nil, ErrApplication
+	}
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	var body struct {
+		SSHKeys []*domain.SSHKey `json:"sshKeys"`
+	}
+	if err = json.Unmarshal(readBody, &body); err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	return body.SSHKeys, nil
+}
+
+// AddSSHKey adds the key to log in with, returns the reason as an error if the server does not accept it
+func (c *Client) AddSSHKey(name, publicKey string) (*domain.SSHKey, error) {
+	jsonBytes, err := json.Marshal(domain.SSHKeyAdd{Name: name, PublicKey: publicKey})
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	r, err := http.NewRequest(http.MethodPost, currentUserSSHKeys, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	switch res.StatusCode {
+	case http.StatusCreated:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusUnprocessableEntity:
+		var ev struct {
+			Errors map[string]string `json:"errors"`
+		}
+		if err = json.Unmarshal(readBody, &ev); err != nil {
+			slog.Error(err.Error())
+			return nil, ErrApplication
+		}
+		for field, e := range ev.Errors {
+			return nil, fmt.Errorf("%w, %v %v", ErrServerValidation, field, e)
+		}
+		return nil, ErrServerValidation
+	default:
+		slog.Error(res.Status)
+		return nil, ErrApplication
+	}
+	var body struct {
+		SSHKey *domain.SSHKey `json:"sshKey"`
+	}
+	if err = json.Unmarshal(readBody, &body); err != nil {
+		slog.Error(err.Error())
+		return nil, ErrApplication
+	}
+	return body.SSHKey, nil
+}
+
+// DeleteSSHKey returns ErrServerValidation if there's no such key
+func (c *Client) DeleteSSHKey(id string) error {
+	r, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(deleteSSHKey, id), nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusUnprocessableEntity, http.StatusNotFound:
+		return ErrServerValidation
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
+
+// AddLocalSSHKey adds the key LocalSSHPublicKey returns, named after this device
+func (c *Client) AddLocalSSHKey() (*domain.SSHKey, error) {
+	publicKey, err := LocalSSHPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	name := deviceName()
+	if name == "" {
+		name = "unknown device"
+	}
+	return c.AddSSHKey(name, publicKey)
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+// loginWithSigner returns ErrNoSSHKey if the key of the signer is not added to the account
+func (c *Client) loginWithSigner(email string, signer ssh.Signer) error {
+	fingerprint := ssh.FingerprintSHA256(signer.PublicKey())
+	challenge, err := c.requestSSHChallenge(email, fingerprint)
+	if err != nil {
+		return err
+	}
+	sig, err := signSSHChallenge(signer, challenge)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	jsonBytes, err := json.Marshal(domain.SSHAuth{
+		Challenge:   challenge,
+		Fingerprint: fingerprint,
+		Signature:   domain.SSHSignature{Format: sig.Format, Blob: sig.Blob},
+		Device:      deviceName(),
+	})
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	res, err := http.DefaultClient.Post(authenticateSSH, "application/json", bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return c.completeLogin(readBody, email)
+	case http.StatusAccepted:
+		return c.awaitTwoFactor(readBody, email)
+	case http.StatusTooManyRequests:
+		return ErrTooManyAttempts
+	case http.StatusUnprocessableEntity:
+		return ErrUnauthorized
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
+
+func (c *Client) requestSSHChallenge(email, fingerprint string) (string, error) {
+	jsonBytes, err := json.Marshal(domain.SSHChallengeRequest{Email: email, Fingerprint: fingerprint})
+	if err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	res, err := http.DefaultClient.Post(sshChallenge, "application/json", bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		slog.Error(err.Error())
+		return "", getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	switch res.StatusCode {
+	case http.StatusCreated:
+	case http.StatusTooManyRequests:
+		return "", ErrTooManyAttempts
+	case http.StatusUnprocessableEntity:
+		var ev struct {
+			Errors map[string]string `json:"errors"`
+		}
+		if err = json.Unmarshal(readBody, &ev); err != nil {
+			slog.Error(err.Error())
+			return "", ErrApplication
+		}
+		switch {
+		case ev.Errors["email"] == ErrNonActiveUser.Error():
+			return "", ErrNonActiveUser
+		case ev.Errors["fingerprint"] != "":
+			return "", ErrNoSSHKey
+		default:
+			return "", ErrUnauthorized
+		}
+	default:
+		slog.Error(res.Status)
+		return "", ErrApplication
+	}
+	var body struct {
+		Challenge string `json:"challenge"`
+	}
+	if err = json.Unmarshal(readBody, &body); err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	return body.Challenge, nil
+}
+
+// signSSHChallenge signs the namespaced challenge, the RSA keys with SHA-256 as the server refuses SHA-1
+func signSSHChallenge(signer ssh.Signer, challenge string) (*ssh.Signature, error) {
+	data := []byte(domain.SSHSignatureNamespace + challenge)
+	if as, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		return as.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA256)
+	}
+	return signer.Sign(rand.Reader, data)
+}
+
+// sshSigners returns the keys of the ssh-agent, if it's running, followed by the unencrypted default keys of
+// ~/.ssh not already in the agent, the returned func closes the connection to the agent
+func sshSigners() ([]ssh.Signer, func()) {
+	var signers []ssh.Signer
+	closeAgent := func() {}
+	seen := make(map[string]bool)
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			closeAgent = func() { conn.Close() }
+			agentSigners, err := agent.NewClient(conn).Signers()
+			if err != nil {
+				slog.Error(err.Error())
+			}
+			for _, s := range agentSigners {
+				seen[ssh.FingerprintSHA256(s.PublicKey())] = true
+				signers = append(signers, s)
+			}
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return signers, closeAgent
+	}
+	for _, name := range sshKeyFiles {
+		pemBytes, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		s, err := ssh.ParsePrivateKey(pemBytes)
+		if err != nil { // passphrase protected most likely
+			continue
+		}
+		if fp := ssh.FingerprintSHA256(s.PublicKey()); !seen[fp] {
+			seen[fp] = true
+			signers = append(signers, s)
+		}
+	}
+	return signers, closeAgent
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// accessTokenLeeway is how long before its expiry the access token is refreshed, so it doesn't expire in flight
+const accessTokenLeeway = 30 * time.Second
+
+// bearer returns the Authorization header value, refreshing the access token first if it's about to expire,
+// if the refresh fails the stale token is sent anyway & the request fails as unauthorized as it would have
+func (c *Client) bearer() string {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if c.refreshToken != "" && time.Until(c.accessDeadline) < accessTokenLeeway {
+		if err := c.refreshAuthTokens(); err != nil {
+			slog.Error("unable to refresh the access token", "err", err.Error())
+		}
+	}
+	return "Bearer " + c.AuthToken
+}
+
+// refreshAuthTokens swaps the refresh token for a new pair, must be called with the authMu held;
+// the refresh token is single use, so the new one replaces it in the keyring as well
+func (c *Client) refreshAuthTokens() error {
+	jsonBytes, err := json.Marshal(struct {
+		RefreshToken string `json:"refreshToken"`
+	}{RefreshToken: c.refreshToken})
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Post(refreshAuthToken, "application/json", bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		// revoked, expired or reused, nothing to refresh with anymore, the user has to log in again
+		c.setAuthTokens(&domain.AuthTokens{})
+		return ErrUnauthorized
+	default:
+		return fmt.Errorf("refreshing the access token, unexpected status %v", res.StatusCode)
+	}
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Tokens domain.AuthTokens `json:"tokens"`
+	}
+	if err = json.Unmarshal(readBody, &body); err != nil {
+		return err
+	}
+	c.setAuthTokens(&body.Tokens)
+	var label string
+	if c.CurrentUsr != nil {
+		label = c.CurrentUsr.Email
+	}
+	return c.krm.setAuthTokenInKeyring(label, c.refreshToken)
+}
+
+// setAuthTokens must be called with the authMu held, the zero value clears the tokens
+func (c *Client) setAuthTokens(t *domain.AuthTokens) {
+	c.AuthToken = t.AccessToken
+	c.refreshToken = t.RefreshToken
+	c.accessDeadline = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+}
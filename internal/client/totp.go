@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// GetTOTPStatus tells whether the current user has the two-factor authentication enabled
+func (c *Client) GetTOTPStatus() (bool, error) {
+	var body struct {
+		TOTP struct {
+			Enabled bool `json:"enabled"`
+		} `json:"totp"`
+	}
+	if err := c.doTOTPRequest(http.MethodGet, nil, http.StatusOK, &body); err != nil {
+		return false, err
+	}
+	return body.TOTP.Enabled, nil
+}
+
+// EnrollTOTP returns a new secret to add to an authenticator app, it's enabled once confirmed with ConfirmTOTP
+func (c *Client) EnrollTOTP() (*domain.TOTPEnrollment, error) {
+	var body struct {
+		TOTP *domain.TOTPEnrollment `json:"totp"`
+	}
+	if err := c.doTOTPRequest(http.MethodPost, nil, http.StatusCreated, &body); err != nil {
+		return nil, err
+	}
+	return body.TOTP, nil
+}
+
+// ConfirmTOTP enables the two-factor authentication with the first code of the authenticator app, returns the
+// recovery codes, returns ErrServerValidation if the code does not match
+func (c *Client) ConfirmTOTP(code string) ([]string, error) {
+	input := struct {
+		Code string `json:"code"`
+	}{Code: code}
+	var body struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	if err := c.doTOTPRequest(http.MethodPut, input, http.StatusOK, &body); err != nil {
+		return nil, err
+	}
+	return body.RecoveryCodes, nil
+}
+
+// DisableTOTP turns the two-factor authentication off, returns ErrServerValidation if the password does not match
+func (c *Client) DisableTOTP(password string) error {
+	return c.doTOTPRequest(http.MethodDelete, domain.UserDelete{Password: password}, http.StatusNoContent, nil)
+}
+
+// doTOTPRequest makes the request to the TOTP endpoint of the current user, the response body is decoded into dst
+// if the status is the wantStatus, dst may be nil
+func (c *Client) doTOTPRequest(method string, input any, wantStatus int, dst any) error {
+	var reqBody io.Reader
+	if input != nil {
+		jsonBytes, err := json.Marshal(input)
+		if err != nil {
+			slog.Error(err.Error())
+			return ErrApplication
+		}
+		reqBody = bytes.NewBuffer(jsonBytes)
+	}
+	r, err := http.NewRequest(method, currentUserTOTP, reqBody)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	if input != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case wantStatus:
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusUnprocessableEntity, http.StatusNotFound, http.StatusConflict:
+		return ErrServerValidation
+	case http.StatusTooManyRequests:
+		return ErrTooManyAttempts
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+	if dst == nil {
+		return nil
+	}
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	if err = json.Unmarshal(readBody, dst); err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/client/repository"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/M0hammadUsman/letschat/internal/sync"
@@ -12,7 +13,9 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 )
 
 // LoginState true -> successful login, false -> unauthorized requires login
@@ -61,6 +64,9 @@ func (*Client) Register(u *domain.UserRegister) error {
 }
 
 func (c *Client) Login(u domain.UserAuth) error {
+	if u.Device == "" {
+		u.Device = deviceName()
+	}
 	b, err := json.Marshal(u)
 	if err != nil {
 		slog.Error(err.Error())
@@ -77,31 +83,100 @@ func (c *Client) Login(u domain.UserAuth) error {
 		slog.Error(err.Error())
 		return err
 	}
-	if res.StatusCode == http.StatusOK {
-		var token struct {
-			Token string `json:"token"`
-		}
-		if err = json.Unmarshal(readBody, &token); err != nil {
-			slog.Error(err.Error())
-			return err
-		}
-		c.AuthToken = token.Token
-	} else {
+	if res.StatusCode == http.StatusTooManyRequests {
+		return ErrTooManyAttempts
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return c.completeLogin(readBody, u.Email)
+	case http.StatusAccepted:
+		return c.awaitTwoFactor(readBody, u.Email)
+	}
+	var ev struct {
+		Errors *domain.UserAuth `json:"errors"`
+	}
+	if err = json.Unmarshal(readBody, &ev); err != nil {
+		slog.Error(err.Error())
+		return err
+	}
+	if ev.Errors.Email == ErrNonActiveUser.Error() {
+		return ErrNonActiveUser
+	}
+	return ErrUnauthorized
+}
+
+// CompleteTwoFactorLogin completes the login that returned ErrTwoFactorRequired with a TOTP or a recovery code,
+// returns ErrServerValidation if the code does not match & ErrUnauthorized if the login has to be started over
+func (c *Client) CompleteTwoFactorLogin(code string) error {
+	b, err := json.Marshal(domain.TwoFactorAuth{Token: c.twoFactorToken, Code: code})
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	res, err := http.DefaultClient.Post(authenticateTwoFactor, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	readBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		email := c.twoFactorEmail
+		c.twoFactorToken, c.twoFactorEmail = "", ""
+		return c.completeLogin(readBody, email)
+	case http.StatusTooManyRequests:
+		return ErrTooManyAttempts
+	case http.StatusUnprocessableEntity:
 		var ev struct {
-			Errors *domain.UserAuth `json:"errors"`
+			Errors map[string]string `json:"errors"`
 		}
 		if err = json.Unmarshal(readBody, &ev); err != nil {
 			slog.Error(err.Error())
-			return err
+			return ErrApplication
 		}
-		if ev.Errors.Email == ErrNonActiveUser.Error() {
-			return ErrNonActiveUser
-		} else {
+		if _, ok := ev.Errors["twoFactorToken"]; ok { // expired, the password has to be entered again
 			return ErrUnauthorized
 		}
+		return ErrServerValidation
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
+
+// awaitTwoFactor keeps the token of the login response body to complete the login with, returns ErrTwoFactorRequired
+func (c *Client) awaitTwoFactor(readBody []byte, email string) error {
+	var body struct {
+		TwoFactorToken string `json:"twoFactorToken"`
+	}
+	if err := json.Unmarshal(readBody, &body); err != nil {
+		slog.Error(err.Error())
+		return err
+	}
+	c.twoFactorToken = body.TwoFactorToken
+	c.twoFactorEmail = email
+	return ErrTwoFactorRequired
+}
+
+// completeLogin keeps the tokens of the login response body & signals the authenticated user
+func (c *Client) completeLogin(readBody []byte, email string) error {
+	var body struct {
+		Tokens domain.AuthTokens `json:"tokens"`
+	}
+	if err := json.Unmarshal(readBody, &body); err != nil {
+		slog.Error(err.Error())
+		return err
 	}
-	// putting auth token in keyring
-	if err = c.krm.setAuthTokenInKeyring(u.Email, c.AuthToken); err != nil {
+	c.authMu.Lock()
+	c.setAuthTokens(&body.Tokens)
+	c.authMu.Unlock()
+	// putting the refresh token in keyring
+	if err := c.krm.setAuthTokenInKeyring(email, c.refreshToken); err != nil {
 		slog.Error(err.Error())
 		return err
 	}
@@ -112,7 +187,86 @@ func (c *Client) Login(u domain.UserAuth) error {
 	return nil
 }
 
+// DeleteAccount deletes the account of the current user for good, once the password is re-confirmed,
+// returns ErrServerValidation if the password does not match, the user is logged out on success
+func (c *Client) DeleteAccount(password string) error {
+	jsonBytes, err := json.Marshal(domain.UserDelete{Password: password})
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r, err := http.NewRequest(http.MethodDelete, deleteUser, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNoContent:
+		return c.Logout()
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusUnprocessableEntity:
+		return ErrServerValidation
+	case http.StatusTooManyRequests:
+		return ErrTooManyAttempts
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
+
+// ExportAccount saves all the server holds about the current user as a JSON file within the FilesDir,
+// returns the path of the saved file
+func (c *Client) ExportAccount() (string, error) {
+	r, err := http.NewRequest(http.MethodGet, exportUser, nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return "", getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", ErrUnauthorized
+	default:
+		slog.Error(res.Status)
+		return "", ErrApplication
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		return "", getMostNestedError(err)
+	}
+	path, err := freePath(c.FilesDir, "letschat-export-"+time.Now().Format("20060102-150405")+".json")
+	if err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	if err = os.WriteFile(path, body, 0o600); err != nil {
+		slog.Error(err.Error())
+		return "", ErrApplication
+	}
+	return path, nil
+}
+
 func (c *Client) Logout() error {
+	c.authMu.Lock()
+	c.setAuthTokens(&domain.AuthTokens{})
+	c.authMu.Unlock()
 	if err := c.krm.removeAuthTokenFromKeyring(); err != nil {
 		slog.Error(err.Error())
 		return err
@@ -121,10 +275,12 @@ func (c *Client) Logout() error {
 	return nil
 }
 
-func (c *Client) ActivateUser(otp string) error {
+func (c *Client) ActivateUser(email, otp string) error {
 	var token struct {
-		OTP string `json:"otp"`
+		Email string `json:"email"`
+		OTP   string `json:"otp"`
 	}
+	token.Email = email
 	token.OTP = otp
 	jsonBytes, err := json.Marshal(token)
 	if err != nil {
@@ -137,6 +293,9 @@ func (c *Client) ActivateUser(otp string) error {
 		return getMostNestedError(err)
 	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests {
+		return ErrTooManyAttempts
+	}
 	if res.StatusCode != http.StatusOK {
 		slog.Error(res.Status)
 		resBody, _ := io.ReadAll(res.Body)
@@ -146,6 +305,46 @@ func (c *Client) ActivateUser(otp string) error {
 	return nil
 }
 
+// ResetPassword sets the new password of the account, all the devices logged in are logged out
+func (c *Client) ResetPassword(pr domain.UserPasswordReset) error {
+	jsonBytes, err := json.Marshal(pr)
+	if err != nil {
+		slog.Error(err.Error())
+		return err
+	}
+	r, err := http.NewRequest(http.MethodPut, resetPassword, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		slog.Error(err.Error())
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusTooManyRequests:
+		return ErrTooManyAttempts
+	case http.StatusUnprocessableEntity:
+		var ev struct {
+			Errors map[string]string `json:"errors"`
+		}
+		resBody, _ := io.ReadAll(res.Body)
+		_ = json.Unmarshal(resBody, &ev)
+		if msg, ok := ev.Errors["password"]; ok {
+			return fmt.Errorf("password %v", msg)
+		}
+		return ErrExpiredOTP
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
+
 func (c *Client) UpdateUser(u domain.UserUpdate) (*domain.ErrValidation, int, error) {
 	jsonBytes, err := json.Marshal(u)
 	if err != nil {
@@ -158,7 +357,7 @@ func (c *Client) UpdateUser(u domain.UserUpdate) (*domain.ErrValidation, int, er
 		return nil, 0, ErrApplication
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	req.Header.Set("Authorization", c.bearer())
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		slog.Error(err.Error())
@@ -190,27 +389,106 @@ func (c *Client) UpdateUser(u domain.UserUpdate) (*domain.ErrValidation, int, er
 
 	}
 
-	if res.StatusCode == http.StatusOK {
-		// get and update the user
-		usr, _, _ := c.GetCurrentActiveUser()
-		c.CurrentUsr = usr
-		var retrievedUsr *domain.User
-		retrievedUsr, err = c.repo.GetCurrentUser()
-		if err != nil {
-			slog.Error(err.Error())
-			return nil, 0, ErrApplication
-		}
-		retrievedUsr.Name = usr.Name
-		retrievedUsr.Email = usr.Email
-		if err = c.repo.UpdateCurrentUser(retrievedUsr); err != nil {
-			slog.Error(err.Error())
-			return nil, 0, ErrApplication
+	// StatusAccepted is for the email change left pending, the rest of the update is applied all the same
+	if res.StatusCode == http.StatusOK || res.StatusCode == http.StatusAccepted {
+		if err = c.syncCurrentUser(); err != nil {
+			return nil, 0, err
 		}
 	}
 
 	return nil, res.StatusCode, nil
 }
 
+// ConfirmEmailChange applies the email change UpdateUser left pending, with the OTP mailed to the new email,
+// returns the reason as an error if the server does not accept the OTP
+func (c *Client) ConfirmEmailChange(otp string) error {
+	jsonBytes, err := json.Marshal(domain.UserEmailChange{OTP: otp})
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r, err := http.NewRequest(http.MethodPut, currentUserEmail, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNoContent:
+		return c.syncCurrentUser()
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrTooManyAttempts
+	case http.StatusUnprocessableEntity:
+		var ev struct {
+			Errors map[string]string `json:"errors"`
+		}
+		resBody, _ := io.ReadAll(res.Body)
+		_ = json.Unmarshal(resBody, &ev)
+		for field, e := range ev.Errors {
+			return fmt.Errorf("%w, %v %v", ErrServerValidation, field, e)
+		}
+		return ErrServerValidation
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
+
+// CancelEmailChange drops the pending email change, the OTP mailed for it is no longer valid
+func (c *Client) CancelEmailChange() error {
+	r, err := http.NewRequest(http.MethodDelete, currentUserEmail, nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	default:
+		slog.Error(res.Status)
+		return ErrApplication
+	}
+}
+
+// syncCurrentUser re-fetches the current user & saves the name & email of it
+func (c *Client) syncCurrentUser() error {
+	usr, _, err := c.GetCurrentActiveUser()
+	if err != nil {
+		return err
+	}
+	c.CurrentUsr = usr
+	retrievedUsr, err := c.repo.GetCurrentUser()
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	retrievedUsr.Name = usr.Name
+	retrievedUsr.Email = usr.Email
+	if err = c.repo.UpdateCurrentUser(retrievedUsr); err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	return nil
+}
+
 type PagedUserResponse struct {
 	Metadata domain.Metadata `json:"metadata"`
 	Users    []domain.User   `json:"users"`
@@ -222,7 +500,7 @@ func (c *Client) SearchUser(param string, page int) (*PagedUserResponse, int, er
 		slog.Error(err.Error())
 		return nil, 0, ErrApplication
 	}
-	r.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	r.Header.Set("Authorization", c.bearer())
 	v := r.URL.Query()
 	v.Set("param", param)
 	v.Set("page", strconv.Itoa(page))
@@ -248,7 +526,7 @@ func (c *Client) GetCurrentActiveUser() (*domain.User, int, error) {
 		slog.Error(err.Error())
 		return nil, 0, ErrApplication
 	}
-	r.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	r.Header.Set("Authorization", c.bearer())
 	resp, err := http.DefaultClient.Do(r)
 	if err != nil {
 		slog.Error(err.Error())
@@ -308,5 +586,48 @@ func (c *Client) manageUserLogins(shtdwnCtx context.Context) {
 		if err := c.repo.SaveCurrentUser(u); err != nil {
 			slog.Error("unable to save current user to local repo", "err", err.Error())
 		}
+		if err := c.setupIdentityKey(); err != nil {
+			slog.Error("unable to set up the identity key", "err", err.Error())
+		}
+	}
+}
+
+// BlockUser blocks the user or unblocks if block is false, the server then asks to sync the conversations
+func (c *Client) BlockUser(usrID string, block bool) error {
+	return c.updateUserRelation(blockUser, usrID, block)
+}
+
+// MuteUser mutes the user or unmutes if mute is false, the server then asks to sync the conversations
+func (c *Client) MuteUser(usrID string, mute bool) error {
+	return c.updateUserRelation(muteUser, usrID, mute)
+}
+
+func (c *Client) updateUserRelation(endpoint, usrID string, set bool) error {
+	method := http.MethodPut
+	if !set {
+		method = http.MethodDelete
+	}
+	r, err := http.NewRequest(method, fmt.Sprintf(endpoint, usrID), nil)
+	if err != nil {
+		slog.Error(err.Error())
+		return ErrApplication
+	}
+	r.Header.Set("Authorization", c.bearer())
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		slog.Error(err.Error())
+		return getMostNestedError(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusUnprocessableEntity, http.StatusNotFound:
+		return ErrServerValidation
+	default:
+		slog.Error(resp.Status)
+		return ErrApplication
 	}
 }
@@ -36,7 +36,7 @@ func newWsConnBroadcaster() *WsConnBroadcaster {
 // we read on WsConnStateChan for reconnection and stuff
 func (c *Client) wsConnectAndListenForMessages(shtdwnCtx context.Context) {
 	h := make(http.Header)
-	h.Set("Authorization", "Bearer "+c.AuthToken)
+	h.Set("Authorization", c.bearer())
 	opts := &websocket.DialOptions{
 		CompressionMode: websocket.CompressionContextTakeover,
 		HTTPHeader:      h,
@@ -86,6 +86,7 @@ func (c *Client) handleReceiveMessages(conn *websocket.Conn, shtdwnCtx context.C
 		if err := wsjson.Read(shtdwnCtx, conn, &msg); err != nil {
 			return err
 		}
+		c.decryptMsg(&msg)
 		c.RecvMsgs.Write(&msg)
 	}
 }
@@ -101,7 +102,14 @@ func (c *Client) handleSentMessages(conn *websocket.Conn, shtdwnCtx context.Cont
 		case msg := <-msgChan:
 			if msg.Operation == domain.DeliveredMsg {
 			}
-			if err := writeWithTimeout(conn, 2*time.Second, msg); err != nil {
+			// the server rejects the 1:1 msgs that are not encrypted, so ones that can't be are kept unsent
+			sent, err := c.encryptMsg(msg)
+			if err != nil {
+				slog.Error("unable to encrypt msg", "id", msg.ID, "err", err.Error())
+				doneChan <- false
+				continue
+			}
+			if err = writeWithTimeout(conn, 2*time.Second, sent); err != nil {
 				doneChan <- false
 				return err
 			}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,7 +19,7 @@ type BackgroundTask struct {
 	wg     sync.WaitGroup
 	ctx    context.Context
 	cancel context.CancelFunc
-	Tasks  int
+	tasks  atomic.Int64
 }
 
 func NewBackgroundTask() *BackgroundTask {
@@ -34,11 +35,11 @@ func NewBackgroundTask() *BackgroundTask {
 
 func (bt *BackgroundTask) Run(fn func(shtdwnCtx context.Context)) {
 	bt.wg.Add(1)
-	bt.Tasks++
+	bt.tasks.Add(1)
 	go func() {
 		defer func() {
 			bt.wg.Done()
-			bt.Tasks--
+			bt.tasks.Add(-1)
 			if r := recover(); r != nil {
 				slog.Error(fmt.Errorf("%v", r).Error())
 				debug.PrintStack()
@@ -59,10 +60,15 @@ func (bt *BackgroundTask) Shutdown(timeout time.Duration) error {
 	case <-wait:
 		return nil
 	case <-time.After(timeout):
-		return fmt.Errorf("shutdown timeout, some background tasks may not have finished, \"count\"=%v", bt.Tasks)
+		return fmt.Errorf("shutdown timeout, some background tasks may not have finished, \"count\"=%v", bt.Tasks())
 	}
 }
 
+// Tasks is the count of the tasks running
+func (bt *BackgroundTask) Tasks() int {
+	return int(bt.tasks.Load())
+}
+
 func (bt *BackgroundTask) GetShtdwnCtx() context.Context {
 	return bt.ctx
 }
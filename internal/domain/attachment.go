@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+)
+
+type Attachment struct {
+	ID          string    `json:"id"`
+	UploaderID  string    `json:"uploaderID"  db:"uploader_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType" db:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"      db:"sha256"` // hex encoded hash of the content
+	CreatedAt   time.Time `json:"createdAt"   db:"created_at"`
+}
+
+type AttachmentService interface {
+	// UploadAttachment stores the content read from r as an attachment of the user in the context
+	UploadAttachment(ctx context.Context, name, contentType string, r io.Reader) (*Attachment, error)
+	// OpenAttachment returns the attachment along with its content, only to the users it's shared with
+	OpenAttachment(ctx context.Context, id string) (*Attachment, io.ReadCloser, error)
+	// ShareAttachment lets the receivers download the attachment, the sender must have access to it as well
+	ShareAttachment(ctx context.Context, id, senderID string, receiverIDs ...string) error
+	GetUploadedAttachmentIDs(ctx context.Context, userID string) ([]string, error)
+	// DeleteAttachmentContents deletes the content of the attachments from the storage, the rows are left as is
+	DeleteAttachmentContents(ctx context.Context, ids ...string) error
+}
+
+type AttachmentRepository interface {
+	InsertAttachment(ctx context.Context, a *Attachment) error
+	GetAttachment(ctx context.Context, id string) (*Attachment, error)
+	InsertAttachmentGrant(ctx context.Context, id, userID string) error
+	HasAttachmentAccess(ctx context.Context, id, userID string) (bool, error)
+	GetUploadedAttachmentIDs(ctx context.Context, userID string) ([]string, error)
+}
+
+// AttachmentStorage keeps the content of the attachments, keyed by the attachment's ID
+type AttachmentStorage interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func ValidateAttachmentName(name string, ev *ErrValidation) {
+	ev.Evaluate(name != "", "name", "must be provided")
+	ev.Evaluate(len(name) <= 255, "name", "must be no more than 255 bytes long")
+	ev.Evaluate(utf8.ValidString(name), "name", "must be valid UTF-8")
+	ev.Evaluate(!strings.ContainsAny(name, `/\`) && name != "." && name != "..", "name", "must be a file name, not a path")
+}
@@ -12,8 +12,20 @@ type Conversation struct {
 	UserID    string `json:"userID"          db:"user_id"`
 	Username  string `json:"username"        db:"username"`
 	UserEmail string `json:"userEmail"       db:"user_email"`
-	// status of user other than the currently logged-in user, can be either sender or receiver
+	// status of user other than the currently logged-in user, can be either sender or receiver,
+	// not disclosed to the sender of a msg request till it's accepted
 	LastOnline *time.Time `json:"lastOnline" db:"last_online"`
+	// for group conversations, UserID is the group's ID & Username is the group's name
+	IsGroup bool           `json:"isGroup"           db:"is_group"`
+	Members []*GroupMember `json:"members,omitempty" db:"-"`
+	// the current user has blocked the user of the conversation, always false for the groups
+	Blocked bool `json:"blocked" db:"blocked"`
+	// the current user has muted the user of the conversation, msgs are delivered but not flagged as unread
+	Muted bool `json:"muted" db:"muted"`
+	// the conversation was started by someone the receiver has never talked to, and is yet to be accepted
+	Pending bool `json:"pending" db:"pending"`
+	// a pending conversation, the current user is the receiver & is to accept or decline it
+	Request bool `json:"request" db:"request"`
 	// latest msg to display under user's name in TUI, only used on frontend side
 	LatestMsg       *string    `json:"-"`
 	LatestMsgSentAt *time.Time `json:"-"`
@@ -30,10 +42,22 @@ type ConversationService interface {
 	CreateConversation(ctx context.Context, senderID, receiverID string) (bool, error)
 	GetConversations(ctx context.Context) ([]*Conversation, error)
 	ConversationExists(ctx context.Context, senderID, receiverID string) (bool, error)
+	AcceptConversationRequest(ctx context.Context, senderID string) error
+	DeclineConversationRequest(ctx context.Context, senderID string) error
+	IsConversationRequest(ctx context.Context, senderID, receiverID string) (bool, error)
+	DeleteAllConversationsForUser(ctx context.Context, usrID string) error
 }
 
 type ConversationRepository interface {
+	// CreateConversation creates a pending conversation, i.e. a msg request, to be accepted by the receiver
 	CreateConversation(ctx context.Context, senderID, receiverID string) (bool, error)
 	GetConversations(ctx context.Context, usrID string) ([]*Conversation, error)
 	ConversationExists(ctx context.Context, senderID, receiverID string) (bool, error)
+	// AcceptConversation accepts the pending conversation, ErrRecordNotFound if there is no such request
+	AcceptConversation(ctx context.Context, senderID, receiverID string) error
+	// DeletePendingConversation declines the request, ErrRecordNotFound if there is no such request
+	DeletePendingConversation(ctx context.Context, senderID, receiverID string) error
+	PendingConversationExists(ctx context.Context, senderID, receiverID string) (bool, error)
+	// DeleteAllConversationsForUser deletes the direct conversations the user is part of, either side
+	DeleteAllConversationsForUser(ctx context.Context, usrID string) error
 }
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+)
+
+// Digest lists the senders of the msgs the offline user has yet to receive, mailed to the user once the msgs are
+// old enough, the bodies are left out, they may well be end-to-end encrypted anyway
+type Digest struct {
+	UserID  string
+	Name    string
+	Email   string
+	Senders []*DigestSender
+	// LastSentAt is the time the previous digest was mailed to the user, nil if none was
+	LastSentAt *time.Time
+}
+
+type DigestSender struct {
+	Name  string
+	Count int
+}
+
+// DigestSetting is whether the user gets the digests, all the users do unless they opt out
+type DigestSetting struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Total is the count of all the msgs in the digest
+func (d *Digest) Total() int {
+	var total int
+	for _, s := range d.Senders {
+		total += s.Count
+	}
+	return total
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	ErrDuplicateEmail = errors.New("duplicate email")
@@ -8,6 +11,9 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 	ErrAlreadyActive  = errors.New("user already active")
 	ErrInactive       = errors.New("user inactive")
+	ErrNoMsgHistory   = errors.New("message history is not enabled")
+	ErrTOTPEnabled    = errors.New("two-factor authentication already enabled")
+	ErrSSHKeyExists   = errors.New("ssh key already added")
 )
 
 type ErrValidation struct {
@@ -36,3 +42,33 @@ func (e *ErrValidation) Evaluate(ok bool, field, message string) {
 		e.AddError(field, message)
 	}
 }
+
+// ErrLockedOut is returned while the account is locked after too many failed password or OTP attempts
+type ErrLockedOut struct {
+	Until time.Time
+}
+
+func (ErrLockedOut) Error() string {
+	return "account temporarily locked"
+}
+
+// ErrRefreshTokenReused is returned when a refresh token already rotated out is presented again, meaning it was
+// stolen, the session it belonged to is revoked
+type ErrRefreshTokenReused struct {
+	UserID    string
+	SessionID string
+}
+
+func (ErrRefreshTokenReused) Error() string {
+	return "refresh token reused"
+}
+
+// ErrTwoFactorRequired is returned when the password is right but the user has the second factor enabled,
+// the login is to be completed with the Token & a code
+type ErrTwoFactorRequired struct {
+	Token string
+}
+
+func (ErrTwoFactorRequired) Error() string {
+	return "two-factor authentication required"
+}
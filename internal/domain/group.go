@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
+
+var (
+	ErrNotGroupMember = errors.New("not a group member")
+	ErrNotGroupAdmin  = errors.New("not a group admin")
+)
+
+type Group struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"createdBy" db:"created_by"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	Version   int       `json:"-"`
+}
+
+type GroupMember struct {
+	GroupID    string     `json:"-"          db:"group_id"`
+	UserID     string     `json:"userID"     db:"user_id"`
+	Username   string     `json:"username"   db:"username"`
+	UserEmail  string     `json:"userEmail"  db:"user_email"`
+	Role       string     `json:"role"       db:"role"`
+	LastOnline *time.Time `json:"lastOnline" db:"last_online"`
+}
+
+// MessageReceipt is the delivery & read state of a group msg for a single member, only used on frontend side
+type MessageReceipt struct {
+	MsgID       string     `db:"msg_id"`
+	UserID      string     `db:"user_id"`
+	DeliveredAt *time.Time `db:"delivered_at"`
+	ReadAt      *time.Time `db:"read_at"`
+}
+
+type GroupService interface {
+	CreateGroup(ctx context.Context, g *GroupCreate) (*Group, error)
+	AddGroupMember(ctx context.Context, groupID string, m *GroupMemberAdd) error
+	RemoveGroupMember(ctx context.Context, groupID, userID string) error
+	LeaveGroup(ctx context.Context, groupID string) error
+	GetGroupMembers(ctx context.Context, groupID string) ([]*GroupMember, error)
+	IsGroupMember(ctx context.Context, groupID, userID string) (bool, error)
+	AcceptGroupInvite(ctx context.Context, groupID string) error
+	DeclineGroupInvite(ctx context.Context, groupID string) error
+}
+
+type GroupRepository interface {
+	InsertGroup(ctx context.Context, g *Group) (string, error)
+	DeleteGroup(ctx context.Context, groupID string) error
+	// InsertGroupMember adds the user to the group, or just invites if the user has no accepted conversation with
+	// the one adding, the invite is to be accepted like a msg request, on conflict only the role is updated
+	InsertGroupMember(ctx context.Context, groupID, userID, role, addedByID string) error
+	// AcceptGroupMember accepts the invite to the group, ErrRecordNotFound if there is no such invite
+	AcceptGroupMember(ctx context.Context, groupID, userID string) error
+	// DeletePendingGroupMember declines the invite to the group, ErrRecordNotFound if there is no such invite
+	DeletePendingGroupMember(ctx context.Context, groupID, userID string) error
+	PendingGroupMemberExists(ctx context.Context, groupID, userID string) (bool, error)
+	DeleteGroupMember(ctx context.Context, groupID, userID string) error
+	// GetGroupMember & GetGroupMembers only return the members who have accepted, the invited ones are left out
+	GetGroupMember(ctx context.Context, groupID, userID string) (*GroupMember, error)
+	GetGroupMembers(ctx context.Context, groupID string) ([]*GroupMember, error)
+	PromoteOldestGroupMember(ctx context.Context, groupID string) error
+}
+
+// DTOs
+
+type GroupCreate struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+type GroupMemberAdd struct {
+	UserID string `json:"userID"`
+	Role   string `json:"role"`
+}
+
+func ValidateGroupName(name string, ev *ErrValidation) {
+	ev.Evaluate(name != "", "name", "must be provided")
+	ev.Evaluate(len(name) <= 64, "name", "must be no more than 64 bytes long")
+}
+
+func ValidateGroupMembers(members []string, ev *ErrValidation) {
+	ev.Evaluate(len(members) <= 256, "members", "must be no more than 256")
+	for _, id := range members {
+		if !rgxUUID.MatchString(id) {
+			ev.AddError("members", "must only contain valid UUIDs")
+			return
+		}
+	}
+}
+
+func ValidateGroupRole(role string, ev *ErrValidation) {
+	ev.Evaluate(role == GroupRoleAdmin || role == GroupRoleMember, "role", "must be either admin or member")
+}
+
+func ValidateUUID(id string, ev *ErrValidation, errKey string) {
+	ev.Evaluate(rgxUUID.MatchString(id), errKey, "must be a valid UUID")
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Mail is the mail as rendered from the template, the ones failed to be sent are queued to be retried
+type Mail struct {
+	ID        string
+	Recipient string
+	Subject   string
+	// Text is the plain-text alternative of the HTML
+	Text          string `db:"text_body"`
+	HTML          string `db:"html_body"`
+	Attempts      int
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+	// Expiry is when the mail is no longer worth sending, e.g. the OTP in it has expired by then
+	Expiry    time.Time
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type MailRepository interface {
+	// InsertMail queues the mail to be sent at the NextAttemptAt
+	InsertMail(ctx context.Context, m *Mail) error
+	// ClaimDueMails returns up to the limit of the unexpired mails due to be sent, they're not due again for the
+	// lease, so the other nodes don't pick them as well
+	ClaimDueMails(ctx context.Context, limit int, lease time.Duration) ([]*Mail, error)
+	// RescheduleMail counts the failed attempt & sets the time of the next one
+	RescheduleMail(ctx context.Context, id string, next time.Time) error
+	DeleteMail(ctx context.Context, id string) error
+	// DeleteExpiredMails returns the count of the mails deleted
+	DeleteExpiredMails(ctx context.Context) (int64, error)
+}
@@ -2,8 +2,12 @@ package domain
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type MsgOperation int
@@ -37,6 +41,27 @@ const (
 	// not to be persisted, as we only want to send this for conversations' online users
 	// offline ones will fetch from the server, when the TUI starts
 	SyncConvosMsg
+	// EditMsg indicates the sender has edited this msg, the body is the new revision & sent_at is when it was edited
+	EditMsg
+	// EditConfirmMsg indicates the receiver's acknowledgment of the edited message.
+	// not to be persisted
+	EditConfirmMsg
+	// ReactMsg indicates the sender has reacted to the msg with the emoji in the body, one reaction per emoji per user
+	ReactMsg
+	// UnreactMsg indicates the sender has removed the reaction with the emoji in the body
+	UnreactMsg
+	// ReactConfirmMsg indicates the receiver's acknowledgment of the (un)reaction, the body holds the emoji.
+	// not to be persisted
+	ReactConfirmMsg
+	// SessionRevokedMsg tells the connections of the user that the session with the ID in the body is revoked,
+	// the server closes the connection of that session instead of delivering it; not to be persisted
+	SessionRevokedMsg
+)
+
+const (
+	maxMsgBodyLen = 4096
+	// base64 of the 12 bytes nonce, the max body & the 16 bytes tag
+	maxEncryptedMsgBodyLen = (12 + maxMsgBodyLen + 16 + 2) / 3 * 4
 )
 
 var (
@@ -44,15 +69,37 @@ var (
 )
 
 type Message struct {
-	ID          string       `json:"id,omitempty"`
-	SenderID    string       `json:"senderID,omitempty"     db:"sender_id"`
-	ReceiverID  string       `json:"receiverID,omitempty"   db:"receiver_id"`
-	Body        string       `json:"body,omitempty"`
-	SentAt      *time.Time   `json:"sent_at,omitempty"      db:"sent_at"`
-	DeliveredAt *time.Time   `json:"delivered_at,omitempty" db:"delivered_at"`
-	ReadAt      *time.Time   `json:"read_at,omitempty"      db:"read_at"`
-	Version     int          `json:"-"`
-	Operation   MsgOperation `json:"operation"              db:"operation"`
+	ID             string       `json:"id,omitempty"`
+	SenderID       string       `json:"senderID,omitempty"       db:"sender_id"`
+	ReceiverID     string       `json:"receiverID,omitempty"     db:"receiver_id"`
+	ConversationID *string      `json:"conversationID,omitempty" db:"conversation_id"` // group of the msg, nil if direct
+	ReplyToID      *string      `json:"replyToID,omitempty"      db:"reply_to_id"`     // msg being replied to, if any
+	AttachmentID   *string      `json:"attachmentID,omitempty"   db:"attachment_id"`   // file sent along, if any
+	Body           string       `json:"body,omitempty"`
+	Encrypted      bool         `json:"encrypted,omitempty"      db:"encrypted"` // the body is end-to-end encrypted
+	SentAt         *time.Time   `json:"sent_at,omitempty"        db:"sent_at"`
+	DeliveredAt    *time.Time   `json:"delivered_at,omitempty"   db:"delivered_at"`
+	ReadAt         *time.Time   `json:"read_at,omitempty"        db:"read_at"`
+	EditedAt       *time.Time   `json:"edited_at,omitempty"      db:"edited_at"`
+	Version        int          `json:"-"`
+	Operation      MsgOperation `json:"operation"                db:"operation"`
+	// only used on frontend side
+	Reactions []*MessageReaction `json:"-" db:"-"`
+	ReplyTo   *Message           `json:"-" db:"-"` // the msg being replied to, if it's still there
+}
+
+// MessageReaction is the emoji a user has reacted with to the msg, only used on frontend side
+type MessageReaction struct {
+	MsgID     string     `db:"msg_id"`
+	UserID    string     `db:"user_id"`
+	Emoji     string     `db:"emoji"`
+	ReactedAt *time.Time `db:"reacted_at"`
+}
+
+// MessageRevision is a prior body of an edited msg, only used on frontend side
+type MessageRevision struct {
+	Body      string
+	WrittenAt *time.Time
 }
 
 type MsgChan chan *Message
@@ -62,23 +109,184 @@ type MessageService interface {
 	ProcessSentMessages(ctx context.Context, m *Message) error
 	GetUnDeliveredMessages(ctx context.Context, c MsgChan) error
 	SaveMessage(ctx context.Context, m *Message) error
+	GetMessageHistory(ctx context.Context, withUsrID string, cursor *MessageCursor, pageSize int) ([]*Message, *MessageCursor, error)
+	FanOutMessage(m *Message, memberIDs []string) []*Message
+	// RecordMessageSender keeps the sender of the new msg, for its edits to be validated against, even once delivered
+	RecordMessageSender(ctx context.Context, m *Message) error
+	ValidateMessageEdit(ctx context.Context, m *Message) error
+	// GetPendingMessages returns the msgs to & from the user in the context, yet to be delivered
+	GetPendingMessages(ctx context.Context) ([]*Message, error)
+	DeleteAllMessagesForUser(ctx context.Context, usrID string) error
+	// GetDueDigests returns up to the limit of the digests due to the offline users, the ones with a msg yet to be
+	// delivered, older than the olderThan & sent after their last digest, who've not opted out
+	GetDueDigests(ctx context.Context, olderThan time.Duration, limit int) ([]*Digest, error)
 }
 
 type MessageRepository interface {
-	GetByID(ctx context.Context, id string, op MsgOperation) (*Message, error)
+	GetByID(ctx context.Context, id, senderID, receiverID string, op MsgOperation) (*Message, error)
 	GetUnDeliveredMessages(ctx context.Context, rcvrID string, op MsgOperation, c MsgChan) error
 	InsertMessage(ctx context.Context, m *Message) error
-	DeleteMessage(ctx context.Context, mID string) error
+	DeleteMessage(ctx context.Context, mID, senderID, receiverID string) error
+	InsertMessageHistory(ctx context.Context, m *Message) error
+	UpdateMessageHistory(ctx context.Context, m *Message) error
+	DeleteMessageHistory(ctx context.Context, m *Message) error
+	EditMessageHistory(ctx context.Context, m *Message) error
+	// InsertMessageSender records the sender of the new msg, the first one sending a msg with the ID stays its sender
+	InsertMessageSender(ctx context.Context, id, senderID string) error
+	GetMessageSenderID(ctx context.Context, id string) (string, error)
+	UpsertReaction(ctx context.Context, m *Message) error
+	DeleteReaction(ctx context.Context, mID, senderID, receiverID, emoji string) error
+	GetUnDeliveredReactions(ctx context.Context, rcvrID string, c MsgChan) error
+	GetMessageHistory(ctx context.Context, usrID, withUsrID string, cursor *MessageCursor, limit int) ([]*Message, error)
+	GetPendingMessages(ctx context.Context, usrID string) ([]*Message, error)
+	// DeleteAllMessagesForUser deletes the undelivered msgs sent to or by the user
+	DeleteAllMessagesForUser(ctx context.Context, usrID string) error
+	// GetDueDigests counts the undelivered CreateMsg msgs of the users due a digest, per sender
+	GetDueDigests(ctx context.Context, olderThan time.Duration, limit int) ([]*Digest, error)
 }
 
 // DTO
 
+// MessageSent for the group msgs must have the ConversationID set, for the ops fanned out to the members
+// the ReceiverID must be the group's ID as well
 type MessageSent struct {
-	ID          *string      `json:"id"`
-	ReceiverID  string       `json:"receiverID"`
-	Body        *string      `json:"body"`
-	SentAt      *time.Time   `json:"sent_at"`
-	DeliveredAt *time.Time   `json:"delivered_at"`
-	ReadAt      *time.Time   `json:"read_at"`
-	Operation   MsgOperation `json:"operation"`
+	ID             *string      `json:"id"`
+	ReceiverID     string       `json:"receiverID"`
+	ConversationID *string      `json:"conversationID"`
+	ReplyToID      *string      `json:"replyToID"`
+	AttachmentID   *string      `json:"attachmentID"`
+	Body           *string      `json:"body"`
+	Encrypted      bool         `json:"encrypted"`
+	SentAt         *time.Time   `json:"sent_at"`
+	DeliveredAt    *time.Time   `json:"delivered_at"`
+	ReadAt         *time.Time   `json:"read_at"`
+	Operation      MsgOperation `json:"operation"`
+}
+
+func (m *MessageSent) ValidateMessageSent() *ErrValidation {
+	ev := NewErrValidation()
+	switch m.Operation {
+	case CreateMsg, DeliveredMsg, DeliveredConfirmMsg, ReadMsg, ReadConfirmMsg, DeleteMsg, DeleteConfirmMsg, TypingMsg,
+		EditMsg, EditConfirmMsg, ReactMsg, UnreactMsg, ReactConfirmMsg:
+	default: // OnlineMsg, OfflineMsg, SyncConvosMsg & SessionRevokedMsg are only sent by the server
+		ev.AddError("operation", "invalid operation")
+	}
+	if m.ID != nil {
+		ev.Evaluate(rgxUUID.MatchString(*m.ID), "id", "must be a valid UUID")
+	} else {
+		ev.Evaluate(m.Operation == CreateMsg, "id", "must be provided")
+	}
+	ev.Evaluate(rgxUUID.MatchString(m.ReceiverID), "receiverID", "must be a valid UUID")
+	if m.ConversationID != nil {
+		ev.Evaluate(rgxUUID.MatchString(*m.ConversationID), "conversationID", "must be a valid UUID")
+		if m.IsFannedOut() {
+			ev.Evaluate(*m.ConversationID == m.ReceiverID, "receiverID", "must be the conversationID for group msgs")
+		}
+	}
+	if m.ReplyToID != nil {
+		ev.Evaluate(m.Operation == CreateMsg, "replyToID", "must only be provided for a new msg")
+		ev.Evaluate(rgxUUID.MatchString(*m.ReplyToID), "replyToID", "must be a valid UUID")
+		ev.Evaluate(m.ID == nil || *m.ReplyToID != *m.ID, "replyToID", "must not be the msg itself")
+	}
+	if m.AttachmentID != nil {
+		ev.Evaluate(m.Operation == CreateMsg, "attachmentID", "must only be provided for a new msg")
+		ev.Evaluate(rgxUUID.MatchString(*m.AttachmentID), "attachmentID", "must be a valid UUID")
+	}
+	if m.Encrypted {
+		ev.Evaluate(m.Operation == CreateMsg || m.Operation == EditMsg, "encrypted", "must only be set for a new or edited msg")
+		ev.Evaluate(m.ConversationID == nil, "encrypted", "must not be set for group msgs")
+	}
+	if m.Operation == CreateMsg || m.Operation == EditMsg {
+		// the server only ever relays ciphertext for 1:1 conversations
+		ev.Evaluate(m.ConversationID != nil || m.Encrypted, "encrypted", "must be set, the body must be end-to-end encrypted")
+		ev.Evaluate(m.Body != nil && *m.Body != "", "body", "must be provided")
+		if m.Encrypted {
+			ev.Evaluate(m.Body == nil || len(*m.Body) <= maxEncryptedMsgBodyLen, "body", "must not be more than 4096 bytes long")
+		} else {
+			ev.Evaluate(m.Body == nil || len(*m.Body) <= maxMsgBodyLen, "body", "must not be more than 4096 bytes long")
+		}
+		ev.Evaluate(m.SentAt != nil, "sent_at", "must be provided")
+	}
+	if m.Operation == ReactMsg || m.Operation == UnreactMsg || m.Operation == ReactConfirmMsg {
+		ValidateEmoji(m.Body, ev)
+	}
+	return ev
+}
+
+// MessageCursor points to a msg in the history, pages are fetched from newest to oldest,
+// so the next page holds the msgs sent before the cursor
+type MessageCursor struct {
+	SentAt time.Time
+	ID     string
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// CursorFromMessage returns the cursor pointing to the given msg
+func CursorFromMessage(m *Message) *MessageCursor {
+	if m == nil || m.SentAt == nil {
+		return nil
+	}
+	return &MessageCursor{SentAt: *m.SentAt, ID: m.ID}
+}
+
+// Encode returns the opaque form of the cursor, to be used as a query param
+func (c *MessageCursor) Encode() string {
+	if c == nil {
+		return ""
+	}
+	raw := c.SentAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeMessageCursor parses the cursor returned by MessageCursor.Encode, an empty cursor means from the newest msg
+func DecodeMessageCursor(s string) (*MessageCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	sentAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || !rgxUUID.MatchString(id) {
+		return nil, errInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, sentAt)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	return &MessageCursor{SentAt: t, ID: id}, nil
+}
+
+// IsFannedOut reports whether the msg is addressed to a group & has to be copied to every member,
+// the acknowledgments (delivered, read & the confirms) are exchanged between the sender & the member directly
+func (m *MessageSent) IsFannedOut() bool {
+	if m.ConversationID == nil {
+		return false
+	}
+	switch m.Operation {
+	case CreateMsg, DeleteMsg, TypingMsg, EditMsg, ReactMsg, UnreactMsg:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateEmoji ensures the reaction is a single, short emoji (may be composed of a few code points)
+func ValidateEmoji(emoji *string, ev *ErrValidation) {
+	ev.Evaluate(emoji != nil && strings.TrimSpace(*emoji) != "", "body", "must be provided")
+	ev.Evaluate(emoji == nil || len(*emoji) <= 32, "body", "must not be more than 32 bytes long")
+	ev.Evaluate(emoji == nil || utf8.RuneCountInString(*emoji) <= 8, "body", "must be a single emoji")
+}
+
+// ValidateMessageEditor ensures the msg is edited by no one other than its original sender
+func ValidateMessageEditor(ev *ErrValidation, originalSndrID, editorID string) {
+	ev.Evaluate(originalSndrID == editorID, "id", "must be a msg sent by you to be edited")
+}
+
+func ValidateMessageHistoryParams(ev *ErrValidation, withUsrID string, pageSize int) {
+	ev.Evaluate(rgxUUID.MatchString(withUsrID), "userID", "must be a valid UUID")
+	ev.Evaluate(pageSize > 0, "size", "must be greater than zero")
+	ev.Evaluate(pageSize <= 100, "size", "must be a max of 100")
 }
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// SSHSignatureNamespace is prepended to the challenge before it's signed, so the signature is of no use
+	// anywhere else the key is trusted
+	SSHSignatureNamespace = "letschat-login-v1:"
+	MaxSSHKeysPerUser     = 10
+)
+
+// SSHKey is an SSH public key the user logs in with, instead of the password
+type SSHKey struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"-"           db:"user_id"`
+	Name        string     `json:"name"`
+	Fingerprint string     `json:"fingerprint"`
+	PublicKey   string     `json:"publicKey"   db:"public_key"` // authorized_keys format
+	CreatedAt   time.Time  `json:"createdAt"   db:"created_at"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"  db:"last_used_at"`
+}
+
+// DTOs
+
+type SSHKeyAdd struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"` // authorized_keys format, the comment if any is dropped
+}
+
+// SSHChallengeRequest asks for a challenge to sign with the private key of the fingerprint
+type SSHChallengeRequest struct {
+	Email       string `json:"email"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// SSHSignature is the ssh.Signature as sent over the wire
+type SSHSignature struct {
+	Format string `json:"format"`
+	Blob   []byte `json:"blob"` // base64 encoded
+}
+
+// SSHAuth is the signature of SSHSignatureNamespace + Challenge, made with the private key of the fingerprint
+type SSHAuth struct {
+	Challenge   string       `json:"challenge"`
+	Fingerprint string       `json:"fingerprint"`
+	Signature   SSHSignature `json:"signature"`
+	Device      string       `json:"device"`
+	IP          string       `json:"-"`
+}
+
+func ValidateSSHKeyName(name string, ev *ErrValidation) {
+	ev.Evaluate(strings.TrimSpace(name) != "", "name", "must be provided")
+	ev.Evaluate(len(name) <= 64, "name", "must be no more than 64 bytes long")
+}
+
+func ValidateSSHFingerprint(fingerprint string, ev *ErrValidation) {
+	ev.Evaluate(strings.HasPrefix(fingerprint, "SHA256:"), "fingerprint", "must be a SHA256 fingerprint")
+	ev.Evaluate(len(fingerprint) <= 64, "fingerprint", "must be no more than 64 bytes long")
+}
+
+func ValidateSSHSignature(sig SSHSignature, ev *ErrValidation) {
+	ev.Evaluate(sig.Format != "", "signature", "must be provided")
+	ev.Evaluate(len(sig.Blob) > 0 && len(sig.Blob) <= 1024, "signature", "must be no more than 1024 bytes long")
+}
@@ -7,10 +7,23 @@ import (
 )
 
 const (
-	ScopeActivation        = "activation"
-	ScopeAuthentication    = "authentication"
-	ScopeActivationTTL     = 15 * time.Minute
+	ScopeActivation = "activation"
+	// ScopeAuthentication tokens are the refresh tokens, one per session, rotated on every refresh;
+	// the requests are authenticated with the short-lived signed access tokens issued against them
+	ScopeAuthentication = "authentication"
+	ScopePasswordReset  = "password-reset"
+	// ScopeEmailChange OTPs are mailed to the email the user is changing to, the change is applied once confirmed
+	ScopeEmailChange = "email-change"
+	// ScopeTwoFactor tokens stand for the password already checked, while the second factor is yet to be
+	ScopeTwoFactor = "two-factor"
+	// ScopeSSHChallenge tokens are the challenges signed with an SSH key to log in, single use
+	ScopeSSHChallenge = "ssh-challenge"
+	// The TTLs are the defaults of the -auth-*-ttl flags, OTPTTL is the one of the activation, password reset
+	// & email change OTPs
+	OTPTTL                 = 15 * time.Minute
 	ScopeAuthenticationTTL = 7 * 24 * time.Hour
+	ScopeTwoFactorTTL      = 5 * time.Minute
+	ScopeSSHChallengeTTL   = 2 * time.Minute
 )
 
 var (
@@ -18,27 +31,110 @@ var (
 )
 
 type Token struct {
+	ID        string    `json:"-"`
 	PlainText string    `json:"plainText"`
 	Hash      []byte    `json:"-"`
 	UserID    string    `json:"-" db:"user_id"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	// Session related, only set for the ScopeAuthentication tokens
+	Device string `json:"-"`
+	IP     string `json:"-"`
+}
+
+// AuthTokens is what a login or a refresh hands out, the RefreshToken replaces the one refreshed with
+type AuthTokens struct {
+	AccessToken string `json:"accessToken"`
+	// ExpiresIn is the seconds the AccessToken is valid for, relative so the clocks of the client & server may differ
+	ExpiresIn    int    `json:"expiresIn"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Session is an auth token as shown to its user, the token itself is never shown back
+type Session struct {
+	ID         string     `json:"id"`
+	Device     string     `json:"device"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"  db:"created_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt" db:"last_used_at"`
+	Expiry     time.Time  `json:"expiry"`
+	// Current is the session the request was made with
+	Current bool `json:"current" db:"-"`
 }
 
 type TokenService interface {
 	GenerateToken(ctx context.Context, userID string, scope string) (string, error)
 	DeleteAllForUser(ctx context.Context, userID string, scope string) error
+	// OTPTTL is how long the mailed OTPs are valid for, the mails tell it to the user
+	OTPTTL() time.Duration
+	// GenerateSessionToken generates the refresh token of a new session, along with the device & IP it's issued to
+	GenerateSessionToken(ctx context.Context, userID, device, ip string) (*Token, error)
+	// GenerateTwoFactorToken generates the token to complete the login with the second factor, the device & IP
+	// are carried over to the session
+	GenerateTwoFactorToken(ctx context.Context, userID, device, ip string) (string, error)
+	// GetTwoFactorToken returns the unexpired ScopeTwoFactor token
+	GetTwoFactorToken(ctx context.Context, plainToken string) (*Token, error)
+	DeleteToken(ctx context.Context, id string) error
+	// ConsumeSSHChallenge deletes the unexpired ScopeSSHChallenge token & returns it, so it's only ever used once
+	ConsumeSSHChallenge(ctx context.Context, plainToken string) (*Token, error)
+	// RotateRefreshToken swaps the refresh token for a new one of the same session, if the token was already
+	// rotated out the session is revoked & ErrRefreshTokenReused is returned
+	RotateRefreshToken(ctx context.Context, plainToken string) (*Token, error)
+	// GenerateAccessToken signs a short-lived access token for the session, returns it along with its TTL
+	GenerateAccessToken(userID, sessionID string) (string, time.Duration, error)
+	// VerifyAccessToken checks the signature & expiry of the access token, without a trip to the DB,
+	// the returned user only has the ID & SessionID set
+	VerifyAccessToken(token string) (*User, error)
+	// GetSessions returns the active sessions of the user in the context
+	GetSessions(ctx context.Context) ([]*Session, error)
+	// DeleteSession revokes the session of the user in the context
+	DeleteSession(ctx context.Context, id string) error
+	// DeleteAllSessions revokes every session of the user, returns the revoked ones with the ID & UserID set
+	DeleteAllSessions(ctx context.Context, userID string) ([]*Token, error)
 }
 
 type TokenRepository interface {
 	Insert(ctx context.Context, token *Token) error
 	DeleteAllForUser(ctx context.Context, userID, scope string) error
+	// GetByHash returns the unexpired token of the scope
+	GetByHash(ctx context.Context, scope string, hash []byte) (*Token, error)
+	Delete(ctx context.Context, id string) error
+	// DeleteByHash deletes the unexpired token of the scope & returns it
+	DeleteByHash(ctx context.Context, scope string, hash []byte) (*Token, error)
+	GetSessions(ctx context.Context, userID string) ([]*Session, error)
+	DeleteSession(ctx context.Context, id, userID string) error
+	// DeleteAllSessions deletes the ScopeAuthentication tokens of the user & returns them, with the ID & UserID set
+	DeleteAllSessions(ctx context.Context, userID string) ([]*Token, error)
+	// RotateRefreshToken replaces the hash & expiry of the session with the oldHash by the ones of the token,
+	// keeping the oldHash as rotated, sets the ID & UserID of the token to the ones of the session
+	RotateRefreshToken(ctx context.Context, oldHash []byte, token *Token) error
+	// DeleteRotatedSession deletes the session the hash was rotated out of, returns the deleted session
+	DeleteRotatedSession(ctx context.Context, hash []byte) (*Token, error)
 }
 
 func ValidateOTP(otp string, ev *ErrValidation) {
 	ev.Evaluate(RgxOtp.MatchString(otp), "otp", "invalid sequence")
 }
 
+func ValidateDevice(device string, ev *ErrValidation) {
+	ev.Evaluate(len(device) <= 64, "device", "must be no more than 64 bytes long")
+}
+
+func ValidateRefreshToken(token string, ev *ErrValidation) {
+	ev.Evaluate(token != "", "refreshToken", "must be provided")
+	ev.Evaluate(len(token) == 26, "refreshToken", "must be 26 bytes long")
+}
+
+func ValidateTwoFactorToken(token string, ev *ErrValidation) {
+	ev.Evaluate(token != "", "twoFactorToken", "must be provided")
+	ev.Evaluate(len(token) == 26, "twoFactorToken", "must be 26 bytes long")
+}
+
+func ValidateSSHChallenge(token string, ev *ErrValidation) {
+	ev.Evaluate(token != "", "challenge", "must be provided")
+	ev.Evaluate(len(token) == 26, "challenge", "must be 26 bytes long")
+}
+
 func ValidateAuthenticationToken(token string, ev *ErrValidation) {
 	ev.Evaluate(token != "", "token", "must be provided")
 	ev.Evaluate(len(token) == 26, "token", "must be 26 bytes long")
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	TOTPIssuer = "Letschat"
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is the time steps either side of the current one a code is accepted in, for the clocks that drift
+	TOTPSkew          = 1
+	RecoveryCodeCount = 10
+)
+
+var (
+	RgxTOTPCode     = regexp.MustCompile("^[0-9]{6}$")
+	RgxRecoveryCode = regexp.MustCompile("^[a-z2-7]{10}$")
+)
+
+// TOTP is the second factor of the user, it's enabled once the first code generated with the secret is confirmed
+type TOTP struct {
+	UserID       string    `db:"user_id"`
+	Secret       []byte    `db:"secret"`
+	Enabled      bool      `db:"enabled"`
+	LastUsedStep int64     `db:"last_used_step"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// DTOs
+
+// TOTPEnrollment is shown to the user to add the secret to their authenticator app, the URI as a QR code
+type TOTPEnrollment struct {
+	Secret string `json:"secret"` // base32 encoded
+	URI    string `json:"uri"`
+}
+
+// TwoFactorAuth is the second login step, the Code is either a TOTP or one of the recovery codes
+type TwoFactorAuth struct {
+	Token string `json:"twoFactorToken"`
+	Code  string `json:"code"`
+}
+
+// NormalizeRecoveryCode lets the recovery codes be entered in any case, with or without the separator
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+func ValidateTOTPCode(code string, ev *ErrValidation) {
+	ev.Evaluate(RgxTOTPCode.MatchString(code), "code", "must be 6 digits")
+}
+
+// ValidateSecondFactorCode accepts either a TOTP code or a recovery code
+func ValidateSecondFactorCode(code string, ev *ErrValidation) {
+	ev.Evaluate(code != "", "code", "must be provided")
+	if code != "" && !RgxTOTPCode.MatchString(code) && !RgxRecoveryCode.MatchString(NormalizeRecoveryCode(code)) {
+		ev.AddError("code", "must be 6 digits or a recovery code")
+	}
+}
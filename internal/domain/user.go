@@ -20,22 +20,81 @@ type User struct {
 	LastOnline *time.Time `json:"lastOnline,omitempty" db:"last_online"`
 	CreatedAt  time.Time  `json:"createdAt"  db:"created_at"`
 	Version    int        `json:"-"`
+	// SessionID is the ID of the auth token the user is authenticated with
+	SessionID string `json:"-" db:"session_id"`
 	// Websocket related
 	Messages  MsgChan `json:"-"`
 	CloseSlow func()  `json:"-"`
 }
 
+// UserKey is the public X25519 identity key of the user, the 1:1 msgs are encrypted with a key derived from
+// the identity keys of both the users, a single key per account, the private key only lives on the device that
+// published it
+type UserKey struct {
+	UserID    string    `json:"userID"    db:"user_id"`
+	PublicKey []byte    `json:"publicKey" db:"public_key"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	// only used on frontend side, the key of the contact differs from the one trusted before, till it's accepted
+	Changed bool `json:"-" db:"changed"`
+}
+
 type UserService interface {
 	RegisterUser(ctx context.Context, u *UserRegister) (string, error)
 	ExistsUser(ctx context.Context, email string) (bool, error)
 	GetByUniqueField(ctx context.Context, fieldValue string) (*User, error)
+	// UpdateUser updates the name & the password, the email is changed with RequestEmailChange instead
 	UpdateUser(ctx context.Context, u *UserUpdate) error
+	// RequestEmailChange sets the email the user in the context is changing to, returns the user as it's before
+	// the change, nil if the email is the same
+	RequestEmailChange(ctx context.Context, email string) (*User, error)
+	// VerifyEmailChange returns the email the user in the context is changing to, if the OTP is theirs, failed
+	// attempts count towards lockout
+	VerifyEmailChange(ctx context.Context, plainOTP string) (string, error)
+	// ApplyEmailChange changes the email of the user in the context to the pending one
+	ApplyEmailChange(ctx context.Context, email string) error
+	CancelEmailChange(ctx context.Context) error
+	// IsDigestEnabled returns whether the user in the context gets the digests of the msgs yet to be delivered
+	IsDigestEnabled(ctx context.Context) (bool, error)
+	SetDigestEnabled(ctx context.Context, enabled bool) error
+	// ClaimDigest marks the digest as sent to the user, returns false if it was already claimed, e.g. by some
+	// other node, since the lastSentAt
+	ClaimDigest(ctx context.Context, userID string, lastSentAt *time.Time) (bool, error)
 	UpdateUserOnlineStatus(ctx context.Context, usr *User, online bool) error
 	GetForToken(ctx context.Context, scope string, plainToken string) (*User, error)
 	ActivateUser(ctx context.Context, user *User) error
 	AuthenticateUser(ctx context.Context, u *UserAuth) (string, error)
+	// VerifyOTP returns the user with the email if the OTP of the scope is theirs, failed attempts count towards lockout
+	VerifyOTP(ctx context.Context, scope, email, plainOTP string) (*User, error)
+	ResetPassword(ctx context.Context, user *User, newPassword string) error
+	// ConfirmPassword re-confirms the password of the user in the context, failed attempts count towards lockout
+	ConfirmPassword(ctx context.Context, password string) error
+	DeleteUser(ctx context.Context) error
+	// EnrollTOTP generates a new secret for the user in the context, pending till it's confirmed with ConfirmTOTP
+	EnrollTOTP(ctx context.Context) (*TOTPEnrollment, error)
+	// ConfirmTOTP enables the pending TOTP once the code matches, returns the recovery codes, only ever shown once
+	ConfirmTOTP(ctx context.Context, code string) ([]string, error)
+	DisableTOTP(ctx context.Context) error
+	IsTOTPEnabled(ctx context.Context, userID string) (bool, error)
+	// VerifySecondFactor checks the TOTP or recovery code of the user, failed attempts count towards lockout
+	VerifySecondFactor(ctx context.Context, userID, code string) error
+	AddSSHKey(ctx context.Context, k *SSHKeyAdd) (*SSHKey, error)
+	GetSSHKeys(ctx context.Context) ([]*SSHKey, error)
+	DeleteSSHKey(ctx context.Context, id string) error
+	// GetSSHLoginUserID returns the ID of the user with the email, if the key of the fingerprint is theirs
+	GetSSHLoginUserID(ctx context.Context, r *SSHChallengeRequest) (string, error)
+	// AuthenticateSSH verifies the signature of the challenge with the key of the user, failed attempts count
+	// towards lockout
+	AuthenticateSSH(ctx context.Context, userID string, a *SSHAuth) error
 	GetByQuery(ctx context.Context, queryParam string, filter Filter) ([]*User, *Metadata, error)
 	SetOnlineUsersLastSeen(ctx context.Context, t time.Time) error
+	SetUserKey(ctx context.Context, publicKey []byte) (*UserKey, error)
+	GetUserKey(ctx context.Context, userID string) (*UserKey, error)
+	BlockUser(ctx context.Context, userID string) error
+	UnblockUser(ctx context.Context, userID string) error
+	IsUserBlocked(ctx context.Context, userID, byUserID string) (bool, error)
+	GetBlockerIDs(ctx context.Context, userID string) ([]string, error)
+	MuteUser(ctx context.Context, userID string) error
+	UnmuteUser(ctx context.Context, userID string) error
 }
 
 type UserRepository interface {
@@ -45,8 +104,53 @@ type UserRepository interface {
 	UpdateUser(ctx context.Context, u *User) error
 	GetForToken(ctx context.Context, scope string, hash []byte) (*User, error)
 	ActivateUser(ctx context.Context, user *User) error
-	GetByQuery(ctx context.Context, paramName string, paramValue string, filter Filter) ([]*User, *Metadata, error)
+	// GetByQuery searches the activated users, except the ones blocked by the user with the usrID
+	GetByQuery(ctx context.Context, usrID, paramName, paramValue string, filter Filter) ([]*User, *Metadata, error)
 	SetOnlineUsersLastSeen(ctx context.Context, t time.Time) error
+	UpsertUserKey(ctx context.Context, k *UserKey) error
+	GetUserKey(ctx context.Context, userID string) (*UserKey, error)
+	InsertUserBlock(ctx context.Context, userID, blockedUserID string) error
+	DeleteUserBlock(ctx context.Context, userID, blockedUserID string) error
+	UserBlockExists(ctx context.Context, userID, blockedUserID string) (bool, error)
+	// GetBlockerIDs returns the IDs of the users who have blocked the user
+	GetBlockerIDs(ctx context.Context, userID string) ([]string, error)
+	InsertUserMute(ctx context.Context, userID, mutedUserID string) error
+	DeleteUserMute(ctx context.Context, userID, mutedUserID string) error
+	// GetLockedUntil returns the time the account is locked till, nil if it's not locked
+	GetLockedUntil(ctx context.Context, userID string) (*time.Time, error)
+	// InsertFailedAttempt counts a failed attempt, once they reach the maxAttempts within the window since the first
+	// of them, the account is locked for the lockout duration & the count starts over, the count also starts over
+	// once the window has passed, returns the time the account is locked till, if it's locked
+	InsertFailedAttempt(ctx context.Context, userID string, maxAttempts int, window, lockout time.Duration) (*time.Time, error)
+	DeleteFailedAttempts(ctx context.Context, userID string) error
+	// DeleteUser deletes the user, along with the tokens, keys & everything else cascading from the user
+	DeleteUser(ctx context.Context, userID string) error
+	// UpsertPendingTOTP sets the secret of the TOTP yet to be confirmed, ErrTOTPEnabled if it's already enabled
+	UpsertPendingTOTP(ctx context.Context, userID string, secret []byte) error
+	GetTOTP(ctx context.Context, userID string) (*TOTP, error)
+	EnableTOTP(ctx context.Context, userID string, step int64) error
+	// SetTOTPLastUsedStep only moves the step forward, returns ErrEditConflict if the step was already used
+	SetTOTPLastUsedStep(ctx context.Context, userID string, step int64) error
+	DeleteTOTP(ctx context.Context, userID string) error
+	// ReplaceRecoveryCodes deletes the previous recovery codes of the user, if any, & inserts the hashes
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes [][]byte) error
+	// DeleteRecoveryCode uses the recovery code up, returns ErrRecordNotFound if the user has no such code
+	DeleteRecoveryCode(ctx context.Context, userID string, hash []byte) error
+	// InsertSSHKey returns ErrSSHKeyExists if the user already has the key
+	InsertSSHKey(ctx context.Context, k *SSHKey) error
+	GetSSHKeys(ctx context.Context, userID string) ([]*SSHKey, error)
+	GetSSHKey(ctx context.Context, userID, fingerprint string) (*SSHKey, error)
+	DeleteSSHKey(ctx context.Context, id, userID string) error
+	SetSSHKeyLastUsed(ctx context.Context, id string) error
+	UpsertPendingEmail(ctx context.Context, userID, email string) error
+	GetPendingEmail(ctx context.Context, userID string) (string, error)
+	DeletePendingEmail(ctx context.Context, userID string) error
+	// GetDigestOptOut returns false if the user has not opted out of the digests
+	GetDigestOptOut(ctx context.Context, userID string) (bool, error)
+	SetDigestOptOut(ctx context.Context, userID string, optOut bool) error
+	// SetDigestSent sets the last digest of the user as sent now, only if the last one was sent at the lastSentAt,
+	// returns false otherwise
+	SetDigestSent(ctx context.Context, userID string, lastSentAt *time.Time) (bool, error)
 }
 
 // DTOs
@@ -60,6 +164,37 @@ type UserRegister struct {
 type UserAuth struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Device names the session, optional
+	Device string `json:"device"`
+	// IP is filled in by the server
+	IP string `json:"-"`
+}
+
+type UserPasswordReset struct {
+	Email    string `json:"email"`
+	OTP      string `json:"otp"`
+	Password string `json:"password"`
+}
+
+// UserEmailChange confirms the pending email change with the OTP mailed to the new email
+type UserEmailChange struct {
+	OTP string `json:"otp"`
+}
+
+type UserDelete struct {
+	Password string `json:"password"`
+}
+
+// UserExport is all the server holds about the user, the msgs are only held till they're delivered
+type UserExport struct {
+	Profile             *User           `json:"profile"`
+	Conversations       []*Conversation `json:"conversations"`
+	UndeliveredMessages []*Message      `json:"undeliveredMessages"`
+	ExportedAt          time.Time       `json:"exportedAt"`
+}
+
+type UserKeySet struct {
+	PublicKey []byte `json:"publicKey"` // base64 encoded
 }
 
 type UserUpdate struct {
@@ -98,3 +233,8 @@ func ValidPlainPasswordWithKey(pass string, ev *ErrValidation, errKey string) {
 	ev.Evaluate(pass == "" || len(pass) >= 8, errKey, "must be at least 8 bytes long")
 	ev.Evaluate(len(pass) <= 72, errKey, "must no be more than 72 bytes long")
 }
+
+func ValidatePublicKey(key []byte, ev *ErrValidation) {
+	ev.Evaluate(len(key) != 0, "publicKey", "must be provided")
+	ev.Evaluate(len(key) == 0 || len(key) == 32, "publicKey", "must be a 32 bytes X25519 key")
+}
@@ -215,6 +215,22 @@ var ( // Conversation Styling
 
 	conversationAgoTimestampStyle = lipgloss.NewStyle().
 					Foreground(orangeColor)
+
+	conversationGroupMembersStyle = lipgloss.NewStyle().
+					Foreground(primarySubtleDarkColor)
+
+	// muted & blocked conversations
+	conversationFlagStyle = lipgloss.NewStyle().
+				Foreground(darkGreyColor)
+
+	// chats & requests sections of the conversations
+	conversationSectionStyle = lipgloss.NewStyle().
+					Foreground(lightGreyColor).
+					Padding(0, 1)
+
+	conversationActiveSectionStyle = conversationSectionStyle.
+					Foreground(primaryColor).
+					Underline(true)
 )
 
 var (
@@ -234,6 +250,38 @@ var (
 
 	chatHeaderHeight, chatTextareaHeight int // used by ChatModel.chatViewport for its height calculations
 
+	chatHeaderMembersStyle = lipgloss.NewStyle().
+				Foreground(lightGreyColor).
+				Bold(false).
+				Faint(true)
+
+	chatBubbleReactionsStyle = lipgloss.NewStyle().
+					Foreground(lightGreyColor).
+					Margin(0, 1)
+
+	chatBubbleSenderStyle = lipgloss.NewStyle().
+				Foreground(primarySubtleDarkColor).
+				Italic(true).
+				Margin(0, 1)
+
+	chatReplyQuoteStyle = lipgloss.NewStyle().
+				BorderStyle(lipgloss.ThickBorder()).
+				BorderLeft(true).
+				BorderForeground(primarySubtleDarkColor).
+				Foreground(lightGreyColor).
+				Faint(true).
+				PaddingLeft(1)
+
+	chatConvoRequestStyle = lipgloss.NewStyle().
+				Foreground(primaryColor).
+				Italic(true).
+				PaddingLeft(1)
+
+	chatKeyChangedStyle = lipgloss.NewStyle().
+				Foreground(dangerColor).
+				Italic(true).
+				PaddingLeft(1)
+
 	chatTxtareaStyle = lipgloss.NewStyle().
 				BorderStyle(lipgloss.NormalBorder()).
 				BorderTop(true).
@@ -308,6 +356,23 @@ var ( // Message Info Styling
 				Margin(2, 5).
 				Foreground(primarySubtleDarkColor)
 
+	msgInfoEditedStyle = lipgloss.NewStyle().
+				Foreground(primarySubtleDarkColor).
+				Margin(1, 5, 0, 5).
+				Italic(true)
+
+	msgInfoHintStyle = lipgloss.NewStyle().
+				Foreground(primarySubtleDarkColor).
+				Margin(1, 5, 0, 5).
+				Faint(true)
+
+	msgInfoReactionPickerStyle = lipgloss.NewStyle().
+					Margin(2, 5, 0, 5)
+
+	msgInfoReactionStyle = lipgloss.NewStyle().
+				Padding(0, 1).
+				MarginRight(1)
+
 	msgInfoContainerBtn = lipgloss.NewStyle().
 				Margin(2, 5, 1, 5)
 
@@ -336,6 +401,57 @@ var ( // Preferences Styles
 				Italic(true)
 )
 
+var ( // Sessions Styles
+
+	sessionDeviceStyle = lipgloss.NewStyle().
+				Foreground(primaryColor).
+				MarginLeft(1)
+
+	sessionDetailStyle = lipgloss.NewStyle().
+				Foreground(primarySubtleDarkColor)
+
+	sessionCurrentStyle = lipgloss.NewStyle().
+				Foreground(lightGreyColor).
+				Italic(true)
+
+	sessionRevokeStyle = lipgloss.NewStyle().
+				Foreground(dangerDarkColor)
+
+	sessionRevokeArmedStyle = sessionRevokeStyle.
+				Foreground(dangerColor).
+				Italic(true).
+				Underline(true)
+)
+
+var ( // Two-Factor Authentication Styles
+
+	totpActionStyle = lipgloss.NewStyle().
+			Foreground(primaryColor)
+
+	totpInfoStyle = lipgloss.NewStyle().
+			Foreground(lightGreyColor).
+			MarginLeft(1)
+
+	totpSecretStyle = lipgloss.NewStyle().
+			Foreground(primaryColor).
+			Bold(true)
+
+	totpInputStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder(), true).
+			BorderForeground(primaryContrastColor).
+			Padding(0, 1)
+
+	totpErrStyle = lipgloss.NewStyle().
+			Foreground(dangerColor).
+			Italic(true).
+			MarginLeft(1)
+
+	// fixed colors, the QR code has to be light on dark whatever the theme
+	totpQRStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFFFF")).
+			Background(lipgloss.Color("#000000"))
+)
+
 var ( // Update Profile Form Styles
 
 	updateProfileInputHeaderStyle = lipgloss.NewStyle().
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/client"
 	"github.com/M0hammadUsman/letschat/internal/domain"
 	"github.com/charmbracelet/bubbles/textarea"
@@ -9,6 +10,8 @@ import (
 	"github.com/google/uuid"
 	zone "github.com/lrstanley/bubblezone"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -18,17 +21,31 @@ const (
 	chatMenu            = "chatMenu"
 	menuGotoFirstMsgBtn = "menuGotoFirstMsgBtn"
 	menuClearConvoBtn   = "menuClearConvoBtn"
+	menuMuteBtn         = "menuMuteBtn"
+	menuBlockBtn        = "menuBlockBtn"
+	menuAcceptBtn       = "menuAcceptBtn"
+	menuDeclineBtn      = "menuDeclineBtn"
 	chatViewport        = "chatViewport"
 	chatTxtarea         = "chatTxtarea"
 )
 
+const (
+	// attachCmd typed in the textarea followed by a file path, sends the file instead of a text msg
+	attachCmd              = "/attach "
+	chatTxtareaPlaceholder = "Type a message, or /attach <path> to send a file..."
+)
+
 type ChatModel struct {
 	chatTxtarea    textarea.Model
 	chatViewport   ChatViewportModel
 	focus          bool
 	prevChatLength int
-	// menu buttons, -1 -> None Selected | 0 -> Goto First Msg | 1 -> Clear Conversation
+	// index of the selected button in menuBtns, -1 -> None Selected
 	menuBtnIdx int
+	// the msg being edited in the textarea, nil when composing a new one
+	editingMsg *domain.Message
+	// the msg being replied to, quoted above the textarea, nil when not replying
+	replyingTo *domain.Message
 	client     *client.Client
 }
 
@@ -56,6 +73,18 @@ func (m ChatModel) Update(msg tea.Msg) (ChatModel, tea.Cmd) {
 		m.chatViewport.focus = true
 	}
 
+	// the conversation is changed while editing
+	if m.editingMsg != nil && m.editingMsg.ReceiverID != selUserID {
+		m.stopEditing()
+	}
+	if m.replyingTo != nil && !belongsToSelConvo(m.replyingTo) {
+		m.stopReplying()
+	}
+	// the conversation is changed to a group, which has fewer menu buttons
+	if m.menuBtnIdx >= len(menuBtns()) {
+		m.menuBtnIdx = -1
+	}
+
 	var typingCmd tea.Cmd
 
 	switch msg := msg.(type) {
@@ -71,22 +100,38 @@ func (m ChatModel) Update(msg tea.Msg) (ChatModel, tea.Cmd) {
 			m.updateChatTxtareaAndViewportDimensions()
 		case "ctrl+o":
 			m.menuBtnIdx = 0
+		case "ctrl+y": // trust the changed security key of the contact
+			if selGroupMembers == nil && m.client.ContactKeyChanged(selUserID) {
+				return m, m.acceptContactKey(selUserID)
+			}
+		// use a new security key on this device, the one of the account is on another device, not ctrl+k as the
+		// textarea deletes after the cursor with it
+		case "alt+k":
+			if m.client.IdentityKeyMissing() {
+				return m, m.resetIdentityKey()
+			}
 		case "left":
-			if m.menuBtnIdx == 1 {
+			if m.menuBtnIdx > 0 {
 				m.menuBtnIdx--
 			}
 		case "right":
-			if m.menuBtnIdx == 0 {
+			if m.menuBtnIdx > -1 && m.menuBtnIdx < len(menuBtns())-1 {
 				m.menuBtnIdx++
 			}
 		case "tab":
-			if m.menuBtnIdx > -1 && m.menuBtnIdx <= 1 {
-				m.menuBtnIdx = (m.menuBtnIdx + 1) % 2
+			if m.menuBtnIdx > -1 {
+				m.menuBtnIdx = (m.menuBtnIdx + 1) % len(menuBtns())
 			}
 		case "esc", "ctrl+f":
 			if m.menuBtnIdx != -1 {
 				m.menuBtnIdx = -1
 			}
+			if m.editingMsg != nil {
+				m.stopEditing()
+			}
+			if m.replyingTo != nil {
+				m.stopReplying()
+			}
 			m.chatTxtarea.Blur()
 			m.updateChatTxtareaAndViewportDimensions()
 		case "enter":
@@ -98,15 +143,42 @@ func (m ChatModel) Update(msg tea.Msg) (ChatModel, tea.Cmd) {
 					return m, nil
 				}
 				m.chatTxtarea.Reset()
-				return m, tea.Batch(m.sendMessage(s), m.handleChatTextareaUpdate(msg), m.handleChatViewportUpdate(msg))
+				if m.editingMsg != nil {
+					validMsgForSend = false // not a new msg, the conversation stays where it is
+					cmd := m.editMessage(m.editingMsg, s)
+					m.stopEditing()
+					return m, tea.Batch(cmd, m.handleChatTextareaUpdate(msg), m.handleChatViewportUpdate(msg))
+				}
+				var cmd tea.Cmd
+				if path, ok := strings.CutPrefix(s, attachCmd); ok {
+					cmd = m.sendAttachment(path, m.replyingTo)
+				} else {
+					cmd = m.sendMessage(s, m.replyingTo)
+				}
+				m.stopReplying()
+				return m, tea.Batch(cmd, m.handleChatTextareaUpdate(msg), m.handleChatViewportUpdate(msg))
+			}
+			if m.menuBtnIdx == -1 {
+				break
 			}
-			switch m.menuBtnIdx {
-			case 0:
+			btn := menuBtns()[m.menuBtnIdx]
+			m.menuBtnIdx = -1
+			switch btn {
+			case menuGotoFirstMsgBtn:
 				m.chatViewport.gotoFirstMsg = true
-				m.menuBtnIdx = -1
-			case 1:
-				m.menuBtnIdx = -1
+			case menuClearConvoBtn:
 				return m, m.deleteAllMsgsForConvo(m.client.CurrentUsr.ID, selUserID)
+			case menuMuteBtn:
+				return m, m.muteUser(selUserID, !selConvoMuted)
+			case menuBlockBtn:
+				if selConvoRequest { // no point in keeping the request of a blocked user
+					return m, m.blockAndDeclineRequest(selUserID)
+				}
+				return m, m.blockUser(selUserID, !selConvoBlocked)
+			case menuAcceptBtn:
+				return m, m.acceptConvoRequest(selUserID)
+			case menuDeclineBtn:
+				return m, m.declineConvoRequest(selUserID)
 			}
 		}
 
@@ -129,11 +201,10 @@ func (m ChatModel) Update(msg tea.Msg) (ChatModel, tea.Cmd) {
 				m.chatTxtarea.SetCursor(max(0, m.chatTxtarea.LineInfo().CharOffset-1))
 			}
 		case tea.MouseButtonLeft:
-			if zone.Get(menuGotoFirstMsgBtn).InBounds(msg) {
-				m.menuBtnIdx = 0
-			}
-			if zone.Get(menuClearConvoBtn).InBounds(msg) {
-				m.menuBtnIdx = 1
+			for i, btn := range menuBtns() {
+				if zone.Get(btn).InBounds(msg) {
+					m.menuBtnIdx = i
+				}
 			}
 		default:
 		}
@@ -158,6 +229,25 @@ func (m ChatModel) Update(msg tea.Msg) (ChatModel, tea.Cmd) {
 			m.updateChatTxtareaAndViewportDimensions()
 		}
 
+	case editMsgStart:
+		m.stopReplying()
+		m.editingMsg = msg
+		m.chatTxtarea.Placeholder = "Edit the message..."
+		m.chatTxtarea.SetValue(msg.Body)
+		typingCmd = m.chatTxtarea.Focus()
+		m.menuBtnIdx = -1
+		m.updateChatTxtareaAndViewportDimensions()
+
+	case replyMsgStart:
+		if m.editingMsg != nil {
+			m.stopEditing()
+		}
+		m.replyingTo = msg
+		m.chatTxtarea.Placeholder = "Reply to the message..."
+		typingCmd = m.chatTxtarea.Focus()
+		m.menuBtnIdx = -1
+		m.updateChatTxtareaAndViewportDimensions()
+
 	case echoTypingMsg:
 		var cmd tea.Cmd
 		if m.prevChatLength < m.chatTxtarea.Length() && !selUserTyping {
@@ -176,12 +266,26 @@ func (m ChatModel) View() string {
 	if selUsername == "" {
 		return lipgloss.Place(chatWidth(), chatHeight(), lipgloss.Center, lipgloss.Center, banner)
 	}
-	h := renderChatHeader(selUsername, selUserTyping)
+	h := renderChatHeader(selUsername, selUserTyping, m.groupMemberNames())
 	if m.menuBtnIdx != -1 {
 		h = renderMenuBtns(m.menuBtnIdx)
 	}
+	if selConvoRequest {
+		h = lipgloss.JoinVertical(lipgloss.Left, h, renderConvoRequestNotice(selUsername))
+	}
+	if selGroupMembers == nil && m.client.ContactKeyChanged(selUserID) {
+		h = lipgloss.JoinVertical(lipgloss.Left, h, renderKeyChangedWarning(selUsername))
+	}
+	if selGroupMembers == nil && m.client.IdentityKeyMissing() {
+		h = lipgloss.JoinVertical(lipgloss.Left, h, renderNoIdentityKeyWarning())
+	} else if selGroupMembers == nil && m.client.ContactKeyMissing(selUserID) {
+		h = lipgloss.JoinVertical(lipgloss.Left, h, renderNoContactKeyWarning(selUsername))
+	}
 	chatHeaderHeight = lipgloss.Height(h)
 	ta := zone.Mark(chatTxtarea, m.chatTxtarea.View())
+	if m.replyingTo != nil {
+		ta = lipgloss.JoinVertical(lipgloss.Left, m.renderReplyingTo(), ta)
+	}
 	ta = renderChatTextarea(ta, m.chatTxtarea.Focused())
 	chatTextareaHeight = lipgloss.Height(ta)
 	m.chatViewport.chatVp.Height = chatHeight() - (chatHeaderHeight + chatTextareaHeight)
@@ -202,7 +306,7 @@ func (m ChatModel) View() string {
 
 func newChatTxtArea() textarea.Model {
 	ta := textarea.New()
-	ta.Placeholder = "Type a message..."
+	ta.Placeholder = chatTxtareaPlaceholder
 	ta.Prompt = ""
 	ta.CharLimit = 1000
 	ta.ShowLineNumbers = false
@@ -215,7 +319,8 @@ func newChatTxtArea() textarea.Model {
 	return ta
 }
 
-func renderChatHeader(name string, typing bool) string {
+// renderChatHeader renders the name of the selected conversation, for groups the members are listed underneath
+func renderChatHeader(name string, typing bool, members []string) string {
 	c := chatHeaderStyle.Width(chatWidth())
 	menu := zone.Mark(chatMenu, "⚙️")
 	sub := c.GetHorizontalFrameSize() + lipgloss.Width(name) + lipgloss.Width(menu)
@@ -224,7 +329,13 @@ func renderChatHeader(name string, typing bool) string {
 		MarginLeft(menuMarginLeft).
 		Render(menu)
 	name = lipgloss.NewStyle().Blink(typing).Render(name)
-	return zone.Mark(chatHeaderContainer, c.Render(name, menu))
+	if len(members) == 0 {
+		return zone.Mark(chatHeaderContainer, c.Render(name, menu))
+	}
+	m := chatHeaderMembersStyle.
+		MaxWidth(c.GetWidth() - c.GetHorizontalFrameSize()).
+		Render(strings.Join(members, ", "))
+	return zone.Mark(chatHeaderContainer, c.Render(lipgloss.JoinVertical(lipgloss.Left, name+" "+menu, m)))
 }
 
 func renderChatTextarea(ta string, padding bool) string {
@@ -242,17 +353,36 @@ func renderMenuBtns(selection int) string {
 		return ""
 	}
 
-	gotoFirstMsgBtn := renderGotoFirstMsgBtn(true)
-	clearConvoBtn := renderClearConvoBtn(false)
-	if selection == 1 {
-		gotoFirstMsgBtn = renderGotoFirstMsgBtn(false)
-		clearConvoBtn = renderClearConvoBtn(true)
+	var btns []string
+	for i, btn := range menuBtns() {
+		focus := selection == i
+		var b string
+		switch btn {
+		case menuGotoFirstMsgBtn:
+			b = renderGotoFirstMsgBtn(focus)
+		case menuClearConvoBtn:
+			b = renderClearConvoBtn(focus)
+		case menuMuteBtn:
+			b = renderMuteBtn(focus)
+		case menuBlockBtn:
+			b = renderBlockBtn(focus)
+		case menuAcceptBtn:
+			b = renderAcceptBtn(focus)
+		case menuDeclineBtn:
+			b = renderDeclineBtn(focus)
+		}
+		btns = append(btns, zone.Mark(btn, b))
 	}
-	gotoFirstMsgBtn = zone.Mark(menuGotoFirstMsgBtn, gotoFirstMsgBtn)
-	clearConvoBtn = zone.Mark(menuClearConvoBtn, clearConvoBtn)
-	btnContainer := chatMenuBtnContainerStyle.Render(gotoFirstMsgBtn, clearConvoBtn)
-
 	c := chatHeaderStyle.Width(chatWidth())
+	btnContainer := chatMenuBtnContainerStyle.Render(lipgloss.JoinHorizontal(lipgloss.Top, btns...))
+	// wraps onto the second row on narrow terminals
+	if lipgloss.Width(btnContainer) > chatWidth()-c.GetHorizontalFrameSize() && len(btns) > 2 {
+		btnContainer = chatMenuBtnContainerStyle.Render(lipgloss.JoinVertical(lipgloss.Center,
+			lipgloss.JoinHorizontal(lipgloss.Top, btns[:2]...),
+			lipgloss.JoinHorizontal(lipgloss.Top, btns[2:]...),
+		))
+	}
+
 	content := lipgloss.PlaceHorizontal(chatWidth()-c.GetHorizontalFrameSize(), lipgloss.Center, btnContainer)
 
 	return zone.Mark(chatHeaderContainer, c.Render(content))
@@ -284,6 +414,81 @@ func renderClearConvoBtn(focus bool) string {
 		Render("CLEAR CONVERSATION")
 }
 
+func renderMuteBtn(focus bool) string {
+	bg := primaryColor
+	fg := primaryContrastColor
+	if !focus {
+		bg = darkGreyColor
+		fg = lightGreyColor
+	}
+	label := "MUTE"
+	if selConvoMuted {
+		label = "UNMUTE"
+	}
+	return chatMenuBtnStyle.
+		Background(bg).
+		Foreground(fg).
+		Render(label)
+}
+
+func renderBlockBtn(focus bool) string {
+	bg := dangerColor
+	fg := whiteColor
+	if !focus {
+		bg = darkGreyColor
+		fg = lightGreyColor
+	}
+	label := "BLOCK"
+	if selConvoBlocked {
+		label = "UNBLOCK"
+	}
+	return chatMenuBtnStyle.
+		Background(bg).
+		Foreground(fg).
+		Render(label)
+}
+
+func renderAcceptBtn(focus bool) string {
+	bg := primaryColor
+	fg := primaryContrastColor
+	if !focus {
+		bg = darkGreyColor
+		fg = lightGreyColor
+	}
+	return chatMenuBtnStyle.
+		Background(bg).
+		Foreground(fg).
+		Render("ACCEPT")
+}
+
+func renderDeclineBtn(focus bool) string {
+	bg := dangerColor
+	fg := whiteColor
+	if !focus {
+		bg = darkGreyColor
+		fg = lightGreyColor
+	}
+	return chatMenuBtnStyle.
+		Background(bg).
+		Foreground(fg).
+		Render("DECLINE")
+}
+
+// menuBtns returns the buttons of the menu for the selected conversation, the msg requests & the group invites are
+// to be accepted or declined first & the groups can't be muted or blocked
+func menuBtns() []string {
+	switch {
+	case selConvoRequest && selGroupMembers != nil:
+		return []string{menuAcceptBtn, menuDeclineBtn}
+	case selConvoRequest:
+		return []string{menuAcceptBtn, menuDeclineBtn, menuBlockBtn}
+	case selGroupMembers != nil:
+		return []string{menuGotoFirstMsgBtn, menuClearConvoBtn}
+	default:
+		return []string{menuGotoFirstMsgBtn, menuClearConvoBtn, menuMuteBtn, menuBlockBtn}
+	}
+}
+
 func (m *ChatModel) handleChatTextareaUpdate(msg tea.Msg) tea.Cmd {
 	var cmd tea.Cmd
 	m.chatTxtarea, cmd = m.chatTxtarea.Update(msg)
@@ -306,15 +511,21 @@ func (m *ChatModel) updateChatTxtareaAndViewportDimensions() {
 	m.chatViewport.updateDimensions()
 }
 
-func (m *ChatModel) sendMessage(msg string) tea.Cmd {
+// sendMessage sends a new msg to the selected conversation, replyTo is the msg it replies to, may be nil
+func (m *ChatModel) sendMessage(msg string, replyTo *domain.Message) tea.Cmd {
 	t := time.Now()
 	msgToSnd := domain.Message{
-		ID:         uuid.New().String(),
-		SenderID:   m.client.CurrentUsr.ID,
-		ReceiverID: selUserID,
-		Body:       msg,
-		SentAt:     &t,
-		Operation:  domain.CreateMsg,
+		ID:             uuid.New().String(),
+		SenderID:       m.client.CurrentUsr.ID,
+		ReceiverID:     selUserID,
+		ConversationID: selGroupID(),
+		Body:           msg,
+		SentAt:         &t,
+		Operation:      domain.CreateMsg,
+	}
+	if replyTo != nil {
+		msgToSnd.ReplyToID = &replyTo.ID
+		msgToSnd.ReplyTo = replyTo
 	}
 	return func() tea.Msg {
 		if m.client.WsConnState.Get() != client.Connected {
@@ -323,20 +534,246 @@ func (m *ChatModel) sendMessage(msg string) tea.Cmd {
 				code: http.StatusRequestTimeout,
 			}
 		}
+		if err := e2eeUnavailable(m.client); err != nil {
+			return &errMsg{err: err.Error(), code: 0}
+		}
 		go m.client.SendMessage(msgToSnd)
 		// will be used in ChatViewportModel's update method
 		return SentMsg(&msgToSnd)
 	}
 }
 
+// sendAttachment uploads the file at the path & then sends it as a new msg, with the file name as its body
+func (m *ChatModel) sendAttachment(path string, replyTo *domain.Message) tea.Cmd {
+	path = strings.Trim(strings.TrimSpace(path), `"'`)
+	if p, ok := strings.CutPrefix(path, "~"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, p)
+		}
+	}
+	t := time.Now()
+	msgToSnd := domain.Message{
+		ID:             uuid.New().String(),
+		SenderID:       m.client.CurrentUsr.ID,
+		ReceiverID:     selUserID,
+		ConversationID: selGroupID(),
+		Body:           filepath.Base(path),
+		SentAt:         &t,
+		Operation:      domain.CreateMsg,
+	}
+	if replyTo != nil {
+		msgToSnd.ReplyToID = &replyTo.ID
+		msgToSnd.ReplyTo = replyTo
+	}
+	upload := func() tea.Msg {
+		if m.client.WsConnState.Get() != client.Connected {
+			return &errMsg{
+				err:  "No Connection, Unable to send the file.",
+				code: http.StatusRequestTimeout,
+			}
+		}
+		if err := e2eeUnavailable(m.client); err != nil {
+			return &errMsg{err: err.Error(), code: 0}
+		}
+		attachment, err := m.client.UploadAttachment(path)
+		if err != nil {
+			return &errMsg{
+				err:  fmt.Sprintf("Unable to send the file, %v", err),
+				code: 0,
+			}
+		}
+		msgToSnd.AttachmentID = &attachment.ID
+		go m.client.SendMessage(msgToSnd)
+		return SentMsg(&msgToSnd)
+	}
+	return tea.Sequence(spinnerSpinCmd, upload, spinnerResetCmd)
+}
+
+func (m *ChatModel) editMessage(msg *domain.Message, body string) tea.Cmd {
+	t := time.Now()
+	edit := &domain.Message{
+		ID:             msg.ID,
+		SenderID:       m.client.CurrentUsr.ID,
+		ReceiverID:     msg.ReceiverID,
+		ConversationID: msg.ConversationID,
+		Body:           body,
+		SentAt:         &t,
+		Operation:      domain.EditMsg,
+	}
+	return func() tea.Msg {
+		if m.client.WsConnState.Get() != client.Connected {
+			return &errMsg{
+				err:  "No Connection, unable to edit message.",
+				code: http.StatusRequestTimeout,
+			}
+		}
+		if err := e2eeUnavailable(m.client); err != nil {
+			return &errMsg{err: err.Error(), code: 0}
+		}
+		if err := m.client.EditMsg(edit); err != nil {
+			return &errMsg{
+				err:  "Unable to edit this message",
+				code: 0,
+			}
+		}
+		return editMsgSuccess(edit)
+	}
+}
+
+func (m *ChatModel) stopEditing() {
+	m.editingMsg = nil
+	m.chatTxtarea.Placeholder = chatTxtareaPlaceholder
+	m.chatTxtarea.Reset()
+}
+
+func (m *ChatModel) stopReplying() {
+	if m.replyingTo == nil {
+		return
+	}
+	m.replyingTo = nil
+	m.chatTxtarea.Placeholder = chatTxtareaPlaceholder
+}
+
+// renderReplyingTo renders the quote of the msg being replied to, shown above the textarea
+func (m ChatModel) renderReplyingTo() string {
+	w := chatWidth() - chatTxtareaStyle.GetHorizontalFrameSize() - chatReplyQuoteStyle.GetHorizontalFrameSize()
+	q := fmt.Sprintf("%v: %v", msgSenderName(m.replyingTo, m.client.CurrentUsr.ID), m.replyingTo.Body)
+	return chatReplyQuoteStyle.Render(truncate(q, w))
+}
+
+// renderKeyChangedWarning warns that the msgs are now encrypted for a key that is yet to be trusted
+func renderKeyChangedWarning(username string) string {
+	w := chatWidth() - chatKeyChangedStyle.GetHorizontalFrameSize()
+	s := fmt.Sprintf("⚠ %v's security key has changed, ctrl+y to trust it", username)
+	return chatKeyChangedStyle.Render(truncate(s, w))
+}
+
+// renderNoContactKeyWarning tells that the contact is yet to set up the end-to-end encryption, nothing can be sent
+// to the contact till then
+func renderNoContactKeyWarning(username string) string {
+	w := chatWidth() - chatKeyChangedStyle.GetHorizontalFrameSize()
+	s := fmt.Sprintf("⚠ %v has no security key yet, messages can be sent once they set it up", username)
+	return chatKeyChangedStyle.Render(truncate(s, w))
+}
+
+// renderNoIdentityKeyWarning tells that the security key of the account is on another device, a new one can be used
+// here instead, the contacts are then warned of the change & the other devices lose the key
+func renderNoIdentityKeyWarning() string {
+	w := chatWidth() - chatKeyChangedStyle.GetHorizontalFrameSize()
+	s := fmt.Sprintf("⚠ %v, alt+k to use a new one on this device", client.ErrNoIdentityKey)
+	return chatKeyChangedStyle.Render(truncate(s, w))
+}
+
+// renderConvoRequestNotice tells that the conversation is a msg request, the sender knows nothing till it's accepted,
+// or an invite to the group, no group msgs are received till it's accepted
+func renderConvoRequestNotice(username string) string {
+	w := chatWidth() - chatConvoRequestStyle.GetHorizontalFrameSize()
+	s := fmt.Sprintf("%v wants to message you, ctrl+o to accept or decline, replying also accepts", username)
+	if selGroupMembers != nil {
+		s = fmt.Sprintf("You're invited to join %v, ctrl+o to accept or decline", username)
+	}
+	return chatConvoRequestStyle.Render(truncate(s, w))
+}
+
+func (m ChatModel) acceptConvoRequest(usrID string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.AcceptConversationRequest(usrID); err != nil {
+			return &errMsg{
+				err:  "Unable to accept the message request",
+				code: 0,
+			}
+		}
+		return nil
+	}
+}
+
+func (m ChatModel) declineConvoRequest(usrID string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.DeclineConversationRequest(usrID); err != nil {
+			return &errMsg{
+				err:  "Unable to decline the message request",
+				code: 0,
+			}
+		}
+		return nil
+	}
+}
+
+func (m ChatModel) blockAndDeclineRequest(usrID string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.BlockUser(usrID, true); err != nil {
+			return &errMsg{
+				err:  "Unable to update the block setting",
+				code: 0,
+			}
+		}
+		if err := m.client.DeclineConversationRequest(usrID); err != nil {
+			return &errMsg{
+				err:  "Unable to decline the message request",
+				code: 0,
+			}
+		}
+		return nil
+	}
+}
+
+func (m ChatModel) acceptContactKey(usrID string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.AcceptContactKey(usrID); err != nil {
+			return &errMsg{
+				err:  "Unable to trust the security key",
+				code: 0,
+			}
+		}
+		return nil
+	}
+}
+
+func (m ChatModel) resetIdentityKey() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.ResetIdentityKey(); err != nil {
+			return &errMsg{
+				err:  "Unable to use a new security key",
+				code: 0,
+			}
+		}
+		return nil
+	}
+}
+
+func (m ChatModel) muteUser(usrID string, mute bool) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.MuteUser(usrID, mute); err != nil {
+			return &errMsg{
+				err:  "Unable to update the mute setting",
+				code: 0,
+			}
+		}
+		return nil
+	}
+}
+
+func (m ChatModel) blockUser(usrID string, block bool) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.BlockUser(usrID, block); err != nil {
+			return &errMsg{
+				err:  "Unable to update the block setting",
+				code: 0,
+			}
+		}
+		return nil
+	}
+}
+
 func (m *ChatModel) sendTypingStatus() tea.Cmd {
 	t := time.Now()
 	msgToSnd := domain.Message{
-		ID:         uuid.New().String(),
-		SenderID:   m.client.CurrentUsr.ID,
-		ReceiverID: selUserID,
-		SentAt:     &t,
-		Operation:  domain.TypingMsg,
+		ID:             uuid.New().String(),
+		SenderID:       m.client.CurrentUsr.ID,
+		ReceiverID:     selUserID,
+		ConversationID: selGroupID(),
+		SentAt:         &t,
+		Operation:      domain.TypingMsg,
 	}
 	return func() tea.Msg {
 		m.client.SendTypingStatus(msgToSnd)
@@ -355,3 +792,75 @@ func (m ChatModel) deleteAllMsgsForConvo(currUsrId, selUsrId string) tea.Cmd {
 		return clearConvoSuccess{}
 	}
 }
+
+func (m ChatModel) groupMemberNames() []string {
+	names := make([]string, 0, len(selGroupMembers))
+	for _, member := range selGroupMembers {
+		if member.UserID == m.client.CurrentUsr.ID {
+			names = append(names, "You")
+			continue
+		}
+		names = append(names, member.Username)
+	}
+	return names
+}
+
+// e2eeUnavailable tells why nothing can be sent to the selected 1:1 conversation, if the identity key of the
+// account is not on this device or the contact is yet to publish a key
+func e2eeUnavailable(c *client.Client) error {
+	if selGroupMembers != nil {
+		return nil
+	}
+	return c.EncryptionReady(selUserID)
+}
+
+// selGroupID returns the ID of the selected conversation if it's a group, the msgs are then addressed to the group
+func selGroupID() *string {
+	if selGroupMembers == nil {
+		return nil
+	}
+	id := selUserID
+	return &id
+}
+
+// selGroupMemberUsername returns the username of the selected group's member, empty if no such member
+func selGroupMemberUsername(usrID string) string {
+	for _, member := range selGroupMembers {
+		if member.UserID == usrID {
+			return member.Username
+		}
+	}
+	return ""
+}
+
+// belongsToSelConvo tells whether the msg is part of the selected conversation
+func belongsToSelConvo(msg *domain.Message) bool {
+	if msg.ConversationID != nil {
+		return *msg.ConversationID == selUserID
+	}
+	return msg.SenderID == selUserID || msg.ReceiverID == selUserID
+}
+
+// msgSenderName is the name the sender of the msg is shown with in the selected conversation
+func msgSenderName(msg *domain.Message, currUsrID string) string {
+	if msg.SenderID == currUsrID {
+		return "You"
+	}
+	if msg.ConversationID != nil {
+		return selGroupMemberUsername(msg.SenderID)
+	}
+	return selUsername
+}
+
+// truncate cuts the single line form of s down to w cells, ending it with an ellipsis
+func truncate(s string, w int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if w <= 1 || lipgloss.Width(s) <= w {
+		return s
+	}
+	r := []rune(s)
+	for lipgloss.Width(string(r)) > w-1 {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"github.com/M0hammadUsman/letschat/internal/client"
 	"github.com/M0hammadUsman/letschat/internal/domain"
@@ -13,6 +14,7 @@ import (
 	"log/slog"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -22,8 +24,25 @@ const (
 	infoDialogCopyBtn           = "infoDialogCopyBtn"
 	infoDialogDelForMeBtn       = "infoDialogDelForMeBtn"
 	infoDialogDelForEveryoneBtn = "infoDialogDelForEveryoneBtn"
+	infoDialogEditBtn           = "infoDialogEditBtn"
+	infoDialogReplyBtn          = "infoDialogReplyBtn"
+	infoDialogSaveBtn           = "infoDialogSaveBtn"
+	infoDialogReaction          = "infoDialogReaction" // suffixed with the index of the emoji in reactionEmojis
 )
 
+// buttons of the msg info dialog
+const (
+	dialogCopyBtn = iota
+	dialogDelForMeBtn
+	dialogDelForEveryoneBtn
+	dialogEditBtn
+	dialogReplyBtn
+	dialogSaveBtn
+)
+
+// reactionEmojis are the ones offered by the reaction picker, picked using the keys 1 to 6
+var reactionEmojis = []string{"👍", "❤️", "😂", "😮", "😢", "🙏"}
+
 type msgPage struct {
 	msgs []*domain.Message
 	meta *domain.Metadata
@@ -42,13 +61,18 @@ type ChatViewportModel struct {
 	selUsrID           string
 	// currently selected msg for info, we'll hide the dialog once the selMsgId is nil
 	selMsgId *string
-	// current button selection once the msg info dialog in focus,
-	// 0 -> CopyBtn | 1 -> DeleteForMeBtn | 2 -> DeleteForEveryoneBtn
+	// current button selection once the msg info dialog in focus, one of the dialog*Btn
 	selMsgDialogBtn int  // -1 when the selMsgId is nil
 	gotoFirstMsg    bool // once at first msg, set to false
-	focus           bool
-	fetching        bool
-	recvTypingTimer timer.Model
+	// the msg to scroll to once it's fetched, older pages are fetched till it's found
+	jumpToMsgID *string
+	// line of each msg in the rendered chat viewport, used to scroll to a msg
+	msgLineOffsets map[string]int
+	// paths the attachments are saved to during the session, keys are the msg IDs
+	savedAttachments map[string]string
+	focus            bool
+	fetching         bool
+	recvTypingTimer  timer.Model
 	// only used when msgPage is received
 	prevLineCount int
 	client        *client.Client
@@ -97,6 +121,7 @@ func (m ChatViewportModel) Update(msg tea.Msg) (ChatViewportModel, tea.Cmd) {
 		m.msgs = slices.Delete(m.msgs, 0, len(m.msgs))
 		m.msgs = nil
 		m.selUsrID = selUserID
+		m.jumpToMsgID = nil
 		return m, m.getMsgAsPage(1)
 	}
 
@@ -134,62 +159,62 @@ func (m ChatViewportModel) Update(msg tea.Msg) (ChatViewportModel, tea.Cmd) {
 			m.selMsgDialogBtn = -1
 		case "tab":
 			if selMsg != nil {
-				if m.selMsgDialogBtn >= 0 && m.selMsgDialogBtn <= 2 {
-					if selMsg.SenderID == m.client.CurrentUsr.ID {
-						m.selMsgDialogBtn = (m.selMsgDialogBtn + 1) % 3
-					} else {
-						m.selMsgDialogBtn = (m.selMsgDialogBtn + 1) % 2
-					}
-				}
-				if m.selMsgDialogBtn > 2 {
-					m.selMsgDialogBtn = 0
-				}
+				btns := m.dialogBtns(selMsg)
+				i := slices.Index(btns, m.selMsgDialogBtn)
+				m.selMsgDialogBtn = btns[(i+1)%len(btns)]
 				m.msgDialogVp.SetContent(m.renderMsgDialogViewport())
 			}
 		case "left":
 			if selMsg != nil {
-				if m.selMsgDialogBtn > 0 && m.selMsgDialogBtn <= 2 {
-					if selMsg.SenderID == m.client.CurrentUsr.ID {
-						m.selMsgDialogBtn--
-					} else {
-						m.selMsgDialogBtn--
-						if m.selMsgDialogBtn < 0 {
-							m.selMsgDialogBtn = 0
-						}
-					}
-				}
+				btns := m.dialogBtns(selMsg)
+				i := slices.Index(btns, m.selMsgDialogBtn)
+				m.selMsgDialogBtn = btns[max(0, i-1)]
 				m.msgDialogVp.SetContent(m.renderMsgDialogViewport())
 			}
 		case "right":
 			if selMsg != nil {
-				if m.selMsgDialogBtn >= 0 && m.selMsgDialogBtn <= 1 {
-					if selMsg.SenderID == m.client.CurrentUsr.ID {
-						m.selMsgDialogBtn++
-						if m.selMsgDialogBtn > 2 {
-							m.selMsgDialogBtn = 2
-						}
-					} else {
-						m.selMsgDialogBtn++
-						if m.selMsgDialogBtn > 1 {
-							m.selMsgDialogBtn = 1
-						}
-					}
-				}
+				btns := m.dialogBtns(selMsg)
+				i := slices.Index(btns, m.selMsgDialogBtn)
+				m.selMsgDialogBtn = btns[min(len(btns)-1, i+1)]
 				m.msgDialogVp.SetContent(m.renderMsgDialogViewport())
 			}
+		case "ctrl+g": // jump to the msg being replied to
+			if selMsg != nil && selMsg.ReplyToID != nil {
+				m.selMsgId = nil
+				m.selMsgDialogBtn = -1
+				return m, m.jumpToMsg(*selMsg.ReplyToID)
+			}
+		case "1", "2", "3", "4", "5", "6":
+			if selMsg != nil && m.focus { // the digits may be typed in the textarea otherwise
+				i, _ := strconv.Atoi(msg.String())
+				return m, m.toggleReaction(selMsg, reactionEmojis[i-1])
+			}
 		case "enter":
 			if m.selMsgId != nil {
-				if m.selMsgDialogBtn == 0 {
+				if m.selMsgDialogBtn == dialogCopyBtn {
 					_ = clipboard.WriteAll(m.getSelMsgFromMsgSlice().Body)
 				}
-				if m.selMsgDialogBtn == 1 {
+				if m.selMsgDialogBtn == dialogDelForMeBtn {
 					if m.selMsgId != nil {
 						return m, m.deleteForMe(*m.selMsgId)
 					}
 				}
-				if m.selMsgDialogBtn == 2 {
+				if m.selMsgDialogBtn == dialogDelForEveryoneBtn {
 					return m, m.deleteForEveryone(*m.selMsgId)
 				}
+				if m.selMsgDialogBtn == dialogEditBtn {
+					m.selMsgId = nil
+					m.selMsgDialogBtn = -1
+					return m, func() tea.Msg { return editMsgStart(selMsg) }
+				}
+				if m.selMsgDialogBtn == dialogSaveBtn {
+					return m, m.saveAttachment(selMsg)
+				}
+				if m.selMsgDialogBtn == dialogReplyBtn {
+					m.selMsgId = nil
+					m.selMsgDialogBtn = -1
+					return m, func() tea.Msg { return replyMsgStart(selMsg) }
+				}
 			}
 		}
 
@@ -209,13 +234,27 @@ func (m ChatViewportModel) Update(msg tea.Msg) (ChatViewportModel, tea.Cmd) {
 		if m.selMsgId != nil && msg.Button == tea.MouseButtonLeft {
 			if msg.Action == tea.MouseActionPress {
 				if zone.Get(infoDialogCopyBtn).InBounds(msg) {
-					m.selMsgDialogBtn = 0
+					m.selMsgDialogBtn = dialogCopyBtn
 				}
 				if zone.Get(infoDialogDelForMeBtn).InBounds(msg) {
-					m.selMsgDialogBtn = 1
+					m.selMsgDialogBtn = dialogDelForMeBtn
 				}
 				if zone.Get(infoDialogDelForEveryoneBtn).InBounds(msg) {
-					m.selMsgDialogBtn = 2
+					m.selMsgDialogBtn = dialogDelForEveryoneBtn
+				}
+				if zone.Get(infoDialogEditBtn).InBounds(msg) {
+					m.selMsgDialogBtn = dialogEditBtn
+				}
+				if zone.Get(infoDialogReplyBtn).InBounds(msg) {
+					m.selMsgDialogBtn = dialogReplyBtn
+				}
+				if zone.Get(infoDialogSaveBtn).InBounds(msg) {
+					m.selMsgDialogBtn = dialogSaveBtn
+				}
+				for i, emoji := range reactionEmojis {
+					if zone.Get(infoDialogReaction + strconv.Itoa(i)).InBounds(msg) {
+						return m, m.toggleReaction(m.getSelMsgFromMsgSlice(), emoji)
+					}
 				}
 				m.msgDialogVp.SetContent(m.renderMsgDialogViewport())
 			}
@@ -230,10 +269,22 @@ func (m ChatViewportModel) Update(msg tea.Msg) (ChatViewportModel, tea.Cmd) {
 
 	case msgPage:
 		m.fetching = false
-		m.msgs = msg.msgs
+		if msg.meta.CurrentPage > 1 { // older msgs go on top of the ones already there
+			for _, mesg := range msg.msgs {
+				if !slices.ContainsFunc(m.msgs, func(imsg *domain.Message) bool { return imsg.ID == mesg.ID }) {
+					m.msgs = append(m.msgs, mesg)
+				}
+			}
+		} else {
+			m.msgs = msg.msgs
+		}
 		m.currPage = msg.meta.CurrentPage
 		m.lastPage = msg.meta.LastPage
 		m.chatVp.SetContent(m.renderChatViewport())
+		if m.jumpToMsgID != nil {
+			m.prevLineCount = m.chatVp.TotalLineCount()
+			return m, tea.Batch(m.jumpToMsg(*m.jumpToMsgID), m.handleChatViewportUpdate(msg))
+		}
 		if !m.gotoFirstMsg {
 			// Once content is set, it goes to the top, to go to the point where the user was before
 			c := m.chatVp.TotalLineCount() - m.prevLineCount
@@ -247,11 +298,14 @@ func (m ChatViewportModel) Update(msg tea.Msg) (ChatViewportModel, tea.Cmd) {
 		switch msg.Operation {
 
 		case domain.CreateMsg:
+			if msg.ReplyToID != nil && msg.ReplyTo == nil {
+				msg.ReplyTo = m.getMsg(*msg.ReplyToID)
+			}
 			m.msgs = append([]*domain.Message{msg}, m.msgs...)
 			m.chatVp.SetContent(m.renderChatViewport())
 			m.chatVp.GotoBottom()
 			// set it as read also | nil checks, if the terminal focus is not supported, just set the msg as read
-			if msg.SenderID == selUserID && msg.ReadAt == nil && (terminalFocus == nil || *terminalFocus) {
+			if m.isRecvFromSelConvo(msg) && msg.ReadAt == nil && (terminalFocus == nil || *terminalFocus) {
 				t := time.Now()
 				msg.DeliveredAt = &t
 				msg.ReadAt = &t
@@ -259,6 +313,10 @@ func (m ChatViewportModel) Update(msg tea.Msg) (ChatViewportModel, tea.Cmd) {
 			}
 
 		case domain.DeliveredMsg, domain.ReadMsg:
+			// a single member's receipt of a group msg, the msg is updated once settled for every member
+			if msg.ConversationID != nil && msg.SenderID != m.client.CurrentUsr.ID {
+				break
+			}
 			m.updateMsgInMsgs(msg)
 			// the above op will update the msgs so we need to rerender
 			if m.selMsgId != nil {
@@ -285,6 +343,20 @@ func (m ChatViewportModel) Update(msg tea.Msg) (ChatViewportModel, tea.Cmd) {
 				m.chatVp.LineDown(max(0, prevLineCount-currLineCount))
 			}
 
+		case domain.EditMsg:
+			m.editMsgInMsgs(msg)
+			if m.selMsgId != nil && *m.selMsgId == msg.ID {
+				m.msgDialogVp.SetContent(m.renderMsgDialogViewport())
+			}
+			m.chatVp.SetContent(m.renderChatViewport())
+
+		case domain.ReactMsg, domain.UnreactMsg:
+			m.reactInMsgs(msg)
+			if m.selMsgId != nil && *m.selMsgId == msg.ID {
+				m.msgDialogVp.SetContent(m.renderMsgDialogViewport())
+			}
+			m.chatVp.SetContent(m.renderChatViewport())
+
 		case domain.TypingMsg:
 			selUserTyping = true
 
@@ -315,6 +387,24 @@ func (m ChatViewportModel) Update(msg tea.Msg) (ChatViewportModel, tea.Cmd) {
 			m.chatVp.LineDown(max(0, prevLineCount-currLineCount))
 		}
 
+	case attachmentSaved:
+		if m.savedAttachments == nil {
+			m.savedAttachments = make(map[string]string)
+		}
+		m.savedAttachments[msg.msgID] = msg.path
+		m.msgDialogVp.SetContent(m.renderMsgDialogViewport())
+
+	case editMsgSuccess:
+		m.editMsgInMsgs(msg)
+		m.chatVp.SetContent(m.renderChatViewport())
+
+	case reactMsgSuccess:
+		m.reactInMsgs(msg)
+		if m.selMsgId != nil {
+			m.msgDialogVp.SetContent(m.renderMsgDialogViewport())
+		}
+		m.chatVp.SetContent(m.renderChatViewport())
+
 	case clearConvoSuccess:
 		m.msgs = make([]*domain.Message, 0)
 		m.chatVp.SetContent("")
@@ -354,6 +444,7 @@ func (m ChatViewportModel) View() string {
 func (m *ChatViewportModel) renderChatViewport() string {
 	var sb strings.Builder
 	var prevMsgDay int
+	m.msgLineOffsets = make(map[string]int, len(m.msgs))
 	l, err := time.LoadLocation("Local")
 	if err != nil {
 		slog.Error(err.Error())
@@ -384,6 +475,7 @@ func (m *ChatViewportModel) renderChatViewport() string {
 			Align(align).
 			Render(m.renderBubbleWithStatusInfo(msg))
 		sb.WriteString("\n")
+		m.msgLineOffsets[msg.ID] = strings.Count(sb.String(), "\n")
 		sb.WriteString(cb)
 	}
 	return sb.String()
@@ -399,6 +491,8 @@ func (m ChatViewportModel) renderMsgDialogViewport() string {
 	headTxt := "YOU"
 	if infoMsg.SenderID == selUserID {
 		headTxt = selUsername
+	} else if infoMsg.ConversationID != nil && infoMsg.SenderID != m.client.CurrentUsr.ID {
+		headTxt = selGroupMemberUsername(infoMsg.SenderID)
 	}
 	head = msgInfoHeaderStyle.Render(headTxt)
 	body = msgInfoBodyStyle.
@@ -406,31 +500,47 @@ func (m ChatViewportModel) renderMsgDialogViewport() string {
 		Render(infoMsg.Body)
 
 	copyBtn := zone.Mark(infoDialogCopyBtn, renderCopyBtn(m.selMsgDialogBtn))
-	delBtn := zone.Mark(infoDialogDelForMeBtn, renderDeleteBtn(false, "DELETE"))
-	delForMeFocus := false
-	delForEveryoneFocus := false
-	if m.selMsgDialogBtn == 1 {
-		delForMeFocus = true
-	}
-	if m.selMsgDialogBtn == 2 {
-		delForEveryoneFocus = true
+	if infoMsg.AttachmentID != nil { // the file goes along with the copy btn
+		copyBtn += zone.Mark(infoDialogSaveBtn, renderSaveBtn(m.selMsgDialogBtn == dialogSaveBtn))
 	}
-	delForMeBtn := zone.Mark(infoDialogDelForMeBtn, renderDeleteBtn(delForMeFocus, "DELETE FOR ME"))
-	delForEveryoneBtn := zone.Mark(infoDialogDelForEveryoneBtn, renderDeleteBtn(delForEveryoneFocus, "DELETE FOR EVERYONE"))
+	replyBtn := zone.Mark(infoDialogReplyBtn, renderReplyBtn(m.selMsgDialogBtn == dialogReplyBtn))
+	delBtn := zone.Mark(infoDialogDelForMeBtn, renderDeleteBtn(false, "DELETE"))
+	delForMeBtn := zone.Mark(infoDialogDelForMeBtn, renderDeleteBtn(m.selMsgDialogBtn == dialogDelForMeBtn, "DELETE FOR ME"))
+	delForEveryoneBtn := zone.Mark(infoDialogDelForEveryoneBtn, renderDeleteBtn(m.selMsgDialogBtn == dialogDelForEveryoneBtn, "DELETE FOR EVERYONE"))
+	editBtn := zone.Mark(infoDialogEditBtn, renderEditBtn(m.selMsgDialogBtn == dialogEditBtn))
 
-	if m.selMsgDialogBtn == 0 {
-		btnContainer = msgInfoContainerBtn.Render(copyBtn, delBtn)
+	if m.selMsgDialogBtn == dialogCopyBtn || m.selMsgDialogBtn == dialogReplyBtn || m.selMsgDialogBtn == dialogSaveBtn {
+		btnContainer = msgInfoContainerBtn.Render(copyBtn, replyBtn, delBtn)
+		if infoMsg.SenderID == m.client.CurrentUsr.ID {
+			btnContainer = msgInfoContainerBtn.Render(copyBtn, replyBtn, delBtn, editBtn)
+		}
 	} else {
-		btnContainer = msgInfoContainerBtn.Render(copyBtn, delForMeBtn, delForEveryoneBtn)
+		btnContainer = msgInfoContainerBtn.Render(copyBtn, replyBtn, delForMeBtn, delForEveryoneBtn, editBtn)
 		if infoMsg.SenderID != m.client.CurrentUsr.ID {
-			btnContainer = msgInfoContainerBtn.Render(copyBtn, delForMeBtn)
+			btnContainer = msgInfoContainerBtn.Render(copyBtn, replyBtn, delForMeBtn)
 		}
 	}
 
+	if infoMsg.EditedAt != nil {
+		body += m.renderInfoMsgRevisions(infoMsg)
+	}
+
+	if infoMsg.ReplyToID != nil {
+		body += msgInfoHintStyle.Render("ctrl+g to jump to the replied msg")
+	}
+	if path, ok := m.savedAttachments[infoMsg.ID]; ok {
+		body += msgInfoHintStyle.Render("📎 saved to " + path)
+	}
+
 	status := renderInfoMsgStatus(infoMsg)
+	if infoMsg.ConversationID != nil && infoMsg.SenderID == m.client.CurrentUsr.ID {
+		status += m.renderInfoMsgReceipts(infoMsg)
+	}
 	foot = msgInfoFooterStyle.Render(status)
 
-	return head + body + btnContainer + foot
+	picker := m.renderReactionPicker(infoMsg)
+
+	return head + body + picker + btnContainer + foot
 }
 
 func renderInfoMsgStatus(msg *domain.Message) string {
@@ -454,6 +564,60 @@ func renderInfoMsgStatus(msg *domain.Message) string {
 	return sb.String()
 }
 
+// renderInfoMsgRevisions renders the edited marker along with the prior bodies of the msg, oldest first
+func (m ChatViewportModel) renderInfoMsgRevisions(msg *domain.Message) string {
+	revisions, err := m.client.GetMsgRevisions(msg.ID)
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	l, err := time.LoadLocation("Local")
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	f := "02-Jan-2006 | 3:04 PM"
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("✎ edited   %v", msg.EditedAt.In(l).Format(f)))
+	w := chatWidth() - msgInfoEditedStyle.GetHorizontalFrameSize()
+	for _, rev := range revisions {
+		sb.WriteString("\n\n")
+		if rev.WrittenAt != nil {
+			sb.WriteString(rev.WrittenAt.In(l).Format(f))
+			sb.WriteString("\n")
+		}
+		sb.WriteString(lipgloss.NewStyle().Faint(true).Width(w).Render(rev.Body))
+	}
+	return msgInfoEditedStyle.Render(sb.String())
+}
+
+// renderInfoMsgReceipts renders the delivery & read state of the group msg for each member
+func (m ChatViewportModel) renderInfoMsgReceipts(msg *domain.Message) string {
+	receipts, err := m.client.GetMsgReceipts(msg.ID)
+	if err != nil {
+		slog.Error(err.Error())
+		return ""
+	}
+	l, err := time.LoadLocation("Local")
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	f := "3:04 PM"
+	var sb strings.Builder
+	for _, member := range selGroupMembers {
+		if member.UserID == m.client.CurrentUsr.ID {
+			continue
+		}
+		status := "✓"
+		i := slices.IndexFunc(receipts, func(r *domain.MessageReceipt) bool { return r.UserID == member.UserID })
+		if i != -1 && receipts[i].ReadAt != nil {
+			status = fmt.Sprintf("✓✓✓ %v", receipts[i].ReadAt.In(l).Format(f))
+		} else if i != -1 && receipts[i].DeliveredAt != nil {
+			status = fmt.Sprintf("✓✓  %v", receipts[i].DeliveredAt.In(l).Format(f))
+		}
+		sb.WriteString(fmt.Sprintf("\n\n%v  %v", status, member.Username))
+	}
+	return sb.String()
+}
+
 func renderCopyBtn(selBtnIdx int) string {
 	bg := primaryColor
 	fg := primaryContrastColor
@@ -481,6 +645,79 @@ func renderDeleteBtn(focus bool, btnTxt string) string {
 		Render(btnTxt)
 }
 
+// renderReactionPicker renders the emojis to react with, the ones the current user reacted with are highlighted
+func (m ChatViewportModel) renderReactionPicker(msg *domain.Message) string {
+	emojis := make([]string, len(reactionEmojis))
+	for i, emoji := range reactionEmojis {
+		s := msgInfoReactionStyle.Background(darkGreyColor).Foreground(lightGreyColor)
+		if m.hasReacted(msg, emoji) {
+			s = s.Background(primaryContrastColor).Foreground(primaryColor)
+		}
+		emojis[i] = zone.Mark(infoDialogReaction+strconv.Itoa(i), s.Render(fmt.Sprintf("%d %v", i+1, emoji)))
+	}
+	return msgInfoReactionPickerStyle.Render(lipgloss.JoinHorizontal(lipgloss.Center, emojis...))
+}
+
+// renderReactions renders the count of each emoji reacted with to the msg, the ones by the current user highlighted
+func (m *ChatViewportModel) renderReactions(msg *domain.Message) string {
+	var emojis []string
+	counts := make(map[string]int)
+	for _, r := range msg.Reactions {
+		if counts[r.Emoji] == 0 {
+			emojis = append(emojis, r.Emoji)
+		}
+		counts[r.Emoji]++
+	}
+	rendered := make([]string, len(emojis))
+	for i, emoji := range emojis {
+		s := lipgloss.NewStyle().Faint(true)
+		if m.hasReacted(msg, emoji) {
+			s = lipgloss.NewStyle().Foreground(primaryColor)
+		}
+		rendered[i] = s.Render(fmt.Sprintf("%v %d", emoji, counts[emoji]))
+	}
+	return chatBubbleReactionsStyle.Render(strings.Join(rendered, "  "))
+}
+
+func renderSaveBtn(focus bool) string {
+	bg := primaryColor
+	fg := primaryContrastColor
+	if !focus {
+		bg = darkGreyColor
+		fg = lightGreyColor
+	}
+	return msgInfoBtnStyle.
+		Background(bg).
+		Foreground(fg).
+		Render("SAVE")
+}
+
+func renderReplyBtn(focus bool) string {
+	bg := primaryColor
+	fg := primaryContrastColor
+	if !focus {
+		bg = darkGreyColor
+		fg = lightGreyColor
+	}
+	return msgInfoBtnStyle.
+		Background(bg).
+		Foreground(fg).
+		Render("REPLY")
+}
+
+func renderEditBtn(focus bool) string {
+	bg := primaryColor
+	fg := primaryContrastColor
+	if !focus {
+		bg = darkGreyColor
+		fg = lightGreyColor
+	}
+	return msgInfoBtnStyle.
+		Background(bg).
+		Foreground(fg).
+		Render("EDIT")
+}
+
 func (m *ChatViewportModel) getSelMsgFromMsgSlice() *domain.Message {
 	for _, msg := range m.msgs {
 		if m.selMsgId != nil && msg.ID == *m.selMsgId {
@@ -491,9 +728,17 @@ func (m *ChatViewportModel) getSelMsgFromMsgSlice() *domain.Message {
 }
 
 func (m *ChatViewportModel) renderBubbleWithStatusInfo(msg *domain.Message) string {
-	txtWidth := min(chatWidth()-20, lipgloss.Width(msg.Body)+2)
-	bubble := chatBubbleLStyle.Width(txtWidth).Render(msg.Body)
-	sentAt := lipgloss.NewStyle().Faint(true).Foreground(whiteColor).SetString(msg.SentAt.Format(time.Kitchen))
+	body := msg.Body
+	if msg.AttachmentID != nil {
+		body = "📎 " + body
+	}
+	txtWidth := min(chatWidth()-20, lipgloss.Width(body)+2)
+	bubble := chatBubbleLStyle.Width(txtWidth).Render(body)
+	sentAtTxt := msg.SentAt.Format(time.Kitchen)
+	if msg.EditedAt != nil {
+		sentAtTxt += " ✎"
+	}
+	sentAt := lipgloss.NewStyle().Faint(true).Foreground(whiteColor).SetString(sentAtTxt)
 	var status string
 	if msg.SentAt != nil {
 		status = "⁎"
@@ -507,15 +752,47 @@ func (m *ChatViewportModel) renderBubbleWithStatusInfo(msg *domain.Message) stri
 	status = lipgloss.NewStyle().Faint(true).Foreground(primaryColor).Render(status)
 
 	if msg.SenderID == m.client.CurrentUsr.ID {
-		bubble = chatBubbleRStyle.Width(txtWidth).Render(msg.Body)
+		bubble = chatBubbleRStyle.Width(txtWidth).Render(body)
 		// mark the msg with zone on the right side so we can pick these up using mouse clicks
 		bubble = zone.Mark(msg.ID, bubble)
 		sentAt = sentAt.Foreground(primaryColor)
-		return lipgloss.JoinHorizontal(lipgloss.Center, status, " ", sentAt.Render(), " ", bubble)
+		b := lipgloss.JoinHorizontal(lipgloss.Center, status, " ", sentAt.Render(), " ", bubble)
+		if msg.ReplyToID != nil {
+			b = lipgloss.JoinVertical(lipgloss.Right, m.renderReplyQuote(msg), b)
+		}
+		if len(msg.Reactions) != 0 {
+			b = lipgloss.JoinVertical(lipgloss.Right, b, m.renderReactions(msg))
+		}
+		return b
 	}
 	// mark the msg with zone on the left side so we can pick these up using mouse clicks
 	bubble = zone.Mark(msg.ID, bubble)
-	return lipgloss.JoinHorizontal(lipgloss.Center, bubble, " ", sentAt.Render())
+	b := lipgloss.JoinHorizontal(lipgloss.Center, bubble, " ", sentAt.Render())
+	if msg.ReplyToID != nil {
+		b = lipgloss.JoinVertical(lipgloss.Left, m.renderReplyQuote(msg), b)
+	}
+	if len(msg.Reactions) != 0 {
+		b = lipgloss.JoinVertical(lipgloss.Left, b, m.renderReactions(msg))
+	}
+	// in groups, tell whom the msg is from
+	if msg.ConversationID != nil {
+		return lipgloss.JoinVertical(lipgloss.Left, chatBubbleSenderStyle.Render(selGroupMemberUsername(msg.SenderID)), b)
+	}
+	return b
+}
+
+// renderReplyQuote renders a snippet of the msg being replied to, shown above the bubble of the reply
+func (m *ChatViewportModel) renderReplyQuote(msg *domain.Message) string {
+	w := chatWidth() - 20
+	parent := m.getMsg(*msg.ReplyToID)
+	if parent == nil {
+		parent = msg.ReplyTo
+	}
+	if parent == nil {
+		return chatReplyQuoteStyle.Italic(true).Render(truncate("message is no longer available", w))
+	}
+	q := fmt.Sprintf("%v: %v", msgSenderName(parent, m.client.CurrentUsr.ID), parent.Body)
+	return chatReplyQuoteStyle.Render(truncate(q, w))
 }
 
 func (m *ChatViewportModel) updateDimensions() {
@@ -544,6 +821,12 @@ func (m ChatViewportModel) listenForMessages() tea.Cmd {
 		for {
 			if msg, ok := <-m.mb.ch; ok {
 				// if the msg has to do something with the selected chat then
+				if msg.ConversationID != nil {
+					if *msg.ConversationID == selUserID {
+						return msg
+					}
+					continue
+				}
 				if msg.SenderID == selUserID || msg.ReceiverID == selUserID {
 					return msg
 				}
@@ -582,6 +865,14 @@ func (m *ChatViewportModel) updateMsgInMsgs(msg *domain.Message) {
 	}
 }
 
+// isRecvFromSelConvo reports whether the msg is received in the selected conversation, rather than sent by us
+func (m ChatViewportModel) isRecvFromSelConvo(msg *domain.Message) bool {
+	if msg.ConversationID != nil {
+		return *msg.ConversationID == selUserID && msg.SenderID != m.client.CurrentUsr.ID
+	}
+	return msg.SenderID == selUserID
+}
+
 func (m ChatViewportModel) setMsgAsRead(msg *domain.Message) tea.Cmd {
 	return func() tea.Msg {
 		// ignore the error
@@ -590,6 +881,47 @@ func (m ChatViewportModel) setMsgAsRead(msg *domain.Message) tea.Cmd {
 	}
 }
 
+// sets the edited body of the msg in the msg slice, only if edited by its sender, if not exists -> NOOP
+func (m *ChatViewportModel) editMsgInMsgs(edit *domain.Message) {
+	for _, msg := range m.msgs {
+		if msg.ID == edit.ID && msg.SenderID == edit.SenderID {
+			msg.Body = edit.Body
+			msg.EditedAt = edit.SentAt
+			break
+		}
+	}
+}
+
+// adds or removes the reaction of its sender in the reactions of the msg, as per the op, if not exists -> NOOP
+func (m *ChatViewportModel) reactInMsgs(reaction *domain.Message) {
+	for _, msg := range m.msgs {
+		if msg.ID != reaction.ID {
+			continue
+		}
+		i := slices.IndexFunc(msg.Reactions, func(r *domain.MessageReaction) bool {
+			return r.UserID == reaction.SenderID && r.Emoji == reaction.Body
+		})
+		if reaction.Operation == domain.UnreactMsg && i != -1 {
+			msg.Reactions = slices.Delete(msg.Reactions, i, i+1)
+		}
+		if reaction.Operation == domain.ReactMsg && i == -1 {
+			msg.Reactions = append(msg.Reactions, &domain.MessageReaction{
+				MsgID:     reaction.ID,
+				UserID:    reaction.SenderID,
+				Emoji:     reaction.Body,
+				ReactedAt: reaction.SentAt,
+			})
+		}
+		break
+	}
+}
+
+func (m ChatViewportModel) hasReacted(msg *domain.Message, emoji string) bool {
+	return slices.ContainsFunc(msg.Reactions, func(r *domain.MessageReaction) bool {
+		return r.UserID == m.client.CurrentUsr.ID && r.Emoji == emoji
+	})
+}
+
 // once the message is deleted, this deletes it from the msg slice, if not exists -> NOOP
 func (m *ChatViewportModel) deleteMsgInMsgs(msgId string) {
 	for i, mesg := range m.msgs {
@@ -613,14 +945,51 @@ func (m ChatViewportModel) deleteForMe(msgId string) tea.Cmd {
 	}
 }
 
+// toggleReaction reacts to the msg with the emoji, or removes the reaction if the current user has already reacted so
+func (m ChatViewportModel) toggleReaction(msg *domain.Message, emoji string) tea.Cmd {
+	if msg == nil {
+		return nil
+	}
+	t := time.Now()
+	op := domain.ReactMsg
+	if m.hasReacted(msg, emoji) {
+		op = domain.UnreactMsg
+	}
+	reaction := &domain.Message{
+		ID:             msg.ID,
+		SenderID:       m.client.CurrentUsr.ID,
+		ReceiverID:     selUserID,
+		ConversationID: selGroupID(),
+		Body:           emoji,
+		SentAt:         &t,
+		Operation:      op,
+	}
+	return func() tea.Msg {
+		if m.client.WsConnState.Get() != client.Connected {
+			return &errMsg{
+				err:  "No Connection, unable to react to message.",
+				code: http.StatusRequestTimeout,
+			}
+		}
+		if err := m.client.ReactToMsg(reaction); err != nil {
+			return &errMsg{
+				err:  "Unable to react to this message",
+				code: 0,
+			}
+		}
+		return reactMsgSuccess(reaction)
+	}
+}
+
 func (m ChatViewportModel) deleteForEveryone(msgId string) tea.Cmd {
 	t := time.Now()
 	delMsg := &domain.Message{
-		ID:         msgId,
-		SenderID:   m.client.CurrentUsr.ID,
-		ReceiverID: selUserID,
-		SentAt:     &t,
-		Operation:  domain.DeleteMsg,
+		ID:             msgId,
+		SenderID:       m.client.CurrentUsr.ID,
+		ReceiverID:     selUserID,
+		ConversationID: selGroupID(),
+		SentAt:         &t,
+		Operation:      domain.DeleteMsg,
 	}
 	return func() tea.Msg {
 		if m.client.WsConnState.Get() != client.Connected {
@@ -638,3 +1007,69 @@ func (m ChatViewportModel) deleteForEveryone(msgId string) tea.Cmd {
 		return deleteMsgSuccess(msgId)
 	}
 }
+
+// dialogBtns returns the buttons of the msg info dialog for the msg, in the order they are cycled through
+func (m ChatViewportModel) dialogBtns(msg *domain.Message) []int {
+	btns := []int{dialogCopyBtn}
+	if msg.AttachmentID != nil {
+		btns = append(btns, dialogSaveBtn)
+	}
+	btns = append(btns, dialogReplyBtn, dialogDelForMeBtn)
+	if msg.SenderID == m.client.CurrentUsr.ID {
+		btns = append(btns, dialogDelForEveryoneBtn, dialogEditBtn)
+	}
+	return btns
+}
+
+func (m ChatViewportModel) saveAttachment(msg *domain.Message) tea.Cmd {
+	id, msgID := *msg.AttachmentID, msg.ID
+	save := func() tea.Msg {
+		path, err := m.client.SaveAttachment(id)
+		if err != nil {
+			return &errMsg{
+				err:  fmt.Sprintf("Unable to save the file, %v", err),
+				code: 0,
+			}
+		}
+		return attachmentSaved{msgID: msgID, path: path}
+	}
+	return tea.Sequence(spinnerSpinCmd, save, spinnerResetCmd)
+}
+
+// getMsg looks for the msg in the loaded msgs first & then in the local db, nil if it's nowhere to be found
+func (m ChatViewportModel) getMsg(id string) *domain.Message {
+	i := slices.IndexFunc(m.msgs, func(msg *domain.Message) bool { return msg.ID == id })
+	if i != -1 {
+		return m.msgs[i]
+	}
+	msg, err := m.client.GetMsgByID(id)
+	if err != nil {
+		if !errors.Is(err, domain.ErrRecordNotFound) {
+			slog.Error(err.Error())
+		}
+		return nil
+	}
+	return msg
+}
+
+// jumpToMsg scrolls the chat viewport to the msg, if the msg is not loaded yet the older pages are fetched
+// one by one till it's found or there are no more pages
+func (m *ChatViewportModel) jumpToMsg(id string) tea.Cmd {
+	if offset, ok := m.msgLineOffsets[id]; ok {
+		m.jumpToMsgID = nil
+		m.chatVp.SetYOffset(offset)
+		return nil
+	}
+	if m.currPage >= m.lastPage {
+		m.jumpToMsgID = nil
+		return func() tea.Msg {
+			return &errMsg{
+				err:  "The replied msg is no longer available",
+				code: 0,
+			}
+		}
+	}
+	m.jumpToMsgID = &id
+	m.fetching = true
+	return m.getMsgAsPage(m.currPage + 1)
+}
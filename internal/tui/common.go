@@ -52,6 +52,16 @@ func echoTypingCmd() tea.Cmd {
 
 type deleteMsgSuccess string // stores id of the deleted msg, remove msg with this id from the msgs slice
 
+type editMsgStart *domain.Message // the msg selected to be edited, ChatModel puts it into the textarea for editing
+
+type replyMsgStart *domain.Message // the msg selected to be replied to, ChatModel quotes it above the textarea
+
+type attachmentSaved struct{ msgID, path string } // the attachment of the msg is saved to the path
+
+type editMsgSuccess *domain.Message // the edit sent, update the msg with this id in the msgs slice
+
+type reactMsgSuccess *domain.Message // the (un)reaction sent, update the reactions of the msg with this id
+
 type clearConvoSuccess struct{}
 
 type hideSuccessMsg struct{}
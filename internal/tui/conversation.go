@@ -19,8 +19,9 @@ import (
 )
 
 const (
-	conversationSearchBar = "conversationSearchBar"
-	conversationContainer = "conversationContainer"
+	conversationSearchBar   = "conversationSearchBar"
+	conversationContainer   = "conversationContainer"
+	conversationSectionTabs = "conversationSectionTabs"
 )
 
 type convosBroadcast struct {
@@ -37,8 +38,12 @@ type ConversationModel struct {
 	selConvoItemIdx  int
 	// there is no built-in functionality for list focus as far as I scanned the docs, also see
 	// getConversationListKeyMap, this will still update the model but make it look out of focus
-	focus  bool
+	focus bool
+	// conversations of the section being shown, either the chats or the msg requests
 	convos []*domain.Conversation
+	// every conversation, the requests are shown in a separate section, see showRequests
+	allConvos    []*domain.Conversation
+	showRequests bool
 	// rerenderTimer used to rerender conversations, as timestamps gets outdated
 	rerenderTimer timer.Model
 	// resetSelectionTimer helps to move the selection marker back to selected item,
@@ -115,6 +120,8 @@ func (m ConversationModel) Update(msg tea.Msg) (ConversationModel, tea.Cmd) {
 	if m.getSelConvoUsrID() == selUserID {
 		selUsername = m.getSelConvoUsername()
 	}
+	selGroupMembers = m.getSelGroupMembers()
+	selConvoMuted, selConvoBlocked, selConvoRequest = m.getSelConvoFlags()
 
 	if m.rerenderTimer.Timedout() {
 		m.rerenderTimer.Timeout = 10 * time.Second
@@ -171,6 +178,10 @@ func (m ConversationModel) Update(msg tea.Msg) (ConversationModel, tea.Cmd) {
 			}
 		case "esc":
 			m.conversationList.FilterInput.Blur()
+		case "ctrl+q":
+			if m.showRequests || m.requestsCount() > 0 {
+				return m, m.switchSection(!m.showRequests)
+			}
 		}
 
 	case tea.MouseMsg:
@@ -206,6 +217,9 @@ func (m ConversationModel) Update(msg tea.Msg) (ConversationModel, tea.Cmd) {
 					break
 				}
 			}
+			if zone.Get(conversationSectionTabs).InBounds(msg) && msg.Action == tea.MouseActionRelease {
+				return m, m.switchSection(!m.showRequests)
+			}
 			if zone.Get(conversationSearchBar).InBounds(msg) {
 				return m, m.handleConversationListUpdate(tea.KeyMsg{Type: tea.KeyCtrlF})
 			} else {
@@ -232,11 +246,17 @@ func (m ConversationModel) Update(msg tea.Msg) (ConversationModel, tea.Cmd) {
 		}
 
 	case client.Convos:
+		m.allConvos = msg
+		// no requests left to be shown, e.g. the last one is accepted
+		if m.showRequests && m.requestsCount() == 0 {
+			m.showRequests = false
+		}
+		section := m.sectionConvos()
 		// when conversation is selected, set the count of unread msgs to 0
-		if containsSelConvo(msg) {
-			msg[m.selConvoItemIdx].UnreadMsgsCount = 0
+		if containsSelConvo(section) {
+			section[m.selConvoItemIdx].UnreadMsgsCount = 0
 		}
-		m.convos = msg
+		m.convos = section
 		// e.g. if the conversation selected is not at the top, it will get to the top because of recent msg sent
 		// so we also need to change the selection marker accordingly
 		if validMsgForSend {
@@ -262,6 +282,10 @@ func (m ConversationModel) Update(msg tea.Msg) (ConversationModel, tea.Cmd) {
 		)
 
 	case selDiscUserMsg:
+		var sectionCmd tea.Cmd
+		if m.showRequests { // the discovered users are shown along with the chats
+			sectionCmd = m.switchSection(false)
+		}
 		// there is previously discovered user set in the conversation list, we'll remove that before entering a new
 		if len(m.conversationList.Items()) > len(m.convos) {
 			m.selDiscUserConvo = nil
@@ -277,7 +301,7 @@ func (m ConversationModel) Update(msg tea.Msg) (ConversationModel, tea.Cmd) {
 				m.conversationList.Select(i)
 				selUserID = m.getSelConvoUsrID()
 				selUsername = m.getSelConvoUsername()
-				return m, nil
+				return m, sectionCmd
 			}
 		}
 		t := time.Now()
@@ -293,7 +317,7 @@ func (m ConversationModel) Update(msg tea.Msg) (ConversationModel, tea.Cmd) {
 		cmd := m.conversationList.InsertItem(0, populateConvoItem(0, convo, false))
 		m.conversationList.Select(0)
 		m.selConvoItemIdx = m.conversationList.Index()
-		return m, cmd
+		return m, tea.Sequence(sectionCmd, cmd)
 
 	}
 
@@ -307,6 +331,12 @@ func (m ConversationModel) View() string {
 	}
 	s := searchBarStyle.Render(m.conversationList.FilterInput.View())
 	s = zone.Mark(conversationSearchBar, s)
+	if count := m.requestsCount(); count > 0 || m.showRequests {
+		tabs := zone.Mark(conversationSectionTabs, renderSectionTabs(m.showRequests, count))
+		s = lipgloss.JoinVertical(lipgloss.Left, tabs, s)
+		// the list gives up the lines taken by the tabs
+		m.conversationList.SetHeight(terminalHeight - 7 - lipgloss.Height(tabs))
+	}
 	searchAndList := lipgloss.JoinVertical(lipgloss.Left, s, m.conversationList.View())
 	convos := conversationContainerStyle.Width(conversationWidth()).Height(conversationHeight()).Render(searchAndList)
 	return zone.Mark(conversationContainer, convos)
@@ -403,7 +433,12 @@ func populateConvoItem(i int, convo *domain.Conversation, renderState bool) conv
 		s = renderStateInfo(convo)
 	}
 	var count string
-	if convo.UnreadMsgsCount > 0 {
+	switch {
+	case convo.Blocked:
+		count = conversationFlagStyle.Render(" ⊘")
+	case convo.Muted: // delivered but never flagged as unread
+		count = conversationFlagStyle.Render(" 🔇")
+	case convo.UnreadMsgsCount > 0:
 		count = fmt.Sprintf(" %d⁕", convo.UnreadMsgsCount)
 		count = lipgloss.NewStyle().Foreground(greenColor).Render(count)
 		latestMsg = lipgloss.NewStyle().Foreground(primarySubtleDarkColor).Italic(true).Render(latestMsg)
@@ -415,6 +450,12 @@ func populateConvoItem(i int, convo *domain.Conversation, renderState bool) conv
 }
 
 func renderStateInfo(convo *domain.Conversation) string {
+	if convo.Pending && !convo.Request { // the presence of the receiver is unknown, till the request is accepted
+		return conversationFlagStyle.Render("request sent")
+	}
+	if convo.IsGroup {
+		return conversationGroupMembersStyle.Render(fmt.Sprintf("%d👥", len(convo.Members)))
+	}
 	t := convo.LastOnline
 	if t == nil {
 		return conversationOnlineIndicator
@@ -481,6 +522,72 @@ func (m ConversationModel) getSelConvoUsername() string {
 	return strings.Split(fv, "|")[0]
 }
 
+func (m ConversationModel) getSelGroupMembers() []*domain.GroupMember {
+	for _, convo := range m.convos {
+		if convo.UserID == selUserID && convo.IsGroup {
+			return convo.Members
+		}
+	}
+	return nil
+}
+
+// getSelConvoFlags returns whether the selected conversation is muted, blocked & a msg request to be accepted
+func (m ConversationModel) getSelConvoFlags() (bool, bool, bool) {
+	for _, convo := range m.allConvos {
+		if convo.UserID == selUserID {
+			return convo.Muted, convo.Blocked, convo.Request
+		}
+	}
+	return false, false, false
+}
+
+// sectionConvos returns the conversations of the section being shown
+func (m ConversationModel) sectionConvos() []*domain.Conversation {
+	convos := make([]*domain.Conversation, 0, len(m.allConvos))
+	for _, convo := range m.allConvos {
+		if convo.Request == m.showRequests {
+			convos = append(convos, convo)
+		}
+	}
+	return convos
+}
+
+func (m ConversationModel) requestsCount() int {
+	count := 0
+	for _, convo := range m.allConvos {
+		if convo.Request {
+			count++
+		}
+	}
+	return count
+}
+
+// switchSection shows either the msg requests or the chats, the selection moves to the selected conversation
+// if it's in the section
+func (m *ConversationModel) switchSection(requests bool) tea.Cmd {
+	m.showRequests = requests
+	m.convos = m.sectionConvos()
+	m.selDiscUserConvo = nil
+	m.conversationList.ResetFilter()
+	cmd := m.conversationList.SetItems(m.populateConvos())
+	m.selConvoItemIdx = max(0, slices.IndexFunc(m.convos, func(c *domain.Conversation) bool {
+		return c.UserID == selUserID
+	}))
+	m.conversationList.Select(m.selConvoItemIdx)
+	return cmd
+}
+
+func renderSectionTabs(requests bool, count int) string {
+	chats := conversationSectionStyle.Render("Chats")
+	reqs := conversationSectionStyle.Render(fmt.Sprintf("Requests (%d)", count))
+	if requests {
+		reqs = conversationActiveSectionStyle.Render(fmt.Sprintf("Requests (%d)", count))
+	} else {
+		chats = conversationActiveSectionStyle.Render("Chats")
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, chats, reqs, conversationFlagStyle.Render(" ctrl+q"))
+}
+
 func (m ConversationModel) convoExists() bool {
 	return slices.ContainsFunc(m.convos, func(convo *domain.Conversation) bool {
 		if m.selDiscUserConvo != nil && convo.UserID == m.selDiscUserConvo.UserID {
@@ -0,0 +1,124 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/client"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"strings"
+)
+
+const toggleDigest = "toggleDigest"
+
+// DigestModel is the opt-out of the email digests, mailed by the server when the msgs sit undelivered for long
+type DigestModel struct {
+	enabled bool
+	// fetched is false till the setting is fetched, the toggle is hidden till then
+	fetched bool
+	spinner spinner.Model
+	spin    bool
+	client  *client.Client
+}
+
+type digestSettingFetched struct{ enabled bool }
+
+func NewDigestModel(c *client.Client) DigestModel {
+	return DigestModel{
+		spinner: newSpinner(),
+		client:  c,
+	}
+}
+
+func (m DigestModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m DigestModel) Update(msg tea.Msg) (DigestModel, tea.Cmd) {
+	switch msg := msg.(type) {
+
+	case tea.MouseMsg:
+		if msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionRelease || m.spin || !m.fetched {
+			break
+		}
+		if zone.Get(toggleDigest).InBounds(msg) {
+			m.spin = true
+			return m, tea.Batch(m.spinner.Tick, m.setEnabled(!m.enabled))
+		}
+
+	case spinner.TickMsg:
+		if msg.ID == m.spinner.ID() && m.spin {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		}
+
+	case digestSettingFetched:
+		m.spin = false
+		m.spinner = newSpinner()
+		m.enabled = msg.enabled
+		m.fetched = true
+
+	case *errMsg:
+		m.spin = false
+		m.spinner = newSpinner()
+	}
+	return m, nil
+}
+
+func (m DigestModel) View() string {
+	title := sectionTitleStyle.Render("Email Digests")
+	title = lipgloss.PlaceHorizontal(usageWidth(), lipgloss.Center, title)
+	detail := "Off, no mails about the messages missed"
+	label := "Turn On"
+	if m.enabled {
+		detail = "Mailed the senders of the messages missed"
+		label = "Turn Off"
+	}
+	var action string
+	switch {
+	case m.spin:
+		action = m.spinner.View()
+	case m.fetched:
+		action = zone.Mark(toggleDigest, totpActionStyle.Render(label))
+	default:
+		detail = ""
+	}
+	info := sessionDetailStyle.MarginLeft(1).Render(detail)
+	gap := strings.Repeat(" ", max(usageWidth()-lipgloss.Width(info)-lipgloss.Width(action)-2, 1))
+	return lipgloss.JoinVertical(lipgloss.Left, title, lipgloss.JoinHorizontal(lipgloss.Top, info, gap, action))
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+// digestHeight is the height the panel always takes up, the title included
+func digestHeight() int {
+	return lipgloss.Height(sectionTitleStyle.Render("")) + 1
+}
+
+func (m DigestModel) fetchSetting() tea.Cmd {
+	return func() tea.Msg {
+		enabled, err := m.client.GetDigestEnabled()
+		if err != nil {
+			if errors.Is(err, client.ErrUnauthorized) {
+				return requireAuthMsg{}
+			}
+			return &errMsg{err: fmt.Sprintf("Unable to fetch the email digest setting, %v", err)}
+		}
+		return digestSettingFetched{enabled: enabled}
+	}
+}
+
+func (m DigestModel) setEnabled(enabled bool) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.SetDigestEnabled(enabled); err != nil {
+			if errors.Is(err, client.ErrUnauthorized) {
+				return requireAuthMsg{}
+			}
+			return &errMsg{err: fmt.Sprintf("Unable to change the email digest setting, %v", err)}
+		}
+		return digestSettingFetched{enabled: enabled}
+	}
+}
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"github.com/M0hammadUsman/letschat/internal/client"
+	"github.com/M0hammadUsman/letschat/internal/domain"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	zone "github.com/lrstanley/bubblezone"
@@ -17,6 +18,10 @@ var (
 	// selected user from conversations
 	selUserID, selUsername string
 	selUserTyping          bool
+	// members of the selected conversation if it's a group, nil otherwise
+	selGroupMembers []*domain.GroupMember
+	// the selected conversation is muted or blocked by the current user, or is a msg request to be accepted
+	selConvoMuted, selConvoBlocked, selConvoRequest bool
 	// if false msg will not be sent, and ConversationModel will not call for createConvoIfNotExist()
 	validMsgForSend bool
 )
@@ -61,7 +66,8 @@ func (m LetschatModel) Update(msg tea.Msg) (LetschatModel, tea.Cmd) {
 			m.chat.focus = true
 			m.conversation.focus = false
 		case "ctrl+x":
-			selUserID, selUsername, selUserTyping = "", "", false
+			selUserID, selUsername, selUserTyping, selGroupMembers = "", "", false, nil
+			selConvoMuted, selConvoBlocked, selConvoRequest = false, false, false
 		}
 	}
 	return m, tea.Batch(m.handleConversationUpdate(msg), m.handleChatUpdate(msg))
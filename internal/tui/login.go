@@ -19,8 +19,8 @@ type LoginModel struct {
 	placeholders []string
 	spinner      spinner.Model
 	spin         bool
-	activeBtn    int  // -1 -> none, 0 -> Continue 1 -> Signup
-	tabIdx       int  // 0 - 1 -> txtInputs | 2 - 3 -> Continue & Signup btns
+	activeBtn    int  // -1 -> none, 0 -> Continue 1 -> Signup 2 -> Forgot 3 -> SSH
+	tabIdx       int  // 0 - 1 -> txtInputs | 2 - 5 -> Continue, Signup, Forgot & SSH btns
 	dangerState  bool // we turn the form to dangerColor
 	errMsg       errMsg
 	ev           *domain.ErrValidation
@@ -31,6 +31,12 @@ type LoginModel struct {
 
 type InActiveUser struct{}
 
+// twoFactorRequired once the password is right, the login is then completed with the second factor
+type twoFactorRequired struct{}
+
+// passwordResetRequested once the OTP to reset the password is mailed
+type passwordResetRequested struct{}
+
 func InitialLoginModel() LoginModel {
 	s := spinner.New()
 	s.Style = lipgloss.NewStyle().Foreground(primaryContrastColor)
@@ -98,6 +104,18 @@ func (m LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else if m.tabIdx == 3 {
 					registerModel := InitialUserRegisterModel()
 					return registerModel, registerModel.Init()
+				} else if m.tabIdx == 4 && !m.spin {
+					if err := m.validateEmail(); err != nil {
+						return m, nil
+					}
+					m.spin = true
+					return m, tea.Batch(m.spinner.Tick, m.requestPasswordReset())
+				} else if m.tabIdx == 5 && !m.spin {
+					if err := m.validateEmail(); err != nil {
+						return m, nil
+					}
+					m.spin = true
+					return m, tea.Batch(m.spinner.Tick, m.loginWithSSH())
 				} else {
 					if m.tabIdx != 2 {
 						m.tabIdx++
@@ -105,34 +123,30 @@ func (m LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 		case "tab":
-			if m.tabIdx == 3 {
+			if m.tabIdx == 5 {
 				m.tabIdx = 0
 			} else {
 				m.tabIdx++
 			}
 		case "shift+tab":
 			if m.tabIdx == 0 {
-				m.tabIdx = 3
+				m.tabIdx = 5
 			} else {
 				m.tabIdx--
 			}
 		case "right":
-			if m.tabIdx == 2 {
-				m.activeBtn = 1
+			if m.tabIdx >= 2 && m.tabIdx < 5 {
 				m.tabIdx++
 			}
 		case "left":
-			if m.tabIdx == 3 {
-				m.activeBtn = 0
+			if m.tabIdx > 2 {
 				m.tabIdx--
 			}
 		}
 
 		{ // Updating btns
-			if m.tabIdx == 2 {
-				m.activeBtn = 0
-			} else if m.tabIdx == 3 {
-				m.activeBtn = 1
+			if m.tabIdx >= len(m.txtInputs) {
+				m.activeBtn = m.tabIdx - len(m.txtInputs)
 			} else {
 				m.activeBtn = -1
 			}
@@ -150,6 +164,16 @@ func (m LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		otpModel := InitialOTPModel(m.txtInputs[0].Value())
 		return otpModel, tea.Sequence(m.resendOtp(), otpModel.Init())
 
+	case twoFactorRequired:
+		m.spin = false
+		twoFactorModel := InitialTwoFactorModel()
+		return twoFactorModel, twoFactorModel.Init()
+
+	case passwordResetRequested:
+		m.spin = false
+		otpModel := InitialPasswordResetOTPModel(m.txtInputs[0].Value())
+		return otpModel, otpModel.Init()
+
 	case errMsg:
 		m.spin = false
 		m.dangerState = true
@@ -194,22 +218,28 @@ func (m LoginModel) View() string {
 	// Rendering btns
 	continueBtn := buttonStyle.Render("Continue")
 	signupBtn := buttonStyle.Render("Register")
+	forgotBtn := buttonStyle.Render("Forgot")
+	sshBtn := buttonStyle.Render("SSH")
 	if m.tabIdx >= len(m.txtInputs) {
-		if m.activeBtn == 0 {
-			var continueBtnTxt string
+		activeBtnTxt := func(txt string) string {
 			if m.spin {
-				continueBtnTxt = m.spinner.View()
-			} else {
-				continueBtnTxt = "Continue"
+				return m.spinner.View()
 			}
-			continueBtn = activeButtonStyleWithColor(primaryContrastColor, primaryColor).Render(continueBtnTxt)
-			sb.WriteString(activeBtnInputStyle.Render(continueBtn, signupBtn))
-		} else if m.activeBtn == 1 {
+			return txt
+		}
+		switch m.activeBtn {
+		case 0:
+			continueBtn = activeButtonStyleWithColor(primaryContrastColor, primaryColor).Render(activeBtnTxt("Continue"))
+		case 1:
 			signupBtn = activeButtonStyleWithColor(primaryContrastColor, primaryColor).Render("Register")
-			sb.WriteString(activeBtnInputStyle.Render(continueBtn, signupBtn))
+		case 2:
+			forgotBtn = activeButtonStyleWithColor(primaryContrastColor, primaryColor).Render(activeBtnTxt("Forgot"))
+		case 3:
+			sshBtn = activeButtonStyleWithColor(primaryContrastColor, primaryColor).Render(activeBtnTxt("SSH"))
 		}
+		sb.WriteString(activeBtnInputStyle.Render(continueBtn, signupBtn, forgotBtn, sshBtn))
 	} else {
-		sb.WriteString(btnInputStyle.Render(continueBtn, signupBtn))
+		sb.WriteString(btnInputStyle.Render(continueBtn, signupBtn, forgotBtn, sshBtn))
 	}
 	c := formContainer
 	if m.dangerState {
@@ -247,6 +277,21 @@ func (m *LoginModel) validateLoginModel() error {
 	return nil
 }
 
+// validateEmail validates only the email, all that is needed to reset the password
+func (m *LoginModel) validateEmail() error {
+	maps.Clear(m.ev.Errors)
+	domain.ValidateEmail(m.txtInputs[0].Value(), m.ev)
+	if err, ok := m.ev.Errors["email"]; ok {
+		m.dangerState = true
+		m.txtInputs[0].Reset()
+		m.txtInputs[0].Placeholder = err
+		m.txtInputs[0].PlaceholderStyle = lipgloss.NewStyle().Foreground(dangerColor)
+		maps.Clear(m.ev.Errors)
+		return errors.New("validation errors")
+	}
+	return nil
+}
+
 func (m *LoginModel) handleActiveTabIdxElement() {
 	for i := range m.txtInputs {
 		if i == m.tabIdx {
@@ -278,6 +323,9 @@ func (m LoginModel) login() tea.Cmd {
 			if errors.Is(err, client.ErrNonActiveUser) {
 				return InActiveUser{}
 			}
+			if errors.Is(err, client.ErrTwoFactorRequired) {
+				return twoFactorRequired{}
+			}
 			return errMsg{err: err.Error()}
 		} else {
 			return doneMsg{}
@@ -285,6 +333,25 @@ func (m LoginModel) login() tea.Cmd {
 	}
 }
 
+// loginWithSSH logs in with the SSH key of the ssh-agent or ~/.ssh added to the account, only the email is needed
+func (m LoginModel) loginWithSSH() tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.LoginWithSSH(m.txtInputs[0].Value())
+		switch {
+		case err == nil:
+			return doneMsg{}
+		case errors.Is(err, client.ErrNonActiveUser):
+			return InActiveUser{}
+		case errors.Is(err, client.ErrTwoFactorRequired):
+			return twoFactorRequired{}
+		case errors.Is(err, client.ErrUnauthorized):
+			return errMsg{err: "unable to log in with the SSH key, check the email"}
+		default:
+			return errMsg{err: err.Error()}
+		}
+	}
+}
+
 func (m LoginModel) resendOtp() tea.Cmd {
 	return func() tea.Msg {
 		if err := m.client.ResendOtp(m.txtInputs[0].Value()); err != nil {
@@ -293,3 +360,15 @@ func (m LoginModel) resendOtp() tea.Cmd {
 		return nil
 	}
 }
+
+func (m LoginModel) requestPasswordReset() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.RequestPasswordReset(m.txtInputs[0].Value()); err != nil {
+			if errors.Is(err, client.ErrUnauthorized) {
+				return errMsg{err: "no activated account with this email"}
+			}
+			return errMsg{err: err.Error()}
+		}
+		return passwordResetRequested{}
+	}
+}
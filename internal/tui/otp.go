@@ -29,6 +29,9 @@ type OtpModel struct {
 	errMsg      errMsg
 	ev          *domain.ErrValidation
 	client      *client.Client
+	// reset is for the OTP to reset the password with, the new password is entered along with it
+	reset    bool
+	password textinput.Model
 }
 
 func InitialOTPModel(email string) OtpModel {
@@ -52,6 +55,22 @@ func InitialOTPModel(email string) OtpModel {
 	}
 }
 
+func InitialPasswordResetOTPModel(email string) OtpModel {
+	m := InitialOTPModel(email)
+	m.reset = true
+	p := textinput.New()
+	p.Prompt = ""
+	p.CharLimit = 64
+	p.Placeholder = "your new password goes here..."
+	p.TextStyle = lipgloss.NewStyle().Foreground(primaryColor)
+	p.EchoCharacter = '*'
+	p.EchoMode = textinput.EchoPassword
+	p.Cursor = cursor.New()
+	p.Cursor.SetMode(cursor.CursorHide)
+	m.password = p
+	return m
+}
+
 func (m OtpModel) Init() tea.Cmd {
 	return tea.Batch(textinput.Blink, m.timer.Init())
 }
@@ -76,10 +95,26 @@ func (m OtpModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.populateErr("Invalid!")
 					return m, nil
 				}
+				if m.reset { // the new password is yet to be entered
+					m.tabIdx++
+					m.focusTabIdx()
+					return m, nil
+				}
 				m.sent = true
 				return m, m.activateUser()
 			}
-			if m.tabIdx == 1 {
+			if m.reset && m.tabIdx == 1 {
+				if err := m.validateOtp(); err != nil {
+					m.populateErr("Invalid!")
+					return m, nil
+				}
+				if err := m.validatePassword(); err != nil {
+					return m, nil
+				}
+				m.sent = true
+				return m, m.resetPassword()
+			}
+			if m.tabIdx == m.resendIdx() {
 				if m.timer.Timedout() {
 					m.sent = false
 					m.timer.Timeout = timeout
@@ -87,21 +122,19 @@ func (m OtpModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 		case "tab":
-			if m.tabIdx == 1 {
-				m.otp.Focus()
+			if m.tabIdx == m.resendIdx() {
 				m.tabIdx = 0
 			} else {
-				m.otp.Blur()
 				m.tabIdx++
 			}
+			m.focusTabIdx()
 		case "shift+tab":
 			if m.tabIdx == 0 {
-				m.otp.Blur()
-				m.tabIdx = 1
+				m.tabIdx = m.resendIdx()
 			} else {
-				m.otp.Focus()
 				m.tabIdx--
 			}
+			m.focusTabIdx()
 		}
 	case timer.TickMsg:
 		var cmd tea.Cmd
@@ -111,6 +144,8 @@ func (m OtpModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case errMsg:
 		if msg.err == "Expired!" {
 			m.populateErr(msg.String())
+			m.tabIdx = 0 // in case of reset, the focus was on the password
+			m.focusTabIdx()
 		} else {
 			m.errMsg = msg
 		}
@@ -124,7 +159,11 @@ func (m OtpModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	var cmd tea.Cmd
-	m.otp, cmd = m.otp.Update(msg)
+	if m.reset && m.tabIdx == 1 {
+		m.password, cmd = m.password.Update(msg)
+	} else {
+		m.otp, cmd = m.otp.Update(msg)
+	}
 	return m, cmd
 }
 
@@ -140,6 +179,8 @@ func (m OtpModel) View() string {
 		m.otp.TextStyle = m.otp.TextStyle.Foreground(dangerColor)
 		e := ansi.Wordwrap(m.errMsg.String(), 60, " ")
 		sb.WriteString(infoTxtStyle.Foreground(dangerColor).Render(e))
+	} else if m.reset {
+		sb.WriteString(infoTxtStyle.Render("We've sent you some random digits, paste them here along with a new password"))
 	} else {
 		sb.WriteString(infoTxtStyle.Render("We've sent you some random digits, paste them here & hit enter"))
 	}
@@ -148,8 +189,15 @@ func (m OtpModel) View() string {
 		otpStyle = otpStyle.BorderForeground(primaryColor)
 	}
 	sb.WriteString(otpStyle.Render(m.otp.View()))
+	if m.reset {
+		if m.tabIdx == 1 {
+			sb.WriteString(activeInputStyle.Render(m.password.View()))
+		} else {
+			sb.WriteString(inputStyle.Render(m.password.View()))
+		}
+	}
 	btnStyle := buttonStyle
-	if m.tabIdx == 1 {
+	if m.tabIdx == m.resendIdx() {
 		if m.timer.Timedout() {
 			btnStyle = buttonStyle.Background(primaryColor).Foreground(primaryContrastColor)
 		} else {
@@ -176,6 +224,38 @@ func (m OtpModel) validateOtp() error {
 	return nil
 }
 
+func (m *OtpModel) validatePassword() error {
+	domain.ValidPlainPassword(m.password.Value(), m.ev)
+	if err, ok := m.ev.Errors["password"]; ok {
+		m.password.Reset()
+		m.password.Placeholder = err
+		m.password.PlaceholderStyle = lipgloss.NewStyle().Foreground(dangerColor)
+		maps.Clear(m.ev.Errors)
+		return ErrValidation
+	}
+	return nil
+}
+
+// resendIdx is the tabIdx of the Resend btn, the last one
+func (m OtpModel) resendIdx() int {
+	if m.reset {
+		return 2
+	}
+	return 1
+}
+
+// focusTabIdx focuses the input at the tabIdx, if any, blurring the others
+func (m *OtpModel) focusTabIdx() {
+	m.otp.Blur()
+	m.password.Blur()
+	switch {
+	case m.tabIdx == 0:
+		m.otp.Focus()
+	case m.reset && m.tabIdx == 1:
+		m.password.Focus()
+	}
+}
+
 func (m *OtpModel) populateErr(err string) {
 	m.otp.Reset()
 	m.otp.Placeholder = err
@@ -186,7 +266,7 @@ func (m *OtpModel) populateErr(err string) {
 
 func (m OtpModel) activateUser() tea.Cmd {
 	return func() tea.Msg {
-		if err := m.client.ActivateUser(m.otp.Value()); err != nil {
+		if err := m.client.ActivateUser(m.userEmail, m.otp.Value()); err != nil {
 			if errors.Is(err, client.ErrExpiredOTP) {
 				return errMsg{err: "Expired!"}
 			} else {
@@ -198,9 +278,30 @@ func (m OtpModel) activateUser() tea.Cmd {
 	}
 }
 
+func (m OtpModel) resetPassword() tea.Cmd {
+	return func() tea.Msg {
+		pr := domain.UserPasswordReset{
+			Email:    m.userEmail,
+			OTP:      m.otp.Value(),
+			Password: m.password.Value(),
+		}
+		if err := m.client.ResetPassword(pr); err != nil {
+			if errors.Is(err, client.ErrExpiredOTP) {
+				return errMsg{err: "Expired!"}
+			}
+			return errMsg{err: err.Error()}
+		}
+		return doneMsg{}
+	}
+}
+
 func (m OtpModel) resendOtp() tea.Cmd {
 	return func() tea.Msg {
-		if err := m.client.ResendOtp(m.userEmail); err != nil {
+		resend := m.client.ResendOtp
+		if m.reset {
+			resend = m.client.RequestPasswordReset
+		}
+		if err := resend(m.userEmail); err != nil {
 			return errMsg{err: err.Error()}
 		}
 		return nil
@@ -13,46 +13,85 @@ const (
 )
 
 type PreferencesModel struct {
-	up      UpdateProfileModel
-	usageVp UsageViewportModel
-	focus   bool
-	client  *client.Client
+	up       UpdateProfileModel
+	sessions SessionsModel
+	totp     TOTPModel
+	sshKeys  SSHKeysModel
+	digest   DigestModel
+	usageVp  UsageViewportModel
+	// focus of the previous update, the sessions, the TOTP status, the SSH keys & the digest setting are re-fetched
+	// every time the tab is switched to
+	focus, wasFocused bool
+	client            *client.Client
 }
 
 func NewPreferencesModel(c *client.Client) PreferencesModel {
 	return PreferencesModel{
-		up:      NewUpdateProfileModel(c),
-		usageVp: NewUsageViewportModel(),
-		client:  c,
+		up:       NewUpdateProfileModel(c),
+		sessions: NewSessionsModel(c),
+		totp:     NewTOTPModel(c),
+		sshKeys:  NewSSHKeysModel(c),
+		digest:   NewDigestModel(c),
+		usageVp:  NewUsageViewportModel(),
+		client:   c,
 	}
 }
 
 func (m PreferencesModel) Init() tea.Cmd {
-	return tea.Batch(m.up.Init(), m.usageVp.Init())
+	return tea.Batch(m.up.Init(), m.sessions.Init(), m.totp.Init(), m.sshKeys.Init(), m.digest.Init(), m.usageVp.Init())
 }
 
 func (m PreferencesModel) Update(msg tea.Msg) (PreferencesModel, tea.Cmd) {
+	var fetchSessions, fetchTOTPStatus, fetchSSHKeys, fetchDigest tea.Cmd
+	if m.focus && !m.wasFocused {
+		fetchSessions = m.sessions.fetchSessions()
+		fetchTOTPStatus = m.totp.fetchStatus()
+		fetchSSHKeys = m.sshKeys.fetchKeys()
+		fetchDigest = m.digest.fetchSetting()
+	}
+	m.wasFocused = m.focus
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		m.up.focus = m.focus
+		// the keys go to the TOTP panel while it's clicked on, to the profile form otherwise
+		m.totp.focus = m.focus && m.totp.focus
+		m.up.focus = m.focus && !m.totp.focus
 	case tea.MouseMsg:
 		m.usageVp.focus = false
 		m.up.focus = false
+		m.totp.focus = false
 		if zone.Get(updateProfile).InBounds(msg) {
 			m.up.focus = true
 		}
 		if zone.Get(usageVp).InBounds(msg) {
 			m.usageVp.focus = true
 		}
+		if zone.Get(totpPanel).InBounds(msg) {
+			m.totp.focus = true
+		}
 	}
-	return m, tea.Batch(m.handleUsageViewportUpdate(msg), m.handleUpdateProfileModelUpdate(msg))
+	return m, tea.Batch(
+		fetchSessions,
+		fetchTOTPStatus,
+		fetchSSHKeys,
+		fetchDigest,
+		m.handleUsageViewportUpdate(msg),
+		m.handleSessionsModelUpdate(msg),
+		m.handleTOTPModelUpdate(msg),
+		m.handleSSHKeysModelUpdate(msg),
+		m.handleDigestModelUpdate(msg),
+		m.handleUpdateProfileModelUpdate(msg),
+	)
 }
 
 func (m PreferencesModel) View() string {
 	d := verticalDivider.Height(conversationHeight()).Render()
 	upView := zone.Mark(updateProfile, m.up.View())
 	usageVpView := zone.Mark(usageVp, m.usageVp.View())
-	return lipgloss.JoinHorizontal(lipgloss.Left, upView, d, usageVpView)
+	right := lipgloss.JoinVertical(lipgloss.Left, m.sessions.View(), m.totp.View(), m.sshKeys.View(), m.digest.View(), usageVpView)
+	if m.totp.expanded() { // enrolling takes the whole column, the QR code is tall
+		right = m.totp.View()
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Left, upView, d, right)
 }
 
 // Helpers & Stuff -----------------------------------------------------------------------------------------------------
@@ -63,6 +102,30 @@ func (m *PreferencesModel) handleUpdateProfileModelUpdate(msg tea.Msg) tea.Cmd {
 	return cmd
 }
 
+func (m *PreferencesModel) handleSessionsModelUpdate(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	m.sessions, cmd = m.sessions.Update(msg)
+	return cmd
+}
+
+func (m *PreferencesModel) handleTOTPModelUpdate(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	m.totp, cmd = m.totp.Update(msg)
+	return cmd
+}
+
+func (m *PreferencesModel) handleSSHKeysModelUpdate(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	m.sshKeys, cmd = m.sshKeys.Update(msg)
+	return cmd
+}
+
+func (m *PreferencesModel) handleDigestModelUpdate(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	m.digest, cmd = m.digest.Update(msg)
+	return cmd
+}
+
 func (m *PreferencesModel) handleUsageViewportUpdate(msg tea.Msg) tea.Cmd {
 	var cmd tea.Cmd
 	m.usageVp, cmd = m.usageVp.Update(msg)
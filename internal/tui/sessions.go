@@ -0,0 +1,182 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/client"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	zone "github.com/lrstanley/bubblezone"
+	"strings"
+)
+
+// maxShownSessions keeps the panel at a fixed height, so the usage viewport below can be sized once
+const maxShownSessions = 5
+
+type SessionsModel struct {
+	sessions []*domain.Session
+	// the session armed to be revoked, it's revoked on the second click, -1 if none
+	armedIdx int
+	spinner  spinner.Model
+	spin     bool
+	client   *client.Client
+}
+
+type sessionsFetched struct{ sessions []*domain.Session }
+
+type sessionRevoked struct{}
+
+func NewSessionsModel(c *client.Client) SessionsModel {
+	return SessionsModel{
+		armedIdx: -1,
+		spinner:  newSpinner(),
+		client:   c,
+	}
+}
+
+func (m SessionsModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m SessionsModel) Update(msg tea.Msg) (SessionsModel, tea.Cmd) {
+	switch msg := msg.(type) {
+
+	case tea.MouseMsg:
+		if msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionRelease {
+			break
+		}
+		for i := range m.shownSessions() {
+			if !zone.Get(fmt.Sprint("revokeSession", i)).InBounds(msg) {
+				continue
+			}
+			if m.armedIdx != i {
+				m.armedIdx = i
+				return m, nil
+			}
+			if !m.spin {
+				m.spin = true
+				m.armedIdx = -1
+				return m, tea.Batch(m.spinner.Tick, m.revokeSession(m.sessions[i].ID))
+			}
+		}
+		// clicking anywhere else disarms
+		m.armedIdx = -1
+
+	case spinner.TickMsg:
+		if msg.ID == m.spinner.ID() && m.spin {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		}
+
+	case sessionsFetched:
+		m.sessions = msg.sessions
+		m.armedIdx = -1
+
+	case sessionRevoked:
+		m.spin = false
+		m.spinner = newSpinner()
+		return m, m.fetchSessions()
+
+	case *errMsg:
+		m.spin = false
+		m.spinner = newSpinner()
+	}
+	return m, nil
+}
+
+func (m SessionsModel) View() string {
+	title := sectionTitleStyle.Render("Active Sessions")
+	title = lipgloss.PlaceHorizontal(usageWidth(), lipgloss.Center, title)
+	var sb strings.Builder
+	for i, s := range m.shownSessions() {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(m.renderSession(i, s))
+	}
+	if len(m.sessions) > maxShownSessions {
+		sb.WriteString("\n")
+		sb.WriteString(sessionDetailStyle.Render(fmt.Sprintf("& %v more", len(m.sessions)-maxShownSessions)))
+	}
+	list := lipgloss.NewStyle().Height(sessionsListHeight()).Render(sb.String())
+	return lipgloss.JoinVertical(lipgloss.Left, title, list)
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+// sessionsHeight is the height the panel always takes up, the title included
+func sessionsHeight() int {
+	return lipgloss.Height(sectionTitleStyle.Render("")) + sessionsListHeight()
+}
+
+func sessionsListHeight() int {
+	return maxShownSessions + 1 // the "& n more" line
+}
+
+func (m SessionsModel) shownSessions() []*domain.Session {
+	if len(m.sessions) > maxShownSessions {
+		return m.sessions[:maxShownSessions]
+	}
+	return m.sessions
+}
+
+func (m SessionsModel) renderSession(idx int, s *domain.Session) string {
+	device := s.Device
+	if device == "" {
+		device = "unknown device"
+	}
+	lastUsed := "just now"
+	if ago := calculateOnlineAgoTimestamp(s.LastUsedAt); ago != "" {
+		lastUsed = ago + " ago"
+	}
+	var action string
+	switch {
+	case s.Current:
+		action = sessionCurrentStyle.Render("this device")
+	case m.spin:
+		action = m.spinner.View()
+	case m.armedIdx == idx:
+		action = zone.Mark(fmt.Sprint("revokeSession", idx), sessionRevokeArmedStyle.Render("Confirm?"))
+	default:
+		action = zone.Mark(fmt.Sprint("revokeSession", idx), sessionRevokeStyle.Render("Revoke"))
+	}
+	detail := sessionDetailStyle.Render(fmt.Sprintf(" %v · %v", s.IP, lastUsed))
+	// the device name gets whatever width the rest leaves
+	w := max(usageWidth()-lipgloss.Width(detail)-lipgloss.Width(action)-4, 1)
+	device = sessionDeviceStyle.Render(ansi.Truncate(device, w, "…"))
+	left := lipgloss.JoinHorizontal(lipgloss.Top, device, detail)
+	gap := strings.Repeat(" ", max(usageWidth()-lipgloss.Width(left)-lipgloss.Width(action)-2, 1))
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, gap, action)
+}
+
+func (m SessionsModel) fetchSessions() tea.Cmd {
+	return func() tea.Msg {
+		sessions, err := m.client.GetSessions()
+		if err != nil {
+			if errors.Is(err, client.ErrUnauthorized) {
+				return requireAuthMsg{}
+			}
+			return &errMsg{err: fmt.Sprintf("Unable to fetch the sessions, %v", err)}
+		}
+		return sessionsFetched{sessions: sessions}
+	}
+}
+
+// revokeSession logs the device out, its websocket connection is closed by the server
+func (m SessionsModel) revokeSession(id string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.RevokeSession(id)
+		switch {
+		case err == nil, errors.Is(err, client.ErrServerValidation): // already revoked or expired, refetch either way
+			return sessionRevoked{}
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		default:
+			return &errMsg{err: fmt.Sprintf("Unable to revoke the session, %v", err)}
+		}
+	}
+}
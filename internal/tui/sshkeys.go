@@ -0,0 +1,223 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/client"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	zone "github.com/lrstanley/bubblezone"
+	"strings"
+)
+
+const (
+	// maxShownSSHKeys keeps the panel at a fixed height, as does the maxShownSessions
+	maxShownSSHKeys = 3
+	addSSHKey       = "addSSHKey"
+)
+
+type SSHKeysModel struct {
+	keys []*domain.SSHKey
+	// the key armed to be removed, it's removed on the second click, -1 if none
+	armedIdx int
+	// why the local key could not be added, shown next to the add action
+	info    string
+	spinner spinner.Model
+	spin    bool
+	client  *client.Client
+}
+
+type sshKeysFetched struct{ keys []*domain.SSHKey }
+
+type sshKeysChanged struct{}
+
+type sshKeyNotAdded struct{ reason string }
+
+func NewSSHKeysModel(c *client.Client) SSHKeysModel {
+	return SSHKeysModel{
+		armedIdx: -1,
+		spinner:  newSpinner(),
+		client:   c,
+	}
+}
+
+func (m SSHKeysModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m SSHKeysModel) Update(msg tea.Msg) (SSHKeysModel, tea.Cmd) {
+	switch msg := msg.(type) {
+
+	case tea.MouseMsg:
+		if msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionRelease || m.spin {
+			break
+		}
+		if zone.Get(addSSHKey).InBounds(msg) {
+			m.spin = true
+			m.armedIdx = -1
+			m.info = ""
+			return m, tea.Batch(m.spinner.Tick, m.addLocalKey())
+		}
+		for i := range m.shownKeys() {
+			if !zone.Get(fmt.Sprint("removeSSHKey", i)).InBounds(msg) {
+				continue
+			}
+			if m.armedIdx != i {
+				m.armedIdx = i
+				return m, nil
+			}
+			m.spin = true
+			m.armedIdx = -1
+			return m, tea.Batch(m.spinner.Tick, m.removeKey(m.keys[i].ID))
+		}
+		// clicking anywhere else disarms
+		m.armedIdx = -1
+
+	case spinner.TickMsg:
+		if msg.ID == m.spinner.ID() && m.spin {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		}
+
+	case sshKeysFetched:
+		m.keys = msg.keys
+		m.armedIdx = -1
+
+	case sshKeysChanged:
+		m.spin = false
+		m.spinner = newSpinner()
+		return m, m.fetchKeys()
+
+	case sshKeyNotAdded:
+		m.spin = false
+		m.spinner = newSpinner()
+		m.info = msg.reason
+
+	case *errMsg:
+		m.spin = false
+		m.spinner = newSpinner()
+	}
+	return m, nil
+}
+
+func (m SSHKeysModel) View() string {
+	title := sectionTitleStyle.Render("SSH Keys")
+	title = lipgloss.PlaceHorizontal(usageWidth(), lipgloss.Center, title)
+	var sb strings.Builder
+	for i, k := range m.shownKeys() {
+		sb.WriteString(m.renderKey(i, k))
+		sb.WriteString("\n")
+	}
+	if len(m.keys) == 0 {
+		sb.WriteString(sessionDetailStyle.MarginLeft(1).Render("Add a key to log in without the password"))
+		sb.WriteString("\n")
+	}
+	list := lipgloss.NewStyle().Height(maxShownSSHKeys).Render(strings.TrimSuffix(sb.String(), "\n"))
+	return lipgloss.JoinVertical(lipgloss.Left, title, list, m.renderAddAction())
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+// sshKeysHeight is the height the panel always takes up, the title included
+func sshKeysHeight() int {
+	return lipgloss.Height(sectionTitleStyle.Render("")) + maxShownSSHKeys + 1 // the add action line
+}
+
+func (m SSHKeysModel) shownKeys() []*domain.SSHKey {
+	if len(m.keys) > maxShownSSHKeys {
+		return m.keys[:maxShownSSHKeys]
+	}
+	return m.keys
+}
+
+func (m SSHKeysModel) renderKey(idx int, k *domain.SSHKey) string {
+	lastUsed := "never used"
+	if k.LastUsedAt != nil {
+		lastUsed = "used just now"
+		if ago := calculateOnlineAgoTimestamp(k.LastUsedAt); ago != "" {
+			lastUsed = "used " + ago + " ago"
+		}
+	}
+	action := zone.Mark(fmt.Sprint("removeSSHKey", idx), sessionRevokeStyle.Render("Remove"))
+	switch {
+	case m.spin:
+		action = m.spinner.View()
+	case m.armedIdx == idx:
+		action = zone.Mark(fmt.Sprint("removeSSHKey", idx), sessionRevokeArmedStyle.Render("Confirm?"))
+	}
+	// the fingerprint is cut short, the start is enough to tell the keys apart
+	detail := sessionDetailStyle.Render(fmt.Sprintf(" %v… · %v", ansi.Truncate(k.Fingerprint, 19, ""), lastUsed))
+	w := max(usageWidth()-lipgloss.Width(detail)-lipgloss.Width(action)-4, 1)
+	name := sessionDeviceStyle.Render(ansi.Truncate(k.Name, w, "…"))
+	left := lipgloss.JoinHorizontal(lipgloss.Top, name, detail)
+	gap := strings.Repeat(" ", max(usageWidth()-lipgloss.Width(left)-lipgloss.Width(action)-2, 1))
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, gap, action)
+}
+
+func (m SSHKeysModel) renderAddAction() string {
+	var more string
+	if len(m.keys) > maxShownSSHKeys {
+		more = fmt.Sprintf("& %v more", len(m.keys)-maxShownSSHKeys)
+	}
+	if m.info != "" {
+		more = m.info
+	}
+	action := zone.Mark(addSSHKey, totpActionStyle.Render("Add this device's key"))
+	if m.spin {
+		action = m.spinner.View()
+	}
+	w := max(usageWidth()-lipgloss.Width(action)-4, 1)
+	info := sessionDetailStyle.MarginLeft(1).Render(ansi.Truncate(more, w, "…"))
+	gap := strings.Repeat(" ", max(usageWidth()-lipgloss.Width(info)-lipgloss.Width(action)-2, 1))
+	return lipgloss.JoinHorizontal(lipgloss.Top, info, gap, action)
+}
+
+func (m SSHKeysModel) fetchKeys() tea.Cmd {
+	return func() tea.Msg {
+		keys, err := m.client.GetSSHKeys()
+		if err != nil {
+			if errors.Is(err, client.ErrUnauthorized) {
+				return requireAuthMsg{}
+			}
+			return &errMsg{err: fmt.Sprintf("Unable to fetch the SSH keys, %v", err)}
+		}
+		return sshKeysFetched{keys: keys}
+	}
+}
+
+// addLocalKey adds the first key of the ssh-agent or ~/.ssh, the one an SSH login tries first
+func (m SSHKeysModel) addLocalKey() tea.Cmd {
+	return func() tea.Msg {
+		_, err := m.client.AddLocalSSHKey()
+		switch {
+		case err == nil:
+			return sshKeysChanged{}
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		case errors.Is(err, client.ErrServerValidation):
+			return sshKeyNotAdded{reason: strings.TrimPrefix(err.Error(), client.ErrServerValidation.Error()+", ")}
+		case errors.Is(err, client.ErrApplication):
+			return &errMsg{err: fmt.Sprintf("Unable to add the SSH key, %v", err)}
+		default: // no local key
+			return sshKeyNotAdded{reason: err.Error()}
+		}
+	}
+}
+
+func (m SSHKeysModel) removeKey(id string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.DeleteSSHKey(id)
+		switch {
+		case err == nil, errors.Is(err, client.ErrServerValidation): // already removed, refetch either way
+			return sshKeysChanged{}
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		default:
+			return &errMsg{err: fmt.Sprintf("Unable to remove the SSH key, %v", err)}
+		}
+	}
+}
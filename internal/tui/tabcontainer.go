@@ -138,6 +138,7 @@ func (m TabContainerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		selUserID = ""
 		selUserTyping = false
 		selUsername = ""
+		selGroupMembers = nil
 		loginModel := InitialLoginModel()
 		return loginModel, loginModel.Init()
 
@@ -0,0 +1,390 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/M0hammadUsman/letschat/internal/client"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/skip2/go-qrcode"
+	"strings"
+)
+
+const (
+	totpPanel  = "totpPanel"
+	totpAction = "totpAction"
+	totpCancel = "totpCancel"
+)
+
+type totpState int
+
+const (
+	totpIdle totpState = iota
+	// totpEnrolling the secret is shown, waiting for the first code to confirm it with
+	totpEnrolling
+	// totpRecovery the recovery codes are shown, they're never shown again
+	totpRecovery
+	// totpDisabling waiting for the password to disable it with
+	totpDisabling
+)
+
+// TOTPModel is the two-factor authentication panel of the preferences, while enrolling or showing the recovery
+// codes it takes up the whole right column
+type TOTPModel struct {
+	state      totpState
+	enabled    bool
+	enrollment *domain.TOTPEnrollment
+	recovery   []string
+	input      textinput.Model
+	// the code or password did not match, shown below the input
+	invalid string
+	spinner spinner.Model
+	spin    bool
+	focus   bool
+	client  *client.Client
+}
+
+type totpStatusFetched struct{ enabled bool }
+
+type totpEnrolled struct{ enrollment *domain.TOTPEnrollment }
+
+type totpConfirmed struct{ recoveryCodes []string }
+
+type totpDisabled struct{}
+
+type totpInvalid struct{ err string }
+
+func NewTOTPModel(c *client.Client) TOTPModel {
+	i := textinput.New()
+	i.Prompt = ""
+	i.TextStyle = lipgloss.NewStyle().Foreground(primaryColor)
+	i.PlaceholderStyle = lipgloss.NewStyle().Foreground(darkGreyColor)
+	i.Cursor = cursor.New()
+	i.Cursor.SetMode(cursor.CursorHide)
+	return TOTPModel{
+		input:   i,
+		spinner: newSpinner(),
+		client:  c,
+	}
+}
+
+func (m TOTPModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m TOTPModel) Update(msg tea.Msg) (TOTPModel, tea.Cmd) {
+	switch msg := msg.(type) {
+
+	case tea.KeyMsg:
+		if !m.focus || !m.hasInput() {
+			break
+		}
+		m.invalid = ""
+		switch msg.String() {
+		case "esc":
+			m.reset()
+			return m, nil
+		case "enter":
+			if m.spin {
+				return m, nil
+			}
+			return m.submit()
+		}
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+
+	case tea.MouseMsg:
+		if msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionRelease || m.spin {
+			break
+		}
+		switch {
+		case zone.Get(totpCancel).InBounds(msg):
+			m.reset()
+		case zone.Get(totpAction).InBounds(msg):
+			switch {
+			case m.state == totpRecovery: // Done
+				m.reset()
+			case m.enabled:
+				m.state = totpDisabling
+				m.prepareInput("your password, then hit enter", 64, true)
+				return m, textinput.Blink
+			default:
+				m.spin = true
+				return m, tea.Batch(m.spinner.Tick, m.enroll())
+			}
+		}
+
+	case spinner.TickMsg:
+		if msg.ID == m.spinner.ID() && m.spin {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		}
+
+	case totpStatusFetched:
+		m.enabled = msg.enabled
+
+	case totpEnrolled:
+		m.spin = false
+		m.spinner = newSpinner()
+		m.state = totpEnrolling
+		m.enrollment = msg.enrollment
+		m.prepareInput("the 6 digits your app shows, then hit enter", domain.TOTPDigits, false)
+		return m, textinput.Blink
+
+	case totpConfirmed:
+		m.spin = false
+		m.spinner = newSpinner()
+		m.enabled = true
+		m.state = totpRecovery
+		m.enrollment = nil
+		m.recovery = msg.recoveryCodes
+
+	case totpDisabled:
+		m.spin = false
+		m.spinner = newSpinner()
+		m.enabled = false
+		m.reset()
+
+	case totpInvalid:
+		m.spin = false
+		m.spinner = newSpinner()
+		m.invalid = msg.err
+		m.input.Reset()
+
+	case *errMsg:
+		m.spin = false
+		m.spinner = newSpinner()
+	}
+	return m, nil
+}
+
+func (m TOTPModel) View() string {
+	title := sectionTitleStyle.Render("Two-Factor Authentication")
+	title = lipgloss.PlaceHorizontal(usageWidth(), lipgloss.Center, title)
+	var body string
+	switch m.state {
+	case totpEnrolling:
+		body = m.renderEnrollment(conversationHeight() - 1 - lipgloss.Height(title))
+	case totpRecovery:
+		body = m.renderRecoveryCodes()
+	default:
+		body = lipgloss.NewStyle().Height(totpPanelHeight()).Render(m.renderStatus())
+	}
+	return zone.Mark(totpPanel, lipgloss.JoinVertical(lipgloss.Left, title, body))
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+// totpHeight is the height the panel takes up when not expanded, the title included
+func totpHeight() int {
+	return lipgloss.Height(sectionTitleStyle.Render("")) + totpPanelHeight()
+}
+
+func totpPanelHeight() int {
+	return 2 // the status or the password input, & the invalid line
+}
+
+// expanded tells whether the panel takes up the whole right column
+func (m TOTPModel) expanded() bool {
+	return m.state == totpEnrolling || m.state == totpRecovery
+}
+
+func (m TOTPModel) hasInput() bool {
+	return m.state == totpEnrolling || m.state == totpDisabling
+}
+
+func (m *TOTPModel) prepareInput(placeholder string, charLimit int, password bool) {
+	m.input.Reset()
+	m.input.Placeholder = placeholder
+	m.input.CharLimit = charLimit
+	m.input.Width = usageWidth() - 6
+	m.input.EchoMode = textinput.EchoNormal
+	if password {
+		m.input.EchoCharacter = '*'
+		m.input.EchoMode = textinput.EchoPassword
+	}
+	m.input.Focus()
+}
+
+func (m *TOTPModel) reset() {
+	m.state = totpIdle
+	m.enrollment = nil
+	m.recovery = nil
+	m.invalid = ""
+	m.input.Reset()
+	m.input.Blur()
+}
+
+func (m TOTPModel) submit() (TOTPModel, tea.Cmd) {
+	ev := domain.NewErrValidation()
+	switch m.state {
+	case totpEnrolling:
+		domain.ValidateTOTPCode(m.input.Value(), ev)
+	case totpDisabling:
+		ev.Evaluate(m.input.Value() != "", "password", "must be provided")
+	}
+	if ev.HasErrors() {
+		for _, err := range ev.Errors {
+			m.invalid = err
+		}
+		m.input.Reset()
+		return m, nil
+	}
+	m.spin = true
+	if m.state == totpEnrolling {
+		return m, tea.Batch(m.spinner.Tick, m.confirm(m.input.Value()))
+	}
+	return m, tea.Batch(m.spinner.Tick, m.disable(m.input.Value()))
+}
+
+func (m TOTPModel) renderStatus() string {
+	var line string
+	if m.state == totpDisabling {
+		cancel := zone.Mark(totpCancel, sessionRevokeStyle.Render("Cancel"))
+		m.input.Width = max(usageWidth()-lipgloss.Width(cancel)-6, 1)
+		// no border, the panel keeps its height
+		input := lipgloss.NewStyle().MarginLeft(1).Render(m.input.View())
+		if m.spin {
+			cancel = m.spinner.View()
+		}
+		gap := strings.Repeat(" ", max(usageWidth()-lipgloss.Width(input)-lipgloss.Width(cancel)-2, 1))
+		line = lipgloss.JoinHorizontal(lipgloss.Top, input, gap, cancel)
+	} else {
+		status := sessionDetailStyle.Render("Off, only the password is asked for on login")
+		action := zone.Mark(totpAction, totpActionStyle.Render("Enable"))
+		if m.enabled {
+			status = sessionCurrentStyle.Render("On, the authenticator app is asked for on login")
+			action = zone.Mark(totpAction, sessionRevokeStyle.Render("Disable"))
+		}
+		if m.spin {
+			action = m.spinner.View()
+		}
+		status = lipgloss.NewStyle().MarginLeft(1).Render(status)
+		gap := strings.Repeat(" ", max(usageWidth()-lipgloss.Width(status)-lipgloss.Width(action)-2, 1))
+		line = lipgloss.JoinHorizontal(lipgloss.Top, status, gap, action)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, line, m.renderInvalid())
+}
+
+// renderEnrollment renders the QR code of the URI, if it fits the height, along with the secret & the code input
+func (m TOTPModel) renderEnrollment(height int) string {
+	info := totpInfoStyle.Width(usageWidth() - 2).
+		Render("Scan the QR code with your authenticator app, or add the secret by hand, then enter the code it shows")
+	secret := totpInfoStyle.Render("Secret: ") + totpSecretStyle.Render(m.enrollment.Secret)
+	input := totpInputStyle.Render(m.input.View())
+	cancel := zone.Mark(totpCancel, sessionRevokeStyle.Render("Cancel"))
+	if m.spin {
+		cancel = m.spinner.View()
+	}
+	rest := lipgloss.JoinVertical(lipgloss.Left, secret, "", input, m.renderInvalid(), "", cancel)
+	qr, err := qrcode.New(m.enrollment.URI, qrcode.Low)
+	if err != nil {
+		return lipgloss.JoinVertical(lipgloss.Left, info, "", rest)
+	}
+	// the light modules are the blocks, so the code scans the same in the dark & light themes
+	code := totpQRStyle.Render(strings.TrimSuffix(qr.ToSmallString(false), "\n"))
+	if lipgloss.Height(info)+lipgloss.Height(code)+lipgloss.Height(rest)+2 > height {
+		uri := totpInfoStyle.Width(usageWidth() - 2).Render(ansi.Wordwrap(m.enrollment.URI, usageWidth()-4, "&"))
+		return lipgloss.JoinVertical(lipgloss.Left, info, "", uri, "", rest)
+	}
+	code = lipgloss.PlaceHorizontal(usageWidth(), lipgloss.Center, code)
+	return lipgloss.JoinVertical(lipgloss.Left, info, "", code, "", rest)
+}
+
+func (m TOTPModel) renderRecoveryCodes() string {
+	info := totpInfoStyle.Width(usageWidth() - 2).
+		Render("Two-factor authentication is on. Keep these recovery codes somewhere safe, each logs you in once " +
+			"without the app, they won't be shown again")
+	var sb strings.Builder
+	for i := 0; i < len(m.recovery); i += 2 {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(m.recovery[i])
+		if i+1 < len(m.recovery) {
+			sb.WriteString("    " + m.recovery[i+1])
+		}
+	}
+	codes := totpSecretStyle.Render(sb.String())
+	codes = lipgloss.PlaceHorizontal(usageWidth(), lipgloss.Center, codes)
+	done := zone.Mark(totpAction, totpActionStyle.Render("Done"))
+	return lipgloss.JoinVertical(lipgloss.Left, info, "", codes, "", done)
+}
+
+func (m TOTPModel) renderInvalid() string {
+	if m.invalid == "" {
+		return ""
+	}
+	return totpErrStyle.Render(m.invalid)
+}
+
+func (m TOTPModel) fetchStatus() tea.Cmd {
+	return func() tea.Msg {
+		enabled, err := m.client.GetTOTPStatus()
+		if err != nil {
+			if errors.Is(err, client.ErrUnauthorized) {
+				return requireAuthMsg{}
+			}
+			return &errMsg{err: fmt.Sprintf("Unable to fetch the two-factor authentication status, %v", err)}
+		}
+		return totpStatusFetched{enabled: enabled}
+	}
+}
+
+func (m TOTPModel) enroll() tea.Cmd {
+	return func() tea.Msg {
+		enrollment, err := m.client.EnrollTOTP()
+		switch {
+		case err == nil:
+			return totpEnrolled{enrollment: enrollment}
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		case errors.Is(err, client.ErrServerValidation): // enabled on some other device meanwhile
+			return totpStatusFetched{enabled: true}
+		default:
+			return &errMsg{err: fmt.Sprintf("Unable to enable the two-factor authentication, %v", err)}
+		}
+	}
+}
+
+func (m TOTPModel) confirm(code string) tea.Cmd {
+	return func() tea.Msg {
+		codes, err := m.client.ConfirmTOTP(code)
+		switch {
+		case err == nil:
+			return totpConfirmed{recoveryCodes: codes}
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		case errors.Is(err, client.ErrServerValidation):
+			return totpInvalid{err: "the code does not match, check the clock of the device it's on"}
+		default:
+			return &errMsg{err: fmt.Sprintf("Unable to enable the two-factor authentication, %v", err)}
+		}
+	}
+}
+
+func (m TOTPModel) disable(password string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.DisableTOTP(password)
+		switch {
+		case err == nil:
+			return totpDisabled{}
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		case errors.Is(err, client.ErrServerValidation):
+			return totpInvalid{err: "the password does not match"}
+		case errors.Is(err, client.ErrTooManyAttempts):
+			return totpInvalid{err: err.Error()}
+		default:
+			return &errMsg{err: fmt.Sprintf("Unable to disable the two-factor authentication, %v", err)}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package tui
+
+import (
+	"errors"
+	"github.com/M0hammadUsman/letschat/internal/client"
+	"github.com/M0hammadUsman/letschat/internal/domain"
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"golang.org/x/exp/maps"
+	"strings"
+)
+
+// TwoFactorModel is the second login step, after the LoginModel, for the users with the TOTP enabled
+type TwoFactorModel struct {
+	code        textinput.Model
+	spinner     spinner.Model
+	spin        bool
+	tabIdx      int // 0 -> code, 1 -> Back btn
+	dangerState bool
+	errMsg      errMsg
+	ev          *domain.ErrValidation
+	client      *client.Client
+}
+
+// twoFactorExpired the login is to be started over, the password has to be entered again
+type twoFactorExpired struct{}
+
+func InitialTwoFactorModel() TwoFactorModel {
+	s := spinner.New()
+	s.Style = lipgloss.NewStyle().Foreground(primaryContrastColor)
+	s.Spinner = spinner.Meter
+
+	i := textinput.New()
+	i.CharLimit = 11 // the recovery codes are formatted as xxxxx-xxxxx
+	i.Prompt = ""
+	i.Placeholder = "$$$$$$"
+	i.PlaceholderStyle = lipgloss.NewStyle().Foreground(darkGreyColor)
+	i.TextStyle = lipgloss.NewStyle().Foreground(primaryColor)
+	i.Focus()
+	i.Cursor = cursor.New()
+	i.Cursor.SetMode(cursor.CursorHide)
+
+	return TwoFactorModel{
+		code:    i,
+		spinner: s,
+		ev:      domain.NewErrValidation(),
+		client:  client.Get(),
+	}
+}
+
+func (m TwoFactorModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m TwoFactorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+
+	case tea.WindowSizeMsg:
+		terminalWidth = msg.Width
+		terminalHeight = msg.Height
+
+	case tea.KeyMsg:
+		m.dangerState = false // reset the dangerState once there is a key press
+		m.errMsg.err = ""
+		m.code.Placeholder = "$$$$$$"
+		m.code.PlaceholderStyle = lipgloss.NewStyle().Foreground(darkGreyColor)
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "enter":
+			if m.tabIdx == 1 {
+				loginModel := InitialLoginModel()
+				return loginModel, loginModel.Init()
+			}
+			if m.spin {
+				return m, nil
+			}
+			if err := m.validateCode(); err != nil {
+				return m, nil
+			}
+			m.spin = true
+			return m, tea.Batch(m.spinner.Tick, m.verify())
+		case "tab", "shift+tab":
+			m.tabIdx = 1 - m.tabIdx
+			if m.tabIdx == 0 {
+				m.code.Focus()
+			} else {
+				m.code.Blur()
+			}
+		}
+
+	case spinner.TickMsg:
+		if m.spin {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		}
+
+	case twoFactorExpired:
+		loginModel := InitialLoginModel()
+		loginModel.dangerState = true
+		loginModel.errMsg = errMsg{err: "took too long, log in again"}
+		return loginModel, loginModel.Init()
+
+	case errMsg:
+		m.spin = false
+		m.dangerState = true
+		m.errMsg = msg
+		m.code.Reset()
+		return m, nil
+
+	case doneMsg:
+		m.spin = false
+		mainModel := InitialTabContainerModel()
+		return mainModel, tea.Batch(mainModel.Init(), func() tea.Msg {
+			return tea.WindowSizeMsg{Width: terminalWidth, Height: terminalHeight}
+		})
+	}
+
+	var cmd tea.Cmd
+	m.code, cmd = m.code.Update(msg)
+	return m, cmd
+}
+
+func (m TwoFactorModel) View() string {
+	var sb strings.Builder
+	sb.WriteString(letschatLogo)
+	c := formContainer
+	codeStyle := otpInputStyle
+
+	if m.dangerState && m.errMsg.err != "" {
+		c = c.BorderForeground(dangerColor)
+		codeStyle = codeStyle.BorderForeground(dangerColor)
+		e := ansi.Wordwrap(m.errMsg.String(), 60, " ")
+		sb.WriteString(infoTxtStyle.Foreground(dangerColor).Render(e))
+	} else {
+		sb.WriteString(infoTxtStyle.Render("Enter the code from your authenticator app, or one of the recovery codes"))
+	}
+
+	if m.tabIdx == 0 {
+		codeStyle = codeStyle.BorderForeground(primaryColor)
+	}
+	sb.WriteString(codeStyle.Render(m.code.View()))
+	backBtn := buttonStyle.Render("Back")
+	if m.tabIdx == 1 {
+		backBtn = activeButtonStyleWithColor(primaryContrastColor, primaryColor).Render("Back")
+	}
+	if m.spin {
+		backBtn = buttonStyle.Render(m.spinner.View())
+	}
+	sb.WriteString(btnInputStyle.Align(lipgloss.Center).Render(backBtn))
+	return formContainerCentered(c.Render(sb.String()))
+}
+
+// Helpers & Stuff -----------------------------------------------------------------------------------------------------
+
+func (m *TwoFactorModel) validateCode() error {
+	domain.ValidateSecondFactorCode(m.code.Value(), m.ev)
+	if err, ok := m.ev.Errors["code"]; ok {
+		m.code.Reset()
+		m.code.Placeholder = err
+		m.code.PlaceholderStyle = lipgloss.NewStyle().Foreground(dangerColor)
+		m.dangerState = true
+		maps.Clear(m.ev.Errors)
+		return ErrValidation
+	}
+	return nil
+}
+
+func (m TwoFactorModel) verify() tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.CompleteTwoFactorLogin(m.code.Value())
+		switch {
+		case err == nil:
+			return doneMsg{}
+		case errors.Is(err, client.ErrUnauthorized):
+			return twoFactorExpired{}
+		case errors.Is(err, client.ErrServerValidation):
+			return errMsg{err: "the code does not match, or was already used"}
+		default:
+			return errMsg{err: err.Error()}
+		}
+	}
+}
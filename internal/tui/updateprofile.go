@@ -13,6 +13,7 @@ import (
 	zone "github.com/lrstanley/bubblezone"
 	"golang.org/x/exp/maps"
 	"net/http"
+	"slices"
 	"strings"
 )
 
@@ -34,8 +35,21 @@ type UpdateProfileModel struct {
 	spin, includePass, showSuccess, populatePlaceholders, focus bool
 	// to detect changes to currentUser name & email
 	prevName, prevEmail string
+	// exporting the account data, the path of the file it's exported to is shown till the next key press
+	exporting  bool
+	exportedTo string
+	// the email the account is changing to, while the change waits for the OTP mailed to it
+	pendingEmail string
+	otpInput     textinput.Model
 }
 
+type accountExported struct{ path string }
+
+type emailChangePending struct{ email string }
+
+// emailChangeSettled is for the pending email change either confirmed or cancelled
+type emailChangeSettled struct{ changed bool }
+
 func NewUpdateProfileModel(c *client.Client) UpdateProfileModel {
 	up := UpdateProfileModel{
 		inputTitles:          []string{"Name", "Email", "Previous Password", "New Password", "Confirm Password"},
@@ -50,15 +64,7 @@ func NewUpdateProfileModel(c *client.Client) UpdateProfileModel {
 	}
 
 	for i := range up.txtInputs {
-		crsr := cursor.New()
-		crsr.Style = lipgloss.NewStyle().Foreground(primaryColor)
-		crsr.TextStyle = crsr.Style
-
-		t := textinput.New()
-		t.Prompt = ""
-		t.PlaceholderStyle = lipgloss.NewStyle().Foreground(primarySubtleDarkColor)
-		t.TextStyle = lipgloss.NewStyle().Foreground(primaryColor)
-		t.Cursor = crsr
+		t := newUpdateProfileTxtInput()
 		t.CharLimit = 64
 
 		switch i {
@@ -69,6 +75,8 @@ func NewUpdateProfileModel(c *client.Client) UpdateProfileModel {
 
 		up.txtInputs[i] = t
 	}
+	up.otpInput = newUpdateProfileTxtInput()
+	up.otpInput.CharLimit = 6
 	return up
 }
 
@@ -93,7 +101,16 @@ func (m UpdateProfileModel) Update(msg tea.Msg) (UpdateProfileModel, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.setTxtInputWidthAccordingly()
 
+	case tea.KeyMsg, tea.MouseMsg:
+		if m.pendingEmail != "" {
+			return m.handleEmailVerificationUpdate(msg)
+		}
+	}
+
+	switch msg := msg.(type) {
+
 	case tea.KeyMsg:
+		m.exportedTo = ""
 		switch msg.String() {
 
 		case "tab":
@@ -102,13 +119,13 @@ func (m UpdateProfileModel) Update(msg tea.Msg) (UpdateProfileModel, tea.Cmd) {
 				if !m.includePass && m.tabIdx == 1 {
 					m.tabIdx = 4
 				}
-				m.tabIdx = (m.tabIdx + 1) % (len(m.inputTitles) + 3)
+				m.tabIdx = (m.tabIdx + 1) % (len(m.inputTitles) + 5)
 				m.focusTxtInputsAccordingly()
 			}
 
 		case "shift+tab":
 			if m.focus {
-				l := len(m.inputTitles) + 3
+				l := len(m.inputTitles) + 5
 				m.tabIdx = (m.tabIdx - 1 + l) % l
 				if !m.includePass && m.tabIdx == 4 {
 					m.tabIdx = 1
@@ -148,22 +165,40 @@ func (m UpdateProfileModel) Update(msg tea.Msg) (UpdateProfileModel, tea.Cmd) {
 				}
 			case 7:
 				return m, m.logout()
+			case 8:
+				if !m.exporting {
+					m.exporting = true
+					return m, tea.Batch(m.spinner.Tick, m.exportAccount())
+				}
+			case 9:
+				// the previous password field is reused to re-confirm the password
+				if m.txtInputs[2].Value() == "" {
+					m.includePass = true
+					m.tabIdx = 2
+					m.txtInputs[2].Placeholder = "your password, then Delete Account! again"
+					m.focusTxtInputsAccordingly()
+					return m, nil
+				}
+				if !m.spin {
+					m.spin = true
+					return m, tea.Batch(m.spinner.Tick, m.deleteAccount())
+				}
 			}
 
 		case "up", "left":
-			if m.tabIdx == 6 {
-				m.tabIdx = 5
+			if m.tabIdx == 6 || m.tabIdx == 9 {
+				m.tabIdx--
 			}
 
 		case "down", "right":
-			if m.tabIdx == 5 {
-				m.tabIdx = 6
+			if m.tabIdx == 5 || m.tabIdx == 8 {
+				m.tabIdx++
 			}
 		}
 
 	case tea.MouseMsg:
 		if msg.Button == tea.MouseButtonLeft {
-			for i := range 8 {
+			for i := range 10 {
 				if zone.Get(fmt.Sprint("formItem", i)).InBounds(msg) {
 					m.tabIdx = i
 					m.focusTxtInputsAccordingly()
@@ -191,6 +226,34 @@ func (m UpdateProfileModel) Update(msg tea.Msg) (UpdateProfileModel, tea.Cmd) {
 	case hideSuccessMsg:
 		m.showSuccess = false
 
+	case emailChangePending:
+		m.spin = false
+		m.spinner = newSpinner()
+		m.resetAllfields()
+		m.includePass = false
+		m.pendingEmail = msg.email
+		m.otpInput.Reset()
+		m.tabIdx = 1 // the OTP field
+		return m, m.focusTxtInputsAccordingly()
+
+	case emailChangeSettled:
+		m.spin = false
+		m.spinner = newSpinner()
+		m.pendingEmail = ""
+		m.otpInput.Reset()
+		m.tabIdx = -1
+		m.focusTxtInputsAccordingly()
+		m.populateDefaultPlaceholders()
+		if msg.changed {
+			m.showSuccess = true
+			return m, countdownShowSuccessCmd()
+		}
+
+	case accountExported:
+		m.exporting = false
+		m.spinner = newSpinner()
+		m.exportedTo = msg.path
+
 	case *domain.ErrValidation:
 		m.spin = false
 		m.spinner = newSpinner()
@@ -200,6 +263,7 @@ func (m UpdateProfileModel) Update(msg tea.Msg) (UpdateProfileModel, tea.Cmd) {
 
 	case errMsg:
 		m.spin = false
+		m.exporting = false
 		m.spinner = newSpinner()
 		return m, func() tea.Msg { return &msg } // TabContainerModel will show this
 
@@ -238,7 +302,152 @@ func (m *UpdateProfileModel) populateDefaultPlaceholders() {
 	}
 }
 
+func newUpdateProfileTxtInput() textinput.Model {
+	crsr := cursor.New()
+	crsr.Style = lipgloss.NewStyle().Foreground(primaryColor)
+	crsr.TextStyle = crsr.Style
+
+	t := textinput.New()
+	t.Prompt = ""
+	t.PlaceholderStyle = lipgloss.NewStyle().Foreground(primarySubtleDarkColor)
+	t.TextStyle = lipgloss.NewStyle().Foreground(primaryColor)
+	t.Cursor = crsr
+	return t
+}
+
+// handleEmailVerificationUpdate takes the keys & clicks over while the email change is pending, the OTP field is
+// formItem1, cancel formItem5 & verify formItem6, same as the fields & buttons they stand in for
+func (m UpdateProfileModel) handleEmailVerificationUpdate(msg tea.Msg) (UpdateProfileModel, tea.Cmd) {
+	order := []int{1, 5, 6}
+	switch msg := msg.(type) {
+
+	case tea.KeyMsg:
+		if m.tabIdx == 1 {
+			delete(m.ev.Errors, "otp")
+		}
+		switch msg.String() {
+
+		case "tab", "shift+tab":
+			if !m.focus {
+				break
+			}
+			i := slices.Index(order, m.tabIdx)
+			if msg.String() == "tab" {
+				i = (i + 1) % len(order)
+			} else {
+				i = (max(i, 0) - 1 + len(order)) % len(order)
+			}
+			m.tabIdx = order[i]
+			return m, m.focusTxtInputsAccordingly()
+
+		case "esc":
+			m.tabIdx = -1
+			return m, m.focusTxtInputsAccordingly()
+
+		case "enter":
+			switch m.tabIdx {
+			case 1:
+				m.tabIdx = 6
+				return m, m.focusTxtInputsAccordingly()
+			case 5:
+				if !m.spin {
+					m.spin = true
+					return m, tea.Batch(m.spinner.Tick, m.cancelEmailChange())
+				}
+			case 6:
+				if m.spin {
+					break
+				}
+				ev := domain.NewErrValidation()
+				domain.ValidateOTP(m.otpInput.Value(), ev)
+				if ev.HasErrors() {
+					m.ev.AddError("otp", ev.Errors["otp"])
+					m.otpInput.Reset()
+					break
+				}
+				m.spin = true
+				return m, tea.Batch(m.spinner.Tick, m.confirmEmailChange())
+			}
+
+		case "up", "left":
+			if m.tabIdx == 6 {
+				m.tabIdx--
+			}
+
+		case "down", "right":
+			if m.tabIdx == 5 {
+				m.tabIdx++
+			}
+		}
+
+	case tea.MouseMsg:
+		if msg.Button == tea.MouseButtonLeft {
+			for _, i := range order {
+				if zone.Get(fmt.Sprint("formItem", i)).InBounds(msg) {
+					m.tabIdx = i
+					m.focusTxtInputsAccordingly()
+				}
+			}
+		}
+	}
+	var cmd tea.Cmd
+	m.otpInput, cmd = m.otpInput.Update(msg)
+	return m, cmd
+}
+
+// renderEmailVerification renders the OTP field & the buttons to verify the new email or cancel the change with
+func (m UpdateProfileModel) renderEmailVerification() string {
+	header := updateProfileInputHeaderStyle
+	field := updateProfileInputFieldStyle.Width(updateProfileWidth() - 8)
+	if m.tabIdx == 1 {
+		header = updateProfileInputHeaderStyle.Italic(true).Foreground(primaryColor)
+		field = field.BorderForeground(primaryColor)
+	}
+	m.otpInput.Placeholder = "the OTP mailed to the new email"
+	if err, ok := m.ev.Errors["otp"]; ok {
+		header = updateProfileInputHeaderDangerStyle
+		field = updateProfileInputFieldDangerStyle.Width(updateProfileWidth() - 8)
+		m.otpInput.Placeholder = err
+		m.otpInput.PlaceholderStyle = lipgloss.NewStyle().Foreground(dangerColor)
+	}
+	var sb strings.Builder
+	sb.WriteString(header.Render("Email Verification"))
+	sb.WriteString("\n")
+	sb.WriteString(zone.Mark("formItem1", field.Render(m.otpInput.View())))
+	sb.WriteString("\n")
+	info := lipgloss.NewStyle().Foreground(primarySubtleDarkColor).Italic(true).Width(updateProfileWidth() - 8).
+		Render(fmt.Sprintf("The change to %v takes effect once verified, the OTP was mailed to it", m.pendingEmail))
+	sb.WriteString(info)
+	sb.WriteString("\n\n")
+
+	s1 := "CANCEL CHANGE"
+	s2 := "VERIFY EMAIL"
+	btn1 := updateProfileFromBlurBtnStyle.Render(s1)
+	btn2Style := updateProfileFromBlurBtnStyle.Padding(0, 3)
+	switch m.tabIdx {
+	case 5:
+		btn1 = updateProfileFormDangerBtnStyle.Render(s1)
+	case 6:
+		btn2Style = updateProfileFormActiveBtnStyle.Padding(0, 3)
+	}
+	if m.spin {
+		s2 = m.spinner.View()
+		btn2Style = updateProfileFromBlurBtnStyle.Padding(0, 8).Background(primaryContrastColor)
+	}
+	btn1 = zone.Mark("formItem5", btn1)
+	btn2 := zone.Mark("formItem6", btn2Style.Render(s2))
+	btns := lipgloss.JoinHorizontal(lipgloss.Bottom, btn1, "  ", btn2)
+	if updateProfileWidth() < 50 {
+		btns = lipgloss.JoinVertical(lipgloss.Center, btn1, btn2)
+	}
+	sb.WriteString(lipgloss.PlaceHorizontal(updateProfileWidth()-6, lipgloss.Center, btns))
+	return sb.String()
+}
+
 func (m UpdateProfileModel) renderForm() string {
+	if m.pendingEmail != "" {
+		return m.renderEmailVerification()
+	}
 	m.manageInputStylesAccordingly()
 	var sb strings.Builder
 	for i, t := range m.inputTitles {
@@ -272,9 +481,44 @@ func (m UpdateProfileModel) renderForm() string {
 	logoutPrompt = logoutPromptStyle.Render(logoutPrompt)
 	logoutPrompt = lipgloss.PlaceHorizontal(updateProfileWidth()-6, lipgloss.Center, logoutPrompt)
 	sb.WriteString(logoutPrompt)
+	sb.WriteString(m.renderAccountActions())
 	return sb.String()
 }
 
+// renderAccountActions renders the export & delete account actions, or the path the data was just exported to
+func (m UpdateProfileModel) renderAccountActions() string {
+	if m.exportedTo != "" {
+		exported := updateProfileFormSuccessStyle.UnsetString().MarginTop(1).Width(updateProfileWidth() - 10).
+			Render("Data exported to " + m.exportedTo)
+		return lipgloss.PlaceHorizontal(updateProfileWidth()-6, lipgloss.Center, exported)
+	}
+	exportStyle := lipgloss.NewStyle().Foreground(primarySubtleDarkColor)
+	if m.tabIdx == 8 {
+		exportStyle = lipgloss.NewStyle().
+			Foreground(primaryColor).
+			Italic(true).
+			Underline(true)
+	}
+	deleteStyle := lipgloss.NewStyle().Foreground(dangerDarkColor)
+	if m.tabIdx == 9 {
+		deleteStyle = lipgloss.NewStyle().
+			Foreground(dangerColor).
+			Italic(true).
+			Underline(true)
+	}
+	exportTxt := "Export Data"
+	if m.exporting {
+		exportTxt = m.spinner.View()
+	}
+	actions := lipgloss.JoinHorizontal(lipgloss.Top,
+		zone.Mark("formItem8", exportStyle.Render(exportTxt)),
+		"  ",
+		zone.Mark("formItem9", deleteStyle.Render("Delete Account!")),
+	)
+	actions = lipgloss.NewStyle().MarginTop(1).Render(actions)
+	return lipgloss.PlaceHorizontal(updateProfileWidth()-6, lipgloss.Center, actions)
+}
+
 func (m UpdateProfileModel) renderFormBtns() string {
 	s1 := "INCLUDE PASSWORD"
 	s2 := "EXCLUDE PASSWORD"
@@ -340,12 +584,16 @@ func (m *UpdateProfileModel) handleTxtInputUpdate(msg tea.Msg) tea.Cmd {
 
 func (m *UpdateProfileModel) focusTxtInputsAccordingly() tea.Cmd {
 	var cmd tea.Cmd
+	m.otpInput.Blur()
 	for i := range m.txtInputs {
 		m.txtInputs[i].Blur()
-		if m.tabIdx == i && m.focus {
+		if m.tabIdx == i && m.focus && m.pendingEmail == "" {
 			cmd = m.txtInputs[i].Focus()
 		}
 	}
+	if m.tabIdx == 1 && m.focus && m.pendingEmail != "" {
+		cmd = m.otpInput.Focus()
+	}
 	return cmd
 }
 
@@ -353,6 +601,7 @@ func (m *UpdateProfileModel) setTxtInputWidthAccordingly() {
 	for i := range m.txtInputs {
 		m.txtInputs[i].Width = updateProfileWidth() - 11
 	}
+	m.otpInput.Width = updateProfileWidth() - 11
 }
 
 // validateUserRegisterModel validates the input form then adds the errors to ev
@@ -464,6 +713,10 @@ func (m *UpdateProfileModel) populateServerErr(msg *domain.ErrValidation) {
 		m.ev.AddError(m.errFieldTitles[2], err)
 		m.populateErr(2, err)
 	}
+	if err, ok := msg.Errors["otp"]; ok && m.pendingEmail != "" {
+		m.ev.AddError("otp", err)
+		m.otpInput.Reset()
+	}
 }
 
 func (m *UpdateProfileModel) updateUser() tea.Cmd {
@@ -489,6 +742,9 @@ func (m *UpdateProfileModel) updateUser() tea.Cmd {
 		if code == http.StatusUnauthorized {
 			return requireAuthMsg{}
 		}
+		if code == http.StatusAccepted && err == nil {
+			return emailChangePending{email: u.Email}
+		}
 		if code == http.StatusInternalServerError {
 			return errMsg{
 				err:  "the server is overwhelmed",
@@ -516,3 +772,67 @@ func (m UpdateProfileModel) logout() tea.Cmd {
 		return nil
 	}
 }
+
+func (m UpdateProfileModel) exportAccount() tea.Cmd {
+	return func() tea.Msg {
+		path, err := m.client.ExportAccount()
+		if err != nil {
+			if errors.Is(err, client.ErrUnauthorized) {
+				return requireAuthMsg{}
+			}
+			return errMsg{err: fmt.Sprintf("Unable to export the data, %v", err)}
+		}
+		return accountExported{path: path}
+	}
+}
+
+// deleteAccount deletes the account for good, the client logs out on success & the login screen takes over
+func (m UpdateProfileModel) deleteAccount() tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.DeleteAccount(m.txtInputs[2].Value())
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		case errors.Is(err, client.ErrServerValidation):
+			ev := domain.NewErrValidation()
+			ev.AddError("currentPassword", "does not match")
+			return ev
+		default:
+			return errMsg{err: fmt.Sprintf("Unable to delete the account, %v", err)}
+		}
+	}
+}
+
+func (m UpdateProfileModel) confirmEmailChange() tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.ConfirmEmailChange(m.otpInput.Value())
+		switch {
+		case err == nil:
+			return emailChangeSettled{changed: true}
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		case errors.Is(err, client.ErrServerValidation):
+			ev := domain.NewErrValidation()
+			ev.AddError("otp", strings.TrimPrefix(err.Error(), client.ErrServerValidation.Error()+", "))
+			return ev
+		default:
+			return errMsg{err: fmt.Sprintf("Unable to verify the email, %v", err)}
+		}
+	}
+}
+
+func (m UpdateProfileModel) cancelEmailChange() tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.CancelEmailChange()
+		switch {
+		case err == nil:
+			return emailChangeSettled{}
+		case errors.Is(err, client.ErrUnauthorized):
+			return requireAuthMsg{}
+		default:
+			return errMsg{err: fmt.Sprintf("Unable to cancel the email change, %v", err)}
+		}
+	}
+}
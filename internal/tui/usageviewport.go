@@ -39,7 +39,8 @@ func (m UsageViewportModel) Update(msg tea.Msg) (UsageViewportModel, tea.Cmd) {
 	}
 	if _, ok := msg.(tea.WindowSizeMsg); ok {
 		m.vp.Width = usageWidth()
-		m.vp.Height = conversationHeight() - 1
+		// the sessions, the two-factor authentication & the SSH keys panels sit above
+		m.vp.Height = max(conversationHeight()-1-sessionsHeight()-totpHeight()-sshKeysHeight()-digestHeight(), 1)
 		m.vp.SetContent(m.renderViewport())
 	}
 	var cmd tea.Cmd
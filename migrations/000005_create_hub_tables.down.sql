@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS hub_payload;
+DROP TABLE IF EXISTS hub_subscription;
+DROP TABLE IF EXISTS hub_node;
\ No newline at end of file
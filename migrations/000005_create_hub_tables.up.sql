@@ -0,0 +1,22 @@
+-- every running instance of the API registers itself as a node & keeps the heartbeat_at fresh
+CREATE TABLE IF NOT EXISTS hub_node (
+    id UUID PRIMARY KEY,
+    heartbeat_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- number of websocket connections a user has on a node
+CREATE TABLE IF NOT EXISTS hub_subscription (
+    node_id UUID REFERENCES hub_node ON DELETE CASCADE,
+    user_id UUID REFERENCES users ON DELETE CASCADE,
+    connections INT NOT NULL DEFAULT 1,
+    PRIMARY KEY (node_id, user_id)
+);
+
+CREATE INDEX idx_hub_subscription_user_id ON hub_subscription(user_id);
+
+-- payloads too large for NOTIFY are parked here & only their id is notified
+CREATE TABLE IF NOT EXISTS hub_payload (
+    id BIGSERIAL PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS message_history;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- unlike the message table, which only holds the msgs until they're delivered, this keeps them for good,
+-- only filled when the server runs with the history enabled
+CREATE TABLE IF NOT EXISTS message_history (
+    id UUID PRIMARY KEY,
+    sender_id UUID REFERENCES users ON DELETE CASCADE,
+    receiver_id UUID REFERENCES users ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    read_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_message_history_sender_receiver_sent_at ON message_history(sender_id, receiver_id, sent_at DESC, id DESC);
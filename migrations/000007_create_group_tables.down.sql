@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_message_history_conversation_id_sent_at;
+ALTER TABLE message_history DROP COLUMN IF EXISTS conversation_id;
+DELETE FROM message WHERE conversation_id IS NOT NULL;
+ALTER TABLE message DROP CONSTRAINT message_pkey;
+ALTER TABLE message ADD PRIMARY KEY (id);
+ALTER TABLE message DROP COLUMN IF EXISTS conversation_id;
+DROP TABLE IF EXISTS group_member;
+DROP TABLE IF EXISTS group_chat;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS group_chat (
+    id UUID DEFAULT GEN_RANDOM_UUID() PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_by UUID REFERENCES users ON DELETE SET NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS group_member (
+    group_id UUID REFERENCES group_chat ON DELETE CASCADE,
+    user_id UUID REFERENCES users ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member')),
+    joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_group_member_user_id ON group_member(user_id);
+
+-- group msgs are fanned out as a copy per member, all sharing the msg id,
+-- so a msg is now identified by its id along with the pair of users it's exchanged between
+ALTER TABLE message ADD COLUMN conversation_id UUID REFERENCES group_chat ON DELETE CASCADE;
+ALTER TABLE message DROP CONSTRAINT message_pkey;
+ALTER TABLE message ADD PRIMARY KEY (id, sender_id, receiver_id);
+
+-- a single history row is kept per group msg, receiver_id is the first member it got fanned out to
+ALTER TABLE message_history ADD COLUMN conversation_id UUID REFERENCES group_chat ON DELETE CASCADE;
+CREATE INDEX idx_message_history_conversation_id_sent_at ON message_history(conversation_id, sent_at DESC, id DESC);
@@ -0,0 +1 @@
+ALTER TABLE message_history DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,2 @@
+-- set once the msg is edited by its sender, the body always holds the latest revision
+ALTER TABLE message_history ADD COLUMN edited_at TIMESTAMP WITH TIME ZONE;
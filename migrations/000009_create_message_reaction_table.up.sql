@@ -0,0 +1,13 @@
+-- like the message table, holds the reactions until they're delivered, a reaction is added or removed as per operation
+CREATE TABLE IF NOT EXISTS message_reaction (
+    msg_id UUID NOT NULL,
+    sender_id UUID REFERENCES users ON DELETE CASCADE,
+    receiver_id UUID REFERENCES users ON DELETE CASCADE,
+    conversation_id UUID REFERENCES group_chat ON DELETE CASCADE,
+    emoji TEXT NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    operation INT NOT NULL,
+    PRIMARY KEY (msg_id, sender_id, receiver_id, emoji)
+);
+
+CREATE INDEX idx_message_reaction_receiver_id ON message_reaction(receiver_id, sent_at);
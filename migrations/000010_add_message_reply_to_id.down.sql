@@ -0,0 +1,2 @@
+ALTER TABLE message_history DROP COLUMN IF EXISTS reply_to_id;
+ALTER TABLE message DROP COLUMN IF EXISTS reply_to_id;
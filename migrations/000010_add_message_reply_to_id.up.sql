@@ -0,0 +1,3 @@
+-- the msg being replied to, not a foreign key as the parent is gone from the message table once delivered
+ALTER TABLE message ADD COLUMN reply_to_id UUID;
+ALTER TABLE message_history ADD COLUMN reply_to_id UUID;
@@ -0,0 +1,4 @@
+ALTER TABLE message_history DROP COLUMN IF EXISTS attachment_id;
+ALTER TABLE message DROP COLUMN IF EXISTS attachment_id;
+DROP TABLE IF EXISTS attachment_grant;
+DROP TABLE IF EXISTS attachment;
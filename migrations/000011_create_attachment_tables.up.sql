@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS attachment (
+    id UUID PRIMARY KEY,
+    uploader_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 TEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- the users an attachment has been sent to, only the uploader & these are allowed to download it
+CREATE TABLE IF NOT EXISTS attachment_grant (
+    attachment_id UUID NOT NULL REFERENCES attachment ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    PRIMARY KEY (attachment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachment_grant_user_id ON attachment_grant(user_id);
+
+-- like reply_to_id, not a foreign key, the msg only references the attachment
+ALTER TABLE message ADD COLUMN attachment_id UUID;
+ALTER TABLE message_history ADD COLUMN attachment_id UUID;
@@ -0,0 +1,3 @@
+ALTER TABLE message_history DROP COLUMN IF EXISTS encrypted;
+ALTER TABLE message DROP COLUMN IF EXISTS encrypted;
+DROP TABLE IF EXISTS user_key;
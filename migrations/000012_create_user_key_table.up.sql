@@ -0,0 +1,10 @@
+-- the public identity key of the user, for the end-to-end encryption of the 1:1 msgs
+CREATE TABLE IF NOT EXISTS user_key (
+    user_id UUID PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    public_key BYTEA NOT NULL,
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- the body of the 1:1 msgs is ciphertext, the server can't read it
+ALTER TABLE message ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE message_history ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE;
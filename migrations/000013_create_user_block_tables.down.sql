@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_block_blocked_user_id;
+DROP TABLE IF EXISTS user_mute;
+DROP TABLE IF EXISTS user_block;
@@ -0,0 +1,17 @@
+-- users blocked by the user, their msgs are rejected & their presence is not sent to the user
+CREATE TABLE IF NOT EXISTS user_block (
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    blocked_user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, blocked_user_id)
+);
+
+-- users muted by the user, their msgs are still delivered but the TUI doesn't flag them as unread
+CREATE TABLE IF NOT EXISTS user_mute (
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    muted_user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, muted_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_block_blocked_user_id ON user_block(blocked_user_id);
@@ -0,0 +1 @@
+ALTER TABLE conversation DROP COLUMN IF EXISTS accepted;
@@ -0,0 +1,3 @@
+-- a conversation started by a stranger is a message request, till the receiver accepts it
+-- the existing conversations are already accepted
+ALTER TABLE conversation ADD COLUMN accepted BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,6 @@
+-- failed password & OTP attempts of the account, once they reach the max the account is locked till locked_until
+CREATE TABLE IF NOT EXISTS failed_attempt (
+    user_id UUID PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    attempts INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP(0) WITH TIME ZONE
+);
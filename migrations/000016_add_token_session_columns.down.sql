@@ -0,0 +1,6 @@
+ALTER TABLE token
+    DROP COLUMN IF EXISTS id,
+    DROP COLUMN IF EXISTS device,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS last_used_at;
\ No newline at end of file
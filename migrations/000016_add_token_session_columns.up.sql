@@ -0,0 +1,8 @@
+-- every auth token is a session of its own, so a user can be logged in on many devices at once
+-- the metadata is only filled in for the auth tokens, the OTPs leave them as the defaults
+ALTER TABLE token
+    ADD COLUMN id UUID NOT NULL UNIQUE DEFAULT GEN_RANDOM_UUID(),
+    ADD COLUMN device TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+    ADD COLUMN created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ADD COLUMN last_used_at TIMESTAMP(0) WITH TIME ZONE;
\ No newline at end of file
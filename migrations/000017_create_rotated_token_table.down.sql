@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rotated_token;
\ No newline at end of file
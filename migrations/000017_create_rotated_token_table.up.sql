@@ -0,0 +1,6 @@
+-- the refresh tokens rotated out of a session, presenting one again means it was stolen & the session is revoked
+CREATE TABLE IF NOT EXISTS rotated_token (
+    hash BYTEA PRIMARY KEY,
+    token_id UUID NOT NULL REFERENCES token (id) ON DELETE CASCADE,
+    rotated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- the TOTP (RFC 6238) second factor of the user, pending till the first code is confirmed,
+-- last_used_step is the time step of the last accepted code, so a code can't be replayed
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    secret BYTEA NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- single use codes to log in with when the authenticator is lost
+CREATE TABLE IF NOT EXISTS recovery_code (
+    hash BYTEA PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_code_user_id ON recovery_code(user_id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS user_ssh_key;
\ No newline at end of file
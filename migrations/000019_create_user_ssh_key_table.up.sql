@@ -0,0 +1,12 @@
+-- the SSH public keys the user logs in with, by signing a challenge with the private key,
+-- fingerprint is the SHA256 one of OpenSSH, the same key may be on several accounts but only once per account
+CREATE TABLE IF NOT EXISTS user_ssh_key (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP(0) WITH TIME ZONE,
+    UNIQUE (user_id, fingerprint)
+);
\ No newline at end of file
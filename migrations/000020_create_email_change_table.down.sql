@@ -0,0 +1 @@
+DROP TABLE IF EXISTS email_change;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- the email the user is changing to, applied once the OTP mailed to it is confirmed, one pending change per user
+CREATE TABLE IF NOT EXISTS email_change (
+    user_id UUID PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    email CITEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS mail_queue;
\ No newline at end of file
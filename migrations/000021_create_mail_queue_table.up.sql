@@ -0,0 +1,15 @@
+-- the mails the mailer failed to send, retried with a backoff till they're sent or expire, they hold the OTPs in
+-- clear so they expire about as soon as the OTPs do
+CREATE TABLE IF NOT EXISTS mail_queue (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expiry TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mail_queue_next_attempt_at ON mail_queue(next_attempt_at);
\ No newline at end of file
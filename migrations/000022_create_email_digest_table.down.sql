@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_message_receiver_id_operation_sent_at;
+DROP TABLE IF EXISTS email_digest;
\ No newline at end of file
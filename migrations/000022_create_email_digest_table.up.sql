@@ -0,0 +1,9 @@
+-- the digests of the msgs yet to be delivered, mailed to the offline users, a row only once the user has opted out
+-- or a digest was mailed to them, last_sent_at is the time of the last digest
+CREATE TABLE IF NOT EXISTS email_digest (
+    user_id UUID PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    opted_out BOOLEAN NOT NULL DEFAULT FALSE,
+    last_sent_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_receiver_id_operation_sent_at ON message(receiver_id, operation, sent_at);
\ No newline at end of file
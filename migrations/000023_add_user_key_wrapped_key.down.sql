@@ -0,0 +1,2 @@
+ALTER TABLE user_key DROP COLUMN IF EXISTS salt;
+ALTER TABLE user_key DROP COLUMN IF EXISTS wrapped_key;
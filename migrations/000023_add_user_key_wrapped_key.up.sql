@@ -0,0 +1,4 @@
+-- the private identity key wrapped with a key derived from the password of the user, by the client,
+-- so every device of the user shares the same identity, the server can't unwrap it without the password
+ALTER TABLE user_key ADD COLUMN wrapped_key BYTEA;
+ALTER TABLE user_key ADD COLUMN salt BYTEA;
@@ -0,0 +1,2 @@
+DELETE FROM group_member WHERE accepted = FALSE;
+ALTER TABLE group_member DROP COLUMN IF EXISTS accepted;
@@ -0,0 +1,3 @@
+-- the users added to a group by someone they've no accepted conversation with are invited, like a msg request,
+-- they're not members, i.e. get no msgs, till they accept the invite
+ALTER TABLE group_member ADD COLUMN accepted BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1 @@
+ALTER TABLE failed_attempt DROP COLUMN IF EXISTS first_failed_at;
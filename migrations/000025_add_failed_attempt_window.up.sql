@@ -0,0 +1,2 @@
+-- the failed attempts only count towards the lockout within the window since the first of them
+ALTER TABLE failed_attempt ADD COLUMN first_failed_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW();
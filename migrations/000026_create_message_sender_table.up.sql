@@ -0,0 +1,11 @@
+-- the sender of every msg, kept after the msg is delivered even without the history, so only the sender can edit it
+CREATE TABLE IF NOT EXISTS message_sender (
+    id UUID PRIMARY KEY,
+    sender_id UUID NOT NULL REFERENCES users ON DELETE CASCADE
+);
+
+INSERT INTO message_sender (id, sender_id)
+SELECT id, sender_id FROM message WHERE operation = 0 AND sender_id IS NOT NULL
+UNION
+SELECT id, sender_id FROM message_history WHERE sender_id IS NOT NULL
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,2 @@
+ALTER TABLE user_key ADD COLUMN wrapped_key BYTEA;
+ALTER TABLE user_key ADD COLUMN salt BYTEA;
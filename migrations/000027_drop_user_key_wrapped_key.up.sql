@@ -0,0 +1,4 @@
+-- the wrapped private keys could be unwrapped with the password the server sees on every login, so they're dropped,
+-- the private identity keys never leave the devices of the users
+ALTER TABLE user_key DROP COLUMN IF EXISTS salt;
+ALTER TABLE user_key DROP COLUMN IF EXISTS wrapped_key;